@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/anthemworld/cli/pkg/audio"
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/jobs"
+	"github.com/spf13/cobra"
+)
+
+// defaultAudioDir returns the mirror store next to the database
+// (~/.local/share/anthemworld/audio)
+func defaultAudioDir() string {
+	return filepath.Join(filepath.Dir(db.GetDBPath()), "audio")
+}
+
+var dataAudioCmd = &cobra.Command{
+	Use:   "audio",
+	Short: "Audio file management commands",
+	Long:  `Commands for mirroring and processing anthem audio recordings locally.`,
+}
+
+var dataAudioMirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Download audio recordings into a local store",
+	Long: `Download every audio recording into a content-addressed store
+(<dest>/<sha1[:2]>/<sha1>.<ext>). Wikimedia Commons files are verified against
+the SHA-1 published by Commons; other files are re-downloaded only when the
+server reports a new ETag, Last-Modified date or size. Already-mirrored files
+are skipped and partial downloads resume when the server still serves the
+same version of the file, so the command can be re-run after an interruption.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dest, _ := cmd.Flags().GetString("dest")
+		workers, _ := cmd.Flags().GetInt("workers")
+
+		absDest, err := filepath.Abs(dest)
+		if err != nil {
+			return fmt.Errorf("failed to resolve destination path: %w", err)
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		fmt.Println("=== Audio Mirror ===")
+		fmt.Printf("Destination: %s\n", absDest)
+
+		jobID, err := jobs.CreateJob(database, "audio-mirror", map[string]interface{}{
+			"dest": absDest,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create job: %w", err)
+		}
+		fmt.Printf("Created job: %s\n\n", jobID)
+
+		logger := jobs.NewJobLogger(database, jobID)
+		if err := jobs.StartJob(database, jobID); err != nil {
+			return fmt.Errorf("failed to start job: %w", err)
+		}
+
+		mirror := audio.NewMirror(absDest, workers)
+		mirror.Logf = func(format string, args ...interface{}) {
+			logger.Infof(format, args...)
+			fmt.Printf("    "+format+"\n", args...)
+		}
+
+		stats, err := mirror.Run(context.Background(), database)
+		if err != nil {
+			jobs.FailJob(database, jobID, err.Error())
+			return fmt.Errorf("mirror failed: %w", err)
+		}
+		if err := jobs.CompleteJob(database, jobID); err != nil {
+			return fmt.Errorf("failed to complete job: %w", err)
+		}
+
+		fmt.Println("\n=== Mirror Summary ===")
+		fmt.Printf("✓ Downloaded: %d\n", stats.Downloaded)
+		fmt.Printf("✓ Already mirrored: %d\n", stats.Skipped)
+		if stats.Failed > 0 {
+			fmt.Printf("✗ Failed: %d (re-run to retry)\n", stats.Failed)
+		}
+		return nil
+	},
+}
+
+var dataAudioProbeCmd = &cobra.Command{
+	Use:   "probe",
+	Short: "Read duration, codec and loudness from mirrored audio",
+	Long: `Read the headers of every mirrored recording (Ogg Vorbis/Opus, MP3, WAV,
+FLAC) and store duration, codec, channels, sample rate and bitrate. Integrated
+loudness (EBU R128) is measured so the player can normalise volume between
+anthems. Every codec but Opus is decoded; Opus recordings keep no
+loudness_lufs and play at their own level. Run "data audio mirror" first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		fmt.Println("=== Audio Probe ===")
+
+		stats, err := audio.ProbeRecordings(database, func(format string, args ...interface{}) {
+			fmt.Printf("    "+format+"\n", args...)
+		})
+		if err != nil {
+			return fmt.Errorf("probe failed: %w", err)
+		}
+
+		fmt.Println("\n=== Probe Summary ===")
+		fmt.Printf("✓ Probed: %d (%d with loudness)\n", stats.Probed, stats.WithLUFS)
+		if stats.Undecoded > 0 {
+			fmt.Printf("- Without loudness: %d Opus recordings (no decoder for their codec)\n", stats.Undecoded)
+		}
+		if stats.NotMirrored > 0 {
+			fmt.Printf("- Not mirrored: %d (run: worldanthem data audio mirror)\n", stats.NotMirrored)
+		}
+		if stats.Failed > 0 {
+			fmt.Printf("✗ Failed: %d\n", stats.Failed)
+		}
+		return nil
+	},
+}
+
+var dataAudioDeriveCmd = &cobra.Command{
+	Use:   "derive",
+	Short: "Generate preview clips and waveform peaks from mirrored audio",
+	Long: `Decode every mirrored recording and write, under the mirror store:
+  previews/<sha1[:2]>/<sha1>.wav  — a short loudness-normalised clip for the game
+  peaks/<sha1[:2]>/<sha1>.json    — min/max waveform peaks (audiowaveform format)
+
+The clip starts at the first non-silent sample and is faded in and out.
+Their paths are exported as preview_url and peaks_url. Opus is not decoded
+and is reported as unsupported. Run "data audio mirror" and "data audio
+probe" first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dest, _ := cmd.Flags().GetString("dest")
+		opts := audio.DefaultDeriveOptions
+		opts.ClipSeconds, _ = cmd.Flags().GetFloat64("clip-seconds")
+		opts.Buckets, _ = cmd.Flags().GetInt("buckets")
+		opts.TargetLUFS, _ = cmd.Flags().GetFloat64("target-lufs")
+
+		if opts.ClipSeconds <= 0 {
+			return fmt.Errorf("--clip-seconds must be positive")
+		}
+		if opts.Buckets <= 0 {
+			return fmt.Errorf("--buckets must be positive")
+		}
+
+		absDest, err := filepath.Abs(dest)
+		if err != nil {
+			return fmt.Errorf("failed to resolve destination path: %w", err)
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		fmt.Println("=== Audio Derive ===")
+		fmt.Printf("Store: %s\n", absDest)
+		fmt.Printf("Preview: %.1fs at %.1f LUFS, %d waveform buckets\n\n", opts.ClipSeconds, opts.TargetLUFS, opts.Buckets)
+
+		stats, err := audio.DeriveRecordings(database, absDest, opts, func(format string, args ...interface{}) {
+			fmt.Printf("    "+format+"\n", args...)
+		})
+		if err != nil {
+			return fmt.Errorf("derive failed: %w", err)
+		}
+
+		fmt.Println("\n=== Derive Summary ===")
+		fmt.Printf("✓ Derived: %d\n", stats.Derived)
+		if stats.Unsupported > 0 {
+			fmt.Printf("- Unsupported codec: %d\n", stats.Unsupported)
+		}
+		if stats.NotProbed > 0 {
+			fmt.Printf("- Not probed: %d (run: worldanthem data audio probe)\n", stats.NotProbed)
+		}
+		if stats.NotMirrored > 0 {
+			fmt.Printf("- Not mirrored: %d (run: worldanthem data audio mirror)\n", stats.NotMirrored)
+		}
+		if stats.Failed > 0 {
+			fmt.Printf("✗ Failed: %d\n", stats.Failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	dataCmd.AddCommand(dataAudioCmd)
+	dataAudioCmd.AddCommand(dataAudioMirrorCmd)
+	dataAudioCmd.AddCommand(dataAudioProbeCmd)
+	dataAudioCmd.AddCommand(dataAudioDeriveCmd)
+
+	dataAudioMirrorCmd.Flags().String("dest", defaultAudioDir(), "Mirror store directory")
+	dataAudioMirrorCmd.Flags().Int("workers", 4, "Number of parallel downloads")
+
+	dataAudioDeriveCmd.Flags().String("dest", defaultAudioDir(), "Mirror store directory")
+	dataAudioDeriveCmd.Flags().Float64("clip-seconds", audio.DefaultDeriveOptions.ClipSeconds, "Preview clip length in seconds")
+	dataAudioDeriveCmd.Flags().Int("buckets", audio.DefaultDeriveOptions.Buckets, "Number of waveform peak buckets")
+	dataAudioDeriveCmd.Flags().Float64("target-lufs", audio.DefaultDeriveOptions.TargetLUFS, "Preview loudness target (LUFS)")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/jobs"
+	"github.com/anthemworld/cli/pkg/sources"
+	"github.com/spf13/cobra"
+)
+
+var dataContributorsCmd = &cobra.Command{
+	Use:   "contributors",
+	Short: "Fetch composers, lyricists and adoption dates from Wikidata",
+	Long: `Read the composers (P86) and lyricists (P676) of every anthem with a
+Wikidata item into the people table, with birth and death years, and the
+adoption date with its precision (year, month or day). Run "data download" or
+"data labels" first so countries are linked to their Wikidata items; without
+that, adoption dates come from the anthem's inception only.
+
+The composer and lyricist text columns are kept and only filled in when they
+are empty or hold a raw Wikidata URL.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		fmt.Println("=== Wikidata Contributors ===")
+
+		jobID, err := jobs.CreateJob(database, "contributors", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create job: %w", err)
+		}
+		fmt.Printf("Created job: %s\n\n", jobID)
+
+		logger := jobs.NewJobLogger(database, jobID)
+		if err := jobs.StartJob(database, jobID); err != nil {
+			return fmt.Errorf("failed to start job: %w", err)
+		}
+
+		var stats sources.ContributorStats
+		err = db.WithWritingSource(database, sources.NewWikidataSource().ID(), func() error {
+			stats, err = sources.FetchContributors(context.Background(), database, logger)
+			return err
+		})
+		if err != nil {
+			jobs.FailJob(database, jobID, err.Error())
+			return fmt.Errorf("contributor fetch failed: %w", err)
+		}
+		if err := jobs.CompleteJob(database, jobID); err != nil {
+			return fmt.Errorf("failed to complete job: %w", err)
+		}
+
+		fmt.Printf("✓ Anthems with contributors: %d\n", stats.Anthems)
+		fmt.Printf("✓ People stored: %d\n", stats.People)
+		fmt.Printf("✓ Adoption dates set: %d\n", stats.AdoptedDates)
+		return nil
+	},
+}
+
+func init() {
+	dataCmd.AddCommand(dataContributorsCmd)
+}
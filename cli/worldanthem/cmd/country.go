@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/format"
+	"github.com/spf13/cobra"
+)
+
+var countryCmd = &cobra.Command{
+	Use:   "country",
+	Short: "Browse countries in the database",
+	Long: `Commands for looking at downloaded countries without opening the database.
+They read the same records as "data format", so what they show is what gets exported.`,
+}
+
+var countryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List countries",
+	Long: `List countries with their region, anthem and number of recordings.
+
+Examples:
+  worldanthem country list --region Europe
+  worldanthem country list --subregion "Western Africa" --has-audio
+  worldanthem country list --un-member`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var filter format.Filter
+		filter.Regions, _ = cmd.Flags().GetStringSlice("region")
+		filter.Subregions, _ = cmd.Flags().GetStringSlice("subregion")
+		filter.HasAudio, _ = cmd.Flags().GetBool("has-audio")
+		filter.UNMembersOnly, _ = cmd.Flags().GetBool("un-member")
+
+		countries, err := loadDatabaseCountries()
+		if err != nil {
+			return err
+		}
+		countries, err = filter.Apply(countries)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ISO\tCOUNTRY\tREGION\tUN\tANTHEM\tAUDIO")
+		for i := range countries {
+			c := &countries[i]
+			un := ""
+			if c.UNMember() {
+				un = "✓"
+			}
+			anthem := "-"
+			if c.Anthem != nil {
+				anthem = c.Anthem.Name
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", format.CountryKey(c), format.DisplayName(c),
+				orDash(c.Region), un, format.Truncate(anthem, 40), len(c.AudioFiles))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d countries\n", len(countries))
+		return nil
+	},
+}
+
+var countryShowCmd = &cobra.Command{
+	Use:   "show <iso>",
+	Short: "Show a country with its anthem, history and recordings",
+	Long: `Show everything known about a country: its details, anthem, contributors,
+history, recordings and lyrics, with where each came from. For the country
+and its anthem that is the data source that last wrote the record, as
+recorded by "data download", and when. The country is named by ISO alpha-3 or alpha-2
+code, e.g. "fra" or "FR".`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		countries, err := format.Countries(database)
+		if err != nil {
+			return fmt.Errorf("failed to query countries: %w", err)
+		}
+		c := format.FindCountry(countries, args[0])
+		if c == nil {
+			return fmt.Errorf("no country with code %q", args[0])
+		}
+		countrySource, anthemSource, err := format.CountryProvenance(database, c)
+		if err != nil {
+			return fmt.Errorf("failed to query provenance: %w", err)
+		}
+
+		fmt.Printf("=== %s (%s) ===\n", format.DisplayName(c), format.CountryKey(c))
+		if c.Name != format.DisplayName(c) {
+			fmt.Printf("  Official name: %s\n", c.Name)
+		}
+		printField("ISO alpha-2", c.ISOAlpha2)
+		printField("Capital", c.Capital)
+		printField("Region", strings.Trim(c.Region+" / "+c.Subregion, " /"))
+		fmt.Printf("  UN member: %v\n", c.UNMember())
+		printField("National symbols", c.NationalSymbol)
+		printField("National colors", c.NationalColors)
+		printProvenance(countrySource)
+
+		a := c.Anthem
+		fmt.Println("\n=== Anthem ===")
+		if a == nil {
+			fmt.Println("  No anthem in the database")
+		} else {
+			fmt.Printf("  Name: %s\n", a.Name)
+			printField("Native name", a.NativeName)
+			printField("English title", a.TitleEn)
+			printPeople("Composer", a.Composer, a.Composers)
+			printPeople("Lyricist", a.Lyricist, a.Lyricists)
+			if a.Adopted != nil {
+				fmt.Printf("  Adopted: %s (%s precision)\n", a.Adopted.Date, a.Adopted.Precision)
+			} else {
+				printField("Adopted", a.AdoptedDate)
+			}
+			printField("Wikidata", a.WikidataID)
+			printField("Wikipedia", a.WikipediaURL)
+			printProvenance(anthemSource)
+			if a.History != "" {
+				fmt.Println("\n=== History (CIA World Factbook) ===")
+				fmt.Printf("  %s\n", a.History)
+			}
+		}
+
+		fmt.Printf("\n=== Audio (%d) ===\n", len(c.AudioFiles))
+		for _, r := range c.AudioFiles {
+			marker := " "
+			if r.Primary {
+				marker = "★"
+			}
+			fmt.Printf("  %s %s\n", marker, r.Title)
+			fmt.Printf("      %s, %s", orDash(r.Type), orDash(r.Format))
+			if r.Duration > 0 {
+				fmt.Printf(", %d:%02d", r.Duration/60, r.Duration%60)
+			}
+			fmt.Println()
+			fmt.Printf("      source: %s, license: %s\n", orDash(r.Source), orDash(r.License))
+			fmt.Printf("      %s\n", r.URL)
+		}
+
+		if len(c.Lyrics) > 0 {
+			fmt.Printf("\n=== Lyrics (%d) ===\n", len(c.Lyrics))
+			for _, l := range c.Lyrics {
+				fmt.Printf("  %s %s (%s), %d verses\n", l.Kind, l.Lang, l.Script, len(l.Verses))
+				fmt.Printf("      source: %s, %s\n", l.Source, orDash(l.License))
+			}
+		}
+		return nil
+	},
+}
+
+var anthemCmd = &cobra.Command{
+	Use:   "anthem",
+	Short: "Browse anthems in the database",
+	Long:  `Commands for looking at downloaded anthems without opening the database.`,
+}
+
+var anthemSearchCmd = &cobra.Command{
+	Use:   "search <text>",
+	Short: "Search anthems by name, composer, lyricist or country",
+	Long: `Find anthems whose name, native or English title, composer, lyricist or
+country name contains the text, ignoring case.
+
+Example:
+  worldanthem anthem search marseillaise`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		countries, err := loadDatabaseCountries()
+		if err != nil {
+			return err
+		}
+
+		matches := format.SearchAnthems(countries, strings.Join(args, " "))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ISO\tCOUNTRY\tANTHEM\tMATCHED")
+		for _, m := range matches {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s: %s\n", format.CountryKey(m.Country), format.DisplayName(m.Country),
+				format.Truncate(m.Country.Anthem.Name, 40), m.Field, format.Truncate(m.Value, 40))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d anthems\n", len(matches))
+		return nil
+	},
+}
+
+// loadDatabaseCountries reads every country as the exporter sees it
+func loadDatabaseCountries() ([]format.CountryRecord, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %w", err)
+	}
+	defer database.Close()
+
+	countries, err := format.Countries(database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query countries: %w", err)
+	}
+	return countries, nil
+}
+
+func printField(label, value string) {
+	if value != "" {
+		fmt.Printf("  %s: %s\n", label, value)
+	}
+}
+
+// printPeople prints structured contributors with their years, or the
+// free-text value when there are none
+func printPeople(label, text string, people []format.PersonRecord) {
+	if len(people) == 0 {
+		printField(label, text)
+		return
+	}
+	for _, p := range people {
+		name := p.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		years := ""
+		switch {
+		case p.BirthYear != 0 && p.DeathYear != 0:
+			years = fmt.Sprintf(" (%d–%d)", p.BirthYear, p.DeathYear)
+		case p.BirthYear != 0:
+			years = fmt.Sprintf(" (b. %d)", p.BirthYear)
+		}
+		fmt.Printf("  %s: %s%s [%s]\n", label, name, years, p.WikidataID)
+	}
+}
+
+// printProvenance prints the data source that last wrote a record and when
+func printProvenance(p format.Provenance) {
+	fmt.Printf("  Last written by: %s", orDash(p.Source))
+	if p.Updated != "" {
+		fmt.Printf(" (updated %s)", p.Updated)
+	}
+	fmt.Println()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(countryCmd)
+	countryCmd.AddCommand(countryListCmd)
+	countryCmd.AddCommand(countryShowCmd)
+	rootCmd.AddCommand(anthemCmd)
+	anthemCmd.AddCommand(anthemSearchCmd)
+
+	countryListCmd.Flags().StringSlice("region", nil, "Only list countries in these regions (e.g. Europe,Africa)")
+	countryListCmd.Flags().StringSlice("subregion", nil, "Only list countries in these subregions")
+	countryListCmd.Flags().Bool("has-audio", false, "Only list countries with at least one recording")
+	countryListCmd.Flags().Bool("un-member", false, "Only list UN member states")
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/anthemworld/cli/internal/testdb"
+)
+
+// setupCountryDB creates a database under a temporary HOME with France
+// (anthem with factbook history, one recording) and Antarctica, written as
+// their data sources would.
+func setupCountryDB(t *testing.T) {
+	testdb.Open(t, `
+		INSERT INTO meta (key, value) VALUES ('writing_source', 'rest-countries-api');
+		INSERT INTO countries (id, name, common_name, iso_alpha2, iso_alpha3, un_member, region, updated_at)
+		VALUES ('fra', 'French Republic', 'France', 'FR', 'FRA', 1, 'Europe', '2026-01-02 03:04:05'),
+		       ('ata', 'Antarctica', 'Antarctica', 'AQ', 'ATA', 0, 'Antarctic', '2026-01-02 03:04:05');
+		UPDATE meta SET value = 'wikidata-sparql' WHERE key = 'writing_source';
+		INSERT INTO anthems (country_id, name, composer, updated_at)
+		VALUES ('fra', 'La Marseillaise', 'Rouget de Lisle', '2026-02-03 04:05:06');
+		UPDATE meta SET value = 'factbook-json' WHERE key = 'writing_source';
+		UPDATE anthems SET anthem_history = 'Written in 1792.' WHERE country_id = 'fra';
+		DELETE FROM meta WHERE key = 'writing_source';
+		INSERT INTO audio_recordings (id, country_id, title, url, format, type, source)
+		VALUES ('rec-a', 'fra', 'La Marseillaise.ogg', 'https://example.org/a.ogg', 'ogg', 'vocal', 'wikimedia-commons');
+	`)
+}
+
+// runCommand runs the CLI with args and returns what it printed
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	w.Close()
+	return <-out, err
+}
+
+func TestCountryShow(t *testing.T) {
+	setupCountryDB(t)
+
+	out, err := runCommand(t, "country", "show", "fr")
+	if err != nil {
+		t.Fatalf("country show failed: %v", err)
+	}
+	for _, want := range []string{
+		"=== France (FRA) ===",
+		"Last written by: rest-countries-api (updated 2026-01-02 03:04:05)",
+		"Last written by: factbook-json (updated 2026-02-03 04:05:06)",
+		"Written in 1792.",
+		"source: wikimedia-commons",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+
+	if _, err := runCommand(t, "country", "show", "xyz"); err == nil || !strings.Contains(err.Error(), "xyz") {
+		t.Errorf("Expected an error for an unknown country, got %v", err)
+	}
+}
+
+func TestCountryListAndAnthemSearch(t *testing.T) {
+	setupCountryDB(t)
+
+	out, err := runCommand(t, "country", "list", "--region", "europe")
+	if err != nil {
+		t.Fatalf("country list failed: %v", err)
+	}
+	if !strings.Contains(out, "FRA") || strings.Contains(out, "ATA") || !strings.Contains(out, "1 countries") {
+		t.Errorf("Expected only France, got:\n%s", out)
+	}
+
+	out, err = runCommand(t, "anthem", "search", "rouget")
+	if err != nil {
+		t.Fatalf("anthem search failed: %v", err)
+	}
+	if !strings.Contains(out, "composer: Rouget de Lisle") || !strings.Contains(out, "1 anthems") {
+		t.Errorf("Expected La Marseillaise by its composer, got:\n%s", out)
+	}
+}
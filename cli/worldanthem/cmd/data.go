@@ -95,6 +95,9 @@ var dataStatusCmd = &cobra.Command{
 		fmt.Printf("Schema Applied: %v\n", stats.SchemaApplied)
 		fmt.Printf("Schema Version: %d\n", stats.SchemaVersion)
 		fmt.Printf("Schema Up-to-date: %v\n", stats.SchemaUpToDate)
+		if since, err := db.SearchIndexStale(database); err == nil && since != "" {
+			fmt.Printf("Search Index: stale since %s (rebuilt by the next search in a build with FTS5)\n", since)
+		}
 		fmt.Println("\nData Counts:")
 		fmt.Printf("  Countries: %d\n", stats.CountryCount)
 		fmt.Printf("  Anthems: %d\n", stats.AnthemCount)
@@ -206,11 +209,69 @@ var dataSourcesCmd = &cobra.Command{
 
 var dataFormatCmd = &cobra.Command{
 	Use:   "format",
-	Short: "Format and export data to JSON",
-	Long:  `Export database data to JSON files for use by the website.`,
+	Short: "Format and export data",
+	Long: `Export database data for use by the website and for analysis.
+
+Formats:
+  json      anthems.json, countries indexed by ISO alpha-3 (used by the website)
+  ndjson    countries.ndjson, one country record per line
+  csv       countries.csv, anthems.csv and audio.csv
+  columnar  countries/anthems/audio.columns.json, column arrays for notebooks
+
+Every format also writes an index.json manifest listing every file written
+with its SHA-256. With --shard, countries/<iso3>.json (one full record per
+country) and a slim summary.json are written as well. With --search-index,
+search.json holds a prebuilt index that the site's countries table searches
+without a server.
+
+With --hugo-content DIR, one Hugo page per country is written to DIR (e.g.
+hugo/site/content/countries) with front matter from the database. Pages for
+countries no longer in the database are deleted; text below the marker line
+in each page is preserved.
+
+Files are staged in a temporary directory that replaces the output directory
+only when the export succeeds; other files in the output directory are kept.
+On Linux the two directories are exchanged atomically; elsewhere the output
+directory is briefly missing between two renames. With --reproducible, generated_at is taken from
+SOURCE_DATE_EPOCH so two exports of the same database are byte-identical:
+
+  SOURCE_DATE_EPOCH=$(git log -1 --format=%ct) worldanthem data format --reproducible
+
+Filters limit the export to a subset of countries and are combined with AND;
+the filter used is recorded in index.json:
+
+  worldanthem data format --un-members-only --has-anthem
+  worldanthem data format --region Europe,Oceania --has-audio
+  worldanthem data format --countries usa,fra -o ./sample
+
+With --lang, a translated copy of the export is written per language (e.g.
+fr/anthems.json) using labels fetched by "data labels". Missing labels fall
+back to the base language (pt-br → pt), then --fallback, then the stored name:
+
+  worldanthem data format --lang fr,es,ar --fallback en`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outputFormat, _ := cmd.Flags().GetString("format")
 		output, _ := cmd.Flags().GetString("output")
+		audioBaseURL, _ := cmd.Flags().GetString("audio-base-url")
+		shard, _ := cmd.Flags().GetBool("shard")
+		searchIndex, _ := cmd.Flags().GetBool("search-index")
+		gameRanks, _ := cmd.Flags().GetBool("game-ranks")
+		hugoContent, _ := cmd.Flags().GetString("hugo-content")
+		reproducible, _ := cmd.Flags().GetBool("reproducible")
+		var filter format.Filter
+		filter.UNMembersOnly, _ = cmd.Flags().GetBool("un-members-only")
+		filter.Regions, _ = cmd.Flags().GetStringSlice("region")
+		filter.Subregions, _ = cmd.Flags().GetStringSlice("subregion")
+		filter.HasAudio, _ = cmd.Flags().GetBool("has-audio")
+		filter.HasAnthem, _ = cmd.Flags().GetBool("has-anthem")
+		filter.Countries, _ = cmd.Flags().GetStringSlice("countries")
+		langs, _ := cmd.Flags().GetStringSlice("lang")
+		fallback, _ := cmd.Flags().GetStringSlice("fallback")
+		
+		// Reject unknown formats before touching the output directory
+		if _, err := format.Lookup(outputFormat); err != nil {
+			return err
+		}
 		
 		// Create output directory if it doesn't exist (mkdir -p behavior)
 		absOutput, err := filepath.Abs(output)
@@ -238,8 +299,20 @@ var dataFormatCmd = &cobra.Command{
 		}
 		defer database.Close()
 		
-		fmt.Println("\nExporting data to JSON...")
-		if err := format.ExportToDir(database, absOutput); err != nil {
+		fmt.Printf("\nExporting data to %s...\n", outputFormat)
+		opts := format.Options{Format: outputFormat, AudioBaseURL: audioBaseURL, Shard: shard, Filter: filter,
+			Languages: langs, Fallback: fallback, SearchIndex: searchIndex, GameRanks: gameRanks}
+		if reproducible {
+			if opts.GeneratedAt, err = format.SourceDateEpoch(); err != nil {
+				return fmt.Errorf("--reproducible: %w", err)
+			}
+		}
+		if hugoContent != "" {
+			if opts.HugoContentDir, err = filepath.Abs(hugoContent); err != nil {
+				return fmt.Errorf("failed to resolve hugo content path: %w", err)
+			}
+		}
+		if err := format.ExportToDir(database, absOutput, opts); err != nil {
 			return fmt.Errorf("export failed: %w", err)
 		}
 		fmt.Println("\n✓ Export complete")
@@ -250,8 +323,17 @@ var dataFormatCmd = &cobra.Command{
 var dataDownloadCmd = &cobra.Command{
 	Use:   "download [source-id...]",
 	Short: "Download data from sources",
-	Long:  `Download data from all or specified data sources. Pass source IDs to download only those sources (e.g. "worldanthem data download wikimedia-commons factbook-json").`,
+	Long: `Download data from all or specified data sources. Pass source IDs to download only those sources (e.g. "worldanthem data download wikimedia-commons factbook-json").
+
+After the Wikidata source downloads, the translated country and anthem labels
+are fetched in the same job for the --label-lang languages, as "data labels"
+does, so a refresh never leaves them stale.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		labelLangs, _ := cmd.Flags().GetStringSlice("label-lang")
+		if len(labelLangs) == 0 {
+			return fmt.Errorf("--label-lang needs at least one language")
+		}
+
 		database, err := db.GetDB()
 		if err != nil {
 			return fmt.Errorf("failed to get database: %w", err)
@@ -287,7 +369,8 @@ var dataDownloadCmd = &cobra.Command{
 
 		// Create job
 		jobID, err := jobs.CreateJob(database, "data-download", map[string]interface{}{
-			"sources": strings.Join(args, ","),
+			"sources":         strings.Join(args, ","),
+			"label_languages": labelLangs,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create job: %w", err)
@@ -307,12 +390,28 @@ var dataDownloadCmd = &cobra.Command{
 		ctx := context.Background()
 		successCount := 0
 		failCount := 0
+		wikidataID := sources.NewWikidataSource().ID()
 
 		for i, source := range allSources {
 			fmt.Printf("[%d/%d] %s\n", i+1, len(allSources), source.Name())
 			logger.Infof("Starting download from %s", source.Name())
 
-			if err := source.Download(ctx, database, logger); err != nil {
+			err := db.WithWritingSource(database, source.ID(), func() error {
+				if err := source.Download(ctx, database, logger); err != nil {
+					return err
+				}
+				if source.ID() != wikidataID {
+					return nil
+				}
+				// Labels are keyed by the Wikidata IDs this download refreshed
+				stats, err := sources.FetchWikidataLabels(ctx, database, labelLangs, logger)
+				if err != nil {
+					return fmt.Errorf("failed to fetch labels: %w", err)
+				}
+				fmt.Printf("    ✓ Labels stored: %d (%s)\n", stats.Labels, strings.Join(labelLangs, ","))
+				return nil
+			})
+			if err != nil {
 				logger.Errorf("Failed to download from %s: %v", source.Name(), err.Error())
 				fmt.Printf("    ✗ Failed: %v\n\n", err)
 				failCount++
@@ -363,6 +462,22 @@ func init() {
 	dataCmd.AddCommand(dataFormatCmd)
 	dataCmd.AddCommand(dataDownloadCmd)
 	
-	dataFormatCmd.Flags().StringP("format", "f", "json", "Output format (json)")
+	dataDownloadCmd.Flags().StringSlice("label-lang", sources.DefaultLabelLanguages, "Languages to fetch Wikidata labels in")
+
+	dataFormatCmd.Flags().StringP("format", "f", "json", "Output format ("+strings.Join(format.Formats(), ", ")+")")
 	dataFormatCmd.Flags().StringP("output", "o", "./output", "Output directory")
+	dataFormatCmd.Flags().Bool("shard", false, "Also write per-country files (countries/<iso3>.json) and summary.json")
+	dataFormatCmd.Flags().Bool("search-index", false, "Also write search.json, a prebuilt index for client-side search")
+	dataFormatCmd.Flags().Bool("game-ranks", false, "Include each country's current anthem game rank and rating")
+	dataFormatCmd.Flags().String("hugo-content", "", "Also write Hugo country pages to this directory (e.g. hugo/site/content/countries)")
+	dataFormatCmd.Flags().Bool("reproducible", false, "Take generated_at from SOURCE_DATE_EPOCH for byte-identical output")
+	dataFormatCmd.Flags().Bool("un-members-only", false, "Only export UN member states")
+	dataFormatCmd.Flags().StringSlice("region", nil, "Only export countries in these regions (e.g. Europe,Africa)")
+	dataFormatCmd.Flags().StringSlice("subregion", nil, "Only export countries in these subregions (e.g. \"Western Europe\")")
+	dataFormatCmd.Flags().Bool("has-audio", false, "Only export countries with at least one audio recording")
+	dataFormatCmd.Flags().Bool("has-anthem", false, "Only export countries with an anthem")
+	dataFormatCmd.Flags().StringSlice("countries", nil, "Only export these countries (ISO alpha-3 or alpha-2, e.g. usa,fra)")
+	dataFormatCmd.Flags().StringSlice("lang", nil, "Also write translated exports for these languages (e.g. fr,es,ar)")
+	dataFormatCmd.Flags().StringSlice("fallback", []string{"en"}, "Languages to try when a label is missing in --lang")
+	dataFormatCmd.Flags().String("audio-base-url", "", "Rewrite mirrored audio, preview and waveform URLs to this CDN or base path (e.g. /audio); previews and waveforms are only exported with it")
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/anthemworld/cli/pkg/format"
+	"github.com/spf13/cobra"
+)
+
+var dataDiffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Show what changed between two exports or database snapshots",
+	Long: `Compare two exports and report added and removed countries, changed country
+and anthem fields, and new or removed audio recordings, keyed by ISO alpha-3.
+
+Each side can be an export directory written by "data format" (anthems.json,
+countries.ndjson or countries/*.json) or a SQLite database snapshot such as a
+copy of ~/.local/share/anthemworld/data.db taken before a refresh.
+
+Output formats:
+  human     summary for the terminal (default)
+  json      machine-readable diff
+  markdown  "What's new" section for release notes or the website`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, _ := cmd.Flags().GetString("format")
+		switch outputFormat {
+		case "human", "json", "markdown":
+		default:
+			return fmt.Errorf("unknown format %q (available: human, json, markdown)", outputFormat)
+		}
+
+		oldCountries, err := loadCountries(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", args[0], err)
+		}
+		newCountries, err := loadCountries(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", args[1], err)
+		}
+
+		diff := format.DiffCountries(oldCountries, newCountries)
+		switch outputFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(diff)
+		case "markdown":
+			return diff.WriteMarkdown(os.Stdout)
+		}
+		return diff.WriteText(os.Stdout)
+	},
+}
+
+// loadCountries reads countries from an export directory or a SQLite snapshot
+func loadCountries(path string) ([]format.CountryRecord, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return format.LoadExportDir(path)
+	}
+
+	header := make([]byte, 16)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Read(header)
+	f.Close()
+	if err != nil || !bytes.Equal(header, []byte("SQLite format 3\x00")) {
+		return nil, fmt.Errorf("not an export directory or SQLite database")
+	}
+
+	// Read-only, so old snapshots are not migrated
+	database, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer database.Close()
+	return format.Countries(database)
+}
+
+func init() {
+	dataCmd.AddCommand(dataDiffCmd)
+
+	dataDiffCmd.Flags().StringP("format", "f", "human", "Output format (human, json, markdown)")
+}
@@ -0,0 +1,1074 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/format"
+	"github.com/anthemworld/cli/pkg/game"
+	"github.com/spf13/cobra"
+)
+
+var gameCmd = &cobra.Command{
+	Use:   "game",
+	Short: "Play the anthem ranking game against the local database",
+	Long: `Commands that play the anthem game offline, with the same listen-weighted ELO
+and matchup rules as the Lambda functions in sam/game, but keeping rankings,
+votes, sessions and listen history in the local database.
+
+A vote counts fully only when both anthems were heard for 10 seconds in the
+session; shorter listens scale the rating change down. Countries join the
+rankings at 1500 once they have a recording.`,
+}
+
+var gameMatchupCmd = &cobra.Command{
+	Use:   "matchup",
+	Short: "Pick the next pair of anthems for a session",
+	Long: `Pick the next two countries for a session and make them its current matchup.
+Without --session a new session is started; pass its ID to later calls.
+
+Examples:
+  worldanthem game matchup --user-country FR
+  worldanthem game matchup --session 6f1c...`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessionID, _ := cmd.Flags().GetString("session")
+		userCountry, _ := cmd.Flags().GetString("user-country")
+		seed, _ := cmd.Flags().GetInt64("seed")
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		engine := game.NewEngine(database, gameSeed(seed))
+		if sessionID == "" {
+			s, err := engine.CreateSession(strings.ToUpper(userCountry))
+			if err != nil {
+				return fmt.Errorf("failed to create session: %w", err)
+			}
+			sessionID = s.ID
+			fmt.Printf("✓ Started session %s\n\n", sessionID)
+		}
+
+		m, err := engine.NextMatchup(sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to pick matchup: %w", err)
+		}
+
+		fmt.Println("=== Matchup ===")
+		fmt.Printf("  Session:  %s\n", sessionID)
+		fmt.Printf("  Matchup:  %s", m.ID)
+		if m.IsWildcard {
+			fmt.Print(" (wildcard)")
+		}
+		fmt.Println()
+		for _, side := range []struct {
+			label string
+			c     game.MatchupCountry
+		}{{"A", m.CountryA}, {"B", m.CountryB}} {
+			fmt.Printf("\n  %s: %s %s (ELO %d, heard %.1fs)\n", side.label, side.c.CountryID, side.c.Name,
+				side.c.EloScore, float64(side.c.ListenMS)/1000)
+			if side.c.AnthemName != "" {
+				fmt.Printf("     %s\n", side.c.AnthemName)
+			}
+			fmt.Printf("     %s\n", side.c.AudioURL)
+		}
+		return nil
+	},
+}
+
+var gameVoteCmd = &cobra.Command{
+	Use:   "vote",
+	Short: "Vote on a session's current matchup",
+	Long: `Vote for the winner of a session's current matchup. --listen-a and --listen-b
+are the milliseconds each anthem was heard this round; they add up over the
+session, and the vote is weighted by the product of both anthems' listen
+weights, each capped at 10 seconds.
+
+Example:
+  worldanthem game vote --session 6f1c... --matchup 9a2e... \
+    --winner FRA --loser DEU --listen-a 12000 --listen-b 8000`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := game.VoteRequest{}
+		req.SessionID, _ = cmd.Flags().GetString("session")
+		req.MatchupID, _ = cmd.Flags().GetString("matchup")
+		winner, _ := cmd.Flags().GetString("winner")
+		loser, _ := cmd.Flags().GetString("loser")
+		req.WinnerID, req.LoserID = strings.ToUpper(winner), strings.ToUpper(loser)
+		req.ListenAMS, _ = cmd.Flags().GetInt64("listen-a")
+		req.ListenBMS, _ = cmd.Flags().GetInt64("listen-b")
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		res, err := game.NewEngine(database, gameSeed(0)).Vote(req)
+		if err != nil {
+			return fmt.Errorf("failed to vote: %w", err)
+		}
+
+		fmt.Printf("✓ Recorded vote %s (weight %.2f)\n", res.VoteID, res.VoteWeight)
+		fmt.Printf("  %s: %d → %d\n", res.Winner.CountryID, res.Winner.OldELO, res.Winner.NewELO)
+		fmt.Printf("  %s: %d → %d\n", res.Loser.CountryID, res.Loser.OldELO, res.Loser.NewELO)
+		if res.VoteWeight < 1 {
+			fmt.Println("\n  Listen to both anthems for 10 seconds for a full-weight vote.")
+		}
+		return nil
+	},
+}
+
+var gameLeaderboardCmd = &cobra.Command{
+	Use:   "leaderboard",
+	Short: "Show countries ranked by rating",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		entries, total, err := game.Leaderboard(database, limit)
+		if err != nil {
+			return fmt.Errorf("failed to query leaderboard: %w", err)
+		}
+		if total == 0 {
+			fmt.Println("No rankings yet. Run `worldanthem game matchup` to start playing.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RANK\tISO\tCOUNTRY\tELO\tW\tL\tWIN RATE")
+		for _, e := range entries {
+			rate := "-"
+			if e.WinRate != nil {
+				rate = fmt.Sprintf("%d%%", *e.WinRate)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%s\n", e.Rank, e.CountryID,
+				format.Truncate(e.Name, 30), e.EloScore, e.Wins, e.Losses, rate)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d of %d countries\n", len(entries), total)
+		return nil
+	},
+}
+
+var gameReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay the vote history through rating algorithms and compare them",
+	Long: `Replay a vote log, oldest vote first, through one or more rating algorithms
+and compare the final rankings, how they correlate, and how well each predicts
+the latest votes when trained only on the ones before.
+
+The log is JSON lines of votes as stored in the anthem-votes table (vote_id,
+session_id, winner_id, loser_id, listen_a_ms, listen_b_ms, voted_at); without
+--votes the local game_votes table is replayed. Listen weights follow the
+game's rule, with --full-listen-ms as the time a full-weight vote needs.
+With --exclude-flagged the votes "game audit" flags at its default
+thresholds are left out; to use other thresholds, write the flagged votes
+with "game audit --output" and pass the file to --exclude.
+
+Algorithms:
+  elo            the game's integer ELO (--k, 32 in production)
+  glicko2        Glicko-2, one rating period per vote, weight scales the evidence
+  bradley-terry  weighted maximum-likelihood Bradley-Terry over all votes
+
+Examples:
+  worldanthem game replay --votes votes.jsonl
+  worldanthem game replay --votes votes.jsonl --algo elo --k 24 --full-listen-ms 15000
+  worldanthem game replay --holdout 0.1 --format json
+  worldanthem game replay --exclude-flagged
+  worldanthem game audit --burst-votes 50 --output flagged.txt && worldanthem game replay --exclude flagged.txt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		votesPath, _ := cmd.Flags().GetString("votes")
+		algos, _ := cmd.Flags().GetStringSlice("algo")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		opts := game.ReplayOptions{}
+		opts.K, _ = cmd.Flags().GetFloat64("k")
+		opts.FullListenMS, _ = cmd.Flags().GetInt64("full-listen-ms")
+		opts.Holdout, _ = cmd.Flags().GetFloat64("holdout")
+		excludeFlagged, _ := cmd.Flags().GetBool("exclude-flagged")
+		excludePath, _ := cmd.Flags().GetString("exclude")
+
+		switch outputFormat {
+		case "human", "json":
+		default:
+			return fmt.Errorf("unknown format %q (available: human, json)", outputFormat)
+		}
+		if excludeFlagged && excludePath != "" {
+			return fmt.Errorf("--exclude-flagged and --exclude cannot be combined")
+		}
+
+		votes, source, err := loadVotes(votesPath)
+		if err != nil {
+			return err
+		}
+		excluded := 0
+		if excludeFlagged {
+			audit, err := auditVotes(votes, game.DefaultAuditOptions)
+			if err != nil {
+				return err
+			}
+			kept := audit.Exclude(votes)
+			excluded, votes = len(votes)-len(kept), kept
+		}
+		if excludePath != "" {
+			f, err := os.Open(excludePath)
+			if err != nil {
+				return fmt.Errorf("failed to open vote IDs: %w", err)
+			}
+			ids, err := game.ReadVoteIDs(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", excludePath, err)
+			}
+			kept := game.ExcludeVotes(votes, ids)
+			excluded, votes = len(votes)-len(kept), kept
+		}
+		if len(votes) == 0 {
+			return fmt.Errorf("no votes in %s", source)
+		}
+
+		report := replayReport{Source: source, Votes: len(votes), Excluded: excluded, Holdout: opts.Holdout}
+		for _, algo := range algos {
+			res, err := game.Replay(votes, algo, opts)
+			if err != nil {
+				return err
+			}
+			report.Results = append(report.Results, res)
+		}
+		for i := range report.Results {
+			for j := i + 1; j < len(report.Results); j++ {
+				a, b := report.Results[i], report.Results[j]
+				report.Correlations = append(report.Correlations, replayCorrelation{
+					A: a.Algorithm, B: b.Algorithm, RankCorrelation: game.CorrelateRankings(a.Rankings, b.Rankings),
+				})
+			}
+		}
+
+		if outputFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		return report.writeText(limit)
+	},
+}
+
+// loadVotes reads a vote log, or the local game_votes table when path is empty
+func loadVotes(path string) ([]game.LoggedVote, string, error) {
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open vote log: %w", err)
+		}
+		defer f.Close()
+		votes, err := game.ReadVoteLog(f)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return votes, path, nil
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get database: %w", err)
+	}
+	defer database.Close()
+	votes, err := game.LocalVotes(database)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query votes: %w", err)
+	}
+	return votes, "game_votes", nil
+}
+
+type replayCorrelation struct {
+	A string `json:"a"`
+	B string `json:"b"`
+	game.RankCorrelation
+}
+
+type replayReport struct {
+	Source       string              `json:"source"`
+	Votes        int                 `json:"votes"`
+	Excluded     int                 `json:"excluded,omitempty"` // flagged votes left out
+	Holdout      float64             `json:"holdout"`
+	Results      []game.ReplayResult `json:"results"`
+	Correlations []replayCorrelation `json:"correlations,omitempty"`
+}
+
+func (r replayReport) writeText(limit int) error {
+	fmt.Println("=== Replay ===")
+	fmt.Printf("  Votes: %d from %s", r.Votes, r.Source)
+	if r.Excluded > 0 {
+		fmt.Printf(" (%d flagged votes excluded)", r.Excluded)
+	}
+	fmt.Print("\n\n")
+
+	// Rankings side by side, in the order of the first algorithm
+	fmt.Println("=== Final Rankings ===")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "ISO\tVOTES"
+	byCountry := make([]map[string]game.RatedCountry, len(r.Results))
+	for i, res := range r.Results {
+		header += "\t" + strings.ToUpper(res.Algorithm) + "\t"
+		byCountry[i] = map[string]game.RatedCountry{}
+		for _, c := range res.Rankings {
+			byCountry[i][c.CountryID] = c
+		}
+	}
+	fmt.Fprintln(w, header)
+	first := r.Results[0].Rankings
+	if limit > 0 && limit < len(first) {
+		first = first[:limit]
+	}
+	for _, c := range first {
+		row := fmt.Sprintf("%s\t%d", c.CountryID, c.Votes)
+		for i := range r.Results {
+			rc := byCountry[i][c.CountryID]
+			row += fmt.Sprintf("\t#%d\t%.0f", rc.Rank, rc.Score)
+		}
+		fmt.Fprintln(w, row)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d of %d countries\n", len(first), len(r.Results[0].Rankings))
+
+	if len(r.Correlations) > 0 {
+		fmt.Println("\n=== Rank Correlation ===")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ALGORITHMS\tCOUNTRIES\tSPEARMAN\tKENDALL")
+		for _, c := range r.Correlations {
+			fmt.Fprintf(w, "%s / %s\t%d\t%.3f\t%.3f\n", c.A, c.B, c.Countries, c.Spearman, c.Kendall)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if r.Results[0].Evaluation != nil {
+		fmt.Printf("\n=== Prediction (latest %.0f%% held out) ===\n", r.Holdout*100)
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ALGORITHM\tTRAIN\tTEST\tACCURACY\tLOG LOSS\tBRIER")
+		for _, res := range r.Results {
+			e := res.Evaluation
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%.4f\t%.4f\n", res.Algorithm, e.TrainVotes, e.TestVotes,
+				e.Accuracy*100, e.LogLoss, e.Brier)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Println("\n  A coin flip scores 50% accuracy, log loss 0.6931 and Brier 0.2500.")
+	}
+	return nil
+}
+
+var gameSeedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Write initial game rankings for every country with playable audio",
+	Long: `Write a ranking row at 1500 for every country in the database with at least
+one playable recording (MIDI and other formats browsers cannot play do not
+count), so the game plays exactly the countries "data format" publishes with
+audio. Countries left out are listed with the reason.
+
+Targets:
+  sqlite    the local game_rankings table (default). Ranked countries keep
+            their rating but get the current name, flag, anthem and audio;
+            countries that lost their audio are no longer matched.
+  dynamodb  the anthem-rankings-<stage> table at --endpoint, e.g. LocalStack,
+            updated the same way. Credentials come from AWS_ACCESS_KEY_ID and
+            AWS_SECRET_ACCESS_KEY or the AWS_PROFILE (or default) profile in
+            ~/.aws/credentials; only local endpoints may go without.
+  jsonl     anthem-rankings items as JSON lines, to --output or stdout
+
+--reset starts every rating over at 1500.
+
+Examples:
+  worldanthem game seed
+  worldanthem game seed --target dynamodb --endpoint http://localhost:4566 --stage local
+  worldanthem game seed --target jsonl --output rankings.jsonl`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, _ := cmd.Flags().GetString("target")
+		reset, _ := cmd.Flags().GetBool("reset")
+		output, _ := cmd.Flags().GetString("output")
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		region, _ := cmd.Flags().GetString("region")
+		stage, _ := cmd.Flags().GetString("stage")
+		table, _ := cmd.Flags().GetString("table")
+
+		switch target {
+		case "sqlite", "dynamodb", "jsonl":
+		default:
+			return fmt.Errorf("unknown target %q (available: sqlite, dynamodb, jsonl)", target)
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		countries, err := format.Countries(database)
+		if err != nil {
+			return fmt.Errorf("failed to query countries: %w", err)
+		}
+		rows, excluded := game.SeedRankings(countries)
+
+		// With JSON lines on stdout the report goes to stderr
+		report := os.Stdout
+		if target == "jsonl" && (output == "" || output == "-") {
+			report = os.Stderr
+		}
+		fmt.Fprintln(report, "=== Seeding Game Rankings ===")
+		fmt.Fprintf(report, "  Playable: %d countries\n", len(rows))
+		fmt.Fprintf(report, "  Excluded: %d countries\n", len(excluded))
+		if len(excluded) > 0 {
+			fmt.Fprintln(report)
+			w := tabwriter.NewWriter(report, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  ISO\tCOUNTRY\tREASON")
+			for _, e := range excluded {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", e.CountryID, format.Truncate(e.Name, 30), e.Reason)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(report)
+
+		switch target {
+		case "sqlite":
+			res, err := game.SeedLocal(database, rows, reset)
+			if err != nil {
+				return fmt.Errorf("failed to seed game_rankings: %w", err)
+			}
+			fmt.Printf("✓ game_rankings: %d added, %d updated, %d without audio now", res.Added, res.Updated, res.Disabled)
+			if reset {
+				fmt.Printf(", %d reset", res.Reset)
+			}
+			fmt.Println()
+
+		case "dynamodb":
+			if table == "" {
+				table = game.TableName(game.RankingsTable, stage)
+			}
+			d, err := game.NewDynamoDB(endpoint, region)
+			if err != nil {
+				return err
+			}
+			res, err := game.SeedDynamoDB(d, table, rows, reset)
+			if err != nil {
+				return fmt.Errorf("failed to seed %s: %w", table, err)
+			}
+			fmt.Printf("✓ %s: %d added, %d updated, %d without audio now", table, res.Added, res.Updated, res.Disabled)
+			if reset {
+				fmt.Printf(", %d reset", res.Reset)
+			}
+			fmt.Println()
+
+		case "jsonl":
+			out := os.Stdout
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", output, err)
+				}
+				defer f.Close()
+				out = f
+			}
+			if err := game.WriteRankingsJSONL(out, rows); err != nil {
+				return fmt.Errorf("failed to write rankings: %w", err)
+			}
+			if out != os.Stdout {
+				fmt.Fprintf(report, "✓ Wrote %d rankings to %s\n", len(rows), output)
+			}
+		}
+		return nil
+	},
+}
+
+var gameSimulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Run synthetic voters through the game engine to test matchmaking",
+	Long: `Simulate voters with hidden preferences playing the game, through the same
+engine as "game matchup" and "game vote", on a scratch copy of the rankings
+(the database's game tables are not touched).
+
+Every playable country gets a hidden quality and popularity. Each voter comes
+from a random country and prefers anthems by quality, plus --popularity-bias
+times popularity, plus --regional-bias for anthems of their own region, with
+--noise added to every judgement. The truth is that preference averaged over
+all voters.
+
+Reported:
+  convergence  rank correlation with the truth as votes come in, and the votes
+               after which it stays at or above --converged
+  coverage     how often countries are matched, by truth quartile, so starved
+               low-ranked or over-served top anthems show up
+  rank error   per country, engine rank against truth rank
+
+Examples:
+  worldanthem game simulate --voters 200 --votes 10000 --seed 7
+  worldanthem game simulate --regional-bias 2 --noise 0.5 --format json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := game.DefaultSimulationOptions
+		opts.Voters, _ = cmd.Flags().GetInt("voters")
+		opts.Votes, _ = cmd.Flags().GetInt("votes")
+		opts.Seed, _ = cmd.Flags().GetInt64("seed")
+		opts.RegionalBias, _ = cmd.Flags().GetFloat64("regional-bias")
+		opts.PopularityBias, _ = cmd.Flags().GetFloat64("popularity-bias")
+		opts.Noise, _ = cmd.Flags().GetFloat64("noise")
+		opts.ListenMS, _ = cmd.Flags().GetInt64("listen-ms")
+		opts.Checkpoints, _ = cmd.Flags().GetInt("checkpoints")
+		opts.Converged, _ = cmd.Flags().GetFloat64("converged")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outputFormat, _ := cmd.Flags().GetString("format")
+
+		switch outputFormat {
+		case "human", "json":
+		default:
+			return fmt.Errorf("unknown format %q (available: human, json)", outputFormat)
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		countries, err := format.Countries(database)
+		database.Close()
+		if err != nil {
+			return fmt.Errorf("failed to query countries: %w", err)
+		}
+
+		res, err := game.Simulate(countries, opts)
+		if err != nil {
+			return fmt.Errorf("simulation failed: %w", err)
+		}
+		if outputFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(res)
+		}
+		return writeSimulation(res, limit)
+	},
+}
+
+func writeSimulation(res game.SimulationResult, limit int) error {
+	o := res.Options
+	fmt.Println("=== Simulation ===")
+	fmt.Printf("  Countries: %d   Voters: %d   Votes: %d   Seed: %d\n", len(res.Countries), o.Voters, o.Votes, o.Seed)
+	fmt.Printf("  Regional bias: %.2f   Popularity bias: %.2f   Noise: %.2f   Listen: %dms\n\n",
+		o.RegionalBias, o.PopularityBias, o.Noise, o.ListenMS)
+
+	fmt.Println("=== Convergence ===")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VOTES\tSPEARMAN\tKENDALL\tMEAN RANK ERROR\tMAX")
+	for _, cp := range res.Checkpoints {
+		fmt.Fprintf(w, "%d\t%.3f\t%.3f\t%.1f\t%d\n", cp.Votes, cp.Spearman, cp.Kendall, cp.MeanRankError, cp.MaxRankError)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if res.ConvergedAt > 0 {
+		fmt.Printf("\n✓ ρ stayed ≥ %.2f from %d votes on (%.1f per country)\n\n", o.Converged, res.ConvergedAt,
+			float64(res.ConvergedAt)/float64(len(res.Countries)))
+	} else {
+		fmt.Printf("\n✗ ρ did not settle at or above %.2f in %d votes\n\n", o.Converged, o.Votes)
+	}
+
+	fmt.Println("=== Coverage ===")
+	shown := make([]int, len(res.Countries))
+	for i, c := range res.Countries {
+		shown[i] = c.Appearances
+	}
+	sort.Ints(shown)
+	fmt.Printf("  Matchups per country: min %d, median %d, max %d (fair share %.1f)\n",
+		shown[0], shown[len(shown)/2], shown[len(shown)-1], 2*float64(o.Votes)/float64(len(shown)))
+	fmt.Printf("  Share by truth quartile: top %.1f%%, %.1f%%, %.1f%%, bottom %.1f%%\n",
+		res.QuartileShare[0]*100, res.QuartileShare[1]*100, res.QuartileShare[2]*100, res.QuartileShare[3]*100)
+	fmt.Printf("  Wildcard matchups: %d   Never matched: %d\n\n", res.Wildcards, res.Unseen)
+
+	fmt.Println("=== Rank Error ===")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRUTH\tISO\tCOUNTRY\tREGION\tRANK\tELO\tERROR\tMATCHUPS")
+	countries := res.Countries
+	if limit > 0 && limit < len(countries) {
+		countries = countries[:limit]
+	}
+	for _, c := range countries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%+d\t%d\n", c.TruthRank, c.CountryID, format.Truncate(c.Name, 25),
+			orDash(c.Region), c.Rank, c.EloScore, c.Rank-c.TruthRank, c.Appearances)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d of %d countries\n", len(countries), len(res.Countries))
+	return nil
+}
+
+var gameImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import the deployed game's DynamoDB tables for analysis",
+	Long: `Copy rankings, votes, sessions and listen history of the deployed game into
+the local game tables, where they are joined to countries, then print the
+same report as "game report".
+
+Read the tables from a DynamoDB endpoint (--endpoint, with --stage naming the
+tables anthem-*-<stage>, signed with the credentials "game seed" uses), or
+from a directory of JSON files (--from) named after the tables, e.g.
+anthem-votes-prod.json, holding "aws dynamodb scan" output, DynamoDB export
+lines or one item per line.
+
+Rows with the same key are replaced; --replace empties the game tables first.
+Votes keep the cumulative listen times and weight the vote Lambda stored
+with them. Votes stored before the Lambda recorded them are imported
+without; "game replay" and "game audit" weigh those by the listens of their
+own round. The Lambda's listen totals expire after 24 hours, so they cannot
+be rebuilt later.
+
+Examples:
+  worldanthem game import --endpoint https://dynamodb.eu-west-1.amazonaws.com --region eu-west-1 --stage prod
+  worldanthem game import --from ./export --replace`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		region, _ := cmd.Flags().GetString("region")
+		stage, _ := cmd.Flags().GetString("stage")
+		from, _ := cmd.Flags().GetString("from")
+		replace, _ := cmd.Flags().GetBool("replace")
+		by, _ := cmd.Flags().GetString("by")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outputFormat, _ := cmd.Flags().GetString("format")
+
+		if (endpoint == "") == (from == "") {
+			return fmt.Errorf("give either --endpoint or --from")
+		}
+		if err := checkReportFlags(by, outputFormat); err != nil {
+			return err
+		}
+
+		var tables game.GameTables
+		var err error
+		source := from
+		if from != "" {
+			tables, err = game.ReadExportDir(from)
+		} else {
+			source = endpoint + " (stage " + stage + ")"
+			var d *game.DynamoDB
+			if d, err = game.NewDynamoDB(endpoint, region); err != nil {
+				return err
+			}
+			tables, err = game.ScanTables(d, stage)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read game tables: %w", err)
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		res, err := game.Import(database, tables, replace)
+		if err != nil {
+			return fmt.Errorf("failed to import game tables: %w", err)
+		}
+		report, err := buildGameReport(database, by)
+		if err != nil {
+			return err
+		}
+		if outputFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Import game.ImportResult `json:"import"`
+				gameReport
+			}{res, report})
+		}
+
+		fmt.Println("=== Game Import ===")
+		fmt.Printf("  Source: %s\n", source)
+		fmt.Printf("✓ %d rankings, %d votes, %d sessions, %d listen totals\n", res.Rankings, res.Votes, res.Sessions, res.Listens)
+		if res.Unweighted > 0 {
+			fmt.Printf("✗ %d votes without stored listen totals, weighed by their round's listens\n", res.Unweighted)
+		}
+		if len(res.UnknownCountries) > 0 {
+			fmt.Printf("✗ %d country IDs match no country: %s\n", len(res.UnknownCountries), strings.Join(res.UnknownCountries, ", "))
+		}
+		fmt.Println()
+		return writeGameReport(report, limit)
+	},
+}
+
+var gameReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report win rates by voter country and home-country bias",
+	Long: `Analyse the votes in the local game tables, e.g. after "game import":
+
+  win rates   how often anthems of each region win with voters of each
+              country (--by country) or region (--by region)
+  home bias   how often an anthem wins with voters from its own country,
+              against its win rate with everyone else, and how often voters
+              pick the anthem of their own region over another region's
+
+Voters are placed by the country of their session; votes of sessions without
+a known country are counted but not placed.
+
+Examples:
+  worldanthem game report
+  worldanthem game report --by region --format json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		by, _ := cmd.Flags().GetString("by")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		if err := checkReportFlags(by, outputFormat); err != nil {
+			return err
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		report, err := buildGameReport(database, by)
+		if err != nil {
+			return err
+		}
+		if outputFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		return writeGameReport(report, limit)
+	},
+}
+
+// gameReport is the output of game report
+type gameReport struct {
+	By       string           `json:"by"`
+	WinRates []game.WinRate   `json:"win_rates"`
+	Bias     game.BiasSummary `json:"bias"`
+}
+
+func checkReportFlags(by, outputFormat string) error {
+	switch by {
+	case "country", "region":
+	default:
+		return fmt.Errorf("unknown --by %q (available: country, region)", by)
+	}
+	switch outputFormat {
+	case "human", "json":
+	default:
+		return fmt.Errorf("unknown format %q (available: human, json)", outputFormat)
+	}
+	return nil
+}
+
+func buildGameReport(database *sql.DB, by string) (gameReport, error) {
+	report := gameReport{By: by}
+	var err error
+	if report.WinRates, err = game.WinRates(database, by == "region"); err != nil {
+		return report, fmt.Errorf("failed to query win rates: %w", err)
+	}
+	if report.Bias, err = game.HomeCountryBias(database); err != nil {
+		return report, fmt.Errorf("failed to query home bias: %w", err)
+	}
+	return report, nil
+}
+
+func writeGameReport(report gameReport, limit int) error {
+	b := report.Bias
+	fmt.Println("=== Votes ===")
+	fmt.Printf("  Placed: %d   Unplaced (no voter country): %d\n\n", b.PlacedVotes, b.UnplacedVotes)
+	if b.PlacedVotes+b.UnplacedVotes == 0 {
+		fmt.Println("No votes to report on")
+		return nil
+	}
+
+	fmt.Printf("=== Win Rate by Voter %s ===\n", strings.ToUpper(report.By[:1])+report.By[1:])
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VOTER\tANTHEM REGION\tMATCHUPS\tWINS\tWIN RATE")
+	for _, r := range report.WinRates {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f%%\n", orDash(r.Voter), orDash(r.AnthemRegion), r.Matchups, r.Wins, r.WinRate*100)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	fmt.Println("=== Home Bias ===")
+	if b.HomeVotes > 0 {
+		fmt.Printf("  Home anthem won %d of %d matchups (%.1f%%)", b.HomeWins, b.HomeVotes, b.HomeRate*100)
+		if b.ExpectedRate > 0 {
+			fmt.Printf(", %.1f%% expected from other voters", b.ExpectedRate*100)
+		}
+		fmt.Println()
+	} else {
+		fmt.Println("  No votes on an anthem of the voter's own country")
+	}
+	if b.RegionVotes > 0 {
+		fmt.Printf("  Own-region anthem won %d of %d matchups against another region (%.1f%%)\n",
+			b.RegionWins, b.RegionVotes, b.RegionRate*100)
+	}
+	if len(b.Countries) == 0 {
+		return nil
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ISO\tCOUNTRY\tHOME\tHOME RATE\tOTHERS\tOTHER RATE\tBIAS")
+	countries := b.Countries
+	if limit > 0 && limit < len(countries) {
+		countries = countries[:limit]
+	}
+	for _, c := range countries {
+		otherRate, bias := "-", "-"
+		if c.OtherVotes > 0 {
+			otherRate, bias = fmt.Sprintf("%.1f%%", c.OtherRate*100), fmt.Sprintf("%+.1f", c.Bias*100)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\t%d\t%s\t%s\n", c.CountryID, format.Truncate(c.Name, 25),
+			c.HomeVotes, c.HomeRate*100, c.OtherVotes, otherRate, bias)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d of %d countries voted on by their own voters\n", len(countries), len(b.Countries))
+	return nil
+}
+
+var gameAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Flag suspicious votes: impossible listens, favouritism, bursts and rating jumps",
+	Long: `Look for votes the vote Lambda's per-session and per-IP caps let through but
+that are unlikely to be honest, such as many sessions voting one country up.
+Run it on the local game tables, e.g. after "game import", or on a vote log.
+
+Checks:
+  listen     votes claiming more listening than the voter can have had: a
+             negative time, more than --max-listen-ms of one anthem, or two
+             anthems new to the session heard for longer than the time since
+             the previous vote
+  favourite  sessions voting on a country at least --favour-votes times and
+             letting it win more often than its win rate elsewhere makes
+             plausible (chance below --favour-p); its wins are flagged
+  burst      at least --burst-votes votes from one voter country within
+             --burst-window; if one anthem won --burst-share of them, its
+             wins are flagged
+  jump       a country gaining --jump-points of ELO within --jump-window;
+             its wins during the rise are flagged
+
+Flagged votes can be left out of a recomputation with "game replay
+--exclude-flagged", at the default thresholds, or written with --output and
+passed to "game replay --exclude".
+
+Examples:
+  worldanthem game audit
+  worldanthem game audit --burst-votes 50 --burst-window 5m --format json
+  worldanthem game audit --votes votes.jsonl
+  worldanthem game audit --jump-points 80 --output flagged.txt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		votesPath, _ := cmd.Flags().GetString("votes")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		opts := game.DefaultAuditOptions
+		opts.MaxListenMS, _ = cmd.Flags().GetInt64("max-listen-ms")
+		opts.FavourVotes, _ = cmd.Flags().GetInt("favour-votes")
+		opts.FavourP, _ = cmd.Flags().GetFloat64("favour-p")
+		opts.BurstVotes, _ = cmd.Flags().GetInt("burst-votes")
+		opts.BurstWindow, _ = cmd.Flags().GetDuration("burst-window")
+		opts.BurstShare, _ = cmd.Flags().GetFloat64("burst-share")
+		opts.JumpPoints, _ = cmd.Flags().GetInt("jump-points")
+		opts.JumpWindow, _ = cmd.Flags().GetDuration("jump-window")
+		opts.K, _ = cmd.Flags().GetFloat64("k")
+
+		switch outputFormat {
+		case "human", "json":
+		default:
+			return fmt.Errorf("unknown format %q (available: human, json)", outputFormat)
+		}
+
+		votes, source, err := loadVotes(votesPath)
+		if err != nil {
+			return err
+		}
+		res, err := auditVotes(votes, opts)
+		if err != nil {
+			return err
+		}
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", output, err)
+			}
+			err = res.WriteVoteIDs(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+		}
+		if outputFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(res)
+		}
+
+		fmt.Println("=== Vote Audit ===")
+		fmt.Printf("  Votes: %d from %s\n", res.Votes, source)
+		kinds := map[string]int{}
+		for _, f := range res.Flags {
+			kinds[f.Kind]++
+		}
+		fmt.Printf("  Flags: %d listen, %d favourite, %d burst, %d jump\n\n",
+			kinds[game.FlagListen], kinds[game.FlagFavourite], kinds[game.FlagBurst], kinds[game.FlagJump])
+		if len(res.Flags) == 0 {
+			fmt.Println("✓ Nothing suspicious")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tSTART\tSESSION\tVOTER\tANTHEM\tVOTES\tDETAIL")
+		flags := res.Flags
+		if limit > 0 && limit < len(flags) {
+			flags = flags[:limit]
+		}
+		for _, f := range flags {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", f.Kind, f.Start.Format("2006-01-02 15:04"),
+				orDash(format.Truncate(f.SessionID, 12)), orDash(f.VoterCountry), orDash(f.CountryID), len(f.Votes), f.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d of %d flags\n", len(flags), len(res.Flags))
+		hint := `"game replay --exclude-flagged"`
+		if output != "" {
+			hint = fmt.Sprintf(`"game replay --exclude %s"`, output)
+		}
+		fmt.Printf("✗ %d of %d votes flagged (%.1f%%); replay without them with %s\n",
+			res.Flagged, res.Votes, float64(res.Flagged)/float64(max(res.Votes, 1))*100, hint)
+		return nil
+	},
+}
+
+// auditVotes audits votes against the sessions of the local database
+func auditVotes(votes []game.LoggedVote, opts game.AuditOptions) (game.AuditResult, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return game.AuditResult{}, fmt.Errorf("failed to get database: %w", err)
+	}
+	defer database.Close()
+	sessions, err := game.LocalSessions(database)
+	if err != nil {
+		return game.AuditResult{}, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	return game.Audit(votes, sessions, opts), nil
+}
+
+// gameSeed returns seed, or a time-based seed when it is 0
+func gameSeed(seed int64) int64 {
+	if seed == 0 {
+		return time.Now().UnixNano()
+	}
+	return seed
+}
+
+func init() {
+	rootCmd.AddCommand(gameCmd)
+	gameCmd.AddCommand(gameMatchupCmd)
+	gameCmd.AddCommand(gameVoteCmd)
+	gameCmd.AddCommand(gameLeaderboardCmd)
+	gameCmd.AddCommand(gameReplayCmd)
+	gameCmd.AddCommand(gameSeedCmd)
+	gameCmd.AddCommand(gameSimulateCmd)
+	gameCmd.AddCommand(gameImportCmd)
+	gameCmd.AddCommand(gameReportCmd)
+	gameCmd.AddCommand(gameAuditCmd)
+
+	gameMatchupCmd.Flags().String("session", "", "Session ID (default: start a new session)")
+	gameMatchupCmd.Flags().String("user-country", "", "Voter's country for a new session (ISO alpha-2)")
+	gameMatchupCmd.Flags().Int64("seed", 0, "Random seed for reproducible matchups (default: time-based)")
+
+	gameVoteCmd.Flags().String("session", "", "Session ID")
+	gameVoteCmd.Flags().String("matchup", "", "Matchup ID from game matchup")
+	gameVoteCmd.Flags().String("winner", "", "ISO alpha-3 of the preferred anthem")
+	gameVoteCmd.Flags().String("loser", "", "ISO alpha-3 of the other anthem")
+	gameVoteCmd.Flags().Int64("listen-a", 0, "Milliseconds country A was heard this round")
+	gameVoteCmd.Flags().Int64("listen-b", 0, "Milliseconds country B was heard this round")
+
+	gameLeaderboardCmd.Flags().Int("limit", 50, "Number of countries to show (0 for all)")
+
+	gameReplayCmd.Flags().String("votes", "", "Vote log (JSON lines); default: the local game_votes table")
+	gameReplayCmd.Flags().StringSlice("algo", []string{"elo", "glicko2", "bradley-terry"}, "Algorithms to replay, the first ordering the rankings")
+	gameReplayCmd.Flags().Float64("k", game.K, "ELO K factor")
+	gameReplayCmd.Flags().Int64("full-listen-ms", game.FullListenMS, "Listen time each anthem needs for a full-weight vote")
+	gameReplayCmd.Flags().Float64("holdout", 0.2, "Fraction of the latest votes to predict instead of train on (0 to skip)")
+	gameReplayCmd.Flags().Int("limit", 20, "Number of countries to show (0 for all)")
+	gameReplayCmd.Flags().Bool("exclude-flagged", false, "Leave out the votes game audit flags at its default thresholds")
+	gameReplayCmd.Flags().String("exclude", "", "File of vote IDs to leave out, one per line, as game audit --output writes them")
+	gameReplayCmd.Flags().String("format", "human", "Output format: human or json")
+
+	gameSeedCmd.Flags().String("target", "sqlite", "Where to write rankings: sqlite, dynamodb or jsonl")
+	gameSeedCmd.Flags().Bool("reset", false, "Start every rating over at 1500")
+	gameSeedCmd.Flags().String("output", "", "File for --target jsonl (default: stdout)")
+	gameSeedCmd.Flags().String("endpoint", "http://localhost:4566", "DynamoDB endpoint for --target dynamodb")
+	gameSeedCmd.Flags().String("region", "us-east-1", "AWS region for --target dynamodb")
+	gameSeedCmd.Flags().String("stage", "local", "SAM stage; the table is anthem-rankings-<stage>")
+	gameSeedCmd.Flags().String("table", "", "DynamoDB table name (overrides --stage)")
+
+	d := game.DefaultSimulationOptions
+	gameSimulateCmd.Flags().Int("voters", d.Voters, "Number of simulated voters (sessions)")
+	gameSimulateCmd.Flags().Int("votes", d.Votes, "Total number of votes")
+	gameSimulateCmd.Flags().Int64("seed", d.Seed, "Random seed")
+	gameSimulateCmd.Flags().Float64("regional-bias", d.RegionalBias, "Preference for anthems of the voter's region")
+	gameSimulateCmd.Flags().Float64("popularity-bias", d.PopularityBias, "Preference for popular anthems")
+	gameSimulateCmd.Flags().Float64("noise", d.Noise, "Randomness of each judgement")
+	gameSimulateCmd.Flags().Int64("listen-ms", d.ListenMS, "Time each anthem is heard per round")
+	gameSimulateCmd.Flags().Int("checkpoints", d.Checkpoints, "Number of convergence measurements")
+	gameSimulateCmd.Flags().Float64("converged", d.Converged, "Spearman correlation counted as converged")
+	gameSimulateCmd.Flags().Int("limit", 20, "Number of countries in the rank error table (0 for all)")
+	gameSimulateCmd.Flags().String("format", "human", "Output format: human or json")
+
+	gameImportCmd.Flags().String("endpoint", "", "DynamoDB endpoint to scan, e.g. https://dynamodb.eu-west-1.amazonaws.com")
+	gameImportCmd.Flags().String("region", "us-east-1", "AWS region for --endpoint")
+	gameImportCmd.Flags().String("stage", "prod", "SAM stage; the tables are anthem-*-<stage>")
+	gameImportCmd.Flags().String("from", "", "Directory of JSON table exports instead of --endpoint")
+	gameImportCmd.Flags().Bool("replace", false, "Empty the local game tables before importing")
+	gameImportCmd.Flags().String("by", "country", "Group win rates by voter country or region")
+	gameImportCmd.Flags().Int("limit", 20, "Number of countries in the home bias table (0 for all)")
+	gameImportCmd.Flags().String("format", "human", "Output format: human or json")
+
+	gameReportCmd.Flags().String("by", "country", "Group win rates by voter country or region")
+	gameReportCmd.Flags().Int("limit", 20, "Number of countries in the home bias table (0 for all)")
+	gameReportCmd.Flags().String("format", "human", "Output format: human or json")
+
+	a := game.DefaultAuditOptions
+	gameAuditCmd.Flags().String("votes", "", "Vote log (JSON lines); default: the local game_votes table")
+	gameAuditCmd.Flags().Int64("max-listen-ms", a.MaxListenMS, "Longest plausible listen of one anthem in a round")
+	gameAuditCmd.Flags().Int("favour-votes", a.FavourVotes, "Votes on a country a session needs before it can favour it")
+	gameAuditCmd.Flags().Float64("favour-p", a.FavourP, "Chance below which a session's wins for a country are favouritism")
+	gameAuditCmd.Flags().Int("burst-votes", a.BurstVotes, "Votes from one voter country that make a burst")
+	gameAuditCmd.Flags().Duration("burst-window", a.BurstWindow, "Time within which those votes make a burst")
+	gameAuditCmd.Flags().Float64("burst-share", a.BurstShare, "Share of a burst one anthem must win for its wins to be flagged")
+	gameAuditCmd.Flags().Int("jump-points", a.JumpPoints, "ELO gain that makes a rating jump")
+	gameAuditCmd.Flags().Duration("jump-window", a.JumpWindow, "Time within which that gain makes a jump")
+	gameAuditCmd.Flags().Float64("k", a.K, "ELO K factor for rating jumps")
+	gameAuditCmd.Flags().Int("limit", 50, "Number of flags to show (0 for all)")
+	gameAuditCmd.Flags().String("output", "", "Also write the flagged vote IDs to this file, for game replay --exclude")
+	gameAuditCmd.Flags().String("format", "human", "Output format: human or json")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/jobs"
+	"github.com/anthemworld/cli/pkg/sources"
+	"github.com/spf13/cobra"
+)
+
+var dataLabelsCmd = &cobra.Command{
+	Use:   "labels",
+	Short: "Fetch translated country and anthem names from Wikidata",
+	Long: `Match countries to their Wikidata item by ISO alpha-3 code and store the
+labels of every country and anthem in the requested languages. Anthems without
+a native name get their official title from Wikidata. The labels are used by
+"data format --lang" to write translated exports. "data download" fetches them
+after the Wikidata source in the default languages; run this command to fetch
+other languages without a full download.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		langs, _ := cmd.Flags().GetStringSlice("lang")
+		if len(langs) == 0 {
+			return fmt.Errorf("--lang needs at least one language")
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		fmt.Println("=== Wikidata Labels ===")
+
+		jobID, err := jobs.CreateJob(database, "labels", map[string]interface{}{
+			"languages": langs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create job: %w", err)
+		}
+		fmt.Printf("Created job: %s\n\n", jobID)
+
+		logger := jobs.NewJobLogger(database, jobID)
+		if err := jobs.StartJob(database, jobID); err != nil {
+			return fmt.Errorf("failed to start job: %w", err)
+		}
+
+		var stats sources.LabelStats
+		err = db.WithWritingSource(database, sources.NewWikidataSource().ID(), func() error {
+			stats, err = sources.FetchWikidataLabels(context.Background(), database, langs, logger)
+			return err
+		})
+		if err != nil {
+			jobs.FailJob(database, jobID, err.Error())
+			return fmt.Errorf("label fetch failed: %w", err)
+		}
+		if err := jobs.CompleteJob(database, jobID); err != nil {
+			return fmt.Errorf("failed to complete job: %w", err)
+		}
+
+		fmt.Printf("✓ Countries linked to Wikidata: %d\n", stats.CountriesLinked)
+		fmt.Printf("✓ Labels stored: %d\n", stats.Labels)
+		fmt.Printf("✓ Native anthem titles filled: %d\n", stats.NativeNames)
+		return nil
+	},
+}
+
+func init() {
+	dataCmd.AddCommand(dataLabelsCmd)
+
+	dataLabelsCmd.Flags().StringSlice("lang", sources.DefaultLabelLanguages, "Languages to fetch")
+}
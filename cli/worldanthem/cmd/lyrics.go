@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/lyrics"
+	"github.com/spf13/cobra"
+)
+
+var dataLyricsCmd = &cobra.Command{
+	Use:   "lyrics",
+	Short: "Anthem lyrics commands",
+	Long: `Commands for managing anthem lyrics. Public-domain texts are downloaded
+from Wikisource with "worldanthem data download wikisource"; curated
+translations are imported from local files. Only public-domain and licensed
+texts are exported.`,
+}
+
+var dataLyricsImportCmd = &cobra.Command{
+	Use:   "import <file-or-dir>",
+	Short: "Import curated lyrics from local files",
+	Long: `Import a lyrics file, or every .txt file below a directory. Each file has
+"key: value" headers, a "---" line, then the verses separated by blank lines:
+
+  country: fra
+  lang: en
+  kind: translation
+  copyright: licensed
+  license: CC BY-SA 4.0
+  attribution: Translated by the Anthem World editors
+  ---
+  Arise, children of the Fatherland,
+  The day of glory has arrived!
+
+Headers: country (ISO alpha-2 or alpha-3, required), lang (required), script
+(ISO 15924, detected when omitted), kind (original, transliteration or
+translation; default translation), copyright (public-domain, licensed,
+copyrighted or unknown; default unknown, which is not exported), license,
+attribution and source-url. Imported texts replace earlier imports of the same
+anthem, language, script and kind, and are never overwritten by Wikisource.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		fmt.Println("=== Lyrics Import ===")
+
+		stats, err := lyrics.ImportPath(database, args[0])
+		if err != nil {
+			return fmt.Errorf("import failed: %w", err)
+		}
+
+		fmt.Printf("✓ Imported: %d\n", stats.Imported)
+		if len(stats.Skipped) > 0 {
+			fmt.Printf("✗ Skipped: %d\n", len(stats.Skipped))
+			for _, s := range stats.Skipped {
+				fmt.Printf("    %s\n", s)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	dataCmd.AddCommand(dataLyricsCmd)
+	dataLyricsCmd.AddCommand(dataLyricsImportCmd)
+}
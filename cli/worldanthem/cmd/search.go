@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/format"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Full-text search over anthems, composers, history and symbols",
+	Long: `Search anthem names and English titles, composers, lyricists, country names,
+anthem history and national symbols, best matches first. Accents are ignored.
+Every word must match; FTS5 query syntax can narrow the search.
+
+Needs a build with full-text search (make build, or go build -tags sqlite_fts5).
+
+Examples:
+  worldanthem search marseillaise
+  worldanthem search composer:haydn
+  worldanthem search '"god save"'
+  worldanthem search 'liber* NOT history:colonial'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		results, err := db.Search(database, strings.Join(args, " "), limit)
+		if errors.Is(err, db.ErrSearchUnavailable) {
+			return fmt.Errorf("this worldanthem was built without SQLite FTS5; rebuild with `make build` or `go build -tags sqlite_fts5`")
+		}
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RANK\tISO\tCOUNTRY\tANTHEM\tMATCH")
+		for _, r := range results {
+			fmt.Fprintf(w, "%.2f\t%s\t%s\t%s\t%s\n", -r.Rank, strings.ToUpper(r.CountryID),
+				format.Truncate(r.Country, 30), format.Truncate(orDash(r.Anthem), 40),
+				strings.Join(strings.Fields(r.Snippet), " "))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d results\n", len(results))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().Int("limit", 20, "Maximum number of results")
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the database over a local read-only HTTP API",
+	Long: `Serve countries, anthems and recordings straight from the database, as the
+records "data format" exports, so changes show up without an export step.
+
+Endpoints:
+  GET /countries              all countries (?region=, ?subregion=, ?countries=,
+                              ?has_audio=1, ?has_anthem=1, ?un_member=1)
+  GET /countries/{iso3}       one country with anthem, recordings and lyrics
+  GET /anthems/search?q=      full-text search (substring search without FTS5)
+  GET /audio/{id}             one recording
+  GET /audio/{id}/stream      the mirrored file, with Range support for seeking
+  GET /stats                  totals, as in index.json
+  GET /plays?since=           plays per country, most played first
+  GET /data/anthems.json      the file the site reads, generated live, with
+                              mirrored recordings played from their stream
+
+Responses carry an ETag and answer If-None-Match with 304. Streaming a
+recording from its first byte counts a play for its country (shown as
+play=<country> in the access log), once per client and recording every 30
+minutes; seeks are not counted. With --static, the
+directory is served for every other path, so the site's scripts run against
+the live database:
+
+  worldanthem serve --addr :8080 --static hugo/site/static`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		static, _ := cmd.Flags().GetString("static")
+		origin, _ := cmd.Flags().GetString("cors-origin")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		if static != "" {
+			if info, err := os.Stat(static); err != nil || !info.IsDir() {
+				return fmt.Errorf("static directory %s not found", static)
+			}
+		}
+
+		database, err := db.GetDB()
+		if err != nil {
+			return fmt.Errorf("failed to get database: %w", err)
+		}
+		defer database.Close()
+
+		// Build the search index now, so requests only ever read
+		fullText := db.SearchAvailable(database)
+		if fullText {
+			if err := db.EnsureSearchIndex(database); err != nil {
+				return fmt.Errorf("failed to build search index: %w", err)
+			}
+		}
+
+		opts := server.Options{StaticDir: static, AllowOrigin: origin}
+		if !quiet {
+			opts.AccessLog = os.Stdout
+		}
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           server.New(database, opts),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		fmt.Println("=== WorldAnthem API ===")
+		fmt.Printf("  Listening on %s\n", addr)
+		if static != "" {
+			fmt.Printf("  Static files: %s\n", static)
+		}
+		fmt.Printf("  Full-text search: %v\n\n", fullText)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		errc := make(chan error, 1)
+		go func() { errc <- srv.ListenAndServe() }()
+
+		select {
+		case err := <-errc:
+			return fmt.Errorf("server failed: %w", err)
+		case <-ctx.Done():
+		}
+		fmt.Println("\nShutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to shut down: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("addr", "localhost:8080", "Address to listen on (e.g. :8080 for every interface)")
+	serveCmd.Flags().String("static", "", "Also serve this directory, e.g. hugo/site/static")
+	serveCmd.Flags().String("cors-origin", "*", "Access-Control-Allow-Origin sent with every response")
+	serveCmd.Flags().Bool("quiet", false, "Do not print the access log")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/anthemworld/cli/pkg/format"
+	"github.com/spf13/cobra"
+)
+
+var dataValidateCmd = &cobra.Command{
+	Use:   "validate <dir>",
+	Short: "Validate an export directory against the export JSON Schemas",
+	Long: `Check an export directory written by "data format": index.json and every
+JSON file it lists must match the JSON Schemas generated from this build's
+export structs, every listed file must exist, and its SHA-256 must match the
+hash recorded in index.json. Exits non-zero when problems are found, so it can
+guard the website build in CI.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+
+		fmt.Println("=== Export Validation ===")
+		fmt.Printf("Directory: %s\n", dir)
+		fmt.Printf("Schema version: %d\n\n", format.ExportSchemaVersion)
+
+		problems, err := format.ValidateDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to validate export: %w", err)
+		}
+		if len(problems) == 0 {
+			fmt.Println("✓ Export is valid")
+			return nil
+		}
+
+		for _, p := range problems {
+			fmt.Printf("  ✗ %s\n", p)
+		}
+		return fmt.Errorf("%d validation problem(s) found", len(problems))
+	},
+}
+
+func init() {
+	dataCmd.AddCommand(dataValidateCmd)
+}
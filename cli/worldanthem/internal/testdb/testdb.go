@@ -0,0 +1,30 @@
+// Package testdb opens throwaway databases for tests.
+package testdb
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/anthemworld/cli/pkg/db"
+)
+
+// Open returns a fully migrated database under a temporary HOME, so code
+// under test that calls db.GetDB opens the same file, with fixture (SQL
+// statements, may be empty) applied. It is closed when the test ends.
+func Open(t testing.TB, fixture string) *sql.DB {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	database, err := db.GetDB()
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if fixture != "" {
+		if _, err := database.Exec(fixture); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+	return database
+}
@@ -0,0 +1,188 @@
+package audio
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Recording types stored in audio_recordings.type
+const (
+	TypeVocal        = "vocal"
+	TypeInstrumental = "instrumental"
+	TypeChoral       = "choral"
+	TypeBand         = "band"
+	TypePiano        = "piano"
+	TypeHistorical   = "historical"
+)
+
+// Recording quality levels stored in audio_recordings.quality
+const (
+	QualityHigh    = "high"
+	QualityMedium  = "medium"
+	QualityLow     = "low"
+	QualityUnknown = "unknown"
+)
+
+// Hints is the evidence available to classify a recording.
+// Sources are listed from most to least authoritative.
+type Hints struct {
+	Qualifiers  []string // labels of Wikidata qualifiers on the anthem's P51 (audio) statement
+	Categories  []string // Commons categories, with or without the "Category:" prefix
+	FileName    string   // Commons file title, e.g. "File:La Marseillaise.ogg"
+	Description string   // Commons ImageDescription (HTML already stripped)
+}
+
+// typeKeywords maps each type to lowercase keywords that indicate it.
+// Checked in order, so more specific types win over generic ones
+// (a "military band" file that also says "instrumental" is a band recording).
+var typeKeywords = []struct {
+	recordingType string
+	keywords      []string
+}{
+	{TypeHistorical, []string{"historical", "historic recording", "gramophone", "phonograph", "shellac", "wax cylinder", "78 rpm", "78rpm", "edison"}},
+	{TypePiano, []string{"piano"}},
+	{TypeBand, []string{"military band", "marine band", "navy band", "army band", "air force band", "brass band", "wind band", "concert band", "wind ensemble", "band"}},
+	{TypeChoral, []string{"choir", "choral", "chorus", "chorale", "chœur", "coro", "chor"}},
+	{TypeInstrumental, []string{"instrumental", "orchestra", "orchestral", "symphony", "midi", "synthesized"}},
+	{TypeVocal, []string{"vocal", "vocals", "sung", "singing", "singer", "voice", "with lyrics"}},
+}
+
+// earlyYear matches a 19th or early 20th century year, a strong signal of an archival recording.
+var earlyYear = regexp.MustCompile(`\b(18[89]\d|19[0-3]\d)\b`)
+
+// ClassifyType returns the recording type for the given hints.
+// Each source is consulted in order of authority and the first one that
+// matches a keyword decides; with no evidence at all the recording is "vocal".
+func ClassifyType(h Hints) string {
+	sources := []string{
+		strings.Join(h.Qualifiers, " "),
+		strings.Join(h.Categories, " "),
+		h.FileName,
+		h.Description,
+	}
+	for _, text := range sources {
+		if t := matchType(text); t != "" {
+			return t
+		}
+	}
+	return TypeVocal
+}
+
+func matchType(text string) string {
+	if text == "" {
+		return ""
+	}
+	lower := normalizeWords(text)
+	for _, tk := range typeKeywords {
+		for _, kw := range tk.keywords {
+			if strings.Contains(lower, " "+kw+" ") {
+				return tk.recordingType
+			}
+		}
+	}
+	if earlyYear.MatchString(lower) && strings.Contains(lower, "record") {
+		return TypeHistorical
+	}
+	return ""
+}
+
+// normalizeWords lowercases text and replaces punctuation with single spaces,
+// padding both ends so keywords can be matched on word boundaries
+// ("band" must not match "bandiera").
+func normalizeWords(text string) string {
+	var b strings.Builder
+	b.WriteByte(' ')
+	prevSpace := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			prevSpace = false
+		} else if !prevSpace {
+			b.WriteByte(' ')
+			prevSpace = true
+		}
+	}
+	if !prevSpace {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// ClassifyQuality grades a recording from its bitrate (kbit/s) and sample rate (Hz).
+// Either value may be zero when unknown.
+func ClassifyQuality(bitrateKbps, sampleRateHz int) string {
+	if bitrateKbps <= 0 && sampleRateHz <= 0 {
+		return QualityUnknown
+	}
+	// Telephone-grade sample rates cap the quality regardless of bitrate
+	if sampleRateHz > 0 && sampleRateHz < 22050 {
+		return QualityLow
+	}
+	switch {
+	case bitrateKbps >= 192:
+		return QualityHigh
+	case bitrateKbps >= 96:
+		return QualityMedium
+	case bitrateKbps > 0:
+		return QualityLow
+	case sampleRateHz >= 44100:
+		return QualityMedium
+	default:
+		return QualityLow
+	}
+}
+
+// typeRank orders recording types by how well they serve as the canonical
+// track for a country: a clean instrumental is what the map and game play.
+var typeRank = map[string]int{
+	TypeInstrumental: 6,
+	TypeBand:         5,
+	TypeVocal:        4,
+	TypeChoral:       3,
+	TypePiano:        2,
+	TypeHistorical:   1,
+}
+
+var qualityRank = map[string]int{
+	QualityHigh:   3,
+	QualityMedium: 2,
+	QualityLow:    1,
+}
+
+// Candidate is the subset of a recording needed to choose a primary track.
+type Candidate struct {
+	Type           string
+	Quality        string
+	Duration       int
+	WikidataLinked bool
+}
+
+// PreferenceScore ranks a recording for primary selection; higher is better.
+// Wikidata-linked files come first (they are the ones editors chose for the
+// anthem item), then type, then quality, and finally whether a duration is known.
+func PreferenceScore(c Candidate) int {
+	score := typeRank[c.Type]*10 + qualityRank[c.Quality]*2
+	if c.WikidataLinked {
+		score += 1000
+	}
+	if c.Duration > 0 {
+		score++
+	}
+	return score
+}
+
+// PickPrimary returns the index of the preferred recording, or -1 if there are none.
+// Ties keep the earliest candidate so the choice is stable across exports.
+func PickPrimary(candidates []Candidate) int {
+	best := -1
+	bestScore := 0
+	for i, c := range candidates {
+		s := PreferenceScore(c)
+		if best == -1 || s > bestScore {
+			best = i
+			bestScore = s
+		}
+	}
+	return best
+}
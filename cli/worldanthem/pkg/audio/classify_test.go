@@ -0,0 +1,65 @@
+package audio
+
+import "testing"
+
+func TestClassifyType(t *testing.T) {
+	tests := []struct {
+		name  string
+		hints Hints
+		want  string
+	}{
+		{"no evidence", Hints{FileName: "File:Anthem.ogg"}, TypeVocal},
+		{"filename instrumental", Hints{FileName: "File:La Marseillaise (instrumental).ogg"}, TypeInstrumental},
+		{"filename band", Hints{FileName: "File:United_States_Navy_Band_-_Il_Canto_degli_Italiani.ogg"}, TypeBand},
+		{"word boundary", Hints{FileName: "File:Inno della bandiera.ogg"}, TypeVocal},
+		{"category choral", Hints{Categories: []string{"Choral music of Estonia"}, FileName: "File:Mu isamaa.ogg"}, TypeChoral},
+		{"qualifier beats filename", Hints{Qualifiers: []string{"piano"}, FileName: "File:Anthem instrumental.ogg"}, TypePiano},
+		{"description historical", Hints{FileName: "File:Anthem.ogg", Description: "Gramophone recording, 1912"}, TypeHistorical},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyType(tt.hints); got != tt.want {
+			t.Errorf("%s: expected type '%s', got '%s'", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestClassifyQuality(t *testing.T) {
+	tests := []struct {
+		bitrate, sampleRate int
+		want                string
+	}{
+		{0, 0, QualityUnknown},
+		{256, 44100, QualityHigh},
+		{128, 44100, QualityMedium},
+		{64, 44100, QualityLow},
+		{320, 11025, QualityLow},
+		{0, 48000, QualityMedium},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyQuality(tt.bitrate, tt.sampleRate); got != tt.want {
+			t.Errorf("ClassifyQuality(%d, %d): expected '%s', got '%s'", tt.bitrate, tt.sampleRate, tt.want, got)
+		}
+	}
+}
+
+func TestPickPrimary(t *testing.T) {
+	if got := PickPrimary(nil); got != -1 {
+		t.Errorf("Expected -1 for no candidates, got %d", got)
+	}
+
+	candidates := []Candidate{
+		{Type: TypeVocal, Quality: QualityHigh},
+		{Type: TypeInstrumental, Quality: QualityLow},
+		{Type: TypeHistorical, Quality: QualityLow, WikidataLinked: true},
+	}
+	if got := PickPrimary(candidates); got != 2 {
+		t.Errorf("Expected Wikidata-linked recording (2), got %d", got)
+	}
+
+	candidates[2].WikidataLinked = false
+	if got := PickPrimary(candidates); got != 1 {
+		t.Errorf("Expected instrumental recording (1), got %d", got)
+	}
+}
@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Decoder streams PCM from an audio file
+type Decoder interface {
+	// Channels returns the channel count
+	Channels() int
+	// SampleRate returns the sample rate in Hz
+	SampleRate() int
+	// ReadFrames fills buf with interleaved samples in [-1, 1] and returns
+	// the number of whole frames read. It returns io.EOF at the end of the stream.
+	ReadFrames(buf []float64) (int, error)
+}
+
+// DecoderFactory opens a decoder over a stream positioned at the start of the file
+type DecoderFactory func(r io.Reader) (Decoder, error)
+
+// decoders maps codec names (as reported by ProbeFile) to their decoder.
+// Vorbis and MP3 register theirs in vorbis.go and mp3.go. Opus has none, as
+// there is no complete pure-Go Opus decoder, so its recordings get no
+// loudness and no derived previews.
+var decoders = map[string]DecoderFactory{
+	CodecPCM:  newWAVDecoder,
+	CodecFLAC: newFLACDecoder,
+}
+
+// RegisterDecoder adds or replaces the decoder used for a codec
+func RegisterDecoder(codec string, factory DecoderFactory) {
+	decoders[codec] = factory
+}
+
+// CanDecode reports whether a decoder is registered for the codec
+func CanDecode(codec string) bool {
+	_, ok := decoders[codec]
+	return ok
+}
+
+// NewDecoder opens a decoder for the given codec
+func NewDecoder(codec string, r io.Reader) (Decoder, error) {
+	factory, ok := decoders[codec]
+	if !ok {
+		return nil, fmt.Errorf("%w: no decoder for %s", ErrUnsupportedFormat, codec)
+	}
+	return factory(bufio.NewReader(r))
+}
+
+// decodeMono decodes a whole stream, downmixing to mono
+func decodeMono(dec Decoder) ([]float32, error) {
+	channels := dec.Channels()
+	buf := make([]float64, 4096*channels)
+	var out []float32
+	for {
+		n, err := dec.ReadFrames(buf)
+		for i := 0; i < n; i++ {
+			var sum float64
+			for c := 0; c < channels; c++ {
+				sum += buf[i*channels+c]
+			}
+			out = append(out, float32(sum/float64(channels)))
+		}
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+}
+
+// ── WAV ─────────────────────────────────────────────────────────────────────
+
+type wavDecoder struct {
+	r         io.Reader
+	format    *wavFormat
+	remaining int64
+	sample    func([]byte) float64
+	block     []byte
+}
+
+func newWAVDecoder(r io.Reader) (Decoder, error) {
+	format, dataSize, err := readWAVHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if format.channels == 0 || format.sampleRate == 0 || format.blockAlign == 0 {
+		return nil, fmt.Errorf("wav: invalid format")
+	}
+	// ReadFrames reads every channel's sample from one block
+	if format.blockAlign < format.channels*(format.bitsPerSample/8) {
+		return nil, fmt.Errorf("wav: block align %d too small for %d channels of %d bits",
+			format.blockAlign, format.channels, format.bitsPerSample)
+	}
+	sample, err := wavSampleDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	return &wavDecoder{
+		r:         r,
+		format:    format,
+		remaining: dataSize,
+		sample:    sample,
+		block:     make([]byte, format.blockAlign),
+	}, nil
+}
+
+func (d *wavDecoder) Channels() int   { return d.format.channels }
+func (d *wavDecoder) SampleRate() int { return d.format.sampleRate }
+
+func (d *wavDecoder) ReadFrames(buf []float64) (int, error) {
+	channels := d.format.channels
+	width := d.format.bitsPerSample / 8
+	frames := 0
+	for frames < len(buf)/channels {
+		if d.remaining < int64(d.format.blockAlign) {
+			return frames, io.EOF
+		}
+		if _, err := io.ReadFull(d.r, d.block); err != nil {
+			// Tolerate truncated files
+			return frames, io.EOF
+		}
+		d.remaining -= int64(d.format.blockAlign)
+		for c := 0; c < channels; c++ {
+			buf[frames*channels+c] = d.sample(d.block[c*width:])
+		}
+		frames++
+	}
+	return frames, nil
+}
+
+// wavSampleDecoder returns a function converting one little-endian sample to [-1, 1]
+func wavSampleDecoder(format *wavFormat) (func([]byte) float64, error) {
+	switch {
+	case format.audioFormat == 1 && format.bitsPerSample == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, nil
+	case format.audioFormat == 1 && format.bitsPerSample == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / 32768 }, nil
+	case format.audioFormat == 1 && format.bitsPerSample == 24:
+		return func(b []byte) float64 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float64(v) / 8388608
+		}, nil
+	case format.audioFormat == 1 && format.bitsPerSample == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648 }, nil
+	case format.audioFormat == 3 && format.bitsPerSample == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
@@ -0,0 +1,279 @@
+package audio
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// DeriveOptions controls preview clip and waveform generation
+type DeriveOptions struct {
+	ClipSeconds float64 // preview length
+	Buckets     int     // waveform resolution (min/max pairs)
+	TargetLUFS  float64 // preview loudness
+}
+
+// DefaultDeriveOptions suits the game's 3–4 second previews
+var DefaultDeriveOptions = DeriveOptions{
+	ClipSeconds: 4,
+	Buckets:     1000,
+	TargetLUFS:  -16,
+}
+
+const (
+	previewPeakCeiling = -1.0 // dBFS, true peak is not measured so keep headroom
+	previewFadeSeconds = 0.05 // fade in/out to avoid clicks at the cut
+	silenceThreshold   = 0.01 // -40 dBFS
+)
+
+// Peaks is a waveform summary in the audiowaveform JSON format (version 2),
+// so it can be rendered directly by peaks.js or wavesurfer.
+type Peaks struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"` // min, max per bucket
+}
+
+// PreviewPath returns the store-relative path of a recording's preview clip
+func PreviewPath(sha1Hex string) string {
+	return "previews/" + StorePath(sha1Hex, ".wav")
+}
+
+// PeaksPath returns the store-relative path of a recording's waveform peaks
+func PeaksPath(sha1Hex string) string {
+	return "peaks/" + StorePath(sha1Hex, ".json")
+}
+
+// ComputePeaks summarises mono samples as min/max pairs over at most buckets buckets
+func ComputePeaks(samples []float32, sampleRate, buckets int) Peaks {
+	p := Peaks{Version: 2, Channels: 1, SampleRate: sampleRate, Bits: 8}
+	if len(samples) == 0 || buckets <= 0 {
+		p.Data = []int8{}
+		return p
+	}
+	p.SamplesPerPixel = (len(samples) + buckets - 1) / buckets
+	for start := 0; start < len(samples); start += p.SamplesPerPixel {
+		end := start + p.SamplesPerPixel
+		if end > len(samples) {
+			end = len(samples)
+		}
+		lo, hi := samples[start], samples[start]
+		for _, s := range samples[start+1 : end] {
+			lo = min(lo, s)
+			hi = max(hi, s)
+		}
+		p.Data = append(p.Data, toInt8(lo), toInt8(hi))
+		p.Length++
+	}
+	return p
+}
+
+func toInt8(s float32) int8 {
+	v := math.Round(float64(s) * 127)
+	return int8(math.Max(-128, math.Min(127, v)))
+}
+
+// PreviewClip cuts a clip of the given length starting at the first non-silent
+// sample, normalises it to targetLUFS (limited so the peak stays below -1 dBFS)
+// and fades both ends.
+func PreviewClip(samples []float32, sampleRate int, seconds, targetLUFS float64) []float32 {
+	start := 0
+	for start < len(samples) && math.Abs(float64(samples[start])) < silenceThreshold {
+		start++
+	}
+	if start == len(samples) {
+		start = 0
+	}
+	end := start + int(seconds*float64(sampleRate))
+	if end > len(samples) {
+		end = len(samples)
+	}
+	clip := make([]float32, end-start)
+	copy(clip, samples[start:end])
+
+	var peak float64
+	for _, s := range clip {
+		peak = math.Max(peak, math.Abs(float64(s)))
+	}
+	if peak == 0 {
+		return clip
+	}
+
+	// Clips too short or quiet to gate fall back to peak normalisation
+	ceiling := math.Pow(10, previewPeakCeiling/20)
+	gain := ceiling / peak
+	meter := newLoudnessMeter(1, sampleRate)
+	frame := make([]float64, 1)
+	for _, s := range clip {
+		frame[0] = float64(s)
+		meter.add(frame)
+	}
+	if lufs, err := meter.integrated(); err == nil {
+		gain = math.Min(gain, math.Pow(10, (targetLUFS-lufs)/20))
+	}
+
+	fade := int(previewFadeSeconds * float64(sampleRate))
+	if fade*2 > len(clip) {
+		fade = len(clip) / 2
+	}
+	for i := range clip {
+		g := gain
+		if i < fade {
+			g *= float64(i) / float64(fade)
+		} else if n := len(clip) - 1 - i; n < fade {
+			g *= float64(n) / float64(fade)
+		}
+		clip[i] = float32(float64(clip[i]) * g)
+	}
+	return clip
+}
+
+// WriteWAV writes mono samples as a 16-bit PCM WAV file
+func WriteWAV(path string, samples []float32, sampleRate int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	dataSize := len(samples) * 2
+	hdr := make([]byte, 44)
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(36+dataSize))
+	copy(hdr[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(hdr[16:], 16)
+	binary.LittleEndian.PutUint16(hdr[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(hdr[22:], 1) // mono
+	binary.LittleEndian.PutUint32(hdr[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(hdr[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(hdr[32:], 2)
+	binary.LittleEndian.PutUint16(hdr[34:], 16)
+	copy(hdr[36:], "data")
+	binary.LittleEndian.PutUint32(hdr[40:], uint32(dataSize))
+	w.Write(hdr)
+
+	buf := make([]byte, 2)
+	for _, s := range samples {
+		v := math.Max(-1, math.Min(1, float64(s)))
+		binary.LittleEndian.PutUint16(buf, uint16(int16(math.Round(v*32767))))
+		w.Write(buf)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DeriveStats summarises a derive run
+type DeriveStats struct {
+	Derived     int
+	Unsupported int // no decoder for the codec yet
+	NotProbed   int
+	NotMirrored int
+	Failed      int
+}
+
+// DeriveRecordings writes a preview clip and waveform peaks for every mirrored,
+// probed recording whose codec can be decoded. Files are written under dest
+// (the mirror store) and their store-relative paths saved on the recording.
+func DeriveRecordings(db *sql.DB, dest string, opts DeriveOptions, logf func(format string, args ...interface{})) (DeriveStats, error) {
+	var stats DeriveStats
+
+	rows, err := db.Query(`
+		SELECT id, title, COALESCE(local_path,''), COALESCE(sha1,''), COALESCE(codec,'')
+		FROM audio_recordings ORDER BY country_id, id
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("query recordings: %w", err)
+	}
+	type pending struct{ id, title, localPath, sha1, codec string }
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.title, &p.localPath, &p.sha1, &p.codec); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	for _, p := range todo {
+		switch {
+		case p.localPath == "" || p.sha1 == "":
+			stats.NotMirrored++
+			continue
+		case p.codec == "":
+			stats.NotProbed++
+			continue
+		case !CanDecode(p.codec):
+			stats.Unsupported++
+			continue
+		}
+
+		previewRel, peaksRel := PreviewPath(p.sha1), PeaksPath(p.sha1)
+		if err := deriveOne(p.localPath, p.codec, dest, previewRel, peaksRel, opts); err != nil {
+			logf("✗ %s: %v", p.title, err)
+			stats.Failed++
+			continue
+		}
+
+		if _, err := db.Exec(`UPDATE audio_recordings SET preview_path = ?, peaks_path = ? WHERE id = ?`,
+			previewRel, peaksRel, p.id); err != nil {
+			return stats, fmt.Errorf("update %s: %w", p.id, err)
+		}
+		logf("✓ %s", p.title)
+		stats.Derived++
+	}
+	return stats, nil
+}
+
+func deriveOne(localPath, codec, dest, previewRel, peaksRel string, opts DeriveOptions) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := NewDecoder(codec, f)
+	if err != nil {
+		return err
+	}
+	samples, err := decodeMono(dec)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	rate := dec.SampleRate()
+
+	previewFile := filepath.Join(dest, filepath.FromSlash(previewRel))
+	if err := os.MkdirAll(filepath.Dir(previewFile), 0755); err != nil {
+		return err
+	}
+	clip := PreviewClip(samples, rate, opts.ClipSeconds, opts.TargetLUFS)
+	if err := WriteWAV(previewFile, clip, rate); err != nil {
+		return fmt.Errorf("write preview: %w", err)
+	}
+
+	peaksFile := filepath.Join(dest, filepath.FromSlash(peaksRel))
+	if err := os.MkdirAll(filepath.Dir(peaksFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ComputePeaks(samples, rate, opts.Buckets))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(peaksFile, data, 0644)
+}
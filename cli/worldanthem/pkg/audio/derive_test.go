@@ -0,0 +1,251 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// bitWriter packs big-endian bit fields for the test FLAC encoder
+type bitWriter struct {
+	buf   []byte
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.nbits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			w.buf[len(w.buf)-1] |= 1 << (7 - w.nbits%8)
+		}
+		w.nbits++
+	}
+}
+
+func (w *bitWriter) align() {
+	w.nbits = uint(len(w.buf)) * 8
+}
+
+// encodeTestFLAC encodes 16-bit stereo as left/side frames with FIXED order 2
+// subframes and Rice-coded residuals, exercising the decoder's main paths.
+func encodeTestFLAC(left, right []int64, sampleRate, blockSize int) []byte {
+	w := &bitWriter{}
+	w.write(0x664C6143, 32)
+
+	// STREAMINFO, last metadata block
+	w.write(1, 1)
+	w.write(0, 7)
+	w.write(34, 24)
+	w.write(uint64(blockSize), 16)
+	w.write(uint64(blockSize), 16)
+	w.write(0, 24)
+	w.write(0, 24)
+	w.write(uint64(sampleRate), 20)
+	w.write(1, 3)  // 2 channels
+	w.write(15, 5) // 16 bits
+	w.write(uint64(len(left)), 36)
+	w.write(0, 64)
+	w.write(0, 64)
+
+	for frame, start := 0, 0; start < len(left); frame, start = frame+1, start+blockSize {
+		end := min(start+blockSize, len(left))
+		n := end - start
+		w.write(0x3FFE, 14)
+		w.write(0, 2)
+		w.write(7, 4) // 16-bit block size at end of header
+		w.write(0, 4) // sample rate from STREAMINFO
+		w.write(8, 4) // left/side
+		w.write(4, 3) // 16 bits
+		w.write(0, 1)
+		w.write(uint64(frame), 8)
+		w.write(uint64(n-1), 16)
+		w.write(0, 8) // CRC-8, not checked
+
+		side := make([]int64, n)
+		for i := range side {
+			side[i] = left[start+i] - right[start+i]
+		}
+		writeFixed2(w, left[start:end], 16)
+		writeFixed2(w, side, 17)
+
+		w.align()
+		w.write(0, 16) // CRC-16, not checked
+	}
+	return w.buf
+}
+
+func writeFixed2(w *bitWriter, x []int64, bps int) {
+	w.write(0, 1)
+	w.write(8+2, 6)
+	w.write(0, 1)
+	for i := 0; i < 2; i++ {
+		w.write(uint64(x[i])&(1<<uint(bps)-1), bps)
+	}
+	w.write(0, 2) // Rice, 4-bit parameters
+	w.write(0, 4) // one partition
+	const param = 6
+	w.write(param, 4)
+	for i := 2; i < len(x); i++ {
+		res := x[i] - 2*x[i-1] + x[i-2]
+		u := uint64(res<<1 ^ (res >> 63))
+		w.write(0, int(u>>param))
+		w.write(1, 1)
+		w.write(u&(1<<param-1), param)
+	}
+}
+
+func TestFLACDecoder(t *testing.T) {
+	const rate, frames = 44100, 10000
+	left := make([]int64, frames)
+	right := make([]int64, frames)
+	for i := range left {
+		left[i] = int64(8000 * math.Sin(2*math.Pi*440*float64(i)/rate))
+		right[i] = int64(-6000 * math.Sin(2*math.Pi*660*float64(i)/rate))
+	}
+
+	dec, err := NewDecoder(CodecFLAC, bytes.NewReader(encodeTestFLAC(left, right, rate, 4096)))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	if dec.Channels() != 2 || dec.SampleRate() != rate {
+		t.Fatalf("Expected 2 channels at %d Hz, got %d at %d", rate, dec.Channels(), dec.SampleRate())
+	}
+
+	buf := make([]float64, 2*frames+64)
+	n, err := dec.ReadFrames(buf)
+	if n != frames {
+		t.Fatalf("Expected %d frames, got %d (err %v)", frames, n, err)
+	}
+	for i := 0; i < frames; i++ {
+		if got := int64(buf[2*i] * 32768); got != left[i] {
+			t.Fatalf("Left sample %d: expected %d, got %d", i, left[i], got)
+		}
+		if got := int64(buf[2*i+1] * 32768); got != right[i] {
+			t.Fatalf("Right sample %d: expected %d, got %d", i, right[i], got)
+		}
+	}
+}
+
+func TestPreviewClipAndPeaks(t *testing.T) {
+	const rate = 48000
+	// One second of silence, then a quiet tone
+	samples := make([]float32, rate*10)
+	for i := rate; i < len(samples); i++ {
+		samples[i] = float32(0.05 * math.Sin(2*math.Pi*440*float64(i)/rate))
+	}
+
+	clip := PreviewClip(samples, rate, 4, -16)
+	if len(clip) != 4*rate {
+		t.Fatalf("Expected %d samples, got %d", 4*rate, len(clip))
+	}
+	if clip[0] != 0 {
+		t.Errorf("Expected clip to fade in from 0, got %f", clip[0])
+	}
+
+	meter := newLoudnessMeter(1, rate)
+	for _, s := range clip {
+		meter.add([]float64{float64(s)})
+	}
+	lufs, err := meter.integrated()
+	if err != nil {
+		t.Fatalf("Failed to measure clip: %v", err)
+	}
+	if math.Abs(lufs-(-16)) > 0.2 {
+		t.Errorf("Expected clip loudness -16 LUFS, got %.2f", lufs)
+	}
+
+	// A click train is far louder in peak than in loudness, so the ceiling applies
+	clicks := make([]float32, rate*4)
+	for i := 0; i < len(clicks); i += rate / 2 {
+		clicks[i] = 0.5
+	}
+	var peak float64
+	for _, s := range PreviewClip(clicks, rate, 4, -16) {
+		peak = math.Max(peak, math.Abs(float64(s)))
+	}
+	if math.Abs(peak-math.Pow(10, -1.0/20)) > 1e-3 {
+		t.Errorf("Expected peak at -1 dBFS, got %.4f", peak)
+	}
+
+	peaks := ComputePeaks(samples, rate, 100)
+	if peaks.Length != 100 || len(peaks.Data) != 200 {
+		t.Errorf("Expected 100 buckets, got length %d with %d values", peaks.Length, len(peaks.Data))
+	}
+	if peaks.Data[0] != 0 || peaks.Data[1] != 0 {
+		t.Errorf("Expected silent first bucket, got %d,%d", peaks.Data[0], peaks.Data[1])
+	}
+	if peaks.Data[len(peaks.Data)-1] != 6 {
+		t.Errorf("Expected last bucket max 6, got %d", peaks.Data[len(peaks.Data)-1])
+	}
+}
+
+// encodeCorruptFLAC writes a mono 16-bit stream of one frame of blockSize
+// samples whose subframe header is kind, followed by the given fields
+func encodeCorruptFLAC(blockSize int, kind uint64, fields func(w *bitWriter)) []byte {
+	w := &bitWriter{}
+	w.write(0x664C6143, 32)
+	w.write(1, 1)
+	w.write(0, 7)
+	w.write(34, 24)
+	w.write(uint64(blockSize), 16)
+	w.write(uint64(blockSize), 16)
+	w.write(0, 48)
+	w.write(44100, 20)
+	w.write(0, 3)  // 1 channel
+	w.write(15, 5) // 16 bits
+	w.write(uint64(blockSize), 36)
+	w.write(0, 64)
+	w.write(0, 64)
+
+	w.write(0x3FFE, 14)
+	w.write(0, 2)
+	w.write(6, 4) // 8-bit block size at end of header
+	w.write(0, 4)
+	w.write(0, 4) // mono
+	w.write(4, 3)
+	w.write(0, 1)
+	w.write(0, 8)
+	w.write(uint64(blockSize-1), 8)
+	w.write(0, 8)
+
+	w.write(0, 1)
+	w.write(kind, 6)
+	w.write(0, 1)
+	fields(w)
+	w.write(0, 256)
+	return w.buf
+}
+
+func TestFLACDecoderCorrupt(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"FIXED order above the block size", encodeCorruptFLAC(2, 8+4, func(w *bitWriter) {})},
+		{"LPC order above the block size", encodeCorruptFLAC(3, 32+7, func(w *bitWriter) {})},
+		{"partitions smaller than the order", encodeCorruptFLAC(16, 8+2, func(w *bitWriter) {
+			w.write(0, 32) // warm-up samples
+			w.write(0, 2)  // Rice
+			w.write(4, 4)  // 16 partitions of one sample
+		})},
+	} {
+		dec, err := NewDecoder(CodecFLAC, bytes.NewReader(tc.data))
+		if err != nil {
+			t.Fatalf("%s: NewDecoder failed: %v", tc.name, err)
+		}
+		buf := make([]float64, 64)
+		if _, err := dec.ReadFrames(buf); err == nil || err == io.EOF {
+			t.Errorf("%s: expected a decode error, got %v", tc.name, err)
+		}
+	}
+
+	// STREAMINFO without a sample rate
+	data := encodeCorruptFLAC(2, 0, func(w *bitWriter) {})
+	data[18], data[19], data[20] = 0, 0, data[20]&0x0F
+	if _, err := NewDecoder(CodecFLAC, bytes.NewReader(data)); err == nil {
+		t.Error("Expected an error for a zero sample rate")
+	}
+}
@@ -0,0 +1,33 @@
+package audio
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CanonicalTitle normalises a Commons file title the way MediaWiki does:
+// no "File:" namespace prefix, underscores as spaces, single spaces and an
+// upper-case first letter. "File:la_marseillaise.ogg" and
+// "La Marseillaise.ogg" are the same file.
+func CanonicalTitle(title string) string {
+	title = strings.TrimSpace(title)
+	for _, prefix := range []string{"File:", "file:", "Image:", "image:"} {
+		title = strings.TrimPrefix(title, prefix)
+	}
+	title = strings.Join(strings.Fields(strings.ReplaceAll(title, "_", " ")), " ")
+	if r, size := utf8.DecodeRuneInString(title); r != utf8.RuneError {
+		title = string(unicode.ToUpper(r)) + title[size:]
+	}
+	return title
+}
+
+// RecordingID derives a stable audio_recordings.id from the source and the
+// canonical file title, so re-downloading the same file always yields the
+// same ID and references to it survive a rebuild.
+func RecordingID(source, title string) string {
+	sum := sha1.Sum([]byte(source + "\x00" + CanonicalTitle(title)))
+	return "rec-" + hex.EncodeToString(sum[:])[:16]
+}
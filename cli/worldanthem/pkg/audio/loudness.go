@@ -0,0 +1,194 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// loudnessMeter measures integrated loudness following ITU-R BS.1770-4 /
+// EBU R128: K-weighting, 400 ms blocks with 75% overlap, an absolute gate at
+// -70 LUFS and a relative gate 10 LU below the ungated level.
+type loudnessMeter struct {
+	channels   int
+	weights    []float64
+	filters    []kWeighting
+	blockSize  int // samples per 400 ms block
+	stepSize   int // samples per 100 ms hop
+	window     [][]float64
+	filled     int
+	sinceBlock int
+	blocks     []float64 // mean-square energy per gated block
+}
+
+// kWeighting is the two-stage pre-filter (high shelf + high pass) for one channel
+type kWeighting struct {
+	stages [2]biquad
+}
+
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (q *biquad) process(x float64) float64 {
+	y := q.b0*x + q.z1
+	q.z1 = q.b1*x - q.a1*y + q.z2
+	q.z2 = q.b2*x - q.a2*y
+	return y
+}
+
+// newKWeighting computes the BS.1770 filter coefficients for any sample rate
+// (the standard only tabulates 48 kHz), as libebur128 does.
+func newKWeighting(sampleRate int) kWeighting {
+	rate := float64(sampleRate)
+
+	f0 := 1681.974450955533
+	g := 3.999843853973347
+	q := 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return kWeighting{stages: [2]biquad{shelf, highPass}}
+}
+
+func newLoudnessMeter(channels, sampleRate int) *loudnessMeter {
+	m := &loudnessMeter{
+		channels:  channels,
+		weights:   make([]float64, channels),
+		filters:   make([]kWeighting, channels),
+		blockSize: max(sampleRate*4/10, 1), // at least one sample, whatever the rate
+		stepSize:  max(sampleRate/10, 1),
+		window:    make([][]float64, channels),
+	}
+	for c := 0; c < channels; c++ {
+		m.filters[c] = newKWeighting(sampleRate)
+		m.window[c] = make([]float64, m.blockSize)
+		m.weights[c] = 1
+	}
+	// 5.1 layouts: LFE is excluded, surrounds are weighted +1.5 dB
+	if channels == 6 {
+		m.weights[3] = 0
+		m.weights[4] = 1.41
+		m.weights[5] = 1.41
+	}
+	return m
+}
+
+// add feeds one frame of samples (one per channel, in [-1, 1])
+func (m *loudnessMeter) add(frame []float64) {
+	pos := m.filled % m.blockSize
+	for c := 0; c < m.channels; c++ {
+		y := frame[c]
+		for s := range m.filters[c].stages {
+			y = m.filters[c].stages[s].process(y)
+		}
+		m.window[c][pos] = y * y
+	}
+	m.filled++
+	m.sinceBlock++
+	if m.filled >= m.blockSize && m.sinceBlock >= m.stepSize {
+		m.sinceBlock = 0
+		var energy float64
+		for c := 0; c < m.channels; c++ {
+			var sum float64
+			for _, v := range m.window[c] {
+				sum += v
+			}
+			energy += m.weights[c] * sum / float64(m.blockSize)
+		}
+		m.blocks = append(m.blocks, energy)
+	}
+}
+
+// integrated returns the gated integrated loudness in LUFS
+func (m *loudnessMeter) integrated() (float64, error) {
+	const absoluteGate = -70.0
+	var gated []float64
+	for _, e := range m.blocks {
+		if energyToLUFS(e) > absoluteGate {
+			gated = append(gated, e)
+		}
+	}
+	if len(gated) == 0 {
+		return 0, errors.New("loudness: audio is silent or shorter than 400 ms")
+	}
+
+	relativeGate := energyToLUFS(mean(gated)) - 10
+	var sum float64
+	n := 0
+	for _, e := range gated {
+		if energyToLUFS(e) > relativeGate {
+			sum += e
+			n++
+		}
+	}
+	return energyToLUFS(sum / float64(n)), nil
+}
+
+func energyToLUFS(e float64) float64 {
+	return -0.691 + 10*math.Log10(e)
+}
+
+func mean(xs []float64) float64 {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// measureLoudness decodes r and returns its integrated loudness.
+// It fails with ErrUnsupportedFormat when no decoder is registered for codec.
+func measureLoudness(r io.Reader, codec string) (float64, error) {
+	dec, err := NewDecoder(codec, r)
+	if err != nil {
+		return 0, err
+	}
+	return integratedLoudness(dec)
+}
+
+// integratedLoudness runs a decoder to the end through a loudness meter
+func integratedLoudness(dec Decoder) (float64, error) {
+	channels := dec.Channels()
+	// The meter needs at least one sample per 100 ms step
+	if channels < 1 || dec.SampleRate() < 10 {
+		return 0, fmt.Errorf("loudness: invalid stream of %d channels at %d Hz", channels, dec.SampleRate())
+	}
+	meter := newLoudnessMeter(channels, dec.SampleRate())
+	buf := make([]float64, 4096*channels)
+	for {
+		n, err := dec.ReadFrames(buf)
+		for i := 0; i < n; i++ {
+			meter.add(buf[i*channels : (i+1)*channels])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return meter.integrated()
+}
@@ -0,0 +1,45 @@
+package audio
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// contentTypes maps audio_recordings.format values that are file extensions
+// to their media type. Go's mime table misses several of them.
+var contentTypes = map[string]string{
+	"ogg":  "audio/ogg",
+	"oga":  "audio/ogg",
+	"opus": "audio/ogg; codecs=opus",
+	"mp3":  "audio/mpeg",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"webm": "audio/webm",
+	"m4a":  "audio/mp4",
+	"aac":  "audio/aac",
+	"mid":  "audio/midi",
+	"midi": "audio/midi",
+}
+
+// ContentType returns the media type to serve a recording with. The format
+// column holds a MIME type for Wikimedia Commons files ("audio/ogg") and an
+// extension for other sources ("mp3"); the local file's extension is the
+// fallback.
+func ContentType(format, localPath string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if strings.Contains(format, "/") {
+		return format
+	}
+	if t, ok := contentTypes[strings.TrimPrefix(format, ".")]; ok {
+		return t
+	}
+	ext := strings.ToLower(filepath.Ext(localPath))
+	if t, ok := contentTypes[strings.TrimPrefix(ext, ".")]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,510 @@
+package audio
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const commonsAPIURL = "https://commons.wikimedia.org/w/api.php"
+
+// StorePath returns the content-addressed location of a file inside the
+// mirror, relative to its root: "<sha1[:2]>/<sha1><ext>".
+func StorePath(sha1Hex, ext string) string {
+	return path.Join(sha1Hex[:2], sha1Hex+strings.ToLower(ext))
+}
+
+// URLExt returns the extension of a URL's path, leaving out its query and
+// fragment, as used in the store path of the file it points to
+func URLExt(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Ext(u.Path)
+}
+
+// Mirror downloads audio_recordings into a local content-addressed store
+type Mirror struct {
+	Dest       string       // store root directory
+	Workers    int          // parallel downloads
+	Client     *http.Client // HTTP client for downloads and API calls
+	CommonsAPI string       // MediaWiki API used to look up expected SHA-1s
+	Logf       func(format string, args ...interface{})
+}
+
+// MirrorStats summarises a mirror run
+type MirrorStats struct {
+	Downloaded int
+	Skipped    int // already mirrored and verified
+	Failed     int
+}
+
+type mirrorJob struct {
+	id     string
+	title  string
+	url    string
+	source string
+
+	// What the last run stored, to skip unchanged files of sources without
+	// published SHA-1s
+	localPath    string
+	sha1         string
+	etag         string
+	lastModified string
+	size         int64
+}
+
+type mirrorResult struct {
+	job          mirrorJob
+	localPath    string
+	sha1         string
+	etag         string
+	lastModified string
+	size         int64
+	skipped      bool
+	err          error
+}
+
+// NewMirror creates a mirror rooted at dest with sensible defaults
+func NewMirror(dest string, workers int) *Mirror {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Mirror{
+		Dest:       dest,
+		Workers:    workers,
+		Client:     &http.Client{Timeout: 10 * time.Minute},
+		CommonsAPI: commonsAPIURL,
+		Logf:       func(string, ...interface{}) {},
+	}
+}
+
+// Run mirrors every audio recording and records local_path and sha1.
+// Files already present with the expected checksum are skipped and partial
+// downloads are resumed, so interrupted runs can simply be restarted. For
+// sources without a published SHA-1 the file is skipped when the server
+// answers the ETag or Last-Modified of the last download with 304, or,
+// without either, reports the same size.
+func (m *Mirror) Run(ctx context.Context, db *sql.DB) (MirrorStats, error) {
+	var stats MirrorStats
+
+	rows, err := db.Query(`
+		SELECT id, title, url, COALESCE(source,''), COALESCE(local_path,''), COALESCE(sha1,''),
+			COALESCE(source_etag,''), COALESCE(source_last_modified,''), COALESCE(source_size,0)
+		FROM audio_recordings
+		ORDER BY country_id, id
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("query recordings: %w", err)
+	}
+	var pending []mirrorJob
+	for rows.Next() {
+		var j mirrorJob
+		if err := rows.Scan(&j.id, &j.title, &j.url, &j.source, &j.localPath, &j.sha1,
+			&j.etag, &j.lastModified, &j.size); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		pending = append(pending, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	if err := os.MkdirAll(m.Dest, 0755); err != nil {
+		return stats, fmt.Errorf("create store: %w", err)
+	}
+	m.Logf("Mirroring %d recordings into %s with %d workers", len(pending), m.Dest, m.Workers)
+
+	jobsCh := make(chan mirrorJob)
+	results := make(chan mirrorResult)
+	var wg sync.WaitGroup
+	for i := 0; i < m.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobsCh {
+				results <- m.mirrorOne(ctx, j)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobsCh)
+		for _, j := range pending {
+			select {
+			case jobsCh <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Database writes stay on this goroutine; SQLite serialises writers anyway
+	for r := range results {
+		if r.err != nil {
+			m.Logf("✗ %s: %v", r.job.title, r.err)
+			stats.Failed++
+			continue
+		}
+		_, err := db.Exec(`
+			UPDATE audio_recordings SET local_path = ?, sha1 = ?,
+				source_etag = NULLIF(?, ''), source_last_modified = NULLIF(?, ''), source_size = ?
+			WHERE id = ?
+		`, r.localPath, r.sha1, r.etag, r.lastModified, r.size, r.job.id)
+		if err != nil {
+			m.Logf("✗ %s: update: %v", r.job.title, err)
+			stats.Failed++
+			continue
+		}
+		if r.skipped {
+			stats.Skipped++
+		} else {
+			m.Logf("✓ %s", r.job.title)
+			stats.Downloaded++
+		}
+	}
+
+	return stats, ctx.Err()
+}
+
+func (m *Mirror) mirrorOne(ctx context.Context, j mirrorJob) mirrorResult {
+	res := mirrorResult{job: j}
+	ext := strings.ToLower(URLExt(j.url))
+
+	// Commons publishes the SHA-1 of every file; other sources are trusted as-is
+	var expected string
+	if j.source == "wikimedia-commons" {
+		sum, err := m.commonsSHA1(ctx, j.title)
+		if err != nil {
+			res.err = fmt.Errorf("lookup sha1: %w", err)
+			return res
+		}
+		expected = sum
+		dest := filepath.Join(m.Dest, filepath.FromSlash(StorePath(expected, ext)))
+		if got, err := fileSHA1(dest); err == nil && got == expected {
+			res.localPath, res.sha1, res.skipped = dest, expected, true
+			res.etag, res.lastModified, res.size = j.etag, j.lastModified, j.size
+			return res
+		}
+	} else if j.localPath != "" {
+		if got, err := fileSHA1(j.localPath); err == nil && got == j.sha1 {
+			unchanged, err := m.unchanged(ctx, j)
+			if err != nil {
+				res.err = err
+				return res
+			}
+			if unchanged {
+				res.localPath, res.sha1, res.skipped = j.localPath, j.sha1, true
+				res.etag, res.lastModified, res.size = j.etag, j.lastModified, j.size
+				return res
+			}
+		}
+	}
+
+	part := filepath.Join(m.Dest, "tmp", j.id+".part")
+	var err error
+	if err = os.MkdirAll(filepath.Dir(part), 0755); err != nil {
+		res.err = err
+		return res
+	}
+	if res.etag, res.lastModified, err = m.download(ctx, j.url, part); err != nil {
+		res.err = err
+		return res
+	}
+
+	got, err := fileSHA1(part)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if fi, err := os.Stat(part); err == nil {
+		res.size = fi.Size()
+	}
+	if expected != "" && got != expected {
+		removePart(part)
+		res.err = fmt.Errorf("checksum mismatch: expected %s, got %s", expected, got)
+		return res
+	}
+
+	dest := filepath.Join(m.Dest, filepath.FromSlash(StorePath(got, ext)))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		res.err = err
+		return res
+	}
+	if err := os.Rename(part, dest); err != nil {
+		res.err = err
+		return res
+	}
+	os.Remove(part + ".json")
+	res.localPath, res.sha1 = dest, got
+	return res
+}
+
+// unchanged asks the source whether the file of the last run is still
+// current: with a conditional HEAD when an ETag or Last-Modified was
+// stored, else by comparing Content-Length with the stored size. Without
+// any of them the file counts as changed.
+func (m *Mirror) unchanged(ctx context.Context, j mirrorJob) (bool, error) {
+	if j.etag == "" && j.lastModified == "" && j.size == 0 {
+		return false, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, j.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	if j.etag != "" {
+		req.Header.Set("If-None-Match", j.etag)
+	}
+	if j.lastModified != "" {
+		req.Header.Set("If-Modified-Since", j.lastModified)
+	}
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return true, nil
+	case resp.StatusCode != http.StatusOK, j.etag != "" || j.lastModified != "":
+		// Either HEAD is not supported, and the GET will tell, or the
+		// server judged the validators stale
+		return false, nil
+	}
+	return resp.ContentLength >= 0 && resp.ContentLength == j.size, nil
+}
+
+// download fetches rawURL into partPath. A part file left by an interrupted
+// run is resumed only when the validator it was fetched with is known: the
+// Range request carries it as If-Range, so a file that changed upstream is
+// sent whole instead of being spliced onto the old bytes. It returns the
+// response's ETag and Last-Modified.
+func (m *Mirror) download(ctx context.Context, rawURL, partPath string) (etag, lastModified string, err error) {
+	var offset int64
+	state, haveState := readPartState(partPath)
+	if fi, err := os.Stat(partPath); err == nil && haveState && state.ifRange() != "" {
+		offset = fi.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", state.ifRange())
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	etag, lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, total := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset == 0 || start != offset || (state.Size > 0 && total >= 0 && total != state.Size) {
+			return m.restartDownload(ctx, rawURL, partPath, offset)
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// A whole file: the server ignored Range, or If-Range found the
+		// file changed. Record what it is before writing any of it.
+		flags |= os.O_TRUNC
+		state = partState{ETag: etag, LastModified: lastModified}
+		if resp.ContentLength > 0 {
+			state.Size = resp.ContentLength
+		}
+		if err := writePartState(partPath, state); err != nil {
+			return "", "", err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The part file is complete only if it is as long as the file
+		_, total := parseContentRange(resp.Header.Get("Content-Range"))
+		if total < 0 {
+			total = state.Size
+		}
+		if offset == 0 || total != offset {
+			return m.restartDownload(ctx, rawURL, partPath, offset)
+		}
+		if etag == "" && lastModified == "" {
+			etag, lastModified = state.ETag, state.LastModified
+		}
+		return etag, lastModified, nil
+	default:
+		return "", "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return "", "", err
+	}
+	return etag, lastModified, f.Close()
+}
+
+// restartDownload drops a part file whose bytes cannot be shown to belong to
+// the file being served and downloads it again from the start
+func (m *Mirror) restartDownload(ctx context.Context, rawURL, partPath string, offset int64) (string, string, error) {
+	if offset == 0 {
+		return "", "", errors.New("unexpected range response to a full download")
+	}
+	if err := removePart(partPath); err != nil {
+		return "", "", err
+	}
+	return m.download(ctx, rawURL, partPath)
+}
+
+// partState records which version of a file a part file holds, next to it
+// as "<part>.json", so an interrupted download resumes only onto its own bytes
+type partState struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Size         int64  `json:"size,omitempty"` // whole file, 0 when unknown
+}
+
+// ifRange returns the validator to send as If-Range: a strong ETag, else
+// Last-Modified (weak ETags are not allowed there)
+func (s partState) ifRange() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+func readPartState(partPath string) (partState, bool) {
+	var s partState
+	data, err := os.ReadFile(partPath + ".json")
+	if err != nil || json.Unmarshal(data, &s) != nil {
+		return partState{}, false
+	}
+	return s, true
+}
+
+func writePartState(partPath string, s partState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partPath+".json", data, 0644)
+}
+
+// removePart deletes a part file and its state
+func removePart(partPath string) error {
+	for _, p := range []string{partPath, partPath + ".json"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseContentRange reads "bytes <start>-<end>/<total>" or "bytes */<total>".
+// Missing or unknown parts are -1.
+func parseContentRange(v string) (start, total int64) {
+	start, total = -1, -1
+	spec, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return
+	}
+	if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+		total = n
+	}
+	if first, _, ok := strings.Cut(rng, "-"); ok {
+		if n, err := strconv.ParseInt(first, 10, 64); err == nil {
+			start = n
+		}
+	}
+	return
+}
+
+// commonsSHA1 returns the SHA-1 Commons reports for a file title
+func (m *Mirror) commonsSHA1(ctx context.Context, title string) (string, error) {
+	if !strings.HasPrefix(title, "File:") {
+		title = "File:" + title
+	}
+	apiURL := fmt.Sprintf("%s?action=query&titles=%s&prop=imageinfo&iiprop=sha1&format=json",
+		m.CommonsAPI, url.QueryEscape(title))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Query struct {
+			Pages map[string]struct {
+				ImageInfo []struct {
+					SHA1 string `json:"sha1"`
+				} `json:"imageinfo"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	for _, page := range result.Query.Pages {
+		if len(page.ImageInfo) > 0 && page.ImageInfo[0].SHA1 != "" {
+			return strings.ToLower(page.ImageInfo[0].SHA1), nil
+		}
+	}
+	return "", fmt.Errorf("no sha1 found for %s", title)
+}
+
+func fileSHA1(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,291 @@
+package audio
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSource serves one audio file at /a.ogg, with an ETag and optionally
+// byte ranges, and the Commons imageinfo API at /api.php reporting sha1
+type testSource struct {
+	mu      sync.Mutex
+	body    string
+	sha1    string
+	etag    string
+	gets    int
+	missing bool
+	ranges  bool     // honour Range, checking If-Range against etag
+	asked   []string // "Range If-Range" of each GET
+}
+
+func (s *testSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/api.php":
+		fmt.Fprintf(w, `{"query":{"pages":{"1":{"imageinfo":[{"sha1":%q}]}}}}`, s.sha1)
+	case "/a.ogg":
+		if s.missing {
+			http.NotFound(w, r)
+			return
+		}
+		if s.etag != "" {
+			w.Header().Set("ETag", s.etag)
+			if r.Header.Get("If-None-Match") == s.etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", fmt.Sprint(len(s.body)))
+			return
+		}
+		s.gets++
+		s.asked = append(s.asked, strings.TrimSpace(r.Header.Get("Range")+" "+r.Header.Get("If-Range")))
+		var offset int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset); err == nil && s.ranges &&
+			r.Header.Get("If-Range") == s.etag {
+			if offset >= len(s.body) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(s.body)))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(s.body)-1, len(s.body)))
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, s.body[offset:])
+			return
+		}
+		fmt.Fprint(w, s.body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *testSource) downloads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func newTestMirror(t *testing.T, src *testSource) (*Mirror, string) {
+	srv := httptest.NewServer(src)
+	t.Cleanup(srv.Close)
+	m := NewMirror(t.TempDir(), 1)
+	m.CommonsAPI = srv.URL + "/api.php"
+	return m, srv.URL + "/a.ogg"
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestMirrorDownload(t *testing.T) {
+	src := &testSource{body: "OggS anthem", etag: `"v1"`}
+	m, fileURL := newTestMirror(t, src)
+
+	res := m.mirrorOne(context.Background(), mirrorJob{id: "a", title: "A", url: fileURL, source: "example"})
+	if res.err != nil {
+		t.Fatalf("mirrorOne failed: %v", res.err)
+	}
+	if res.skipped {
+		t.Error("Expected a download, got a skip")
+	}
+	if res.sha1 != sha1Hex(src.body) {
+		t.Errorf("Expected sha1 %s, got %s", sha1Hex(src.body), res.sha1)
+	}
+	if want := filepath.Join(m.Dest, filepath.FromSlash(StorePath(res.sha1, ".ogg"))); res.localPath != want {
+		t.Errorf("Expected local path %s, got %s", want, res.localPath)
+	}
+	data, err := os.ReadFile(res.localPath)
+	if err != nil || string(data) != src.body {
+		t.Errorf("Expected the file in the store, got %q (%v)", data, err)
+	}
+	if res.etag != `"v1"` || res.size != int64(len(src.body)) {
+		t.Errorf("Expected ETag \"v1\" and size %d, got %s and %d", len(src.body), res.etag, res.size)
+	}
+	if _, err := os.Stat(filepath.Join(m.Dest, "tmp", "a.part")); !os.IsNotExist(err) {
+		t.Error("Expected the part file to be moved into the store")
+	}
+}
+
+func TestURLExt(t *testing.T) {
+	tests := map[string]string{
+		"https://upload.wikimedia.org/a/ab/Anthem.ogg": ".ogg",
+		"https://example.org/anthem.OGG?download=1":    ".OGG",
+		"https://example.org/anthem.mp3#t=10":          ".mp3",
+		"https://example.org/play?file=anthem.ogg":     "",
+	}
+	for rawURL, want := range tests {
+		if got := URLExt(rawURL); got != want {
+			t.Errorf("URLExt(%q): expected %q, got %q", rawURL, want, got)
+		}
+	}
+
+	src := &testSource{body: "OggS anthem"}
+	m, fileURL := newTestMirror(t, src)
+	res := m.mirrorOne(context.Background(), mirrorJob{id: "a", title: "A", url: fileURL + "?download=1", source: "example"})
+	if res.err != nil {
+		t.Fatalf("mirrorOne failed: %v", res.err)
+	}
+	if filepath.Ext(res.localPath) != ".ogg" {
+		t.Errorf("Expected a .ogg store path, got %s", res.localPath)
+	}
+}
+
+func TestMirrorCommonsUnchanged(t *testing.T) {
+	src := &testSource{body: "OggS anthem"}
+	src.sha1 = sha1Hex(src.body)
+	m, fileURL := newTestMirror(t, src)
+	job := mirrorJob{id: "a", title: "File:A.ogg", url: fileURL, source: "wikimedia-commons"}
+
+	if res := m.mirrorOne(context.Background(), job); res.err != nil || res.skipped {
+		t.Fatalf("Expected a first download, got skipped=%v err=%v", res.skipped, res.err)
+	}
+	res := m.mirrorOne(context.Background(), job)
+	if res.err != nil {
+		t.Fatalf("mirrorOne failed: %v", res.err)
+	}
+	if !res.skipped || res.sha1 != src.sha1 {
+		t.Errorf("Expected a skip with sha1 %s, got skipped=%v sha1=%s", src.sha1, res.skipped, res.sha1)
+	}
+	if gets := src.downloads(); gets != 1 {
+		t.Errorf("Expected 1 download, got %d", gets)
+	}
+}
+
+func TestMirrorChecksumMismatch(t *testing.T) {
+	src := &testSource{body: "OggS tampered", sha1: sha1Hex("OggS anthem")}
+	m, fileURL := newTestMirror(t, src)
+
+	res := m.mirrorOne(context.Background(), mirrorJob{id: "a", title: "File:A.ogg", url: fileURL, source: "wikimedia-commons"})
+	if res.err == nil || !strings.Contains(res.err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected a checksum mismatch, got %v", res.err)
+	}
+	if _, err := os.Stat(filepath.Join(m.Dest, "tmp", "a.part")); !os.IsNotExist(err) {
+		t.Error("Expected the bad part file to be removed")
+	}
+}
+
+func TestMirrorHTTPError(t *testing.T) {
+	src := &testSource{body: "OggS anthem", missing: true}
+	m, fileURL := newTestMirror(t, src)
+
+	res := m.mirrorOne(context.Background(), mirrorJob{id: "a", title: "A", url: fileURL, source: "example"})
+	if res.err == nil || !strings.Contains(res.err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404, got %v", res.err)
+	}
+}
+
+func TestMirrorValidators(t *testing.T) {
+	tests := []struct {
+		name     string
+		etag     string // served, and so stored by the first run
+		change   func(*testSource)
+		wantSkip bool
+	}{
+		{"etag unchanged", `"v1"`, nil, true},
+		{"etag changed", `"v1"`, func(s *testSource) { s.body, s.etag = "OggS anthem v2", `"v2"` }, false},
+		{"size unchanged", "", nil, true},
+		{"size changed", "", func(s *testSource) { s.body = "OggS anthem, longer" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &testSource{body: "OggS anthem", etag: tt.etag}
+			m, fileURL := newTestMirror(t, src)
+			job := mirrorJob{id: "a", title: "A", url: fileURL, source: "example"}
+
+			first := m.mirrorOne(context.Background(), job)
+			if first.err != nil {
+				t.Fatalf("mirrorOne failed: %v", first.err)
+			}
+			job.localPath, job.sha1 = first.localPath, first.sha1
+			job.etag, job.lastModified, job.size = first.etag, first.lastModified, first.size
+			if tt.change != nil {
+				src.mu.Lock()
+				tt.change(src)
+				src.mu.Unlock()
+			}
+
+			res := m.mirrorOne(context.Background(), job)
+			if res.err != nil {
+				t.Fatalf("mirrorOne failed: %v", res.err)
+			}
+			if res.skipped != tt.wantSkip {
+				t.Errorf("Expected skipped=%v, got %v", tt.wantSkip, res.skipped)
+			}
+			wantGets := 1
+			if !tt.wantSkip {
+				wantGets = 2
+			}
+			if gets := src.downloads(); gets != wantGets {
+				t.Errorf("Expected %d downloads, got %d", wantGets, gets)
+			}
+			if !tt.wantSkip && res.sha1 != sha1Hex(src.body) {
+				t.Errorf("Expected the new file's sha1 %s, got %s", sha1Hex(src.body), res.sha1)
+			}
+		})
+	}
+}
+
+func TestMirrorResume(t *testing.T) {
+	const body = "OggS anthem v1"
+	tests := []struct {
+		name   string
+		part   string
+		state  string // "<part>.json", none when empty
+		served string // body at the time of the resume
+		asked  string // Range and If-Range of the first GET
+	}{
+		{"resumes its own bytes", "OggS an", `{"etag":"\"v1\"","size":14}`, body, `bytes=7- "v1"`},
+		{"file changed upstream", "OggS an", `{"etag":"\"v0\"","size":14}`, "OggS anthem v2, longer", `bytes=7- "v0"`},
+		{"no validator for the part", "OggS an", "", body, ""},
+		{"weak etag only", "OggS an", `{"etag":"W/\"v1\""}`, body, ""},
+		{"already complete", body, `{"etag":"\"v1\"","size":14}`, body, `bytes=14- "v1"`},
+		{"longer than the file", body + " and junk", `{"etag":"\"v1\""}`, body, `bytes=23- "v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &testSource{body: tt.served, etag: `"v1"`, ranges: true}
+			if tt.served != body {
+				src.etag = `"v2"`
+			}
+			m, fileURL := newTestMirror(t, src)
+			part := filepath.Join(m.Dest, "tmp", "a.part")
+			if err := os.MkdirAll(filepath.Dir(part), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(part, []byte(tt.part), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.state != "" {
+				if err := os.WriteFile(part+".json", []byte(tt.state), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res := m.mirrorOne(context.Background(), mirrorJob{id: "a", title: "A", url: fileURL, source: "example"})
+			if res.err != nil {
+				t.Fatalf("mirrorOne failed: %v", res.err)
+			}
+			if res.sha1 != sha1Hex(tt.served) {
+				t.Errorf("Expected the served file's sha1 %s, got %s", sha1Hex(tt.served), res.sha1)
+			}
+			if len(src.asked) == 0 || src.asked[0] != tt.asked {
+				t.Errorf("Expected the first request to ask %q, got %q", tt.asked, src.asked)
+			}
+			if _, err := os.Stat(part + ".json"); !os.IsNotExist(err) {
+				t.Error("Expected the part state to be removed with the part file")
+			}
+		})
+	}
+}
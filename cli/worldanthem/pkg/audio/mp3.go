@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+func init() {
+	RegisterDecoder(CodecMP3, newMP3Decoder)
+}
+
+// mp3Decoder wraps go-mp3, a pure-Go MPEG-1/2/2.5 Layer III decoder. go-mp3
+// always yields 16-bit stereo, so mono streams are folded back to one channel:
+// measuring a duplicated channel twice would read 3 LU too loud.
+type mp3Decoder struct {
+	dec      *mp3.Decoder
+	channels int
+	pcm      []byte
+}
+
+func newMP3Decoder(r io.Reader) (Decoder, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	channels, err := skipToMP3Frame(br)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := mp3.NewDecoder(br)
+	if err != nil {
+		return nil, fmt.Errorf("mp3: %w", err)
+	}
+	return &mp3Decoder{dec: dec, channels: channels}, nil
+}
+
+// skipToMP3Frame discards ID3v2 tags (go-mp3 only skips the first) and any
+// junk before the first frame, and returns that frame's channel count
+func skipToMP3Frame(br *bufio.Reader) (int, error) {
+	for {
+		hdr, err := br.Peek(10)
+		if err != nil {
+			return 0, fmt.Errorf("mp3: %w", err)
+		}
+		if string(hdr[:3]) != "ID3" {
+			break
+		}
+		size := 10 + int(hdr[6])<<21 | int(hdr[7])<<14 | int(hdr[8])<<7 | int(hdr[9])
+		if hdr[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		if _, err := br.Discard(size); err != nil {
+			return 0, fmt.Errorf("mp3: %w", err)
+		}
+	}
+
+	// Peek returns what it could buffer along with an error at the end of the file
+	head, _ := br.Peek(br.Size())
+	for i := 0; i+4 <= len(head); i++ {
+		h := binary.BigEndian.Uint32(head[i:])
+		if !validMP3Header(h) {
+			continue
+		}
+		if _, err := br.Discard(i); err != nil {
+			return 0, err
+		}
+		if (h>>6)&0x3 == 3 {
+			return 1, nil
+		}
+		return 2, nil
+	}
+	return 0, errors.New("mp3: no frame header found")
+}
+
+func (d *mp3Decoder) Channels() int   { return d.channels }
+func (d *mp3Decoder) SampleRate() int { return d.dec.SampleRate() }
+
+func (d *mp3Decoder) ReadFrames(buf []float64) (int, error) {
+	frames := len(buf) / d.channels
+	if cap(d.pcm) < frames*4 {
+		d.pcm = make([]byte, frames*4)
+	}
+	pcm := d.pcm[:frames*4]
+	n, err := io.ReadFull(d.dec, pcm)
+	n /= 4
+	for i := 0; i < n; i++ {
+		left := float64(int16(binary.LittleEndian.Uint16(pcm[i*4:]))) / 32768
+		right := float64(int16(binary.LittleEndian.Uint16(pcm[i*4+2:]))) / 32768
+		if d.channels == 1 {
+			buf[i] = (left + right) / 2
+		} else {
+			buf[i*2], buf[i*2+1] = left, right
+		}
+	}
+	// Tolerate truncated files
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, io.EOF
+	}
+	return n, err
+}
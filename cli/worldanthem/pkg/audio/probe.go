@@ -0,0 +1,443 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Codec names stored in audio_recordings.codec
+const (
+	CodecVorbis = "vorbis"
+	CodecOpus   = "opus"
+	CodecMP3    = "mp3"
+	CodecPCM    = "pcm"
+	CodecFLAC   = "flac"
+)
+
+// Info describes an audio file as read from its headers
+type Info struct {
+	Codec        string
+	Channels     int
+	SampleRateHz int
+	BitrateKbps  int      // nominal bitrate, or the average over the file
+	Duration     float64  // seconds
+	LoudnessLUFS *float64 // nil for silence or when the codec cannot be decoded, see CanDecode
+}
+
+// ErrUnsupportedFormat is returned for files none of the probes recognise
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// ProbeFile reads the container and codec headers of an audio file.
+// The format is detected from magic bytes, not the file extension.
+// Integrated loudness is measured when the codec can be decoded, which is
+// every codec but Opus.
+func ProbeFile(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+
+	magic := make([]byte, 12)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var info *Info
+	switch {
+	case bytes.Equal(magic[:4], []byte("RIFF")) && bytes.Equal(magic[8:12], []byte("WAVE")):
+		info, err = probeWAV(f)
+	case bytes.Equal(magic[:4], []byte("fLaC")):
+		info, err = probeFLAC(f)
+	case bytes.Equal(magic[:4], []byte("OggS")):
+		info, err = probeOgg(f, size)
+	case bytes.Equal(magic[:3], []byte("ID3")) || (magic[0] == 0xFF && magic[1]&0xE0 == 0xE0):
+		info, err = probeMP3(f, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.BitrateKbps == 0 && info.Duration > 0 {
+		info.BitrateKbps = int(float64(size) * 8 / info.Duration / 1000)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if lufs, err := measureLoudness(f, info.Codec); err == nil {
+		info.LoudnessLUFS = &lufs
+	}
+	return info, nil
+}
+
+// ── WAV ─────────────────────────────────────────────────────────────────────
+
+type wavFormat struct {
+	audioFormat   uint16
+	channels      int
+	sampleRate    int
+	byteRate      int
+	blockAlign    int
+	bitsPerSample int
+}
+
+// maxWAVFmtSize bounds the fmt chunk, whose size is read from the file
+const maxWAVFmtSize = 1024
+
+// readWAVHeader walks the RIFF chunks up to "data" and leaves r positioned
+// at the first sample. It returns the format and the data chunk size.
+func readWAVHeader(r io.Reader) (*wavFormat, int64, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, 0, err
+	}
+
+	var format *wavFormat
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, 0, fmt.Errorf("wav: no data chunk: %w", err)
+		}
+		id := string(hdr[:4])
+		size := int64(binary.LittleEndian.Uint32(hdr[4:]))
+
+		switch id {
+		case "fmt ":
+			// The chunk is 16 to 40 bytes; a corrupt size must not decide
+			// how much is allocated
+			if size > maxWAVFmtSize {
+				return nil, 0, fmt.Errorf("wav: fmt chunk of %d bytes", size)
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, 0, err
+			}
+			if len(buf) < 16 {
+				return nil, 0, errors.New("wav: short fmt chunk")
+			}
+			format = &wavFormat{
+				audioFormat:   binary.LittleEndian.Uint16(buf[0:]),
+				channels:      int(binary.LittleEndian.Uint16(buf[2:])),
+				sampleRate:    int(binary.LittleEndian.Uint32(buf[4:])),
+				byteRate:      int(binary.LittleEndian.Uint32(buf[8:])),
+				blockAlign:    int(binary.LittleEndian.Uint16(buf[12:])),
+				bitsPerSample: int(binary.LittleEndian.Uint16(buf[14:])),
+			}
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format in the sub-format GUID
+			if format.audioFormat == 0xFFFE && len(buf) >= 26 {
+				format.audioFormat = binary.LittleEndian.Uint16(buf[24:])
+			}
+		case "data":
+			if format == nil {
+				return nil, 0, errors.New("wav: data chunk before fmt chunk")
+			}
+			return format, size, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		// Chunks are word-aligned
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+}
+
+func probeWAV(r io.Reader) (*Info, error) {
+	format, dataSize, err := readWAVHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Codec:        CodecPCM,
+		Channels:     format.channels,
+		SampleRateHz: format.sampleRate,
+		BitrateKbps:  format.byteRate * 8 / 1000,
+	}
+	if format.byteRate > 0 {
+		info.Duration = float64(dataSize) / float64(format.byteRate)
+	}
+	return info, nil
+}
+
+// ── FLAC ────────────────────────────────────────────────────────────────────
+
+// flacStreamInfo holds the fields of the mandatory STREAMINFO metadata block
+type flacStreamInfo struct {
+	sampleRate    int
+	channels      int
+	bitsPerSample int
+	totalSamples  int64
+}
+
+func parseFLACStreamInfo(b []byte) flacStreamInfo {
+	// Bytes 10..17: 20 bits sample rate, 3 bits channels-1, 5 bits bps-1, 36 bits total samples
+	v := binary.BigEndian.Uint64(b[10:18])
+	return flacStreamInfo{
+		sampleRate:    int(v >> 44),
+		channels:      int((v>>41)&0x7) + 1,
+		bitsPerSample: int((v>>36)&0x1F) + 1,
+		totalSamples:  int64(v & 0xFFFFFFFFF),
+	}
+}
+
+func probeFLAC(r io.Reader) (*Info, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if hdr[0]&0x7F != 0 {
+		return nil, errors.New("flac: first metadata block is not STREAMINFO")
+	}
+	block := make([]byte, 34)
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, err
+	}
+	si := parseFLACStreamInfo(block)
+	info := &Info{
+		Codec:        CodecFLAC,
+		Channels:     si.channels,
+		SampleRateHz: si.sampleRate,
+	}
+	if si.sampleRate > 0 {
+		info.Duration = float64(si.totalSamples) / float64(si.sampleRate)
+	}
+	return info, nil
+}
+
+// ── Ogg (Vorbis / Opus) ─────────────────────────────────────────────────────
+
+func probeOgg(r io.ReadSeeker, size int64) (*Info, error) {
+	// The identification header is the only packet on the first page
+	var page [27]byte
+	if _, err := io.ReadFull(r, page[:]); err != nil {
+		return nil, err
+	}
+	segments := make([]byte, page[26])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return nil, err
+	}
+	packetLen := 0
+	for _, s := range segments {
+		packetLen += int(s)
+	}
+	packet := make([]byte, packetLen)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	var preSkip int64
+	var granuleRate int
+	switch {
+	case len(packet) >= 30 && bytes.Equal(packet[:7], []byte("\x01vorbis")):
+		info.Codec = CodecVorbis
+		info.Channels = int(packet[11])
+		info.SampleRateHz = int(binary.LittleEndian.Uint32(packet[12:]))
+		if nominal := int32(binary.LittleEndian.Uint32(packet[20:])); nominal > 0 {
+			info.BitrateKbps = int(nominal) / 1000
+		}
+		granuleRate = info.SampleRateHz
+	case len(packet) >= 19 && bytes.Equal(packet[:8], []byte("OpusHead")):
+		info.Codec = CodecOpus
+		info.Channels = int(packet[9])
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+		info.SampleRateHz = int(binary.LittleEndian.Uint32(packet[12:]))
+		if info.SampleRateHz == 0 {
+			info.SampleRateHz = 48000
+		}
+		// Opus granule positions always count 48 kHz samples
+		granuleRate = 48000
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	granule, err := lastOggGranule(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if granuleRate > 0 && granule > preSkip {
+		info.Duration = float64(granule-preSkip) / float64(granuleRate)
+	}
+	return info, nil
+}
+
+// lastOggGranule returns the granule position of the final Ogg page,
+// which is the total sample count of the stream.
+func lastOggGranule(r io.ReadSeeker, size int64) (int64, error) {
+	const tail = 64 * 1024
+	start := size - tail
+	if start < 0 {
+		start = 0
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	idx := bytes.LastIndex(buf, []byte("OggS"))
+	if idx < 0 || idx+14 > len(buf) {
+		return 0, errors.New("ogg: no final page found")
+	}
+	return int64(binary.LittleEndian.Uint64(buf[idx+6:])), nil
+}
+
+// ── MP3 ─────────────────────────────────────────────────────────────────────
+
+// mp3Bitrates is indexed by [version is MPEG-1 ? 0 : 1][layer-1][bitrate index], in kbit/s
+var mp3Bitrates = [2][3][16]int{
+	{ // MPEG-1
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	{ // MPEG-2 and 2.5
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+// mp3SampleRates is indexed by [version bits][sample rate index]
+var mp3SampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{0, 0, 0},             // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+func probeMP3(r io.ReadSeeker, size int64) (*Info, error) {
+	// Skip an ID3v2 tag; its size is a 28-bit "syncsafe" integer
+	var offset int64
+	var id3 [10]byte
+	if _, err := io.ReadFull(r, id3[:]); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(id3[:3], []byte("ID3")) {
+		tagSize := int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+		offset = 10 + tagSize
+		if id3[5]&0x10 != 0 {
+			offset += 10 // footer
+		}
+	}
+
+	// Find the first valid frame header within the next 64 KiB
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 64*1024)
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		h := binary.BigEndian.Uint32(buf[i:])
+		if !validMP3Header(h) {
+			continue
+		}
+		versionBits := int(h>>19) & 0x3
+		layerBits := int(h>>17) & 0x3
+		bitrateIdx := int(h>>12) & 0xF
+		rateIdx := int(h>>10) & 0x3
+		channelMode := int(h>>6) & 0x3
+
+		layer := 4 - layerBits
+		mpeg1 := versionBits == 3
+		table := 1
+		if mpeg1 {
+			table = 0
+		}
+		info := &Info{
+			Codec:        CodecMP3,
+			Channels:     2,
+			SampleRateHz: mp3SampleRates[versionBits][rateIdx],
+			BitrateKbps:  mp3Bitrates[table][layer-1][bitrateIdx],
+		}
+		if channelMode == 3 {
+			info.Channels = 1
+		}
+
+		samplesPerFrame := 1152
+		switch {
+		case layer == 1:
+			samplesPerFrame = 384
+		case layer == 3 && !mpeg1:
+			samplesPerFrame = 576
+		}
+
+		// A Xing/Info (VBR) header in the first frame carries the frame count
+		if frames := xingFrameCount(buf[i:], mpeg1, info.Channels); frames > 0 {
+			info.Duration = float64(frames) * float64(samplesPerFrame) / float64(info.SampleRateHz)
+			info.BitrateKbps = 0 // recomputed as the average
+			return info, nil
+		}
+
+		audioBytes := size - offset - int64(i)
+		info.Duration = float64(audioBytes) * 8 / float64(info.BitrateKbps*1000)
+		return info, nil
+	}
+	return nil, errors.New("mp3: no frame header found")
+}
+
+// validMP3Header reports whether h, which starts with the 11-bit frame sync,
+// has no reserved version, layer, bitrate or sample rate
+func validMP3Header(h uint32) bool {
+	versionBits := (h >> 19) & 0x3
+	layerBits := (h >> 17) & 0x3
+	bitrateIdx := (h >> 12) & 0xF
+	rateIdx := (h >> 10) & 0x3
+	return h>>21 == 0x7FF && versionBits != 1 && layerBits != 0 && bitrateIdx != 0 && bitrateIdx != 15 && rateIdx != 3
+}
+
+// xingFrameCount returns the frame count from a Xing or Info header, or 0
+func xingFrameCount(frame []byte, mpeg1 bool, channels int) int {
+	// The tag follows the side information, whose size depends on the stream
+	sideInfo := 17
+	switch {
+	case mpeg1 && channels == 2:
+		sideInfo = 32
+	case !mpeg1 && channels == 1:
+		sideInfo = 9
+	}
+	pos := 4 + sideInfo
+	if pos+12 > len(frame) {
+		return 0
+	}
+	tag := string(frame[pos : pos+4])
+	if tag != "Xing" && tag != "Info" {
+		return 0
+	}
+	flags := binary.BigEndian.Uint32(frame[pos+4:])
+	if flags&0x1 == 0 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(frame[pos+8:]))
+}
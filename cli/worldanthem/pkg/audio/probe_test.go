@@ -0,0 +1,275 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestWAV writes a 16-bit stereo 1 kHz sine at the given level (dBFS)
+func writeTestWAV(t *testing.T, seconds float64, sampleRate int, dbfs float64) string {
+	t.Helper()
+	amplitude := math.Pow(10, dbfs/20)
+	frames := int(seconds * float64(sampleRate))
+	data := make([]byte, frames*4)
+	for i := 0; i < frames; i++ {
+		v := int16(amplitude * 32767 * math.Sin(2*math.Pi*1000*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(data[i*4:], uint16(v))
+		binary.LittleEndian.PutUint16(data[i*4+2:], uint16(v))
+	}
+
+	hdr := make([]byte, 44)
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(36+len(data)))
+	copy(hdr[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(hdr[16:], 16)
+	binary.LittleEndian.PutUint16(hdr[20:], 1)
+	binary.LittleEndian.PutUint16(hdr[22:], 2)
+	binary.LittleEndian.PutUint32(hdr[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(hdr[28:], uint32(sampleRate*4))
+	binary.LittleEndian.PutUint16(hdr[32:], 4)
+	binary.LittleEndian.PutUint16(hdr[34:], 16)
+	copy(hdr[36:], "data")
+	binary.LittleEndian.PutUint32(hdr[40:], uint32(len(data)))
+
+	path := filepath.Join(t.TempDir(), "tone.wav")
+	if err := os.WriteFile(path, append(hdr, data...), 0644); err != nil {
+		t.Fatalf("Failed to write test WAV: %v", err)
+	}
+	return path
+}
+
+func TestProbeWAV(t *testing.T) {
+	path := writeTestWAV(t, 5, 48000, -23)
+
+	info, err := ProbeFile(path)
+	if err != nil {
+		t.Fatalf("ProbeFile failed: %v", err)
+	}
+
+	if info.Codec != CodecPCM {
+		t.Errorf("Expected codec '%s', got '%s'", CodecPCM, info.Codec)
+	}
+	if info.Channels != 2 {
+		t.Errorf("Expected 2 channels, got %d", info.Channels)
+	}
+	if info.SampleRateHz != 48000 {
+		t.Errorf("Expected sample rate 48000, got %d", info.SampleRateHz)
+	}
+	if info.BitrateKbps != 1536 {
+		t.Errorf("Expected bitrate 1536 kbps, got %d", info.BitrateKbps)
+	}
+	if math.Abs(info.Duration-5) > 0.01 {
+		t.Errorf("Expected duration 5s, got %.3f", info.Duration)
+	}
+
+	// EBU Tech 3341 case 1: a -23 dBFS stereo 1 kHz sine measures -23 LUFS
+	if info.LoudnessLUFS == nil {
+		t.Fatal("Expected loudness to be measured")
+	}
+	if math.Abs(*info.LoudnessLUFS-(-23)) > 0.1 {
+		t.Errorf("Expected loudness -23.0 LUFS, got %.2f", *info.LoudnessLUFS)
+	}
+}
+
+func TestProbeUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("not an audio file"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := ProbeFile(path); err != ErrUnsupportedFormat {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestProbeWAVInvalidHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		offset int
+		value  uint32
+		size   int
+	}{
+		{"block align below the frame size", 32, 1, 2},
+		{"sample rate below the meter's step", 24, 2, 4},
+	} {
+		path := writeTestWAV(t, 1, 48000, -23)
+		data, _ := os.ReadFile(path)
+		if tc.size == 2 {
+			binary.LittleEndian.PutUint16(data[tc.offset:], uint16(tc.value))
+		} else {
+			binary.LittleEndian.PutUint32(data[tc.offset:], tc.value)
+		}
+		os.WriteFile(path, data, 0644)
+
+		info, err := ProbeFile(path)
+		if err != nil {
+			t.Fatalf("%s: ProbeFile failed: %v", tc.name, err)
+		}
+		if info.LoudnessLUFS != nil {
+			t.Errorf("%s: expected no loudness, got %.2f", tc.name, *info.LoudnessLUFS)
+		}
+	}
+
+	// A fmt chunk claiming 4 GiB is rejected before it is allocated
+	path := writeTestWAV(t, 1, 48000, -23)
+	data, _ := os.ReadFile(path)
+	binary.LittleEndian.PutUint32(data[16:], 0xFFFFFFF0)
+	os.WriteFile(path, data, 0644)
+	if _, err := ProbeFile(path); err == nil || !strings.Contains(err.Error(), "fmt chunk") {
+		t.Errorf("Expected an oversized fmt chunk error, got %v", err)
+	}
+}
+
+func TestProbeFLAC(t *testing.T) {
+	const rate = 44100
+	left := make([]int64, 2*rate)
+	for i := range left {
+		left[i] = int64(8000 * math.Sin(2*math.Pi*1000*float64(i)/rate))
+	}
+	path := filepath.Join(t.TempDir(), "tone.flac")
+	if err := os.WriteFile(path, encodeTestFLAC(left, left, rate, 4096), 0644); err != nil {
+		t.Fatalf("Failed to write test FLAC: %v", err)
+	}
+
+	info, err := ProbeFile(path)
+	if err != nil {
+		t.Fatalf("ProbeFile failed: %v", err)
+	}
+	if info.Codec != CodecFLAC || info.Channels != 2 || info.SampleRateHz != rate || info.Duration != 2 {
+		t.Errorf("Expected 2s of stereo FLAC at %d Hz, got %+v", rate, info)
+	}
+	if info.LoudnessLUFS == nil {
+		t.Error("Expected loudness to be measured")
+	}
+}
+
+// writeTestOgg writes an Ogg stream of one identification packet and a
+// final page ending at granule
+func writeTestOgg(t *testing.T, packet []byte, granule uint64) string {
+	t.Helper()
+	page := func(headerType byte, granule uint64, packet []byte) []byte {
+		p := make([]byte, 27, 28+len(packet))
+		copy(p, "OggS")
+		p[5] = headerType
+		binary.LittleEndian.PutUint64(p[6:], granule)
+		p[26] = 1
+		p = append(p, byte(len(packet)))
+		return append(p, packet...)
+	}
+	data := append(page(2, 0, packet), page(4, granule, []byte("audio"))...)
+	path := filepath.Join(t.TempDir(), "anthem.ogg")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write test Ogg: %v", err)
+	}
+	return path
+}
+
+func TestProbeOgg(t *testing.T) {
+	opus := make([]byte, 19)
+	copy(opus, "OpusHead")
+	opus[8], opus[9] = 1, 2
+	binary.LittleEndian.PutUint16(opus[10:], 312)
+	binary.LittleEndian.PutUint32(opus[12:], 44100)
+
+	vorbis := make([]byte, 30)
+	copy(vorbis, "\x01vorbis")
+	vorbis[11] = 1
+	binary.LittleEndian.PutUint32(vorbis[12:], 22050)
+	binary.LittleEndian.PutUint32(vorbis[20:], 96000)
+
+	for _, tc := range []struct {
+		packet   []byte
+		granule  uint64
+		expected Info
+	}{
+		{opus, 3*48000 + 312, Info{Codec: CodecOpus, Channels: 2, SampleRateHz: 44100, Duration: 3}},
+		{vorbis, 4 * 22050, Info{Codec: CodecVorbis, Channels: 1, SampleRateHz: 22050, BitrateKbps: 96, Duration: 4}},
+	} {
+		info, err := ProbeFile(writeTestOgg(t, tc.packet, tc.granule))
+		if err != nil {
+			t.Fatalf("ProbeFile failed: %v", err)
+		}
+		if info.Codec != tc.expected.Codec || info.Channels != tc.expected.Channels ||
+			info.SampleRateHz != tc.expected.SampleRateHz || info.Duration != tc.expected.Duration {
+			t.Errorf("Expected %+v, got %+v", tc.expected, *info)
+		}
+		if tc.expected.BitrateKbps != 0 && info.BitrateKbps != tc.expected.BitrateKbps {
+			t.Errorf("Expected nominal bitrate %d kbps, got %d", tc.expected.BitrateKbps, info.BitrateKbps)
+		}
+		// No decoder: loudness is documented as missing for Ogg
+		if info.LoudnessLUFS != nil {
+			t.Errorf("Expected no loudness for %s, got %.2f", info.Codec, *info.LoudnessLUFS)
+		}
+	}
+}
+
+func TestProbeMP3(t *testing.T) {
+	// MPEG-1 Layer III, 128 kbps, 44.1 kHz, joint stereo, 417-byte frames
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x40})
+	var data []byte
+	for i := 0; i < 100; i++ {
+		data = append(data, frame...)
+	}
+	path := filepath.Join(t.TempDir(), "anthem.mp3")
+	if err := os.WriteFile(path, append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), data...), 0644); err != nil {
+		t.Fatalf("Failed to write test MP3: %v", err)
+	}
+
+	info, err := ProbeFile(path)
+	if err != nil {
+		t.Fatalf("ProbeFile failed: %v", err)
+	}
+	if info.Codec != CodecMP3 || info.Channels != 2 || info.SampleRateHz != 44100 || info.BitrateKbps != 128 {
+		t.Errorf("Expected 128 kbps stereo MP3 at 44100 Hz, got %+v", *info)
+	}
+	if want := float64(len(data)) * 8 / 128000; math.Abs(info.Duration-want) > 1e-9 {
+		t.Errorf("Expected duration %.3fs, got %.3f", want, info.Duration)
+	}
+	// The frames decode to silence, which has no loudness
+	if info.LoudnessLUFS != nil {
+		t.Errorf("Expected no loudness for a silent MP3, got %.2f", *info.LoudnessLUFS)
+	}
+}
+
+func TestMP3Decoder(t *testing.T) {
+	// MPEG-1 Layer III, 128 kbps, 44.1 kHz, mono; zeroed side info is silence
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+	// Two ID3v2 tags, of which go-mp3 alone would skip only the first
+	data := []byte("ID3\x03\x00\x00\x00\x00\x00\x02ab" + "ID3\x04\x00\x00\x00\x00\x00\x00")
+	for i := 0; i < 20; i++ {
+		data = append(data, frame...)
+	}
+
+	dec, err := NewDecoder(CodecMP3, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	if dec.Channels() != 1 || dec.SampleRate() != 44100 {
+		t.Errorf("Expected mono at 44100 Hz, got %d channels at %d Hz", dec.Channels(), dec.SampleRate())
+	}
+	samples, err := decodeMono(dec)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(samples) != 20*1152 {
+		t.Errorf("Expected %d samples, got %d", 20*1152, len(samples))
+	}
+	for i, v := range samples {
+		if v != 0 {
+			t.Fatalf("Expected silence, got %v at sample %d", v, i)
+		}
+	}
+
+	if _, err := NewDecoder(CodecMP3, bytes.NewReader([]byte("ID3\x03\x00\x00\x00\x00\x00\x00not audio"))); err == nil {
+		t.Error("Expected an error without a frame header")
+	}
+	if !CanDecode(CodecVorbis) || CanDecode(CodecOpus) {
+		t.Error("Expected a Vorbis decoder and no Opus decoder")
+	}
+}
@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// ProbeStats summarises a probe run
+type ProbeStats struct {
+	Probed      int
+	WithLUFS    int // loudness could be measured
+	Undecoded   int // no decoder for the codec (Opus), so no loudness
+	Failed      int
+	NotMirrored int
+}
+
+// ProbeRecordings probes every mirrored recording and stores duration,
+// codec, channels, sample rate, bitrate, loudness and the re-graded quality.
+func ProbeRecordings(db *sql.DB, logf func(format string, args ...interface{})) (ProbeStats, error) {
+	var stats ProbeStats
+
+	rows, err := db.Query(`SELECT id, title, COALESCE(local_path,'') FROM audio_recordings ORDER BY country_id, id`)
+	if err != nil {
+		return stats, fmt.Errorf("query recordings: %w", err)
+	}
+	type pending struct{ id, title, localPath string }
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.title, &p.localPath); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	for _, p := range todo {
+		if p.localPath == "" {
+			stats.NotMirrored++
+			continue
+		}
+		info, err := ProbeFile(p.localPath)
+		if err != nil {
+			logf("✗ %s: %v", p.title, err)
+			stats.Failed++
+			continue
+		}
+
+		var lufs interface{}
+		if info.LoudnessLUFS != nil {
+			lufs = math.Round(*info.LoudnessLUFS*10) / 10
+			stats.WithLUFS++
+		} else if !CanDecode(info.Codec) {
+			stats.Undecoded++
+		}
+		_, err = db.Exec(`
+			UPDATE audio_recordings
+			SET duration_seconds = ?, codec = ?, channels = ?, sample_rate_hz = ?,
+			    bitrate_kbps = ?, loudness_lufs = ?, quality = ?
+			WHERE id = ?
+		`, int(math.Round(info.Duration)), info.Codec, info.Channels, info.SampleRateHz,
+			info.BitrateKbps, lufs, ClassifyQuality(info.BitrateKbps, info.SampleRateHz), p.id)
+		if err != nil {
+			return stats, fmt.Errorf("update %s: %w", p.id, err)
+		}
+		stats.Probed++
+	}
+	return stats, nil
+}
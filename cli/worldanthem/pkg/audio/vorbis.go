@@ -0,0 +1,57 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+func init() {
+	RegisterDecoder(CodecVorbis, newVorbisDecoder)
+}
+
+// vorbisDecoder wraps oggvorbis, a pure-Go Ogg Vorbis decoder
+type vorbisDecoder struct {
+	r   *oggvorbis.Reader
+	pcm []float32
+}
+
+func newVorbisDecoder(r io.Reader) (Decoder, error) {
+	vr, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("vorbis: %w", err)
+	}
+	if vr.Channels() < 1 {
+		return nil, fmt.Errorf("vorbis: no channels")
+	}
+	return &vorbisDecoder{r: vr}, nil
+}
+
+func (d *vorbisDecoder) Channels() int   { return d.r.Channels() }
+func (d *vorbisDecoder) SampleRate() int { return d.r.SampleRate() }
+
+func (d *vorbisDecoder) ReadFrames(buf []float64) (int, error) {
+	channels := d.r.Channels()
+	want := len(buf) / channels * channels
+	if cap(d.pcm) < want {
+		d.pcm = make([]float32, want)
+	}
+	pcm := d.pcm[:want]
+
+	n := 0
+	var err error
+	for n < want && err == nil {
+		var m int
+		m, err = d.r.Read(pcm[n:])
+		n += m
+	}
+	for i, v := range pcm[:n] {
+		buf[i] = float64(v)
+	}
+	// Tolerate truncated files
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n / channels, io.EOF
+	}
+	return n / channels, err
+}
@@ -0,0 +1,141 @@
+package dates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precisions of a Date
+const (
+	PrecisionYear  = "year"
+	PrecisionMonth = "month"
+	PrecisionDay   = "day"
+)
+
+// Date is an ISO 8601 calendar date truncated to its precision:
+// "1795", "1795-07" or "1795-07-14"
+type Date struct {
+	Value     string
+	Precision string
+}
+
+func (d Date) String() string { return d.Value }
+
+// newDate returns the date truncated at the first zero of month and day, or
+// false for a date that does not exist, such as 30 February or 31 April
+func newDate(year, month, day int) (Date, bool) {
+	switch {
+	case year <= 0 || year > 9999 || month < 0 || month > 12 || day < 0 || day > 31:
+		return Date{}, false
+	case month > 0 && day > 0 && time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Day() != day:
+		return Date{}, false
+	case month == 0:
+		return Date{Value: fmt.Sprintf("%04d", year), Precision: PrecisionYear}, true
+	case day == 0:
+		return Date{Value: fmt.Sprintf("%04d-%02d", year, month), Precision: PrecisionMonth}, true
+	}
+	return Date{Value: fmt.Sprintf("%04d-%02d-%02d", year, month, day), Precision: PrecisionDay}, true
+}
+
+var (
+	isoDate      = regexp.MustCompile(`^\+?(\d{4})(?:-(\d{2})(?:-(\d{2}))?)?(T\d{2}:\d{2}:\d{2}Z?)?$`)
+	dayMonthYear = regexp.MustCompile(`(?i)^(\d{1,2})(?:st|nd|rd|th)? ([a-z]+)\.? (\d{4})$`)
+	monthDayYear = regexp.MustCompile(`(?i)^([a-z]+)\.? (\d{1,2})(?:st|nd|rd|th)?,? (\d{4})$`)
+	monthYear    = regexp.MustCompile(`(?i)^([a-z]+)\.? (\d{4})$`)
+	anyYear      = regexp.MustCompile(`\b(\d{4})\b`)
+)
+
+var months = map[string]int{
+	"january": 1, "february": 2, "march": 3, "april": 4, "may": 5, "june": 6,
+	"july": 7, "august": 8, "september": 9, "october": 10, "november": 11, "december": 12,
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "jun": 6, "jul": 7, "aug": 8,
+	"sep": 9, "sept": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// Parse normalises a free-text date such as "1795-07-14", "14 July 1795",
+// "July 1795" or "1795". Timestamps on 1 January at midnight, as Wikidata's
+// SPARQL endpoint writes year-precision dates, are read as a year. Text with
+// no recognisable date but a four-digit year ("c. 1795, officially 1879")
+// yields the first year.
+func Parse(s string) (Date, bool) {
+	s = strings.Join(strings.Fields(strings.TrimSpace(s)), " ")
+	if s == "" {
+		return Date{}, false
+	}
+
+	if m := isoDate.FindStringSubmatch(s); m != nil {
+		year, month, day := atoi(m[1]), atoi(m[2]), atoi(m[3])
+		if m[4] != "" && month == 1 && day == 1 {
+			month, day = 0, 0
+		}
+		return newDate(year, month, day)
+	}
+	if m := dayMonthYear.FindStringSubmatch(s); m != nil {
+		if month, ok := months[strings.ToLower(m[2])]; ok {
+			return newDate(atoi(m[3]), month, atoi(m[1]))
+		}
+	}
+	if m := monthDayYear.FindStringSubmatch(s); m != nil {
+		if month, ok := months[strings.ToLower(m[1])]; ok {
+			return newDate(atoi(m[3]), month, atoi(m[2]))
+		}
+	}
+	if m := monthYear.FindStringSubmatch(s); m != nil {
+		if month, ok := months[strings.ToLower(m[1])]; ok {
+			return newDate(atoi(m[2]), month, 0)
+		}
+	}
+	if m := anyYear.FindStringSubmatch(s); m != nil {
+		return newDate(atoi(m[1]), 0, 0)
+	}
+	return Date{}, false
+}
+
+// FromWikidata converts a Wikidata time value ("+1795-07-14T00:00:00Z") and
+// its precision (9 year, 10 month, 11 day). Coarser precisions such as
+// decades are rejected.
+func FromWikidata(time string, precision int) (Date, bool) {
+	m := isoDate.FindStringSubmatch(time)
+	if m == nil || m[2] == "" || m[3] == "" {
+		return Date{}, false
+	}
+	year, month, day := atoi(m[1]), atoi(m[2]), atoi(m[3])
+	switch precision {
+	case 9:
+		return newDate(year, 0, 0)
+	case 10:
+		return newDate(year, month, 0)
+	case 11:
+		return newDate(year, month, day)
+	}
+	return Date{}, false
+}
+
+// WikidataYear returns the year of a Wikidata time value, negative for BCE
+func WikidataYear(time string) (int, bool) {
+	sign := 1
+	switch {
+	case strings.HasPrefix(time, "-"):
+		sign = -1
+		time = time[1:]
+	case strings.HasPrefix(time, "+"):
+		time = time[1:]
+	}
+	digits, _, ok := strings.Cut(time, "-")
+	if !ok {
+		return 0, false
+	}
+	year, err := strconv.Atoi(digits)
+	if err != nil || year == 0 {
+		return 0, false
+	}
+	return sign * year, true
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,50 @@
+package dates
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		value     string
+		precision string
+	}{
+		{"1795-07-14", "1795-07-14", PrecisionDay},
+		{"1795-07", "1795-07", PrecisionMonth},
+		{"1795", "1795", PrecisionYear},
+		{"1795-07-14T00:00:00Z", "1795-07-14", PrecisionDay},
+		{"1879-01-01T00:00:00Z", "1879", PrecisionYear},
+		{"14 July 1795", "1795-07-14", PrecisionDay},
+		{"July 14, 1795", "1795-07-14", PrecisionDay},
+		{"Sept. 1931", "1931-09", PrecisionMonth},
+		{"c. 1795, officially 1879", "1795", PrecisionYear},
+	}
+	for _, tt := range tests {
+		d, ok := Parse(tt.in)
+		if !ok || d.Value != tt.value || d.Precision != tt.precision {
+			t.Errorf("Parse(%q): expected %s (%s), got %+v %v", tt.in, tt.value, tt.precision, d, ok)
+		}
+	}
+	for _, in := range []string{"", "unknown", "1795-13-01", "1795-02-30", "31 April 1795", "1900-02-29"} {
+		if d, ok := Parse(in); ok {
+			t.Errorf("Parse(%q): expected no date, got %+v", in, d)
+		}
+	}
+}
+
+func TestFromWikidata(t *testing.T) {
+	if d, ok := FromWikidata("+1795-07-14T00:00:00Z", 10); !ok || d.Value != "1795-07" || d.Precision != PrecisionMonth {
+		t.Errorf("Expected 1795-07 (month), got %+v", d)
+	}
+	if d, ok := FromWikidata("+2000-02-29T00:00:00Z", 11); !ok || d.Value != "2000-02-29" {
+		t.Errorf("Expected the leap day 2000-02-29, got %+v", d)
+	}
+	if _, ok := FromWikidata("+1795-02-30T00:00:00Z", 11); ok {
+		t.Errorf("Expected 30 February to be rejected")
+	}
+	if _, ok := FromWikidata("+1790-00-00T00:00:00Z", 8); ok {
+		t.Errorf("Expected decade precision to be rejected")
+	}
+	if year, ok := WikidataYear("-0043-03-15T00:00:00Z"); !ok || year != -43 {
+		t.Errorf("Expected -43, got %d", year)
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/anthemworld/cli/pkg/audio"
+	"github.com/anthemworld/cli/pkg/dates"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
-	CurrentSchemaVersion = 2
+	CurrentSchemaVersion = 17
 )
 
 func GetDBPath() string {
@@ -56,10 +59,36 @@ func GetDB() (*sql.DB, error) {
 			return nil, fmt.Errorf("failed to apply migrations: %w", err)
 		}
 	}
+
+	if err := dropSearchTriggers(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to disable search triggers: %w", err)
+	}
 	
 	return db, nil
 }
 
+// OpenScratch opens a private in-memory database with the current schema,
+// for work such as simulations that must not touch the user's database.
+// It is gone once closed.
+func OpenScratch() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	if err := initSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+	if err := dropSearchTriggers(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to disable search triggers: %w", err)
+	}
+	return db, nil
+}
+
 func initSchema(db *sql.DB) error {
 	schema := `
 	-- Schema version tracking
@@ -161,12 +190,13 @@ func initSchema(db *sql.DB) error {
 
 	// Insert schema version
 	_, err := db.Exec("INSERT INTO schema_version (version, description) VALUES (?, ?)",
-		CurrentSchemaVersion, "Initial schema with migrations applied")
+		1, "Initial schema")
 	if err != nil {
 		return fmt.Errorf("failed to insert schema version: %w", err)
 	}
 
-	return nil
+	// Bring the fresh database up to date so new and migrated databases match
+	return applyMigrations(db)
 }
 
 // applyMigrations applies any pending schema migrations
@@ -218,9 +248,524 @@ func applyMigrations(db *sql.DB) error {
 		}
 	}
 
+	// Apply migration 3 if needed
+	if currentVersion < 3 {
+		migration3 := `
+		-- Recording classification inputs
+		ALTER TABLE audio_recordings ADD COLUMN bitrate_kbps INTEGER;
+		ALTER TABLE audio_recordings ADD COLUMN sample_rate_hz INTEGER;
+		ALTER TABLE audio_recordings ADD COLUMN wikidata_linked BOOLEAN DEFAULT 0;
+
+		INSERT INTO schema_version (version, description) VALUES (3, 'Audio recording classification');
+		`
+
+		if _, err := db.Exec(migration3); err != nil {
+			return fmt.Errorf("failed to apply migration 3: %w", err)
+		}
+	}
+
+	// Apply migration 4 if needed
+	if currentVersion < 4 {
+		if err := applyMigration4(db); err != nil {
+			return fmt.Errorf("failed to apply migration 4: %w", err)
+		}
+	}
+
+	// Apply migration 5 if needed
+	if currentVersion < 5 {
+		migration5 := `
+		-- Local audio mirror
+		ALTER TABLE audio_recordings ADD COLUMN local_path TEXT;
+		ALTER TABLE audio_recordings ADD COLUMN sha1 TEXT;
+
+		INSERT INTO schema_version (version, description) VALUES (5, 'Local audio mirror');
+		`
+
+		if _, err := db.Exec(migration5); err != nil {
+			return fmt.Errorf("failed to apply migration 5: %w", err)
+		}
+	}
+
+	// Apply migration 6 if needed
+	if currentVersion < 6 {
+		migration6 := `
+		-- Properties measured from mirrored audio files
+		ALTER TABLE audio_recordings ADD COLUMN codec TEXT;
+		ALTER TABLE audio_recordings ADD COLUMN channels INTEGER;
+		ALTER TABLE audio_recordings ADD COLUMN loudness_lufs REAL;
+
+		INSERT INTO schema_version (version, description) VALUES (6, 'Audio probing');
+		`
+
+		if _, err := db.Exec(migration6); err != nil {
+			return fmt.Errorf("failed to apply migration 6: %w", err)
+		}
+	}
+
+	// Apply migration 7 if needed
+	if currentVersion < 7 {
+		migration7 := `
+		-- Preview clips and waveform peaks, relative to the audio store
+		ALTER TABLE audio_recordings ADD COLUMN preview_path TEXT;
+		ALTER TABLE audio_recordings ADD COLUMN peaks_path TEXT;
+
+		INSERT INTO schema_version (version, description) VALUES (7, 'Audio previews and waveforms');
+		`
+
+		if _, err := db.Exec(migration7); err != nil {
+			return fmt.Errorf("failed to apply migration 7: %w", err)
+		}
+	}
+
+	// Apply migration 8 if needed
+	if currentVersion < 8 {
+		migration8 := `
+		-- Translated names of Wikidata items (countries and anthems)
+		CREATE TABLE IF NOT EXISTS labels (
+			entity TEXT NOT NULL,
+			lang TEXT NOT NULL,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity, lang)
+		);
+
+		ALTER TABLE countries ADD COLUMN wikidata_id TEXT;
+
+		INSERT INTO schema_version (version, description) VALUES (8, 'Multilingual labels');
+		`
+
+		if _, err := db.Exec(migration8); err != nil {
+			return fmt.Errorf("failed to apply migration 8: %w", err)
+		}
+	}
+
+	if currentVersion < 9 {
+		migration9 := `
+		-- One row per text of an anthem: original, transliteration or translation
+		CREATE TABLE IF NOT EXISTS lyrics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			anthem_id INTEGER NOT NULL REFERENCES anthems(id) ON DELETE CASCADE,
+			lang TEXT NOT NULL,
+			script TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			source TEXT NOT NULL,
+			source_url TEXT,
+			license TEXT,
+			copyright_status TEXT NOT NULL DEFAULT 'unknown',
+			attribution TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (anthem_id, lang, script, kind)
+		);
+
+		CREATE TABLE IF NOT EXISTS lyric_verses (
+			lyrics_id INTEGER NOT NULL REFERENCES lyrics(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			text TEXT NOT NULL,
+			PRIMARY KEY (lyrics_id, position)
+		);
+
+		INSERT INTO schema_version (version, description) VALUES (9, 'Anthem lyrics');
+		`
+
+		if _, err := db.Exec(migration9); err != nil {
+			return fmt.Errorf("failed to apply migration 9: %w", err)
+		}
+	}
+
+	if currentVersion < 10 {
+		if err := applyMigration10(db); err != nil {
+			return fmt.Errorf("failed to apply migration 10: %w", err)
+		}
+	}
+
+	if currentVersion < 11 {
+		if err := applyMigration11(db); err != nil {
+			return fmt.Errorf("failed to apply migration 11: %w", err)
+		}
+	}
+
+	if currentVersion < 12 {
+		migration12 := `
+		-- Plays of mirrored recordings served by worldanthem serve, per day
+		CREATE TABLE IF NOT EXISTS audio_plays (
+			country_id TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			day TEXT NOT NULL,
+			plays INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (country_id, recording_id, day)
+		);
+
+		INSERT INTO schema_version (version, description) VALUES (12, 'Audio play counts');
+		`
+
+		if _, err := db.Exec(migration12); err != nil {
+			return fmt.Errorf("failed to apply migration 12: %w", err)
+		}
+	}
+
+	if currentVersion < 13 {
+		migration13 := `
+		-- Offline copy of the anthem game (see pkg/game and sam/game)
+		CREATE TABLE IF NOT EXISTS game_rankings (
+			country_id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			flag_url TEXT,
+			anthem_name TEXT,
+			audio_url TEXT,
+			elo_score INTEGER NOT NULL DEFAULT 1500,
+			wins INTEGER NOT NULL DEFAULT 0,
+			losses INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS game_sessions (
+			session_id TEXT PRIMARY KEY,
+			user_country TEXT,
+			created_at TIMESTAMP NOT NULL,
+			vote_count INTEGER NOT NULL DEFAULT 0,
+			vote_date TEXT,
+			vote_count_today INTEGER NOT NULL DEFAULT 0,
+			matchup_id TEXT,
+			matchup_a TEXT,
+			matchup_b TEXT
+		);
+
+		CREATE TABLE IF NOT EXISTS game_votes (
+			vote_id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			matchup_id TEXT NOT NULL,
+			country_a TEXT,
+			winner_id TEXT NOT NULL,
+			loser_id TEXT NOT NULL,
+			listen_a_ms INTEGER NOT NULL DEFAULT 0,
+			listen_b_ms INTEGER NOT NULL DEFAULT 0,
+			listen_winner_ms INTEGER,
+			listen_loser_ms INTEGER,
+			vote_weight REAL,
+			winner_elo_before INTEGER,
+			winner_elo_after INTEGER,
+			loser_elo_before INTEGER,
+			loser_elo_after INTEGER,
+			voted_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_game_votes_session ON game_votes(session_id, voted_at);
+		CREATE INDEX IF NOT EXISTS idx_game_votes_voted_at ON game_votes(voted_at);
+
+		CREATE TABLE IF NOT EXISTS game_listens (
+			session_id TEXT NOT NULL,
+			country_id TEXT NOT NULL,
+			total_listen_ms INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (session_id, country_id)
+		);
+
+		INSERT INTO schema_version (version, description) VALUES (13, 'Anthem game');
+		`
+
+		if _, err := db.Exec(migration13); err != nil {
+			return fmt.Errorf("failed to apply migration 13: %w", err)
+		}
+	}
+
+	if currentVersion < 14 {
+		migration14 := `
+		-- Game data imported from DynamoDB, joined to countries for analysis
+		ALTER TABLE game_sessions ADD COLUMN ip_hash TEXT;
+		CREATE INDEX IF NOT EXISTS idx_game_sessions_ip ON game_sessions(ip_hash, created_at);
+
+		CREATE VIEW IF NOT EXISTS game_vote_details AS
+		SELECT v.vote_id, v.session_id, v.winner_id, v.loser_id, v.vote_weight, v.voted_at,
+			v.listen_winner_ms, v.listen_loser_ms,
+			UPPER(s.user_country) AS voter_country, vc.region AS voter_region,
+			w.iso_alpha2 AS winner_iso2, w.region AS winner_region,
+			l.iso_alpha2 AS loser_iso2, l.region AS loser_region
+		FROM game_votes v
+		LEFT JOIN game_sessions s ON s.session_id = v.session_id
+		LEFT JOIN countries vc ON vc.iso_alpha2 = UPPER(s.user_country)
+		LEFT JOIN countries w ON w.iso_alpha3 = v.winner_id
+		LEFT JOIN countries l ON l.iso_alpha3 = v.loser_id;
+
+		INSERT INTO schema_version (version, description) VALUES (14, 'Game analytics');
+		`
+
+		if _, err := db.Exec(migration14); err != nil {
+			return fmt.Errorf("failed to apply migration 14: %w", err)
+		}
+	}
+
+	if currentVersion < 15 {
+		migration15 := `
+		-- HTTP validators of mirrored files whose source publishes no SHA-1
+		ALTER TABLE audio_recordings ADD COLUMN source_etag TEXT;
+		ALTER TABLE audio_recordings ADD COLUMN source_last_modified TEXT;
+		ALTER TABLE audio_recordings ADD COLUMN source_size INTEGER;
+
+		INSERT INTO schema_version (version, description) VALUES (15, 'Audio mirror validators');
+		`
+
+		if _, err := db.Exec(migration15); err != nil {
+			return fmt.Errorf("failed to apply migration 15: %w", err)
+		}
+	}
+
+	if currentVersion < 16 {
+		migration16 := `
+		-- Database-wide state, such as a search index left stale by a build without FTS5
+		CREATE TABLE IF NOT EXISTS meta (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+
+		INSERT INTO schema_version (version, description) VALUES (16, 'Meta');
+		`
+
+		if _, err := db.Exec(migration16); err != nil {
+			return fmt.Errorf("failed to apply migration 16: %w", err)
+		}
+	}
+
+	if currentVersion < 17 {
+		migration17 := `
+		-- Data source that last wrote each country and anthem, copied by these
+		-- triggers from the writing_source meta key (see pkg/db/provenance.go)
+		ALTER TABLE countries ADD COLUMN updated_by TEXT;
+		ALTER TABLE anthems ADD COLUMN updated_by TEXT;
+
+		CREATE TRIGGER IF NOT EXISTS countries_updated_by_insert AFTER INSERT ON countries
+		WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source')
+		BEGIN
+			UPDATE countries SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS countries_updated_by_update AFTER UPDATE ON countries
+		WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source' AND value IS NOT NEW.updated_by)
+		BEGIN
+			UPDATE countries SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS anthems_updated_by_insert AFTER INSERT ON anthems
+		WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source')
+		BEGIN
+			UPDATE anthems SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS anthems_updated_by_update AFTER UPDATE ON anthems
+		WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source' AND value IS NOT NEW.updated_by)
+		BEGIN
+			UPDATE anthems SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+		END;
+
+		INSERT INTO schema_version (version, description) VALUES (17, 'Row sources');
+		`
+
+		if _, err := db.Exec(migration17); err != nil {
+			return fmt.Errorf("failed to apply migration 17: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// applyMigration10 adds people and anthem contributors keyed by Wikidata ID,
+// and a normalised adoption date. Existing free-text dates are parsed and
+// composers or lyricists stored as Wikidata URLs become contributors, so it
+// runs in Go. Names are filled in by "data contributors".
+func applyMigration10(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		-- Composers, lyricists and other people, keyed by Wikidata ID
+		CREATE TABLE IF NOT EXISTS people (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			birth_year INTEGER,
+			death_year INTEGER,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS anthem_contributors (
+			anthem_id INTEGER NOT NULL REFERENCES anthems(id) ON DELETE CASCADE,
+			person_id TEXT NOT NULL REFERENCES people(id),
+			role TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (anthem_id, person_id, role)
+		);
+
+		ALTER TABLE anthems ADD COLUMN adopted_on TEXT;
+		ALTER TABLE anthems ADD COLUMN adopted_precision TEXT;
+	`)
+	if err != nil {
+		return err
+	}
+
+	type anthemRow struct {
+		id                          int
+		adopted, composer, lyricist string
+	}
+	rows, err := tx.Query(`SELECT id, COALESCE(adopted_date,''), COALESCE(composer,''), COALESCE(lyricist,'') FROM anthems`)
+	if err != nil {
+		return err
+	}
+	var anthems []anthemRow
+	for rows.Next() {
+		var a anthemRow
+		if err := rows.Scan(&a.id, &a.adopted, &a.composer, &a.lyricist); err != nil {
+			rows.Close()
+			return err
+		}
+		anthems = append(anthems, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range anthems {
+		if d, ok := dates.Parse(a.adopted); ok {
+			if _, err := tx.Exec(`UPDATE anthems SET adopted_on = ?, adopted_precision = ? WHERE id = ?`,
+				d.Value, d.Precision, a.id); err != nil {
+				return err
+			}
+		}
+		for role, value := range map[string]string{"composer": a.composer, "lyricist": a.lyricist} {
+			qid := wikidataEntityID(value)
+			if qid == "" {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO people (id) VALUES (?)`, qid); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO anthem_contributors (anthem_id, person_id, role) VALUES (?, ?, ?)`,
+				a.id, qid, role); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, description) VALUES (10, 'People and structured dates')`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applyMigration11 adds the full-text search index. The columns it indexes
+// were added by the factbook source, so they are created here when missing;
+// the source ignores the duplicates. Builds without FTS5 skip the index, and
+// EnsureSearchIndex creates it later.
+func applyMigration11(db *sql.DB) error {
+	for _, c := range []struct{ table, column string }{
+		{"anthems", "anthem_title_en"},
+		{"anthems", "anthem_history"},
+		{"countries", "national_symbols"},
+	} {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`,
+			c.table, c.column).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s TEXT`, c.table, c.column)); err != nil {
+			return err
+		}
+	}
+
+	if err := EnsureSearchIndex(db); err != nil && err != ErrSearchUnavailable {
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO schema_version (version, description) VALUES (11, 'Full-text search')`)
+	return err
+}
+
+// wikidataEntityID returns the QID of a Wikidata entity URL such as
+// "http://www.wikidata.org/entity/Q1234", or "" for anything else
+func wikidataEntityID(s string) string {
+	for _, prefix := range []string{"http://www.wikidata.org/entity/", "https://www.wikidata.org/entity/", "https://www.wikidata.org/wiki/"} {
+		if qid, ok := strings.CutPrefix(s, prefix); ok && len(qid) > 1 && qid[0] == 'Q' {
+			return qid
+		}
+	}
+	return ""
+}
+
+// applyMigration4 replaces time-based audio recording IDs with stable,
+// content-derived ones (see audio.RecordingID), removes duplicate rows for
+// the same (source, url) and adds a unique index so duplicates can't return.
+// The remap needs SHA-1, so unlike earlier migrations it runs in Go.
+func applyMigration4(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT rowid, id, COALESCE(source,''), url, title
+		FROM audio_recordings
+		ORDER BY rowid
+	`)
+	if err != nil {
+		return err
+	}
+
+	type remap struct {
+		rowid int64
+		newID string
+	}
+	var duplicates []int64
+	var updates []remap
+	seenURL := make(map[string]bool)
+	seenID := make(map[string]bool)
+	for rows.Next() {
+		var rowid int64
+		var id, source, url, title string
+		if err := rows.Scan(&rowid, &id, &source, &url, &title); err != nil {
+			rows.Close()
+			return err
+		}
+		// Keep the oldest row for each file; later copies are duplicates
+		newID := audio.RecordingID(source, title)
+		key := source + "\x00" + url
+		if seenURL[key] || seenID[newID] {
+			duplicates = append(duplicates, rowid)
+			continue
+		}
+		seenURL[key] = true
+		seenID[newID] = true
+		if id != newID {
+			updates = append(updates, remap{rowid, newID})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, rowid := range duplicates {
+		if _, err := tx.Exec(`DELETE FROM audio_recordings WHERE rowid = ?`, rowid); err != nil {
+			return err
+		}
+	}
+	for _, u := range updates {
+		if _, err := tx.Exec(`UPDATE audio_recordings SET id = ? WHERE rowid = ?`, u.newID, u.rowid); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_audio_source_url ON audio_recordings(source, url);
+
+		INSERT INTO schema_version (version, description) VALUES (4, 'Stable audio recording IDs');
+	`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 type DataStats struct {
 	DatabaseExists  bool
 	SchemaApplied   bool
@@ -4,7 +4,11 @@ import (
 	"database/sql"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/anthemworld/cli/pkg/audio"
 )
 
 func setupTestDB(t *testing.T) (*sql.DB, func()) {
@@ -144,3 +148,264 @@ func TestGetLastCompletedJob(t *testing.T) {
 		t.Errorf("Expected job status 'COMPLETED', got '%s'", job.Status)
 	}
 }
+
+func TestMigration4StableRecordingIDs(t *testing.T) {
+	// A version 3 database with time-based IDs plus a duplicate
+	db := openSchemaVersion(t, 3)
+	_, err := db.Exec(`
+		INSERT INTO countries (id, name) VALUES ('fra', 'France');
+		INSERT INTO audio_recordings (id, country_id, title, url, source)
+		VALUES ('fra-1700000000000000001', 'fra', 'File:La Marseillaise.ogg', 'https://upload.example/a.ogg', 'wikimedia-commons'),
+		       ('fra-1700000000000000002', 'fra', 'File:La Marseillaise.ogg', 'https://upload.example/a.ogg', 'wikimedia-commons'),
+		       ('fra-1700000000000000003', 'fra', 'File:La Marseillaise (instrumental).ogg', 'https://upload.example/b.ogg', 'wikimedia-commons');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to prepare version 3 database: %v", err)
+	}
+
+	if err := applyMigration4(db); err != nil {
+		t.Fatalf("applyMigration4 failed: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audio_recordings").Scan(&count); err != nil {
+		t.Fatalf("Failed to count recordings: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 recordings after dedupe, got %d", count)
+	}
+
+	var id string
+	err = db.QueryRow("SELECT id FROM audio_recordings WHERE url = 'https://upload.example/a.ogg'").Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	if want := audio.RecordingID("wikimedia-commons", "La_Marseillaise.ogg"); id != want {
+		t.Errorf("Expected ID '%s', got '%s'", want, id)
+	}
+
+	// The unique index now rejects a second copy of the same file
+	_, err = db.Exec(`
+		INSERT INTO audio_recordings (id, country_id, title, url, source)
+		VALUES ('other', 'fra', 'Copy', 'https://upload.example/a.ogg', 'wikimedia-commons')
+	`)
+	if err == nil {
+		t.Error("Expected unique constraint error for duplicate (source, url)")
+	}
+}
+
+// openSchemaVersion creates a database at the given schema version by
+// running the schema files up to it from data/schema, which stay frozen once
+// released, so later migrations need no undoing
+func openSchemaVersion(t *testing.T, version int) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "..", "data", "schema", "[0-9][0-9][0-9]_*.sql"))
+	if err != nil || len(files) < version {
+		t.Fatalf("Expected schema files up to version %d, got %d (%v)", version, len(files), err)
+	}
+	sort.Strings(files)
+	for _, f := range files[:version] {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f, err)
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", filepath.Base(f), err)
+		}
+	}
+	return db
+}
+
+func TestMigration10(t *testing.T) {
+	// A version 9 database with anthems as the Wikidata source stores them
+	db := openSchemaVersion(t, 9)
+	_, err := db.Exec(`
+		INSERT INTO countries (id, name) VALUES ('fra', 'France');
+		INSERT INTO anthems (country_id, name, composer, lyricist, adopted_date)
+		VALUES ('fra', 'La Marseillaise', 'http://www.wikidata.org/entity/Q312750', 'Rouget de Lisle', '14 July 1795');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to prepare version 9 database: %v", err)
+	}
+
+	if err := applyMigrations(db); err != nil {
+		t.Fatalf("applyMigrations failed: %v", err)
+	}
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil || version != CurrentSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d (%v)", CurrentSchemaVersion, version, err)
+	}
+
+	var adoptedOn, precision string
+	if err := db.QueryRow(`SELECT adopted_on, adopted_precision FROM anthems`).Scan(&adoptedOn, &precision); err != nil {
+		t.Fatalf("Failed to read adoption date: %v", err)
+	}
+	if adoptedOn != "1795-07-14" || precision != "day" {
+		t.Errorf("Expected 1795-07-14 (day), got %s (%s)", adoptedOn, precision)
+	}
+
+	var person, role string
+	if err := db.QueryRow(`SELECT person_id, role FROM anthem_contributors`).Scan(&person, &role); err != nil {
+		t.Fatalf("Failed to read contributors: %v", err)
+	}
+	if person != "Q312750" || role != "composer" {
+		t.Errorf("Expected composer Q312750, got %s %s", role, person)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if !SearchAvailable(db) {
+		t.Skip("SQLite built without FTS5 (use -tags sqlite_fts5)")
+	}
+	if err := EnsureSearchIndex(db); err != nil {
+		t.Fatalf("EnsureSearchIndex failed: %v", err)
+	}
+
+	// Writes after the index exists are picked up by the triggers
+	_, err := db.Exec(`
+		INSERT INTO countries (id, name, common_name) VALUES ('fra', 'French Republic', 'France');
+		INSERT INTO anthems (id, country_id, name, composer) VALUES (1, 'fra', 'La Marseillaise', 'Claude Joseph Rouget de Lisle');
+		INSERT INTO countries (id, name) VALUES ('aut', 'Austria');
+		INSERT INTO anthems (id, country_id, name) VALUES (2, 'aut', 'Land der Berge, Land am Strome');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	results, err := Search(db, "marseillaise", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].CountryID != "fra" || results[0].AnthemID != 1 {
+		t.Fatalf("Expected the French anthem, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "[Marseillaise]") {
+		t.Errorf("Expected highlighted snippet, got %q", results[0].Snippet)
+	}
+
+	// Diacritics are folded, and a new contributor makes the anthem findable
+	_, err = db.Exec(`
+		INSERT INTO people (id, name) VALUES ('Q254', 'Wolfgang Amadeus Mozart');
+		INSERT INTO anthem_contributors (anthem_id, person_id, role, position) VALUES (2, 'Q254', 'composer', 0);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert contributor: %v", err)
+	}
+	results, err = Search(db, "composer:mozart", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].CountryID != "aut" {
+		t.Errorf("Expected Austria for composer:mozart, got %+v", results)
+	}
+
+	// Deleting the anthem removes it from the index
+	if _, err := db.Exec(`DELETE FROM anthems WHERE id = 1`); err != nil {
+		t.Fatalf("Failed to delete anthem: %v", err)
+	}
+	results, err = Search(db, "marseillaise", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results after delete, got %+v", results)
+	}
+}
+
+func TestSearchIndexStale(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if !SearchAvailable(db) {
+		// A trigger left by a build with FTS5 is dropped and the index marked stale
+		if _, err := db.Exec(`CREATE TRIGGER ` + searchTriggers[0].name + ` AFTER INSERT ON countries BEGIN SELECT 1; END`); err != nil {
+			t.Fatalf("Failed to create trigger: %v", err)
+		}
+		if err := dropSearchTriggers(db); err != nil {
+			t.Fatalf("dropSearchTriggers failed: %v", err)
+		}
+		if n, _ := countSearchTriggers(db); n != 0 {
+			t.Errorf("Expected search triggers to be dropped, got %d", n)
+		}
+		if since, err := SearchIndexStale(db); err != nil || since == "" {
+			t.Errorf("Expected the index to be marked stale, got %q (%v)", since, err)
+		}
+		return
+	}
+
+	if err := EnsureSearchIndex(db); err != nil {
+		t.Fatalf("EnsureSearchIndex failed: %v", err)
+	}
+	// What a build without FTS5 leaves behind: no triggers, a write the index
+	// missed and the stale marker
+	for _, tr := range searchTriggers {
+		if _, err := db.Exec(`DROP TRIGGER ` + tr.name); err != nil {
+			t.Fatalf("Failed to drop search trigger: %v", err)
+		}
+	}
+	_, err := db.Exec(`
+		INSERT INTO countries (id, name) VALUES ('fra', 'France');
+		INSERT INTO meta (key, value) VALUES ('search_index_stale', '2026-01-01T00:00:00Z');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to prepare stale index: %v", err)
+	}
+
+	results, err := Search(db, "france", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].CountryID != "fra" {
+		t.Errorf("Expected the rebuilt index to find France, got %+v", results)
+	}
+	if since, err := SearchIndexStale(db); err != nil || since != "" {
+		t.Errorf("Expected the stale marker to be cleared, got %q (%v)", since, err)
+	}
+}
+
+func TestWithWritingSource(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	err := WithWritingSource(db, "rest-countries-api", func() error {
+		_, err := db.Exec(`INSERT INTO countries (id, name) VALUES ('fra', 'France'), ('aut', 'Austria')`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithWritingSource failed: %v", err)
+	}
+	err = WithWritingSource(db, "factbook-json", func() error {
+		_, err := db.Exec(`
+			UPDATE countries SET national_symbols = 'Gallic rooster' WHERE id = 'fra';
+			INSERT INTO anthems (country_id, name) VALUES ('fra', 'La Marseillaise');
+		`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithWritingSource failed: %v", err)
+	}
+	// Outside a source, writes keep the last source
+	if _, err := db.Exec(`UPDATE countries SET capital = 'Vienna' WHERE id = 'aut'`); err != nil {
+		t.Fatalf("Failed to update country: %v", err)
+	}
+
+	for query, want := range map[string]string{
+		`SELECT updated_by FROM countries WHERE id = 'fra'`: "factbook-json",
+		`SELECT updated_by FROM countries WHERE id = 'aut'`: "rest-countries-api",
+		`SELECT updated_by FROM anthems`:                    "factbook-json",
+	} {
+		var got string
+		if err := db.QueryRow(query).Scan(&got); err != nil || got != want {
+			t.Errorf("%s: expected %q, got %q (%v)", query, want, got, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RecordPlay counts one play of a recording on the UTC day of at
+func RecordPlay(db *sql.DB, countryID, recordingID string, at time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO audio_plays (country_id, recording_id, day, plays) VALUES (?, ?, ?, 1)
+		ON CONFLICT(country_id, recording_id, day) DO UPDATE SET plays = plays + 1
+	`, countryID, recordingID, at.UTC().Format("2006-01-02"))
+	return err
+}
+
+// PlayCount is the number of plays of a country's recordings
+type PlayCount struct {
+	CountryID  string `json:"country_id"`
+	Plays      int    `json:"plays"`
+	Recordings int    `json:"recordings"` // distinct recordings played
+}
+
+// GetPlayCounts returns plays per country since the UTC day of since (all
+// time when since is zero), most played first
+func GetPlayCounts(db *sql.DB, since time.Time) ([]PlayCount, error) {
+	day := ""
+	if !since.IsZero() {
+		day = since.UTC().Format("2006-01-02")
+	}
+	rows, err := db.Query(`
+		SELECT country_id, SUM(plays), COUNT(DISTINCT recording_id)
+		FROM audio_plays
+		WHERE day >= ?
+		GROUP BY country_id
+		ORDER BY SUM(plays) DESC, country_id
+	`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []PlayCount
+	for rows.Next() {
+		var c PlayCount
+		if err := rows.Scan(&c.CountryID, &c.Plays, &c.Recordings); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
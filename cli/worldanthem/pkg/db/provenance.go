@@ -0,0 +1,21 @@
+package db
+
+import "database/sql"
+
+// writingSourceKey is the meta key naming the data source whose writes are
+// in progress; triggers copy it onto countries and anthems as updated_by
+const writingSourceKey = "writing_source"
+
+// WithWritingSource runs fn with source recorded as the writer of every
+// country and anthem row inserted or updated meanwhile. Writes made outside
+// it, such as manual edits, keep the updated_by of the last source.
+func WithWritingSource(db *sql.DB, source string, fn func() error) error {
+	if _, err := db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, writingSourceKey, source); err != nil {
+		return err
+	}
+	err := fn()
+	if _, clearErr := db.Exec(`DELETE FROM meta WHERE key = ?`, writingSourceKey); err == nil {
+		err = clearErr
+	}
+	return err
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSearchUnavailable is returned when SQLite was built without FTS5.
+// Build with `-tags sqlite_fts5` (the Makefile does) to enable search.
+var ErrSearchUnavailable = errors.New("full-text search needs a build with -tags sqlite_fts5")
+
+// searchColumns are the indexed columns of search_index, with the bm25
+// weight of each: a hit in an anthem's name counts more than one in a
+// history paragraph
+var searchColumns = []struct {
+	name   string
+	weight float64
+}{
+	{"country", 8},
+	{"anthem", 10},
+	{"title_en", 5},
+	{"composer", 5},
+	{"lyricist", 5},
+	{"history", 1},
+	{"symbols", 2},
+}
+
+// searchRows selects the search_index rows of the countries matching where,
+// one per anthem (or one for a country without an anthem). Contributor names
+// are added to the free-text composer and lyricist.
+const searchRows = `
+	SELECT c.id, a.id,
+		TRIM(COALESCE(c.common_name,'') || ' ' || c.name),
+		TRIM(COALESCE(a.name,'') || ' ' || COALESCE(a.native_name,'')),
+		COALESCE(a.anthem_title_en,''),
+		TRIM(COALESCE(a.composer,'') || ' ' || COALESCE((SELECT group_concat(p.name, ' ')
+			FROM anthem_contributors ac JOIN people p ON p.id = ac.person_id
+			WHERE ac.anthem_id = a.id AND ac.role = 'composer'), '')),
+		TRIM(COALESCE(a.lyricist,'') || ' ' || COALESCE((SELECT group_concat(p.name, ' ')
+			FROM anthem_contributors ac JOIN people p ON p.id = ac.person_id
+			WHERE ac.anthem_id = a.id AND ac.role = 'lyricist'), '')),
+		COALESCE(a.anthem_history,''),
+		COALESCE(c.national_symbols,'')
+	FROM countries c LEFT JOIN anthems a ON a.country_id = c.id
+	WHERE %s`
+
+// searchTriggers keep search_index in step with the tables it is built from.
+// Each trigger re-indexes the countries selected by its countries list.
+var searchTriggers = []struct {
+	name, event, countries string
+}{
+	{"search_countries_ai", "AFTER INSERT ON countries", "NEW.id"},
+	{"search_countries_au", "AFTER UPDATE ON countries", "OLD.id, NEW.id"},
+	{"search_countries_ad", "AFTER DELETE ON countries", "OLD.id"},
+	{"search_anthems_ai", "AFTER INSERT ON anthems", "NEW.country_id"},
+	{"search_anthems_au", "AFTER UPDATE ON anthems", "OLD.country_id, NEW.country_id"},
+	{"search_anthems_ad", "AFTER DELETE ON anthems", "OLD.country_id"},
+	{"search_contributors_ai", "AFTER INSERT ON anthem_contributors",
+		"SELECT country_id FROM anthems WHERE id = NEW.anthem_id"},
+	{"search_contributors_ad", "AFTER DELETE ON anthem_contributors",
+		"SELECT country_id FROM anthems WHERE id = OLD.anthem_id"},
+	{"search_people_au", "AFTER UPDATE OF name ON people",
+		"SELECT a.country_id FROM anthems a JOIN anthem_contributors ac ON ac.anthem_id = a.id WHERE ac.person_id = NEW.id"},
+}
+
+// SearchAvailable reports whether this build of SQLite includes FTS5
+func SearchAvailable(db *sql.DB) bool {
+	var used bool
+	err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used)
+	return err == nil && used
+}
+
+// searchStaleKey is the meta row recording when a build without FTS5 dropped
+// the search triggers, so writes since then are missing from search_index
+const searchStaleKey = "search_index_stale"
+
+// EnsureSearchIndex creates the search_index FTS5 table and its triggers if
+// they are missing, and fills it. The index is rebuilt as well when it was
+// marked stale. It is cheap when the index is in place.
+func EnsureSearchIndex(db *sql.DB) error {
+	if !SearchAvailable(db) {
+		return ErrSearchUnavailable
+	}
+
+	triggers, err := countSearchTriggers(db)
+	if err != nil {
+		return err
+	}
+	stale, err := SearchIndexStale(db)
+	if err != nil {
+		return err
+	}
+	if triggers == len(searchTriggers) && stale == "" {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	names := make([]string, len(searchColumns))
+	for i, c := range searchColumns {
+		names[i] = c.name
+	}
+	stmts := []string{
+		fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+			country_id UNINDEXED, anthem_id UNINDEXED, %s, tokenize = 'unicode61 remove_diacritics 2')`,
+			strings.Join(names, ", ")),
+		`DELETE FROM search_index`,
+		`INSERT INTO search_index SELECT * FROM (` + fmt.Sprintf(searchRows, "1") + `)`,
+	}
+	for _, t := range searchTriggers {
+		stmts = append(stmts,
+			`DROP TRIGGER IF EXISTS `+t.name,
+			fmt.Sprintf(`CREATE TRIGGER %s %s BEGIN
+				DELETE FROM search_index WHERE country_id IN (%s);
+				INSERT INTO search_index SELECT * FROM (%s);
+			END`, t.name, t.event, t.countries, fmt.Sprintf(searchRows, "c.id IN ("+t.countries+")")))
+	}
+	if stale != "" {
+		stmts = append(stmts, `DELETE FROM meta WHERE key = '`+searchStaleKey+`'`)
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to build search index: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func countSearchTriggers(db *sql.DB) (int, error) {
+	var triggers int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'search\_%' ESCAPE '\'`).Scan(&triggers)
+	return triggers, err
+}
+
+// SearchIndexStale returns when a build without FTS5 last dropped the search
+// triggers, or "" if the index is current. A stale index is rebuilt by the
+// next EnsureSearchIndex in a build that has FTS5.
+func SearchIndexStale(db *sql.DB) (string, error) {
+	// meta is missing while migrations before 16 run
+	var hasMeta bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM pragma_table_info('meta'))`).Scan(&hasMeta); err != nil || !hasMeta {
+		return "", err
+	}
+	var since string
+	err := db.QueryRow(`SELECT value FROM meta WHERE key = ?`, searchStaleKey).Scan(&since)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return since, err
+}
+
+// dropSearchTriggers removes the search triggers when FTS5 is unavailable,
+// since they would make every write to countries and anthems fail, and marks
+// the index stale so a build that has FTS5 rebuilds it.
+func dropSearchTriggers(db *sql.DB) error {
+	if SearchAvailable(db) {
+		return nil
+	}
+	triggers, err := countSearchTriggers(db)
+	if err != nil || triggers == 0 {
+		return err
+	}
+	for _, t := range searchTriggers {
+		if _, err := db.Exec(`DROP TRIGGER IF EXISTS ` + t.name); err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec(`INSERT OR IGNORE INTO meta (key, value) VALUES (?, ?)`,
+		searchStaleKey, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// SearchResult is one anthem (or country without one) matching a search
+type SearchResult struct {
+	CountryID string
+	AnthemID  int // 0 for a country without an anthem
+	Country   string
+	Anthem    string
+	Snippet   string  // best-matching passage, hits wrapped in [ ]
+	Rank      float64 // bm25, lower is better
+}
+
+// Search runs a full-text query, best matches first. Plain words must all
+// match; FTS5 syntax is passed through for column filters ("composer:haydn"),
+// phrases, OR/NOT and prefixes ("liber*").
+func Search(db *sql.DB, query string, limit int) ([]SearchResult, error) {
+	if err := EnsureSearchIndex(db); err != nil {
+		return nil, err
+	}
+	return SearchIndexed(db, query, limit)
+}
+
+// SearchIndexReady reports whether search_index can be queried as it is:
+// FTS5 is available and the index is built, with its triggers, and not stale
+func SearchIndexReady(db *sql.DB) bool {
+	if !SearchAvailable(db) {
+		return false
+	}
+	triggers, err := countSearchTriggers(db)
+	if err != nil || triggers != len(searchTriggers) {
+		return false
+	}
+	stale, err := SearchIndexStale(db)
+	return err == nil && stale == ""
+}
+
+// SearchIndexed is Search without building the index first, for readers such
+// as the API server that must not write; check SearchIndexReady before.
+func SearchIndexed(db *sql.DB, query string, limit int) ([]SearchResult, error) {
+	match := searchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	weights := make([]string, 0, len(searchColumns)+2)
+	weights = append(weights, "0", "0") // country_id, anthem_id
+	for _, c := range searchColumns {
+		weights = append(weights, fmt.Sprint(c.weight))
+	}
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT s.country_id, COALESCE(s.anthem_id, 0), COALESCE(NULLIF(c.common_name, ''), c.name), COALESCE(a.name, ''),
+			snippet(search_index, -1, '[', ']', '…', 12), bm25(search_index, %s) AS rank
+		FROM search_index s
+		JOIN countries c ON c.id = s.country_id
+		LEFT JOIN anthems a ON a.id = s.anthem_id
+		WHERE search_index MATCH ?
+		ORDER BY rank LIMIT ?
+	`, strings.Join(weights, ", ")), match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.CountryID, &r.AnthemID, &r.Country, &r.Anthem, &r.Snippet, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// searchQuery turns plain words into an FTS5 query that requires each of
+// them, quoting them so punctuation can't be misread as syntax. Queries that
+// already use FTS5 syntax are left alone.
+func searchQuery(q string) string {
+	q = strings.TrimSpace(q)
+	if strings.ContainsAny(q, `":*()^`) {
+		return q
+	}
+	words := strings.Fields(q)
+	for i, w := range words {
+		if w == "AND" || w == "OR" || w == "NOT" {
+			return q
+		}
+		words[i] = `"` + w + `"`
+	}
+	return strings.Join(words, " ")
+}
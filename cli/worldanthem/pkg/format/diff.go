@@ -0,0 +1,336 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Countries returns every country with its anthem and audio, as exported
+func Countries(db *sql.DB) ([]CountryRecord, error) {
+	return queryCountries(db)
+}
+
+// LoadExportDir reads the countries of an export directory from whichever
+// file it has: anthems.json, countries.ndjson or countries/<iso3>.json shards.
+func LoadExportDir(dir string) ([]CountryRecord, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, "anthems.json")); err == nil {
+		var indexed map[string]CountryRecord
+		if err := json.Unmarshal(data, &indexed); err != nil {
+			return nil, fmt.Errorf("parse anthems.json: %w", err)
+		}
+		countries := make([]CountryRecord, 0, len(indexed))
+		for _, c := range indexed {
+			countries = append(countries, c)
+		}
+		return countries, nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "countries.ndjson")); err == nil {
+		var countries []CountryRecord
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var c CountryRecord
+			if err := dec.Decode(&c); err == io.EOF {
+				return countries, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("parse countries.ndjson: %w", err)
+			}
+			countries = append(countries, c)
+		}
+	}
+
+	shards, _ := filepath.Glob(filepath.Join(dir, "countries", "*.json"))
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("%s has no anthems.json, countries.ndjson or countries/*.json", dir)
+	}
+	countries := make([]CountryRecord, 0, len(shards))
+	for _, path := range shards {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var c CountryRecord
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		countries = append(countries, c)
+	}
+	return countries, nil
+}
+
+// ExportDiff lists what changed between two exports, keyed by ISO alpha-3
+type ExportDiff struct {
+	Added   []CountryRef  `json:"added_countries"`
+	Removed []CountryRef  `json:"removed_countries"`
+	Changed []CountryDiff `json:"changed_countries"`
+}
+
+// CountryRef identifies a country in a diff
+type CountryRef struct {
+	ISO3 string `json:"iso_alpha3"`
+	Name string `json:"name"`
+}
+
+// CountryDiff is the set of changes to one country
+type CountryDiff struct {
+	CountryRef
+	Fields       []FieldChange `json:"fields,omitempty"` // e.g. "capital", "anthem.composer"
+	AddedAudio   []AudioRef    `json:"added_audio,omitempty"`
+	RemovedAudio []AudioRef    `json:"removed_audio,omitempty"`
+}
+
+// FieldChange is one changed country or anthem field
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// AudioRef identifies a recording in a diff
+type AudioRef struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+}
+
+// Empty reports whether nothing changed
+func (d *ExportDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffCountries compares two sets of countries. Countries are matched by
+// ISO alpha-3, recordings by their stable ID.
+func DiffCountries(oldCountries, newCountries []CountryRecord) *ExportDiff {
+	oldByKey := make(map[string]*CountryRecord, len(oldCountries))
+	for i := range oldCountries {
+		oldByKey[CountryKey(&oldCountries[i])] = &oldCountries[i]
+	}
+	newByKey := make(map[string]*CountryRecord, len(newCountries))
+	for i := range newCountries {
+		newByKey[CountryKey(&newCountries[i])] = &newCountries[i]
+	}
+
+	d := &ExportDiff{Added: []CountryRef{}, Removed: []CountryRef{}, Changed: []CountryDiff{}}
+	for _, key := range sortedKeys(newByKey) {
+		n := newByKey[key]
+		o, ok := oldByKey[key]
+		if !ok {
+			d.Added = append(d.Added, CountryRef{ISO3: key, Name: DisplayName(n)})
+			continue
+		}
+		if cd := diffCountry(key, o, n); cd != nil {
+			d.Changed = append(d.Changed, *cd)
+		}
+	}
+	for _, key := range sortedKeys(oldByKey) {
+		if _, ok := newByKey[key]; !ok {
+			d.Removed = append(d.Removed, CountryRef{ISO3: key, Name: DisplayName(oldByKey[key])})
+		}
+	}
+	return d
+}
+
+func diffCountry(key string, o, n *CountryRecord) *CountryDiff {
+	cd := &CountryDiff{CountryRef: CountryRef{ISO3: key, Name: DisplayName(n)}}
+	cd.Fields = diffFields("", reflect.ValueOf(*o), reflect.ValueOf(*n))
+
+	switch {
+	case o.Anthem == nil && n.Anthem != nil:
+		cd.Fields = append(cd.Fields, FieldChange{Field: "anthem", New: n.Anthem.Name})
+	case o.Anthem != nil && n.Anthem == nil:
+		cd.Fields = append(cd.Fields, FieldChange{Field: "anthem", Old: o.Anthem.Name})
+	case o.Anthem != nil && n.Anthem != nil:
+		cd.Fields = append(cd.Fields, diffFields("anthem.", reflect.ValueOf(*o.Anthem), reflect.ValueOf(*n.Anthem))...)
+	}
+
+	oldAudio := make(map[string]bool, len(o.AudioFiles))
+	for _, a := range o.AudioFiles {
+		oldAudio[a.ID] = true
+	}
+	newAudio := make(map[string]bool, len(n.AudioFiles))
+	for _, a := range n.AudioFiles {
+		newAudio[a.ID] = true
+		if !oldAudio[a.ID] {
+			cd.AddedAudio = append(cd.AddedAudio, AudioRef{ID: a.ID, Title: a.Title, URL: a.URL})
+		}
+	}
+	for _, a := range o.AudioFiles {
+		if !newAudio[a.ID] {
+			cd.RemovedAudio = append(cd.RemovedAudio, AudioRef{ID: a.ID, Title: a.Title, URL: a.URL})
+		}
+	}
+
+	if len(cd.Fields) == 0 && len(cd.AddedAudio) == 0 && len(cd.RemovedAudio) == 0 {
+		return nil
+	}
+	return cd
+}
+
+// diffFields compares the scalar fields of two structs of the same type
+func diffFields(prefix string, o, n reflect.Value) []FieldChange {
+	var changes []FieldChange
+	for _, f := range scalarFields(o.Type()) {
+		ov, nv := fieldString(o.Field(f.index)), fieldString(n.Field(f.index))
+		if ov != nv {
+			changes = append(changes, FieldChange{Field: prefix + f.name, Old: ov, New: nv})
+		}
+	}
+	return changes
+}
+
+func fieldString(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return csvValue(v.Interface())
+}
+
+// DisplayName is the name a country is shown by: its common name, or its
+// official name when it has none
+func DisplayName(c *CountryRecord) string {
+	if c.CommonName != "" {
+		return c.CommonName
+	}
+	return c.Name
+}
+
+func sortedKeys(m map[string]*CountryRecord) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Truncate shortens long values such as history paragraphs for display,
+// folding runs of whitespace into one space
+func Truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// WriteText writes the diff for a terminal
+func (d *ExportDiff) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "=== Export Diff ===")
+	fmt.Fprintf(bw, "%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed))
+
+	if len(d.Added) > 0 {
+		fmt.Fprintln(bw, "\nAdded countries:")
+		for _, c := range d.Added {
+			fmt.Fprintf(bw, "  + %s (%s)\n", c.Name, c.ISO3)
+		}
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintln(bw, "\nRemoved countries:")
+		for _, c := range d.Removed {
+			fmt.Fprintf(bw, "  - %s (%s)\n", c.Name, c.ISO3)
+		}
+	}
+	if len(d.Changed) > 0 {
+		fmt.Fprintln(bw, "\nChanged countries:")
+		for _, c := range d.Changed {
+			fmt.Fprintf(bw, "  ~ %s (%s)\n", c.Name, c.ISO3)
+			for _, f := range c.Fields {
+				fmt.Fprintf(bw, "      %s: %s → %s\n", f.Field, orNone(Truncate(f.Old, 60)), orNone(Truncate(f.New, 60)))
+			}
+			for _, a := range c.AddedAudio {
+				fmt.Fprintf(bw, "      + audio %s\n", a.Title)
+			}
+			for _, a := range c.RemovedAudio {
+				fmt.Fprintf(bw, "      - audio %s\n", a.Title)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteMarkdown writes the diff as a release note / "What's new" section
+func (d *ExportDiff) WriteMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "## What's new")
+	fmt.Fprintln(bw)
+	if d.Empty() {
+		fmt.Fprintln(bw, "No changes to countries, anthems or recordings.")
+		return bw.Flush()
+	}
+
+	var newAudio int
+	for _, c := range d.Changed {
+		newAudio += len(c.AddedAudio)
+	}
+	fmt.Fprintf(bw, "%d countries added, %d removed, %d updated, %d new recordings.\n",
+		len(d.Added), len(d.Removed), len(d.Changed), newAudio)
+
+	if len(d.Added) > 0 {
+		fmt.Fprintln(bw, "\n### Added countries")
+		fmt.Fprintln(bw)
+		for _, c := range d.Added {
+			fmt.Fprintf(bw, "- %s (%s)\n", markdownEscape(c.Name), c.ISO3)
+		}
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintln(bw, "\n### Removed countries")
+		fmt.Fprintln(bw)
+		for _, c := range d.Removed {
+			fmt.Fprintf(bw, "- %s (%s)\n", markdownEscape(c.Name), c.ISO3)
+		}
+	}
+	if len(d.Changed) > 0 {
+		fmt.Fprintln(bw, "\n### Updated countries")
+		for _, c := range d.Changed {
+			fmt.Fprintf(bw, "\n#### %s (%s)\n\n", markdownEscape(c.Name), c.ISO3)
+			if len(c.Fields) > 0 {
+				fmt.Fprintln(bw, "| Field | Before | After |")
+				fmt.Fprintln(bw, "|---|---|---|")
+				for _, f := range c.Fields {
+					fmt.Fprintf(bw, "| %s | %s | %s |\n", f.Field,
+						tableCell(orNone(Truncate(f.Old, 80))), tableCell(orNone(Truncate(f.New, 80))))
+				}
+				if len(c.AddedAudio) > 0 || len(c.RemovedAudio) > 0 {
+					fmt.Fprintln(bw)
+				}
+			}
+			for _, a := range c.AddedAudio {
+				fmt.Fprintf(bw, "- New recording: [%s](%s)\n", markdownEscape(a.Title), a.URL)
+			}
+			for _, a := range c.RemovedAudio {
+				fmt.Fprintf(bw, "- Removed recording: %s\n", markdownEscape(a.Title))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+var markdownReplacer = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "`", "\\`")
+
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+func tableCell(s string) string {
+	return strings.ReplaceAll(markdownEscape(s), "|", `\|`)
+}
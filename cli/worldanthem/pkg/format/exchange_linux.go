@@ -0,0 +1,11 @@
+//go:build linux
+
+package format
+
+import "golang.org/x/sys/unix"
+
+// exchangeDirs atomically swaps two paths on the same filesystem. It fails
+// on filesystems without RENAME_EXCHANGE and kernels before 3.15.
+var exchangeDirs = func(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package format
+
+import "errors"
+
+// exchangeDirs atomically swaps two paths where the OS supports it
+var exchangeDirs = func(a, b string) error {
+	return errors.ErrUnsupported
+}
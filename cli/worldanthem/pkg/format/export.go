@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/anthemworld/cli/pkg/audio"
 )
 
 // CountryRecord is the JSON representation of a country for the Hugo site
@@ -25,12 +29,18 @@ type CountryRecord struct {
 	FlagURL        string `json:"flag_url,omitempty"` // derived from factbook_code
 	Anthem         *AnthemRecord      `json:"anthem,omitempty"`
 	AudioFiles     []AudioRecord      `json:"audio_files,omitempty"`
+	Lyrics         []LyricsRecord     `json:"lyrics,omitempty"` // only texts that may be published
+	Game           *GameRecord        `json:"game,omitempty"`   // see Options.GameRanks
+
+	unMember   bool
+	wikidataID string
 }
 
 // AnthemRecord is the JSON representation of an anthem
 type AnthemRecord struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
+	NativeName  string `json:"native_name,omitempty"` // title in the anthem's own language
 	TitleEn     string `json:"title_en,omitempty"` // English translation
 	Composer    string `json:"composer,omitempty"`
 	Lyricist    string `json:"lyricist,omitempty"`
@@ -38,6 +48,11 @@ type AnthemRecord struct {
 	WikidataID  string `json:"wikidata_id,omitempty"`
 	WikipediaURL string `json:"wikipedia_url,omitempty"`
 	History     string `json:"history,omitempty"` // CIA factbook history paragraph
+
+	// Structured forms of Composer, Lyricist and AdoptedDate
+	Composers []PersonRecord `json:"composers,omitempty"`
+	Lyricists []PersonRecord `json:"lyricists,omitempty"`
+	Adopted   *DateRecord    `json:"adopted,omitempty"`
 }
 
 // AudioRecord is the JSON representation of an audio recording
@@ -48,80 +63,299 @@ type AudioRecord struct {
 	Format   string `json:"format,omitempty"`
 	Duration int    `json:"duration_seconds,omitempty"`
 	Type     string `json:"type,omitempty"`
+	Quality  string `json:"quality,omitempty"`
 	Source   string `json:"source,omitempty"`
 	License  string `json:"license,omitempty"`
+	Primary  bool   `json:"primary,omitempty"` // canonical track for the country (listed first)
+
+	// Measured by `data audio probe`; loudness lets the player normalise volume
+	// and is absent only for Opus and silent recordings
+	Codec        string   `json:"codec,omitempty"`
+	Channels     int      `json:"channels,omitempty"`
+	SampleRateHz int      `json:"sample_rate_hz,omitempty"`
+	BitrateKbps  int      `json:"bitrate_kbps,omitempty"`
+	LoudnessLUFS *float64 `json:"loudness_lufs,omitempty"`
+
+	// Written by `data audio derive`; exported under the audio base URL,
+	// like mirrored audio, and left out without one
+	PreviewURL string `json:"preview_url,omitempty"`
+	PeaksURL   string `json:"peaks_url,omitempty"`
+
+	wikidataLinked bool
+	sha1           string
 }
 
 // IndexRecord is the manifest file
 type IndexRecord struct {
+	SchemaVersion  int    `json:"schema_version"` // see ExportSchemaVersion and schemas/
 	GeneratedAt    string `json:"generated_at"`
 	TotalCountries int    `json:"total_countries"`
 	TotalAnthems   int    `json:"total_anthems"`
 	TotalAudio     int    `json:"total_audio"`
 	WithHistory    int    `json:"with_history"`
-	Files          []string `json:"files"`
+	WithLyrics     int    `json:"with_lyrics,omitempty"`
+	Files          []string `json:"files"` // every file written, including index.json
+
+	// Written with Options.Shard: country key → "countries/<iso3>.json"
+	Shards map[string]string `json:"shards,omitempty"`
+	// SHA-256 of every file except index.json, for cache-busting
+	Hashes map[string]string `json:"hashes,omitempty"`
+	// Filter that selected the exported countries; absent when all were exported
+	Filter *Filter `json:"filter,omitempty"`
+	// Translated copies are written to <lang>/ (e.g. fr/anthems.json), with
+	// labels looked up along the language's chain, then Fallback
+	Languages []string `json:"languages,omitempty"`
+	Fallback  []string `json:"fallback,omitempty"`
 }
 
-// ExportToDir queries the database and writes JSON files to outputDir.
-// It creates the following files:
-//   - anthems.json  — all countries indexed by ISO alpha-3, including anthem + audio
-//   - index.json    — manifest with stats
-func ExportToDir(db *sql.DB, outputDir string) error {
+// Totals counts the countries, anthems and recordings of an export, as
+// written to index.json
+type Totals struct {
+	Countries   int `json:"total_countries"`
+	Anthems     int `json:"total_anthems"`
+	Audio       int `json:"total_audio"`
+	WithHistory int `json:"with_history"`
+	WithLyrics  int `json:"with_lyrics"`
+}
+
+// CountTotals counts the countries, those with an anthem, anthem history
+// or publishable lyrics, and all their recordings, as index.json and the
+// API's /stats report them
+func CountTotals(countries []CountryRecord) Totals {
+	t := Totals{Countries: len(countries)}
+	for _, c := range countries {
+		if len(c.Lyrics) > 0 {
+			t.WithLyrics++
+		}
+		if c.Anthem != nil {
+			t.Anthems++
+			if c.Anthem.History != "" {
+				t.WithHistory++
+			}
+		}
+		t.Audio += len(c.AudioFiles)
+	}
+	return t
+}
+
+// Options controls how ExportToDir writes its files
+type Options struct {
+	// Format selects the registered exporter (see Formats); empty means "json"
+	Format string
+	// AudioBaseURL, when set, replaces audio URLs of mirrored recordings with
+	// AudioBaseURL + "/" + their content-addressed store path, e.g.
+	// "https://cdn.example.org/audio" or "/audio". Unmirrored recordings keep
+	// their source URL. Preview and peaks URLs use the same base and are
+	// only exported when it is set.
+	AudioBaseURL string
+	// Shard additionally writes countries/<iso3>.json per country and a slim
+	// summary.json, so pages only download what they show
+	Shard bool
+	// HugoContentDir, when set, also writes one Hugo page per country there
+	// (see WriteHugoContent)
+	HugoContentDir string
+	// GeneratedAt is recorded in index.json; zero means now. Set it from
+	// SOURCE_DATE_EPOCH for byte-identical exports (see SourceDateEpoch).
+	GeneratedAt time.Time
+	// Filter limits the export to a subset of countries
+	Filter Filter
+	// Languages also writes a translated copy of the export to <lang>/ for
+	// each language. Missing labels fall back along LanguageChain(lang, Fallback).
+	Languages []string
+	Fallback  []string
+	// SearchIndex also writes search.json, a prebuilt index for searching
+	// on the static site (see SearchIndex)
+	SearchIndex bool
+	// GameRanks adds each country's current anthem game rank and rating,
+	// from game_rankings, to its record
+	GameRanks bool
+}
+
+// SourceDateEpoch returns the time set in $SOURCE_DATE_EPOCH (seconds since
+// the Unix epoch), the reproducible-builds.org convention for build timestamps.
+func SourceDateEpoch() (time.Time, error) {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Time{}, fmt.Errorf("SOURCE_DATE_EPOCH is not set")
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", v, err)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// ExportToDir queries the database and writes files to outputDir using the
+// exporter selected by opts.Format. Every format also writes:
+//   - index.json    — manifest with stats, the list of files written and their hashes
+//   - schemas/*.schema.json — JSON Schema for each export file (see Schemas)
+//
+// With opts.Shard it also writes:
+//   - countries/<iso3>.json — one full country record per file
+//   - summary.json          — slim records for the table and map
+//
+// With opts.SearchIndex it also writes search.json (see SearchIndex).
+//
+// Files are written to a staging directory first, which replaces outputDir
+// once the whole export succeeded (see publish), so a failed export never
+// leaves half-written files in a live site and readers never see a mix of
+// two exports. Output is deterministic: countries are
+// ordered by name, audio by type and ID, and JSON object keys are sorted.
+func ExportToDir(db *sql.DB, outputDir string, opts Options) error {
+	name := opts.Format
+	if name == "" {
+		name = "json"
+	}
+	exporter, err := Lookup(name)
+	if err != nil {
+		return err
+	}
+	if err := validateLanguages(opts.Languages); err != nil {
+		return err
+	}
+	if err := validateLanguages(opts.Fallback); err != nil {
+		return err
+	}
+
 	countries, err := queryCountries(db)
 	if err != nil {
 		return fmt.Errorf("querying countries: %w", err)
 	}
 
-	// Index by ISO alpha-3 (uppercase, e.g. "USA")
-	indexed := make(map[string]*CountryRecord, len(countries))
-	for i := range countries {
-		key := countries[i].ISOAlpha3
-		if key == "" {
-			key = countries[i].ID
+	countries, err = opts.Filter.Apply(countries)
+	if err != nil {
+		return err
+	}
+
+	if opts.GameRanks {
+		if err := addGameRanks(db, countries); err != nil {
+			return fmt.Errorf("querying game ranks: %w", err)
 		}
-		indexed[key] = &countries[i]
 	}
 
-	withHistory := 0
-	for _, c := range indexed {
-		if c.Anthem != nil && c.Anthem.History != "" {
-			withHistory++
+	rewriteAudioURLs(countries, opts.AudioBaseURL)
+
+	staging, err := newStagingDir(outputDir)
+	if err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	files, err := exporter.Export(countries, staging)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	var shards map[string]string
+	if opts.Shard {
+		shardFiles, byCountry, err := writeShards(countries, staging)
+		if err != nil {
+			return fmt.Errorf("writing shards: %w", err)
 		}
+		files = append(files, shardFiles...)
+		shards = byCountry
 	}
 
-	// Write anthems.json
-	anthemsPath := filepath.Join(outputDir, "anthems.json")
-	if err := writeJSON(anthemsPath, indexed); err != nil {
-		return err
+	if opts.SearchIndex {
+		searchFiles, err := writeSearchIndex(countries, staging)
+		if err != nil {
+			return fmt.Errorf("writing search index: %w", err)
+		}
+		files = append(files, searchFiles...)
 	}
 
-	totalAnthems := 0
-	totalAudio := 0
-	for _, c := range indexed {
-		if c.Anthem != nil {
-			totalAnthems++
+	if len(opts.Languages) > 0 {
+		labels, err := queryLabels(db)
+		if err != nil {
+			return fmt.Errorf("querying labels: %w", err)
+		}
+		for _, lang := range opts.Languages {
+			langFiles, err := writeLocalized(exporter, localize(countries, labels, LanguageChain(lang, opts.Fallback)),
+				filepath.Join(staging, lang), opts.Shard)
+			if err != nil {
+				return fmt.Errorf("writing %s export: %w", lang, err)
+			}
+			for _, f := range langFiles {
+				files = append(files, lang+"/"+f)
+			}
 		}
-		totalAudio += len(c.AudioFiles)
+	}
+
+	schemaFiles, err := writeSchemas(staging)
+	if err != nil {
+		return fmt.Errorf("writing schemas: %w", err)
+	}
+	files = append(files, schemaFiles...)
+
+	hashes, err := hashFiles(staging, files)
+	if err != nil {
+		return fmt.Errorf("hashing files: %w", err)
+	}
+
+	totals := CountTotals(countries)
+
+	generatedAt := opts.GeneratedAt
+	if generatedAt.IsZero() {
+		generatedAt = time.Now()
 	}
 
 	// Write index.json
 	idx := IndexRecord{
-		GeneratedAt:    time.Now().UTC().Format(time.RFC3339),
-		TotalCountries: len(indexed),
-		TotalAnthems:   totalAnthems,
-		TotalAudio:     totalAudio,
-		WithHistory:    withHistory,
-		Files:          []string{"anthems.json", "index.json"},
-	}
-	indexPath := filepath.Join(outputDir, "index.json")
-	if err := writeJSON(indexPath, idx); err != nil {
+		SchemaVersion:  ExportSchemaVersion,
+		GeneratedAt:    generatedAt.UTC().Format(time.RFC3339),
+		TotalCountries: totals.Countries,
+		TotalAnthems:   totals.Anthems,
+		TotalAudio:     totals.Audio,
+		WithHistory:    totals.WithHistory,
+		WithLyrics:     totals.WithLyrics,
+		Files:          append(files, "index.json"),
+		Shards:         shards,
+		Hashes:         hashes,
+	}
+	if !opts.Filter.IsZero() {
+		idx.Filter = &opts.Filter
+	}
+	if len(opts.Languages) > 0 {
+		idx.Languages = opts.Languages
+		idx.Fallback = opts.Fallback
+	}
+	if err := writeJSON(filepath.Join(staging, "index.json"), idx); err != nil {
 		return err
 	}
 
-	fmt.Printf("  ✓ %d countries, %d anthems, %d audio files, %d with history\n",
-		len(indexed), totalAnthems, totalAudio, withHistory)
-	fmt.Printf("  → %s\n", anthemsPath)
-	fmt.Printf("  → %s\n", indexPath)
+	if err := publish(staging, outputDir, idx.Files); err != nil {
+		return fmt.Errorf("publishing export: %w", err)
+	}
+
+	fmt.Printf("  ✓ %d countries, %d anthems, %d audio files, %d with history, %d with lyrics\n",
+		totals.Countries, totals.Anthems, totals.Audio, totals.WithHistory, totals.WithLyrics)
+	for _, f := range idx.Files {
+		if strings.Contains(f, "/") {
+			continue
+		}
+		fmt.Printf("  → %s\n", filepath.Join(outputDir, f))
+	}
+	if len(shards) > 0 {
+		fmt.Printf("  → %s (%d shards)\n", filepath.Join(outputDir, "countries"), len(shards))
+	}
+	fmt.Printf("  → %s (%d schemas)\n", filepath.Join(outputDir, "schemas"), len(schemaFiles))
+	for _, lang := range opts.Languages {
+		fmt.Printf("  → %s (%s, falling back to %s)\n", filepath.Join(outputDir, lang), lang,
+			strings.Join(LanguageChain(lang, opts.Fallback)[1:], ", "))
+	}
+
+	if opts.HugoContentDir != "" {
+		stats, err := WriteHugoContent(countries, opts.HugoContentDir)
+		if err != nil {
+			return fmt.Errorf("writing hugo content: %w", err)
+		}
+		fmt.Printf("  ✓ Hugo pages: %d written, %d unchanged, %d deleted\n",
+			stats.Written, stats.Unchanged, len(stats.Deleted))
+		for _, name := range stats.Deleted {
+			fmt.Printf("    - %s\n", name)
+		}
+		fmt.Printf("  → %s\n", opts.HugoContentDir)
+	}
 	return nil
 }
 
@@ -131,7 +365,7 @@ func queryCountries(db *sql.DB) ([]CountryRecord, error) {
 	hasColors := columnExists(db, "countries", "national_colors")
 
 	query := `SELECT c.id, c.name, COALESCE(c.common_name,''), COALESCE(c.iso_alpha2,''), COALESCE(c.iso_alpha3,''),
-		COALESCE(c.capital,''), COALESCE(c.region,''), COALESCE(c.subregion,'')`
+		COALESCE(c.capital,''), COALESCE(c.region,''), COALESCE(c.subregion,''), COALESCE(c.un_member,0)`
 	if hasFactbookCode {
 		query += `, COALESCE(c.factbook_code,'')`
 	} else {
@@ -147,7 +381,13 @@ func queryCountries(db *sql.DB) ([]CountryRecord, error) {
 	} else {
 		query += `, ''`
 	}
-	query += ` FROM countries c ORDER BY c.name`
+	// Added by schema migration 8
+	if columnExists(db, "countries", "wikidata_id") {
+		query += `, COALESCE(c.wikidata_id,'')`
+	} else {
+		query += `, ''`
+	}
+	query += ` FROM countries c ORDER BY c.name, c.id`
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -159,8 +399,8 @@ func queryCountries(db *sql.DB) ([]CountryRecord, error) {
 	for rows.Next() {
 		var c CountryRecord
 		if err := rows.Scan(&c.ID, &c.Name, &c.CommonName, &c.ISOAlpha2, &c.ISOAlpha3,
-			&c.Capital, &c.Region, &c.Subregion, &c.FactbookCode,
-			&c.NationalSymbol, &c.NationalColors); err != nil {
+			&c.Capital, &c.Region, &c.Subregion, &c.unMember, &c.FactbookCode,
+			&c.NationalSymbol, &c.NationalColors, &c.wikidataID); err != nil {
 			return nil, err
 		}
 		if c.FactbookCode != "" {
@@ -177,7 +417,11 @@ func queryCountries(db *sql.DB) ([]CountryRecord, error) {
 	if err != nil {
 		return nil, err
 	}
-	audio, err := queryAudio(db)
+	recordings, err := queryAudio(db)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := queryLyrics(db)
 	if err != nil {
 		return nil, err
 	}
@@ -185,20 +429,68 @@ func queryCountries(db *sql.DB) ([]CountryRecord, error) {
 	for i := range countries {
 		if a, ok := anthems[countries[i].ID]; ok {
 			countries[i].Anthem = a
+			countries[i].Lyrics = texts[a.ID]
 		}
-		if files, ok := audio[countries[i].ID]; ok {
-			countries[i].AudioFiles = files
+		if files, ok := recordings[countries[i].ID]; ok {
+			countries[i].AudioFiles = markPrimary(files)
 		}
 	}
 	return countries, nil
 }
 
+// rewriteAudioURLs points mirrored recordings at baseURL instead of the
+// source. Previews and peaks only exist in the audio store, so they share
+// that base; without one they are left out rather than exported relative.
+func rewriteAudioURLs(countries []CountryRecord, baseURL string) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	for i := range countries {
+		for j := range countries[i].AudioFiles {
+			a := &countries[i].AudioFiles[j]
+			if baseURL == "" || a.sha1 == "" {
+				a.PreviewURL, a.PeaksURL = "", ""
+				continue
+			}
+			a.URL = baseURL + "/" + audio.StorePath(a.sha1, audio.URLExt(a.URL))
+			if a.PreviewURL != "" {
+				a.PreviewURL = baseURL + "/" + a.PreviewURL
+			}
+			if a.PeaksURL != "" {
+				a.PeaksURL = baseURL + "/" + a.PeaksURL
+			}
+		}
+	}
+}
+
+// markPrimary flags the preferred recording and moves it to the front, so
+// consumers that just take audio_files[0] get the canonical track.
+func markPrimary(files []AudioRecord) []AudioRecord {
+	candidates := make([]audio.Candidate, len(files))
+	for i, f := range files {
+		candidates[i] = audio.Candidate{
+			Type:           f.Type,
+			Quality:        f.Quality,
+			Duration:       f.Duration,
+			WikidataLinked: f.wikidataLinked,
+		}
+	}
+	best := audio.PickPrimary(candidates)
+	if best < 0 {
+		return files
+	}
+	primary := files[best]
+	primary.Primary = true
+	out := make([]AudioRecord, 0, len(files))
+	out = append(out, primary)
+	out = append(out, files[:best]...)
+	return append(out, files[best+1:]...)
+}
+
 func queryAnthems(db *sql.DB) (map[string]*AnthemRecord, error) {
 	// Check if new columns exist (added by factbook source)
 	hasHistory := columnExists(db, "anthems", "anthem_history")
 	hasTitleEn := columnExists(db, "anthems", "anthem_title_en")
 
-	query := `SELECT id, country_id, name, COALESCE(composer,''), COALESCE(lyricist,''),
+	query := `SELECT id, country_id, name, COALESCE(native_name,''), COALESCE(composer,''), COALESCE(lyricist,''),
 		COALESCE(adopted_date,''), COALESCE(wikidata_id,''), COALESCE(wikipedia_url,'')`
 	if hasTitleEn {
 		query += `, COALESCE(anthem_title_en,'')`
@@ -210,7 +502,18 @@ func queryAnthems(db *sql.DB) (map[string]*AnthemRecord, error) {
 	} else {
 		query += `, ''`
 	}
-	query += ` FROM anthems`
+	// Added by schema migration 10
+	if columnExists(db, "anthems", "adopted_on") {
+		query += `, COALESCE(adopted_on,''), COALESCE(adopted_precision,'')`
+	} else {
+		query += `, '', ''`
+	}
+	query += ` FROM anthems ORDER BY country_id, id`
+
+	contributors, err := queryContributors(db)
+	if err != nil {
+		return nil, err
+	}
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -221,17 +524,22 @@ func queryAnthems(db *sql.DB) (map[string]*AnthemRecord, error) {
 	result := make(map[string]*AnthemRecord)
 	for rows.Next() {
 		var a AnthemRecord
-		var countryID string
-		if err := rows.Scan(&a.ID, &countryID, &a.Name, &a.Composer, &a.Lyricist,
-			&a.AdoptedDate, &a.WikidataID, &a.WikipediaURL, &a.TitleEn, &a.History); err != nil {
+		var countryID, adoptedOn, adoptedPrecision string
+		if err := rows.Scan(&a.ID, &countryID, &a.Name, &a.NativeName, &a.Composer, &a.Lyricist,
+			&a.AdoptedDate, &a.WikidataID, &a.WikipediaURL, &a.TitleEn, &a.History,
+			&adoptedOn, &adoptedPrecision); err != nil {
 			return nil, err
 		}
-		// Clean up wikidata blank nodes (raw URLs) that aren't useful labels
+		a.Composers = contributors[a.ID]["composer"]
+		a.Lyricists = contributors[a.ID]["lyricist"]
+		a.Adopted = adoptedDate(adoptedOn, adoptedPrecision, a.AdoptedDate)
+		// Wikidata blank nodes (raw URLs) aren't useful labels: use the
+		// resolved names when there are any
 		if isBlankNode(a.Composer) {
-			a.Composer = ""
+			a.Composer = personNames(a.Composers)
 		}
 		if isBlankNode(a.Lyricist) {
-			a.Lyricist = ""
+			a.Lyricist = personNames(a.Lyricists)
 		}
 		result[countryID] = &a
 	}
@@ -239,12 +547,39 @@ func queryAnthems(db *sql.DB) (map[string]*AnthemRecord, error) {
 }
 
 func queryAudio(db *sql.DB) (map[string][]AudioRecord, error) {
-	rows, err := db.Query(`
-		SELECT id, country_id, COALESCE(title,''), COALESCE(url,''), COALESCE(format,''),
-		       COALESCE(duration_seconds,0), COALESCE(type,''), COALESCE(source,''), COALESCE(license,'')
-		FROM audio_recordings
-		ORDER BY country_id, type
-	`)
+	// Added by schema migrations 3 (classification), 5 (mirror), 6 (probe) and 7 (derive)
+	hasLinked := columnExists(db, "audio_recordings", "wikidata_linked")
+	hasSHA1 := columnExists(db, "audio_recordings", "sha1")
+	hasProbe := columnExists(db, "audio_recordings", "loudness_lufs")
+	hasDerived := columnExists(db, "audio_recordings", "preview_path")
+
+	query := `SELECT id, country_id, COALESCE(title,''), COALESCE(url,''), COALESCE(format,''),
+		COALESCE(duration_seconds,0), COALESCE(type,''), COALESCE(quality,''), COALESCE(source,''), COALESCE(license,'')`
+	if hasLinked {
+		query += `, COALESCE(wikidata_linked,0)`
+	} else {
+		query += `, 0`
+	}
+	if hasSHA1 {
+		query += `, COALESCE(sha1,'')`
+	} else {
+		query += `, ''`
+	}
+	if hasProbe {
+		query += `, COALESCE(codec,''), COALESCE(channels,0), COALESCE(sample_rate_hz,0), COALESCE(bitrate_kbps,0), loudness_lufs`
+	} else if hasLinked {
+		query += `, '', 0, COALESCE(sample_rate_hz,0), COALESCE(bitrate_kbps,0), NULL`
+	} else {
+		query += `, '', 0, 0, 0, NULL`
+	}
+	if hasDerived {
+		query += `, COALESCE(preview_path,''), COALESCE(peaks_path,'')`
+	} else {
+		query += `, '', ''`
+	}
+	query += ` FROM audio_recordings ORDER BY country_id, type, id`
+
+	rows, err := db.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -254,10 +589,20 @@ func queryAudio(db *sql.DB) (map[string][]AudioRecord, error) {
 	for rows.Next() {
 		var r AudioRecord
 		var countryID string
+		var loudness sql.NullFloat64
 		if err := rows.Scan(&r.ID, &countryID, &r.Title, &r.URL, &r.Format,
-			&r.Duration, &r.Type, &r.Source, &r.License); err != nil {
+			&r.Duration, &r.Type, &r.Quality, &r.Source, &r.License, &r.wikidataLinked, &r.sha1,
+			&r.Codec, &r.Channels, &r.SampleRateHz, &r.BitrateKbps, &loudness,
+			&r.PreviewURL, &r.PeaksURL); err != nil {
 			return nil, err
 		}
+		if loudness.Valid {
+			r.LoudnessLUFS = &loudness.Float64
+		}
+		// Legacy placeholder from before recordings were classified
+		if r.Quality == "standard" {
+			r.Quality = ""
+		}
 		result[countryID] = append(result[countryID], r)
 	}
 	return result, rows.Err()
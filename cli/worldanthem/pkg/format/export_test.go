@@ -0,0 +1,502 @@
+package format
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthemworld/cli/internal/testdb"
+	"github.com/anthemworld/cli/pkg/lyrics"
+)
+
+// setupTestDB opens a fully migrated database under a temporary HOME with
+// two countries, one anthem and two recordings.
+func setupTestDB(t *testing.T) *sql.DB {
+	return testdb.Open(t, `
+		INSERT INTO countries (id, name, common_name, iso_alpha2, iso_alpha3, un_member, region)
+		VALUES ('fra', 'French Republic', 'France', 'FR', 'FRA', 1, 'Europe'),
+		       ('ata', 'Antarctica', 'Antarctica', 'AQ', 'ATA', 0, 'Antarctic');
+		INSERT INTO anthems (country_id, name, composer) VALUES ('fra', 'La Marseillaise', 'Rouget de Lisle');
+		INSERT INTO audio_recordings (id, country_id, title, url, format, type, source)
+		VALUES ('rec-b', 'fra', 'La Marseillaise (instrumental).ogg', 'https://example.org/b.ogg', 'ogg', 'instrumental', 'wikimedia-commons'),
+		       ('rec-a', 'fra', 'La Marseillaise.ogg', 'https://example.org/a.ogg', 'ogg', 'vocal', 'wikimedia-commons');
+	`)
+}
+
+func TestExportReproducible(t *testing.T) {
+	database := setupTestDB(t)
+	opts := Options{Shard: true, GeneratedAt: time.Unix(1700000000, 0)}
+
+	root := t.TempDir()
+	first, second := filepath.Join(root, "first"), filepath.Join(root, "second")
+	for _, dir := range []string{first, second} {
+		os.MkdirAll(dir, 0755)
+		if err := ExportToDir(database, dir, opts); err != nil {
+			t.Fatalf("ExportToDir failed: %v", err)
+		}
+	}
+
+	for _, name := range []string{"anthems.json", "index.json", "summary.json", "countries/fra.json"} {
+		a, err := os.ReadFile(filepath.Join(first, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		b, _ := os.ReadFile(filepath.Join(second, name))
+		if !bytes.Equal(a, b) {
+			t.Errorf("Expected %s to be byte-identical between runs", name)
+		}
+	}
+
+	// Only the two export directories: the staging directory was removed
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 2 {
+		t.Errorf("Expected staging directory to be cleaned up, found %d entries", len(entries))
+	}
+}
+
+func TestExportSourceDateEpoch(t *testing.T) {
+	database := setupTestDB(t)
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	generatedAt, err := SourceDateEpoch()
+	if err != nil {
+		t.Fatalf("SourceDateEpoch failed: %v", err)
+	}
+	opts := Options{Shard: true, SearchIndex: true, GeneratedAt: generatedAt}
+
+	root := t.TempDir()
+	first, second := filepath.Join(root, "first"), filepath.Join(root, "second")
+	for _, dir := range []string{first, second} {
+		if err := ExportToDir(database, dir, opts); err != nil {
+			t.Fatalf("ExportToDir failed: %v", err)
+		}
+		// Seconds apart, as two --reproducible runs would be
+		time.Sleep(1100 * time.Millisecond)
+	}
+
+	data, err := os.ReadFile(filepath.Join(first, "index.json"))
+	if err != nil {
+		t.Fatalf("Failed to read index.json: %v", err)
+	}
+	var idx IndexRecord
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatalf("Failed to parse index.json: %v", err)
+	}
+	if idx.GeneratedAt != "2023-11-14T22:13:20Z" {
+		t.Errorf("Expected generated_at from SOURCE_DATE_EPOCH, got %s", idx.GeneratedAt)
+	}
+	for _, name := range idx.Files {
+		a, err := os.ReadFile(filepath.Join(first, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		b, _ := os.ReadFile(filepath.Join(second, filepath.FromSlash(name)))
+		if !bytes.Equal(a, b) {
+			t.Errorf("Expected %s to be byte-identical between runs", name)
+		}
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := SourceDateEpoch(); err == nil {
+		t.Error("Expected an error for an invalid SOURCE_DATE_EPOCH")
+	}
+}
+
+func TestExportSwapsDirectory(t *testing.T) {
+	database := setupTestDB(t)
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+
+	// The first export creates the directory
+	if err := ExportToDir(database, dir, Options{Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+	os.Chmod(dir, 0750)
+	os.MkdirAll(filepath.Join(dir, "extra"), 0755)
+	os.WriteFile(filepath.Join(dir, "extra", "notes.txt"), []byte("keep me"), 0644)
+	os.Symlink("anthems.json", filepath.Join(dir, "latest.json"))
+	if _, err := database.Exec(`DELETE FROM countries WHERE id = 'ata'`); err != nil {
+		t.Fatalf("Failed to delete country: %v", err)
+	}
+
+	if err := ExportToDir(database, dir, Options{Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "countries", "ata.json")); !os.IsNotExist(err) {
+		t.Error("Expected stale shard countries/ata.json to be removed")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "extra", "notes.txt")); err != nil || string(data) != "keep me" {
+		t.Errorf("Expected nested unrelated file to be kept, got %q (%v)", data, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "latest.json")); err != nil || target != "anthems.json" {
+		t.Errorf("Expected symlink to be kept, got %q (%v)", target, err)
+	}
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0750 {
+		t.Errorf("Expected directory permissions 0750 to be kept, got %v (%v)", fi.Mode().Perm(), err)
+	}
+
+	// Neither the staging directory nor the old tree are left behind
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the export directory, found %d entries", len(entries))
+	}
+}
+
+func TestSwapDir(t *testing.T) {
+	for _, exchange := range []bool{true, false} {
+		t.Run(fmt.Sprintf("exchange=%v", exchange), func(t *testing.T) {
+			if !exchange {
+				saved := exchangeDirs
+				exchangeDirs = func(a, b string) error { return errors.ErrUnsupported }
+				t.Cleanup(func() { exchangeDirs = saved })
+			}
+			root := t.TempDir()
+			out, staging := filepath.Join(root, "data"), filepath.Join(root, ".data.tmp-1")
+			os.MkdirAll(out, 0755)
+			os.WriteFile(filepath.Join(out, "old.json"), []byte("old"), 0644)
+			os.MkdirAll(staging, 0700)
+			os.WriteFile(filepath.Join(staging, "new.json"), []byte("new"), 0644)
+			// Left by a run that crashed between the two renames
+			os.MkdirAll(filepath.Join(root, ".data.old", "countries"), 0755)
+
+			if err := swapDir(staging, out); err != nil {
+				t.Fatalf("swapDir failed: %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(out, "new.json")); err != nil || string(data) != "new" {
+				t.Errorf("Expected the new tree in place, got %q (%v)", data, err)
+			}
+			if _, err := os.Stat(filepath.Join(out, "old.json")); !os.IsNotExist(err) {
+				t.Error("Expected the old tree to be gone")
+			}
+			var left []string
+			entries, _ := os.ReadDir(root)
+			for _, e := range entries {
+				if e.Name() != "data" {
+					left = append(left, e.Name())
+				}
+			}
+			if exchange {
+				// The exchange touches no .old directory
+				if len(left) != 1 || left[0] != ".data.old" {
+					t.Errorf("Expected only the earlier .data.old beside the export, found %v", left)
+				}
+			} else if len(left) != 0 {
+				t.Errorf("Expected nothing beside the export, found %v", left)
+			}
+		})
+	}
+}
+
+func TestExportRemovesStaleFiles(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0644)
+
+	if err := ExportToDir(database, dir, Options{Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+	if _, err := database.Exec(`DELETE FROM countries WHERE id = 'ata'`); err != nil {
+		t.Fatalf("Failed to delete country: %v", err)
+	}
+	if err := ExportToDir(database, dir, Options{Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "countries", "ata.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected stale shard countries/ata.json to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected unrelated file to be kept: %v", err)
+	}
+}
+
+func TestValidateDir(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	if err := ExportToDir(database, dir, Options{Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+
+	problems, err := ValidateDir(dir)
+	if err != nil {
+		t.Fatalf("ValidateDir failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected a fresh export to be valid, got %v", problems)
+	}
+
+	// Renaming a field the website reads must be caught
+	shard := filepath.Join(dir, "countries", "fra.json")
+	data, _ := os.ReadFile(shard)
+	os.WriteFile(shard, bytes.Replace(data, []byte(`"audio_files"`), []byte(`"audio"`), 1), 0644)
+
+	problems, err = ValidateDir(dir)
+	if err != nil {
+		t.Fatalf("ValidateDir failed: %v", err)
+	}
+	var sawField, sawHash bool
+	for _, p := range problems {
+		sawField = sawField || bytes.Contains([]byte(p), []byte(`unexpected field "audio"`))
+		sawHash = sawHash || bytes.Contains([]byte(p), []byte("SHA-256"))
+	}
+	if !sawField || !sawHash {
+		t.Errorf("Expected unexpected-field and hash problems, got %v", problems)
+	}
+}
+
+func TestExportFilter(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	opts := Options{Filter: Filter{UNMembersOnly: true, HasAudio: true}}
+	if err := ExportToDir(database, dir, opts); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+
+	countries, err := LoadExportDir(dir)
+	if err != nil {
+		t.Fatalf("LoadExportDir failed: %v", err)
+	}
+	if len(countries) != 1 || countries[0].ISOAlpha3 != "FRA" {
+		t.Errorf("Expected only FRA, got %d countries", len(countries))
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "index.json"))
+	if !bytes.Contains(data, []byte(`"un_members_only": true`)) {
+		t.Errorf("Expected filter in index.json, got:\n%s", data)
+	}
+
+	// Several codes for the same country are all known
+	opts = Options{Filter: Filter{Countries: []string{"fra", "FR", "fr"}}}
+	if err := ExportToDir(database, t.TempDir(), opts); err != nil {
+		t.Errorf("Expected codes naming the same country to be accepted, got %v", err)
+	}
+
+	opts = Options{Filter: Filter{Countries: []string{"fr", "xyz"}}}
+	if err := ExportToDir(database, t.TempDir(), opts); err == nil || !strings.Contains(err.Error(), "xyz") {
+		t.Errorf("Expected unknown country error, got %v", err)
+	}
+
+	// Regions match case-insensitively, and a misspelt one is an error
+	opts = Options{Filter: Filter{Regions: []string{"europe"}}}
+	if err := ExportToDir(database, t.TempDir(), opts); err != nil {
+		t.Errorf("Expected region europe to be accepted, got %v", err)
+	}
+	opts = Options{Filter: Filter{Regions: []string{"Europ"}}}
+	if err := ExportToDir(database, t.TempDir(), opts); err == nil || !strings.Contains(err.Error(), "unknown region(s): Europ (available: Antarctic, Europe)") {
+		t.Errorf("Expected unknown region error, got %v", err)
+	}
+	opts = Options{Filter: Filter{Subregions: []string{"Western Europe"}}}
+	if err := ExportToDir(database, t.TempDir(), opts); err == nil || !strings.Contains(err.Error(), "unknown subregion(s): Western Europe") {
+		t.Errorf("Expected unknown subregion error, got %v", err)
+	}
+}
+
+func TestExportGameRanks(t *testing.T) {
+	database := setupTestDB(t)
+	_, err := database.Exec(`
+		INSERT INTO game_rankings (country_id, name, elo_score, wins, losses) VALUES
+			('DEU', 'Germany', 1600, 5, 1), ('FRA', 'France', 1520, 3, 2);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert rankings: %v", err)
+	}
+	dir := t.TempDir()
+	if err := ExportToDir(database, dir, Options{GameRanks: true, Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+
+	countries, err := LoadExportDir(dir)
+	if err != nil {
+		t.Fatalf("LoadExportDir failed: %v", err)
+	}
+	for _, c := range countries {
+		switch c.ISOAlpha3 {
+		case "FRA":
+			if c.Game == nil || c.Game.Rank != 2 || c.Game.EloScore != 1520 || c.Game.Wins != 3 {
+				t.Errorf("Expected FRA ranked 2nd at 1520, got %+v", c.Game)
+			}
+		case "ATA":
+			if c.Game != nil {
+				t.Errorf("Expected no game record for ATA, got %+v", c.Game)
+			}
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "summary.json"))
+	if !bytes.Contains(data, []byte(`"game_rank": 2`)) {
+		t.Errorf("Expected game_rank in summary.json, got:\n%s", data)
+	}
+
+	if err := ExportToDir(database, dir, Options{}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "anthems.json"))
+	if bytes.Contains(data, []byte(`"game"`)) {
+		t.Error("Expected no game records without GameRanks")
+	}
+}
+
+func TestExportLanguages(t *testing.T) {
+	database := setupTestDB(t)
+	_, err := database.Exec(`
+		UPDATE countries SET wikidata_id = 'Q142' WHERE id = 'fra';
+		UPDATE anthems SET wikidata_id = 'Q48984', native_name = 'La Marseillaise';
+		INSERT INTO labels (entity, lang, value) VALUES
+			('Q142', 'es', 'Francia'),
+			('Q142', 'pt', 'França'),
+			('Q48984', 'en', 'The Marseillaise');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert labels: %v", err)
+	}
+
+	dir := t.TempDir()
+	opts := Options{Languages: []string{"es", "pt-br"}, Fallback: []string{"en"}}
+	if err := ExportToDir(database, dir, opts); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+
+	for lang, want := range map[string]string{"es": "Francia", "pt-br": "França"} {
+		countries, err := LoadExportDir(filepath.Join(dir, lang))
+		if err != nil {
+			t.Fatalf("LoadExportDir(%s) failed: %v", lang, err)
+		}
+		for _, c := range countries {
+			if c.ID != "fra" {
+				continue
+			}
+			if c.CommonName != want {
+				t.Errorf("%s: expected common name %q, got %q", lang, want, c.CommonName)
+			}
+			// No es/pt label for the anthem, so the English fallback applies
+			if c.Anthem == nil || c.Anthem.Name != "The Marseillaise" || c.Anthem.NativeName != "La Marseillaise" {
+				t.Errorf("%s: unexpected anthem %+v", lang, c.Anthem)
+			}
+		}
+	}
+
+	if problems, err := ValidateDir(dir); err != nil || len(problems) > 0 {
+		t.Errorf("Expected valid export, got %v %v", problems, err)
+	}
+	if err := ExportToDir(database, dir, Options{Languages: []string{"../x"}}); err == nil {
+		t.Errorf("Expected invalid language code to be rejected")
+	}
+}
+
+func TestExportLyrics(t *testing.T) {
+	database := setupTestDB(t)
+	var anthemID int
+	database.QueryRow(`SELECT id FROM anthems WHERE country_id = 'fra'`).Scan(&anthemID)
+
+	for _, v := range []*lyrics.Version{
+		{Lang: "en", Kind: lyrics.KindTranslation, Verses: []string{"Arise, children"},
+			Source: lyrics.SourceLocal, CopyrightStatus: lyrics.StatusCopyrighted},
+		{Lang: "fr", Kind: lyrics.KindOriginal, Verses: []string{"Allons enfants\nde la Patrie", "Contre nous"},
+			Source: lyrics.SourceWikisource, License: "Public domain", CopyrightStatus: lyrics.StatusPublicDomain},
+		{Lang: "de", Kind: lyrics.KindTranslation, Verses: []string{"Auf, Kinder"},
+			Source: lyrics.SourceLocal, License: "CC BY-SA 4.0", CopyrightStatus: lyrics.StatusLicensed},
+	} {
+		v.AnthemID = anthemID
+		if _, err := lyrics.Store(database, v); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+
+	countries, err := Countries(database)
+	if err != nil {
+		t.Fatalf("Countries failed: %v", err)
+	}
+	for _, c := range countries {
+		if c.ID != "fra" {
+			continue
+		}
+		// The copyrighted English translation is withheld; the original comes first
+		if len(c.Lyrics) != 2 {
+			t.Fatalf("Expected 2 publishable texts, got %+v", c.Lyrics)
+		}
+		if c.Lyrics[0].Lang != "fr" || c.Lyrics[0].Kind != lyrics.KindOriginal || len(c.Lyrics[0].Verses) != 2 {
+			t.Errorf("Expected French original with 2 verses first, got %+v", c.Lyrics[0])
+		}
+		if c.Lyrics[1].Lang != "de" || c.Lyrics[1].Script != "Latn" {
+			t.Errorf("Expected German translation second, got %+v", c.Lyrics[1])
+		}
+	}
+
+	dir := t.TempDir()
+	if err := ExportToDir(database, dir, Options{Shard: true}); err != nil {
+		t.Fatalf("ExportToDir failed: %v", err)
+	}
+	if problems, err := ValidateDir(dir); err != nil || len(problems) > 0 {
+		t.Errorf("Expected valid export, got %v %v", problems, err)
+	}
+}
+
+func TestExportContributors(t *testing.T) {
+	database := setupTestDB(t)
+	_, err := database.Exec(`
+		UPDATE anthems SET composer = 'http://www.wikidata.org/entity/Q312750', adopted_date = 'July 1795';
+		INSERT INTO people (id, name, birth_year, death_year) VALUES
+			('Q312750', 'Claude Joseph Rouget de Lisle', 1760, 1836),
+			('Q1', 'Second Composer', NULL, NULL);
+		INSERT INTO anthem_contributors (anthem_id, person_id, role, position)
+		SELECT id, 'Q1', 'composer', 1 FROM anthems UNION ALL
+		SELECT id, 'Q312750', 'composer', 0 FROM anthems;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert contributors: %v", err)
+	}
+
+	countries, err := Countries(database)
+	if err != nil {
+		t.Fatalf("Countries failed: %v", err)
+	}
+	for _, c := range countries {
+		if c.ID != "fra" {
+			continue
+		}
+		a := c.Anthem
+		if len(a.Composers) != 2 || a.Composers[0].WikidataID != "Q312750" || a.Composers[0].BirthYear != 1760 {
+			t.Errorf("Unexpected composers %+v", a.Composers)
+		}
+		if a.Composer != "Claude Joseph Rouget de Lisle, Second Composer" {
+			t.Errorf("Expected resolved composer names, got %q", a.Composer)
+		}
+		if a.Adopted == nil || a.Adopted.Date != "1795-07" || a.Adopted.Precision != "month" {
+			t.Errorf("Expected adopted 1795-07 (month), got %+v", a.Adopted)
+		}
+		if a.AdoptedDate != "July 1795" {
+			t.Errorf("Expected adopted_date to be kept, got %q", a.AdoptedDate)
+		}
+	}
+}
+
+func TestRewriteAudioURLs(t *testing.T) {
+	record := func() []CountryRecord {
+		return []CountryRecord{{ID: "fra", AudioFiles: []AudioRecord{
+			{ID: "mirrored", URL: "https://example.org/a.ogg", PreviewURL: "previews/ab/abc.wav", PeaksURL: "peaks/ab/abc.json", sha1: "abc"},
+			{ID: "remote", URL: "https://example.org/b.ogg"},
+		}}}
+	}
+
+	countries := record()
+	rewriteAudioURLs(countries, "/audio/")
+	a, b := countries[0].AudioFiles[0], countries[0].AudioFiles[1]
+	if !strings.HasPrefix(a.URL, "/audio/") || a.PreviewURL != "/audio/previews/ab/abc.wav" || a.PeaksURL != "/audio/peaks/ab/abc.json" {
+		t.Errorf("Expected the mirrored recording and its previews under /audio, got %+v", a)
+	}
+	if b.URL != "https://example.org/b.ogg" {
+		t.Errorf("Expected the unmirrored recording to keep its source URL, got %q", b.URL)
+	}
+
+	countries = record()
+	rewriteAudioURLs(countries, "")
+	a = countries[0].AudioFiles[0]
+	if a.URL != "https://example.org/a.ogg" || a.PreviewURL != "" || a.PeaksURL != "" {
+		t.Errorf("Expected the source URL and no previews without a base URL, got %+v", a)
+	}
+}
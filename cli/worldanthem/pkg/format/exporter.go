@@ -0,0 +1,103 @@
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exporter writes the exported countries in one output format
+type Exporter interface {
+	// Export writes its files to outputDir and returns their names
+	// relative to outputDir
+	Export(countries []CountryRecord, outputDir string) ([]string, error)
+}
+
+// exporters maps --format values to their writer
+var exporters = map[string]Exporter{
+	"json":     jsonExporter{},
+	"ndjson":   ndjsonExporter{},
+	"csv":      csvExporter{},
+	"columnar": columnarExporter{},
+}
+
+// Register adds or replaces the exporter for a format name
+func Register(name string, e Exporter) {
+	exporters[name] = e
+}
+
+// Formats returns the registered format names, sorted
+func Formats() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the exporter for a format name
+func Lookup(name string) (Exporter, error) {
+	e, ok := exporters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(Formats(), ", "))
+	}
+	return e, nil
+}
+
+// CountryKey is the key a country is indexed by: ISO alpha-3 (uppercase, e.g.
+// "USA"), or its ID when it has none
+func CountryKey(c *CountryRecord) string {
+	if c.ISOAlpha3 != "" {
+		return c.ISOAlpha3
+	}
+	return c.ID
+}
+
+// ByKey indexes countries by ISO alpha-3, the layout of anthems.json
+func ByKey(countries []CountryRecord) map[string]*CountryRecord {
+	indexed := make(map[string]*CountryRecord, len(countries))
+	for i := range countries {
+		indexed[CountryKey(&countries[i])] = &countries[i]
+	}
+	return indexed
+}
+
+// jsonExporter writes anthems.json, all countries indexed by ISO alpha-3
+// including anthem and audio. This is what the Hugo site reads.
+type jsonExporter struct{}
+
+func (jsonExporter) Export(countries []CountryRecord, outputDir string) ([]string, error) {
+	if err := writeJSON(filepath.Join(outputDir, "anthems.json"), ByKey(countries)); err != nil {
+		return nil, err
+	}
+	return []string{"anthems.json"}, nil
+}
+
+// ndjsonExporter writes countries.ndjson, one nested country record per line
+type ndjsonExporter struct{}
+
+func (ndjsonExporter) Export(countries []CountryRecord, outputDir string) ([]string, error) {
+	path := filepath.Join(outputDir, "countries.ndjson")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w) // Encode appends the newline
+	for i := range countries {
+		if err := enc.Encode(&countries[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return []string{"countries.ndjson"}, f.Close()
+}
@@ -0,0 +1,298 @@
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testCountries() []CountryRecord {
+	lufs := -18.5
+	return []CountryRecord{
+		{
+			ID: "fra", Name: "France", ISOAlpha3: "FRA",
+			Anthem: &AnthemRecord{ID: 1, Name: "La Marseillaise", Composer: "Rouget de Lisle"},
+			AudioFiles: []AudioRecord{
+				{ID: "rec-1", URL: "https://example.org/a.ogg", Type: "vocal", Primary: true, LoudnessLUFS: &lufs},
+				{ID: "rec-2", URL: "https://example.org/b.ogg", Type: "instrumental"},
+			},
+		},
+		{ID: "nru", Name: "Nauru, \"Pleasant Island\"", ISOAlpha3: "NRU"},
+	}
+}
+
+func TestLookupUnknownFormat(t *testing.T) {
+	if _, err := Lookup("xml"); err == nil || !strings.Contains(err.Error(), "csv") {
+		t.Errorf("Expected unknown format error listing available formats, got %v", err)
+	}
+	for _, name := range []string{"json", "ndjson", "csv", "columnar"} {
+		if _, err := Lookup(name); err != nil {
+			t.Errorf("Expected format %s to be registered, got %v", name, err)
+		}
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	dir := t.TempDir()
+	files, err := csvExporter{}.Export(testCountries(), dir)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if strings.Join(files, ",") != "countries.csv,anthems.csv,audio.csv" {
+		t.Errorf("Unexpected files: %v", files)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "audio.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open audio.csv: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse audio.csv: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected header + 2 rows, got %d", len(records))
+	}
+	header := strings.Join(records[0], ",")
+	if !strings.HasPrefix(header, "country_id,id,title,url") || !strings.Contains(header, "loudness_lufs") {
+		t.Errorf("Unexpected header: %s", header)
+	}
+	row := map[string]string{}
+	for i, col := range records[0] {
+		row[col] = records[1][i]
+	}
+	if row["country_id"] != "fra" || row["primary"] != "true" || row["loudness_lufs"] != "-18.5" {
+		t.Errorf("Unexpected first row: %v", row)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "countries.csv"))
+	if err != nil {
+		t.Fatalf("Failed to read countries.csv: %v", err)
+	}
+	if !strings.Contains(string(data), `"Nauru, ""Pleasant Island"""`) {
+		t.Errorf("Expected quoted country name, got:\n%s", data)
+	}
+}
+
+func TestColumnarExporter(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := (columnarExporter{}).Export(testCountries(), dir); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "audio.columns.json"))
+	if err != nil {
+		t.Fatalf("Failed to read audio.columns.json: %v", err)
+	}
+	var doc columnarFile
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if doc.NumRows != 2 {
+		t.Errorf("Expected 2 rows, got %d", doc.NumRows)
+	}
+	for _, col := range doc.Columns {
+		if len(col.Values) != doc.NumRows {
+			t.Errorf("Column %s has %d values, expected %d", col.Name, len(col.Values), doc.NumRows)
+		}
+		if col.Name == "loudness_lufs" && (col.Type != "number" || col.Values[1] != nil) {
+			t.Errorf("Expected nullable number column, got %s %v", col.Type, col.Values)
+		}
+	}
+}
+
+func TestWriteShards(t *testing.T) {
+	dir := t.TempDir()
+	files, shards, err := writeShards(testCountries(), dir)
+	if err != nil {
+		t.Fatalf("writeShards failed: %v", err)
+	}
+	if strings.Join(files, ",") != "countries/fra.json,countries/nru.json,summary.json" {
+		t.Errorf("Unexpected files: %v", files)
+	}
+	if shards["FRA"] != "countries/fra.json" {
+		t.Errorf("Expected FRA shard countries/fra.json, got %q", shards["FRA"])
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		t.Fatalf("Failed to read summary.json: %v", err)
+	}
+	var summary map[string]SummaryRecord
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("Invalid summary.json: %v", err)
+	}
+	fra := summary["FRA"]
+	if fra.AnthemName != "La Marseillaise" || fra.AudioCount != 2 || fra.AudioURL != "https://example.org/a.ogg" {
+		t.Errorf("Unexpected summary for FRA: %+v", fra)
+	}
+
+	hashes, err := hashFiles(dir, files)
+	if err != nil {
+		t.Fatalf("hashFiles failed: %v", err)
+	}
+	if len(hashes) != 3 || len(hashes["summary.json"]) != 64 {
+		t.Errorf("Expected a SHA-256 per file, got %v", hashes)
+	}
+}
+
+func TestWriteHugoContent(t *testing.T) {
+	dir := t.TempDir()
+	notes := "France's anthem was written in 1792.\n"
+	page := "---\ntitle: \"France\"\niso: \"FRA\"\ntype: \"country\"\n---\n" + HugoBodyMarker + "\n" + notes
+	os.WriteFile(filepath.Join(dir, "fra.md"), []byte(page), 0644)
+	os.WriteFile(filepath.Join(dir, "yug.md"), []byte("---\ntitle: \"Yugoslavia\"\ntype: \"country\"\n---\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "_index.md"), []byte("---\ntitle: \"Countries\"\n---\n"), 0644)
+
+	stats, err := WriteHugoContent(testCountries(), dir)
+	if err != nil {
+		t.Fatalf("WriteHugoContent failed: %v", err)
+	}
+	if stats.Written != 2 || len(stats.Deleted) != 1 || stats.Deleted[0] != "yug.md" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "_index.md")); err != nil {
+		t.Errorf("Expected _index.md to be kept: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "fra.md"))
+	if err != nil {
+		t.Fatalf("Failed to read fra.md: %v", err)
+	}
+	for _, want := range []string{`anthem: "La Marseillaise"`, `composer: "Rouget de Lisle"`, "audio_count: 2", notes} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected fra.md to contain %q, got:\n%s", want, data)
+		}
+	}
+
+	// A second run changes nothing
+	stats, err = WriteHugoContent(testCountries(), dir)
+	if err != nil {
+		t.Fatalf("WriteHugoContent failed: %v", err)
+	}
+	if stats.Written != 0 || stats.Unchanged != 2 {
+		t.Errorf("Expected no changes on re-run, got %+v", stats)
+	}
+}
+
+func TestDiffCountries(t *testing.T) {
+	before := testCountries()
+	after := testCountries()
+	after[0].Anthem.Composer = "Claude Joseph Rouget de Lisle"
+	after[0].AudioFiles = after[0].AudioFiles[1:]
+	after[0].AudioFiles = append(after[0].AudioFiles, AudioRecord{ID: "rec-3", Title: "Choir.ogg", URL: "https://example.org/c.ogg"})
+	after = append(after[:1], CountryRecord{ID: "deu", Name: "Germany", ISOAlpha3: "DEU"})
+
+	d := DiffCountries(before, after)
+	if len(d.Added) != 1 || d.Added[0].ISO3 != "DEU" {
+		t.Errorf("Expected DEU added, got %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].ISO3 != "NRU" {
+		t.Errorf("Expected NRU removed, got %+v", d.Removed)
+	}
+	if len(d.Changed) != 1 {
+		t.Fatalf("Expected 1 changed country, got %d", len(d.Changed))
+	}
+	fra := d.Changed[0]
+	if len(fra.Fields) != 1 || fra.Fields[0].Field != "anthem.composer" {
+		t.Errorf("Expected anthem.composer change, got %+v", fra.Fields)
+	}
+	if len(fra.AddedAudio) != 1 || fra.AddedAudio[0].ID != "rec-3" || len(fra.RemovedAudio) != 1 || fra.RemovedAudio[0].ID != "rec-1" {
+		t.Errorf("Unexpected audio changes: +%v -%v", fra.AddedAudio, fra.RemovedAudio)
+	}
+
+	var md strings.Builder
+	if err := d.WriteMarkdown(&md); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{"### Added countries", "- Germany (DEU)", "| anthem.composer | Rouget de Lisle | Claude Joseph Rouget de Lisle |", "[Choir.ogg](https://example.org/c.ogg)"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", want, md.String())
+		}
+	}
+
+	if d := DiffCountries(before, testCountries()); !d.Empty() {
+		t.Errorf("Expected no changes between identical exports, got %+v", d)
+	}
+}
+
+func TestSearchAnthems(t *testing.T) {
+	countries := testCountries()
+	if c := FindCountry(countries, "FRA"); c == nil || c.ID != "fra" {
+		t.Errorf("Expected FindCountry to match ISO alpha-3, got %+v", c)
+	}
+	if c := FindCountry(countries, "xyz"); c != nil {
+		t.Errorf("Expected no match for xyz, got %s", c.ID)
+	}
+
+	matches := SearchAnthems(countries, "MARSEIL")
+	if len(matches) != 1 || matches[0].Country.ID != "fra" || matches[0].Field != "name" {
+		t.Errorf("Expected one name match for France, got %+v", matches)
+	}
+	if matches := SearchAnthems(countries, "  "); len(matches) != 0 {
+		t.Errorf("Expected blank search to match nothing, got %d", len(matches))
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	// Expected terms are the output of tokenize() in countries-table.js,
+	// which the site runs on queries against search.json:
+	//   text.toLowerCase().normalize('NFD').replace(/\p{Mn}/gu, '')
+	//       .split(/[^\p{L}\p{N}]+/u).filter(Boolean)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hymne à la Liberté, 1795!", "hymne a la liberte 1795"},
+		{"Tiến Quân Ca", "tien quan ca"},
+		{"Государственный гимн Российской Федерации", "государственныи гимн россиискои федерации"},
+		{"Ύμνος εις την Ελευθερίαν", "υμνος εις την ελευθεριαν"},
+		{"ΕΛΛΑΣ ΚΑΙ ΣΥ", "ελλας και συ"},
+		{"Hatikvah הַתִּקְוָה", "hatikvah התקוה"},
+		{"Qaumī Tarāna قومی ترانہ", "qaumi tarana قومی ترانہ"},
+		{"Jana Gana Mana, भारत", "jana gana mana भ रत"},
+		{"Ĳssel ½ İstanbul", "ĳssel ½ istanbul"},
+		{"Maamme—Vårt land", "maamme vart land"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Tokenize(tt.text), " "); got != tt.want {
+			t.Errorf("Tokenize(%q): expected %q, got %q", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+
+	idx := BuildSearchIndex(testCountries())
+	if len(idx.Docs) != 2 || idx.Docs[0][0] != "FRA" || idx.Docs[0][2] != "La Marseillaise" {
+		t.Fatalf("Expected FRA as first doc, got %v", idx.Docs)
+	}
+	if got := idx.Terms["marseillaise"]; len(got) != 2 || got[0] != 0 || got[1] != 10 {
+		t.Errorf("Expected marseillaise → [0 10], got %v", got)
+	}
+	if got := idx.Terms["rouget"]; len(got) != 2 || got[1] != 5 {
+		t.Errorf("Expected rouget with composer weight 5, got %v", got)
+	}
+	if got := idx.Terms["nauru"]; len(got) != 2 || got[0] != 1 {
+		t.Errorf("Expected nauru in doc 1, got %v", got)
+	}
+	if _, ok := idx.Terms["de"]; !ok {
+		t.Error("Expected non-English word 'de' to be indexed")
+	}
+
+	dir := t.TempDir()
+	files, err := writeSearchIndex(testCountries(), dir)
+	if err != nil {
+		t.Fatalf("writeSearchIndex failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0]))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", files[0], err)
+	}
+	if problems := validateJSON(files[0], data, schemaForFile(files[0], Schemas())); len(problems) > 0 {
+		t.Errorf("Expected search.json to match its schema, got %v", problems)
+	}
+}
@@ -0,0 +1,139 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Filter selects the countries to export. The zero value exports everything.
+// It is recorded in index.json so consumers know which subset a file holds.
+type Filter struct {
+	UNMembersOnly bool     `json:"un_members_only,omitempty"`
+	Regions       []string `json:"regions,omitempty"` // matched case-insensitively
+	Subregions    []string `json:"subregions,omitempty"`
+	HasAudio      bool     `json:"has_audio,omitempty"`
+	HasAnthem     bool     `json:"has_anthem,omitempty"`
+	Countries     []string `json:"countries,omitempty"` // ID, ISO alpha-3 or alpha-2, e.g. "usa,fra"
+}
+
+// IsZero reports whether the filter keeps every country
+func (f *Filter) IsZero() bool {
+	return !f.UNMembersOnly && len(f.Regions) == 0 && len(f.Subregions) == 0 && !f.HasAudio && !f.HasAnthem && len(f.Countries) == 0
+}
+
+// Match reports whether a country passes every condition of the filter
+func (f *Filter) Match(c *CountryRecord) bool {
+	if f.UNMembersOnly && !c.unMember {
+		return false
+	}
+	if f.HasAnthem && c.Anthem == nil {
+		return false
+	}
+	if f.HasAudio && len(c.AudioFiles) == 0 {
+		return false
+	}
+	if len(f.Regions) > 0 && !containsFold(f.Regions, c.Region) {
+		return false
+	}
+	if len(f.Subregions) > 0 && !containsFold(f.Subregions, c.Subregion) {
+		return false
+	}
+	if len(f.Countries) > 0 && matchCode(f.Countries, c) == "" {
+		return false
+	}
+	return true
+}
+
+// Apply returns the countries that match. Every code in f.Countries must
+// name a country, and every region and subregion must be one some country
+// is in, so typos ("Europ") fail loudly instead of exporting less.
+func (f *Filter) Apply(countries []CountryRecord) ([]CountryRecord, error) {
+	if f.IsZero() {
+		return countries, nil
+	}
+
+	// Keyed by the codes as given, so several codes for one country
+	// ("usa,us") all count as found
+	seen := make(map[string]bool)
+	regions := make(map[string]string)    // folded → as stored
+	subregions := make(map[string]string) // folded → as stored
+	var out []CountryRecord
+	for i := range countries {
+		if r := countries[i].Region; r != "" {
+			regions[strings.ToLower(r)] = r
+		}
+		if r := countries[i].Subregion; r != "" {
+			subregions[strings.ToLower(r)] = r
+		}
+		for _, code := range f.Countries {
+			if namesCountry(code, &countries[i]) {
+				seen[code] = true
+			}
+		}
+		if f.Match(&countries[i]) {
+			out = append(out, countries[i])
+		}
+	}
+
+	var unknown []string
+	for _, code := range f.Countries {
+		if !seen[code] {
+			unknown = append(unknown, code)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown country code(s): %s", strings.Join(unknown, ", "))
+	}
+	if err := checkKnown("region", f.Regions, regions); err != nil {
+		return nil, err
+	}
+	if err := checkKnown("subregion", f.Subregions, subregions); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkKnown returns an error naming the values not in known (keyed by the
+// lowercased value) and listing the known ones
+func checkKnown(what string, values []string, known map[string]string) error {
+	var unknown []string
+	for _, v := range values {
+		if _, ok := known[strings.ToLower(v)]; !ok {
+			unknown = append(unknown, v)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	available := make([]string, 0, len(known))
+	for _, v := range known {
+		available = append(available, v)
+	}
+	sort.Strings(available)
+	return fmt.Errorf("unknown %s(s): %s (available: %s)", what, strings.Join(unknown, ", "), strings.Join(available, ", "))
+}
+
+// matchCode returns the first code in codes that names c, or ""
+func matchCode(codes []string, c *CountryRecord) string {
+	for _, code := range codes {
+		if namesCountry(code, c) {
+			return code
+		}
+	}
+	return ""
+}
+
+// namesCountry reports whether code is c's ID, ISO alpha-3 or alpha-2 code
+func namesCountry(code string, c *CountryRecord) bool {
+	return strings.EqualFold(code, c.ID) || strings.EqualFold(code, c.ISOAlpha3) || strings.EqualFold(code, c.ISOAlpha2)
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
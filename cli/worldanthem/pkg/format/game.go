@@ -0,0 +1,45 @@
+package format
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// GameRecord is a country's standing in the anthem game
+type GameRecord struct {
+	Rank     int `json:"rank"` // leaderboard position over all ranked countries
+	EloScore int `json:"elo_score"`
+	Wins     int `json:"wins"`
+	Losses   int `json:"losses"`
+}
+
+// addGameRanks sets Game on every country in game_rankings. Ranks follow the
+// game leaderboard: ELO descending, then name.
+func addGameRanks(db *sql.DB, countries []CountryRecord) error {
+	rows, err := db.Query(`SELECT country_id, elo_score, wins, losses FROM game_rankings ORDER BY elo_score DESC, name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*GameRecord)
+	for rank := 1; rows.Next(); rank++ {
+		var id string
+		g := &GameRecord{Rank: rank}
+		if err := rows.Scan(&id, &g.EloScore, &g.Wins, &g.Losses); err != nil {
+			return err
+		}
+		byID[strings.ToUpper(id)] = g
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range countries {
+		id := countries[i].ISOAlpha3
+		if id == "" {
+			id = countries[i].ID
+		}
+		countries[i].Game = byID[strings.ToUpper(id)]
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HugoBodyMarker separates generated front matter from hand-written page
+// text. Everything below it is kept when pages are regenerated.
+const HugoBodyMarker = "<!-- worldanthem: front matter above is generated; text below this line is preserved -->"
+
+// HugoStats summarises a content generation run
+type HugoStats struct {
+	Written   int
+	Unchanged int
+	Deleted   []string // pages removed because their country no longer exists
+}
+
+// WriteHugoContent writes one page per country to contentDir (e.g.
+// hugo/site/content/countries) with front matter from the country record.
+// Existing body text is preserved, and country pages whose country is no
+// longer in the database are deleted. Files that would not change are not
+// rewritten, so Hugo's change detection stays quiet.
+func WriteHugoContent(countries []CountryRecord, contentDir string) (HugoStats, error) {
+	var stats HugoStats
+	if err := os.MkdirAll(contentDir, 0755); err != nil {
+		return stats, err
+	}
+
+	keep := make(map[string]bool, len(countries))
+	for i := range countries {
+		c := &countries[i]
+		name := c.ID + ".md"
+		keep[name] = true
+		path := filepath.Join(contentDir, name)
+
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return stats, err
+		}
+
+		page := hugoFrontMatter(c) + HugoBodyMarker + "\n" + hugoBody(existing)
+		if string(existing) == page {
+			stats.Unchanged++
+			continue
+		}
+		if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+			return stats, err
+		}
+		stats.Written++
+	}
+
+	entries, err := os.ReadDir(contentDir)
+	if err != nil {
+		return stats, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".md") || strings.HasPrefix(name, "_") || keep[name] {
+			continue
+		}
+		path := filepath.Join(contentDir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return stats, err
+		}
+		// Only remove country pages, never other content that shares the directory
+		if !bytes.Contains(hugoFrontMatterBlock(data), []byte(`type: "country"`)) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return stats, err
+		}
+		stats.Deleted = append(stats.Deleted, name)
+	}
+	return stats, nil
+}
+
+// hugoFrontMatter renders the YAML front matter for a country page
+func hugoFrontMatter(c *CountryRecord) string {
+	title := c.CommonName
+	if title == "" {
+		title = c.Name
+	}
+	iso := c.ISOAlpha3
+	if iso == "" {
+		iso = strings.ToUpper(c.ID)
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	field := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, strconv.Quote(value))
+		}
+	}
+	field("title", title)
+	field("iso", iso)
+	field("type", "country")
+	if c.Name != title {
+		field("official_name", c.Name)
+	}
+	field("region", c.Region)
+	field("subregion", c.Subregion)
+	field("capital", c.Capital)
+	field("flag_url", c.FlagURL)
+	if a := c.Anthem; a != nil {
+		field("anthem", a.Name)
+		field("anthem_title_en", a.TitleEn)
+		field("composer", a.Composer)
+		field("lyricist", a.Lyricist)
+		field("adopted_date", a.AdoptedDate)
+	}
+	fmt.Fprintf(&b, "audio_count: %d\n", len(c.AudioFiles))
+	b.WriteString("---\n")
+	return b.String()
+}
+
+// hugoFrontMatterBlock returns the front matter of a page, including delimiters
+func hugoFrontMatterBlock(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte("---\n")) {
+		return nil
+	}
+	end := bytes.Index(data[4:], []byte("\n---\n"))
+	if end < 0 {
+		return nil
+	}
+	return data[:4+end+5]
+}
+
+// hugoBody returns the hand-written part of an existing page: the text below
+// the marker, or for pages written before the marker existed, everything
+// after the front matter.
+func hugoBody(data []byte) string {
+	if i := bytes.Index(data, []byte(HugoBodyMarker)); i >= 0 {
+		rest := data[i+len(HugoBodyMarker):]
+		return string(bytes.TrimPrefix(rest, []byte("\n")))
+	}
+	return string(data[len(hugoFrontMatterBlock(data)):])
+}
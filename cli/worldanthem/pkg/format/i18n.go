@@ -0,0 +1,118 @@
+package format
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// languageCode accepts codes like "fr", "ast" or "pt-br"; they become directory names
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]+)*$`)
+
+// LanguageChain returns the order in which labels are looked up for lang:
+// the language itself, its base language ("pt-br" → "pt"), then fallbacks.
+// When none has a label the stored (English) value is kept.
+func LanguageChain(lang string, fallbacks []string) []string {
+	chain := []string{lang}
+	for l := lang; strings.Contains(l, "-"); {
+		l = l[:strings.LastIndex(l, "-")]
+		chain = append(chain, l)
+	}
+	for _, f := range fallbacks {
+		seen := false
+		for _, c := range chain {
+			seen = seen || c == f
+		}
+		if !seen {
+			chain = append(chain, f)
+		}
+	}
+	return chain
+}
+
+func validateLanguages(langs []string) error {
+	for _, l := range langs {
+		if !languageCode.MatchString(l) {
+			return fmt.Errorf("invalid language code %q (expected e.g. fr, es, pt-br)", l)
+		}
+	}
+	return nil
+}
+
+// queryLabels loads the labels table as entity → lang → value
+func queryLabels(db *sql.DB) (map[string]map[string]string, error) {
+	labels := make(map[string]map[string]string)
+	// Added by schema migration 8
+	if !columnExists(db, "labels", "value") {
+		return labels, nil
+	}
+	rows, err := db.Query(`SELECT entity, lang, value FROM labels`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entity, lang, value string
+		if err := rows.Scan(&entity, &lang, &value); err != nil {
+			return nil, err
+		}
+		if labels[entity] == nil {
+			labels[entity] = make(map[string]string)
+		}
+		labels[entity][lang] = value
+	}
+	return labels, rows.Err()
+}
+
+// lookupLabel returns the first label of entity along chain
+func lookupLabel(labels map[string]map[string]string, entity string, chain []string) (string, bool) {
+	byLang := labels[entity]
+	for _, lang := range chain {
+		if v, ok := byLang[lang]; ok && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// localize returns a copy of countries with the country common name and the
+// anthem name translated along chain
+func localize(countries []CountryRecord, labels map[string]map[string]string, chain []string) []CountryRecord {
+	out := make([]CountryRecord, len(countries))
+	for i, c := range countries {
+		if v, ok := lookupLabel(labels, c.wikidataID, chain); ok {
+			c.CommonName = v
+		}
+		if c.Anthem != nil {
+			a := *c.Anthem
+			if v, ok := lookupLabel(labels, a.WikidataID, chain); ok {
+				a.Name = v
+			}
+			c.Anthem = &a
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// writeLocalized writes one translated copy of the export to dir and returns
+// the files relative to dir
+func writeLocalized(exporter Exporter, countries []CountryRecord, dir string, shard bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := exporter.Export(countries, dir)
+	if err != nil {
+		return nil, err
+	}
+	if shard {
+		shardFiles, _, err := writeShards(countries, dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, shardFiles...)
+	}
+	return files, nil
+}
@@ -0,0 +1,70 @@
+package format
+
+import (
+	"database/sql"
+
+	"github.com/anthemworld/cli/pkg/lyrics"
+)
+
+// LyricsRecord is the JSON representation of one text of an anthem
+type LyricsRecord struct {
+	Lang            string   `json:"lang"`
+	Script          string   `json:"script"`
+	Kind            string   `json:"kind"`   // original, transliteration or translation
+	Verses          []string `json:"verses"` // stanzas, lines separated by "\n"
+	Source          string   `json:"source"`
+	SourceURL       string   `json:"source_url,omitempty"`
+	License         string   `json:"license,omitempty"`
+	CopyrightStatus string   `json:"copyright_status"` // public-domain or licensed
+	Attribution     string   `json:"attribution,omitempty"`
+}
+
+// queryLyrics returns the publishable texts of each anthem, keyed by anthem
+// ID: originals first, then transliterations and translations by language.
+// Texts still under copyright, or of unknown status, are never exported.
+func queryLyrics(db *sql.DB) (map[int][]LyricsRecord, error) {
+	result := make(map[int][]LyricsRecord)
+	// Added by schema migration 9
+	if !columnExists(db, "lyrics", "copyright_status") {
+		return result, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT l.anthem_id, l.lang, l.script, l.kind, l.source, COALESCE(l.source_url,''), COALESCE(l.license,''),
+			l.copyright_status, COALESCE(l.attribution,''), v.text
+		FROM lyrics l JOIN lyric_verses v ON v.lyrics_id = l.id
+		ORDER BY l.anthem_id,
+			CASE l.kind WHEN 'original' THEN 0 WHEN 'transliteration' THEN 1 ELSE 2 END,
+			l.lang, l.script, l.id, v.position
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var anthemID int
+		var r LyricsRecord
+		var verse string
+		if err := rows.Scan(&anthemID, &r.Lang, &r.Script, &r.Kind, &r.Source, &r.SourceURL, &r.License,
+			&r.CopyrightStatus, &r.Attribution, &verse); err != nil {
+			return nil, err
+		}
+		if !lyrics.Exportable(r.CopyrightStatus) {
+			continue
+		}
+		texts := result[anthemID]
+		if n := len(texts); n > 0 && sameText(&texts[n-1], &r) {
+			texts[n-1].Verses = append(texts[n-1].Verses, verse)
+			continue
+		}
+		r.Verses = []string{verse}
+		result[anthemID] = append(texts, r)
+	}
+	return result, rows.Err()
+}
+
+// sameText reports whether two rows of the verse query belong to one text
+func sameText(a, b *LyricsRecord) bool {
+	return a.Lang == b.Lang && a.Script == b.Script && a.Kind == b.Kind
+}
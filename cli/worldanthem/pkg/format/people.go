@@ -0,0 +1,80 @@
+package format
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/anthemworld/cli/pkg/dates"
+)
+
+// PersonRecord is a composer or lyricist
+type PersonRecord struct {
+	WikidataID string `json:"wikidata_id"`
+	Name       string `json:"name,omitempty"`
+	BirthYear  int    `json:"birth_year,omitempty"` // negative for BCE
+	DeathYear  int    `json:"death_year,omitempty"`
+}
+
+// DateRecord is an ISO 8601 date truncated to its precision, e.g.
+// {"date": "1795-07", "precision": "month"}
+type DateRecord struct {
+	Date      string `json:"date"`
+	Precision string `json:"precision"` // year, month or day
+}
+
+// queryContributors returns the composers and lyricists of each anthem,
+// keyed by anthem ID and role, in their Wikidata order
+func queryContributors(db *sql.DB) (map[int]map[string][]PersonRecord, error) {
+	result := make(map[int]map[string][]PersonRecord)
+	// Added by schema migration 10
+	if !columnExists(db, "anthem_contributors", "role") {
+		return result, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT ac.anthem_id, ac.role, p.id, COALESCE(p.name,''), COALESCE(p.birth_year,0), COALESCE(p.death_year,0)
+		FROM anthem_contributors ac JOIN people p ON p.id = ac.person_id
+		ORDER BY ac.anthem_id, ac.role, ac.position, p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var anthemID int
+		var role string
+		var p PersonRecord
+		if err := rows.Scan(&anthemID, &role, &p.WikidataID, &p.Name, &p.BirthYear, &p.DeathYear); err != nil {
+			return nil, err
+		}
+		if result[anthemID] == nil {
+			result[anthemID] = make(map[string][]PersonRecord)
+		}
+		result[anthemID][role] = append(result[anthemID][role], p)
+	}
+	return result, rows.Err()
+}
+
+// adoptedDate prefers the normalised adoption date and falls back to parsing
+// the free-text one, which sources may have rewritten since
+func adoptedDate(on, precision, text string) *DateRecord {
+	if on != "" && precision != "" {
+		return &DateRecord{Date: on, Precision: precision}
+	}
+	if d, ok := dates.Parse(text); ok {
+		return &DateRecord{Date: d.Value, Precision: d.Precision}
+	}
+	return nil
+}
+
+// personNames joins the names of people for the legacy string fields
+func personNames(people []PersonRecord) string {
+	names := make([]string, 0, len(people))
+	for _, p := range people {
+		if p.Name != "" {
+			names = append(names, p.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,156 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// newStagingDir creates an empty directory next to outputDir, on the same
+// filesystem, so it can be renamed into place.
+func newStagingDir(outputDir string) (string, error) {
+	return os.MkdirTemp(filepath.Dir(outputDir), "."+filepath.Base(outputDir)+".tmp-")
+}
+
+// publish replaces outputDir with stagingDir, holding a finished export, so
+// readers see the old export or the new one and never a mix (see swapDir).
+// Files listed by the previous index.json but not written this time (e.g.
+// shards of removed countries) are dropped; other files in outputDir are
+// linked into the new tree first, so they are kept.
+func publish(stagingDir, outputDir string, files []string) error {
+	written := make(map[string]bool, len(files))
+	for _, name := range files {
+		written[name] = true
+	}
+	stale := map[string]bool{}
+	for _, name := range previousFiles(outputDir) {
+		if isLocalPath(name) {
+			stale[name] = true
+		}
+	}
+	if err := carryOver(outputDir, stagingDir, func(name string) bool {
+		return !written[name] && !stale[name]
+	}); err != nil {
+		return fmt.Errorf("keeping unrelated files: %w", err)
+	}
+	return swapDir(stagingDir, outputDir)
+}
+
+// carryOver links the files of outputDir that keep reports true for into
+// the same place in stagingDir, copying them where hard links fail.
+func carryOver(outputDir, stagingDir string, keep func(name string) bool) error {
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, p)
+		if err != nil || !keep(filepath.ToSlash(rel)) {
+			return err
+		}
+		dest := filepath.Join(stagingDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dest)
+		}
+		if os.Link(p, dest) == nil {
+			return nil
+		}
+		return copyFile(p, dest)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// swapDir puts stagingDir in the place of outputDir, keeping outputDir's
+// permissions. On Linux the two are exchanged in one atomic rename
+// (renameat2 with RENAME_EXCHANGE), so outputDir never goes missing. Where
+// that is unavailable the old tree is moved aside to ".<name>.old" and
+// restored if the second rename fails, leaving outputDir missing between
+// the two renames.
+func swapDir(stagingDir, outputDir string) error {
+	fi, err := os.Stat(outputDir)
+	if os.IsNotExist(err) {
+		if err := os.Chmod(stagingDir, 0755); err != nil {
+			return err
+		}
+		return os.Rename(stagingDir, outputDir)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(stagingDir, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if exchangeDirs(stagingDir, outputDir) == nil {
+		// stagingDir now holds the old tree
+		return os.RemoveAll(stagingDir)
+	}
+
+	// A crash between the renames of an earlier run can leave .old behind
+	old := filepath.Join(filepath.Dir(outputDir), "."+filepath.Base(outputDir)+".old")
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(outputDir, old); err != nil {
+		return err
+	}
+	if err := os.Rename(stagingDir, outputDir); err != nil {
+		os.Rename(old, outputDir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// previousFiles returns the files listed by an existing index.json
+func previousFiles(outputDir string) []string {
+	data, err := os.ReadFile(filepath.Join(outputDir, "index.json"))
+	if err != nil {
+		return nil
+	}
+	var idx IndexRecord
+	if json.Unmarshal(data, &idx) != nil {
+		return nil
+	}
+	return idx.Files
+}
+
+// isLocalPath guards against a tampered index.json deleting files outside outputDir
+func isLocalPath(name string) bool {
+	clean := path.Clean(name)
+	return clean == name && !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
@@ -0,0 +1,104 @@
+package format
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// UNMember reports whether the country is a UN member state
+func (c *CountryRecord) UNMember() bool {
+	return c.unMember
+}
+
+// FindCountry returns the country named by an ID, ISO alpha-3 or alpha-2
+// code, or nil
+func FindCountry(countries []CountryRecord, code string) *CountryRecord {
+	for i := range countries {
+		if matchCode([]string{code}, &countries[i]) != "" {
+			return &countries[i]
+		}
+	}
+	return nil
+}
+
+// AnthemMatch is a country whose anthem matched a search
+type AnthemMatch struct {
+	Country *CountryRecord
+	Field   string // first field that matched, e.g. "name", "composer"
+	Value   string // its value
+}
+
+type searchField struct{ name, value string }
+
+// SearchAnthems finds anthems whose name, titles, composers, lyricists or
+// country name contain text, case-insensitively, in country order
+func SearchAnthems(countries []CountryRecord, text string) []AnthemMatch {
+	needle := strings.ToLower(strings.TrimSpace(text))
+	if needle == "" {
+		return nil
+	}
+
+	var matches []AnthemMatch
+	for i := range countries {
+		c := &countries[i]
+		a := c.Anthem
+		if a == nil {
+			continue
+		}
+		fields := []searchField{
+			{"name", a.Name},
+			{"native_name", a.NativeName},
+			{"title_en", a.TitleEn},
+			{"composer", a.Composer},
+			{"lyricist", a.Lyricist},
+		}
+		for _, p := range a.Composers {
+			fields = append(fields, searchField{"composer", p.Name})
+		}
+		for _, p := range a.Lyricists {
+			fields = append(fields, searchField{"lyricist", p.Name})
+		}
+		fields = append(fields,
+			searchField{"country", c.Name},
+			searchField{"country", c.CommonName})
+
+		for _, f := range fields {
+			if f.value != "" && strings.Contains(strings.ToLower(f.value), needle) {
+				matches = append(matches, AnthemMatch{Country: c, Field: f.name, Value: f.value})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// Provenance is which data source last wrote a country or anthem row, and when
+type Provenance struct {
+	Source  string // data source ID, e.g. "rest-countries-api"; empty when not recorded
+	Updated string // the row's updated_at
+}
+
+// CountryProvenance returns the provenance of a country and of its anthem,
+// which is zero without one. Sources are recorded in updated_by by
+// `data download` (see db.WithWritingSource); rows written before that
+// have none.
+func CountryProvenance(db *sql.DB, c *CountryRecord) (country, anthem Provenance, err error) {
+	var anthemID int
+	if c.Anthem != nil {
+		anthemID = c.Anthem.ID
+	}
+	source := `''`
+	if columnExists(db, "countries", "updated_by") {
+		source = `COALESCE(updated_by, '')`
+	}
+	err = db.QueryRow(`
+		SELECT `+source+`, COALESCE(updated_at, ''),
+			COALESCE((SELECT `+source+` FROM anthems WHERE id = ?), ''),
+			COALESCE((SELECT updated_at FROM anthems WHERE id = ?), '')
+		FROM countries WHERE id = ?
+	`, anthemID, anthemID, c.ID).Scan(&country.Source, &country.Updated, &anthem.Source, &anthem.Updated)
+	if err != nil {
+		return Provenance{}, Provenance{}, err
+	}
+	return country, anthem, nil
+}
@@ -0,0 +1,133 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ExportSchemaVersion is written to index.json as schema_version. Bump it
+// whenever a field the website reads is renamed, removed or changes type.
+const ExportSchemaVersion = 1
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema generated from the export structs and
+// understood by the validator
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // false or *Schema
+	Items                *Schema            `json:"items,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// exportSchemas maps each schema file name to the Go type it describes
+var exportSchemas = []struct {
+	name  string
+	title string
+	value interface{}
+}{
+	{"index.schema.json", "index.json manifest", IndexRecord{}},
+	{"anthems.schema.json", "anthems.json, countries keyed by ISO alpha-3", map[string]CountryRecord{}},
+	{"country.schema.json", "countries/<iso3>.json and countries.ndjson lines", CountryRecord{}},
+	{"summary.schema.json", "summary.json, slim countries keyed by ISO alpha-3", map[string]SummaryRecord{}},
+	{"columns.schema.json", "<entity>.columns.json", columnarFile{}},
+	{"search.schema.json", "search.json, prebuilt client-side search index", SearchIndex{}},
+}
+
+// Schemas generates the JSON Schema documents for the export files,
+// keyed by file name (written under schemas/ in every export)
+func Schemas() map[string]*Schema {
+	out := make(map[string]*Schema, len(exportSchemas))
+	for _, s := range exportSchemas {
+		g := &schemaGen{defs: map[string]*Schema{}}
+		root := g.schemaFor(reflect.TypeOf(s.value))
+		root.Schema = jsonSchemaDraft
+		root.Title = s.title
+		if len(g.defs) > 0 {
+			root.Defs = g.defs
+		}
+		out[s.name] = root
+	}
+	return out
+}
+
+// schemaGen builds schemas by reflection. Named structs become $defs so
+// nested records are described once.
+type schemaGen struct {
+	defs map[string]*Schema
+}
+
+func (g *schemaGen) schemaFor(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaFor(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // guards against recursive types
+			g.defs[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/$defs/" + name}
+	}
+	// interface{} and anything else: no constraint
+	return &Schema{}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// writeSchemas writes schemas/<name> for every export schema
+func writeSchemas(outputDir string) ([]string, error) {
+	if err := os.MkdirAll(filepath.Join(outputDir, "schemas"), 0755); err != nil {
+		return nil, err
+	}
+	schemas := Schemas()
+	var files []string
+	for name, schema := range schemas {
+		if err := writeJSON(filepath.Join(outputDir, "schemas", name), schema); err != nil {
+			return nil, err
+		}
+		files = append(files, "schemas/"+name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,187 @@
+package format
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SearchIndexVersion is written to search.json as version. Bump it when the
+// tokenizer or layout changes, so cached copies are not read the old way.
+const SearchIndexVersion = 1
+
+// SearchIndex is search.json, a prebuilt inverted index the static site can
+// search without a server; the countries table (countries-table.js) uses it
+// when present. Terms are tokenized like Tokenize: lowercased,
+// accents removed (NFD without combining marks), split on anything that
+// is not a letter or number, stop words dropped. Prefix search scans the
+// term keys.
+type SearchIndex struct {
+	Version   int      `json:"version"`
+	Fields    []string `json:"fields"` // indexed fields, highest weight first
+	StopWords []string `json:"stop_words"`
+	// Docs are [key, country name, anthem name], key as in anthems.json
+	Docs [][]string `json:"docs"`
+	// Terms maps each term to flat [doc, score, doc, score, …] pairs, doc
+	// being an index into Docs and score the summed weight of the fields
+	// containing the term
+	Terms map[string][]int `json:"terms"`
+}
+
+// searchFields are the indexed fields with their weights, matching the
+// bm25 weights of the database search index
+var searchFields = []struct {
+	name   string
+	weight int
+	value  func(c *CountryRecord) []string
+}{
+	{"anthem", 10, func(c *CountryRecord) []string {
+		if c.Anthem == nil {
+			return nil
+		}
+		return []string{c.Anthem.Name, c.Anthem.NativeName}
+	}},
+	{"country", 8, func(c *CountryRecord) []string { return []string{c.Name, c.CommonName} }},
+	{"title_en", 5, func(c *CountryRecord) []string {
+		if c.Anthem == nil {
+			return nil
+		}
+		return []string{c.Anthem.TitleEn}
+	}},
+	{"composer", 5, func(c *CountryRecord) []string {
+		if c.Anthem == nil {
+			return nil
+		}
+		return []string{c.Anthem.Composer, personNames(c.Anthem.Composers)}
+	}},
+	{"lyricist", 5, func(c *CountryRecord) []string {
+		if c.Anthem == nil {
+			return nil
+		}
+		return []string{c.Anthem.Lyricist, personNames(c.Anthem.Lyricists)}
+	}},
+	{"symbols", 2, func(c *CountryRecord) []string { return []string{c.NationalSymbol} }},
+	{"history", 1, func(c *CountryRecord) []string {
+		if c.Anthem == nil {
+			return nil
+		}
+		return []string{c.Anthem.History}
+	}},
+}
+
+// searchStopWords are English words too frequent to be worth indexing
+var searchStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "by", "for", "from", "has", "in", "is",
+	"it", "its", "of", "on", "or", "that", "the", "this", "to", "was", "were", "with",
+}
+
+// BuildSearchIndex builds search.json for countries, in their order
+func BuildSearchIndex(countries []CountryRecord) *SearchIndex {
+	stop := make(map[string]bool, len(searchStopWords))
+	for _, w := range searchStopWords {
+		stop[w] = true
+	}
+
+	idx := &SearchIndex{
+		Version:   SearchIndexVersion,
+		StopWords: searchStopWords,
+		Docs:      make([][]string, 0, len(countries)),
+		Terms:     map[string][]int{},
+	}
+	for _, f := range searchFields {
+		idx.Fields = append(idx.Fields, f.name)
+	}
+
+	for i := range countries {
+		c := &countries[i]
+		anthem := ""
+		if c.Anthem != nil {
+			anthem = c.Anthem.Name
+		}
+		idx.Docs = append(idx.Docs, []string{CountryKey(c), DisplayName(c), anthem})
+
+		scores := map[string]int{}
+		for _, f := range searchFields {
+			seen := map[string]bool{}
+			for _, text := range f.value(c) {
+				for _, term := range Tokenize(text) {
+					if !stop[term] && !seen[term] {
+						seen[term] = true
+						scores[term] += f.weight
+					}
+				}
+			}
+		}
+		for term, score := range scores {
+			idx.Terms[term] = append(idx.Terms[term], i, score)
+		}
+	}
+	return idx
+}
+
+// writeSearchIndex writes search.json without indentation, since it is
+// downloaded whole by the browser
+func writeSearchIndex(countries []CountryRecord, outputDir string) ([]string, error) {
+	data, err := json.Marshal(BuildSearchIndex(countries))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "search.json"), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return []string{"search.json"}, nil
+}
+
+// Tokenize splits text into lowercase search terms with accents removed.
+// It follows tokenize() in countries-table.js, which searches the index:
+// lowercase, decompose (NFD), drop combining marks (Mn) and split on
+// anything that is not a letter or number.
+func Tokenize(text string) []string {
+	var terms []string
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			terms = append(terms, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range norm.NFD.String(toLower(text)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			b.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// toLower lowercases s like JavaScript's toLowerCase, which also turns a
+// capital sigma that ends a word into the final form ς
+func toLower(s string) string {
+	runes := []rune(s)
+	cased := func(i, step int) bool {
+		for i += step; i >= 0 && i < len(runes); i += step {
+			if !unicode.Is(unicode.Mn, runes[i]) {
+				return unicode.IsUpper(runes[i]) || unicode.IsLower(runes[i]) || unicode.IsTitle(runes[i])
+			}
+		}
+		return false
+	}
+	var b strings.Builder
+	for i, r := range runes {
+		if r == 'Σ' && cased(i, -1) && !cased(i, 1) {
+			b.WriteRune('ς')
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package format
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SummaryRecord is the slim per-country row in summary.json, enough for the
+// countries table and map without downloading every anthem's history and audio.
+type SummaryRecord struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ISOAlpha2   string `json:"iso_alpha2,omitempty"`
+	ISOAlpha3   string `json:"iso_alpha3,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Subregion   string `json:"subregion,omitempty"`
+	FlagURL     string `json:"flag_url,omitempty"`
+	AnthemName  string `json:"anthem_name,omitempty"`
+	TitleEn     string `json:"title_en,omitempty"`
+	Composer    string `json:"composer,omitempty"`
+	AdoptedDate string `json:"adopted_date,omitempty"`
+	AudioURL    string `json:"audio_url,omitempty"` // primary recording
+	AudioFormat string `json:"audio_format,omitempty"`
+	AudioCount  int    `json:"audio_count"`
+	GameRank    int    `json:"game_rank,omitempty"`
+	Shard       string `json:"shard"` // path of the full record, relative to the export
+}
+
+// shardPath returns the per-country file for c, e.g. "countries/fra.json"
+func shardPath(c *CountryRecord) string {
+	return "countries/" + c.ID + ".json"
+}
+
+// writeShards writes countries/<iso3>.json for every country and summary.json
+// keyed by ISO alpha-3. It returns the files written and the shard of each country.
+func writeShards(countries []CountryRecord, outputDir string) ([]string, map[string]string, error) {
+	if err := os.MkdirAll(filepath.Join(outputDir, "countries"), 0755); err != nil {
+		return nil, nil, err
+	}
+
+	var files []string
+	shards := make(map[string]string, len(countries))
+	summary := make(map[string]SummaryRecord, len(countries))
+	for i := range countries {
+		c := &countries[i]
+		shard := shardPath(c)
+		if err := writeJSON(filepath.Join(outputDir, filepath.FromSlash(shard)), c); err != nil {
+			return nil, nil, err
+		}
+		files = append(files, shard)
+		shards[CountryKey(c)] = shard
+		summary[CountryKey(c)] = summarize(c, shard)
+	}
+
+	if err := writeJSON(filepath.Join(outputDir, "summary.json"), summary); err != nil {
+		return nil, nil, err
+	}
+	return append(files, "summary.json"), shards, nil
+}
+
+func summarize(c *CountryRecord, shard string) SummaryRecord {
+	s := SummaryRecord{
+		ID:         c.ID,
+		Name:       c.Name,
+		ISOAlpha2:  c.ISOAlpha2,
+		ISOAlpha3:  c.ISOAlpha3,
+		Region:     c.Region,
+		Subregion:  c.Subregion,
+		FlagURL:    c.FlagURL,
+		AudioCount: len(c.AudioFiles),
+		Shard:      shard,
+	}
+	if c.Game != nil {
+		s.GameRank = c.Game.Rank
+	}
+	if c.Anthem != nil {
+		s.AnthemName = c.Anthem.Name
+		s.TitleEn = c.Anthem.TitleEn
+		s.Composer = c.Anthem.Composer
+		s.AdoptedDate = c.Anthem.AdoptedDate
+	}
+	// markPrimary puts the canonical track first
+	if len(c.AudioFiles) > 0 {
+		s.AudioURL = c.AudioFiles[0].URL
+		s.AudioFormat = c.AudioFiles[0].Format
+	}
+	return s
+}
+
+// hashFiles returns the SHA-256 of each file, for cache-busting
+func hashFiles(outputDir string, files []string) (map[string]string, error) {
+	hashes := make(map[string]string, len(files))
+	for _, name := range files {
+		f, err := os.Open(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		hashes[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes, nil
+}
@@ -0,0 +1,213 @@
+package format
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// table is one entity flattened to rows of scalar values, shared by the
+// CSV and columnar exporters. Nested records and lists become their own table
+// keyed by country_id.
+type table struct {
+	name    string
+	columns []string
+	types   []string // string, integer, number or boolean
+	rows    [][]interface{}
+}
+
+// field is an exported scalar struct field and its JSON name
+type field struct {
+	name  string
+	index int
+	typ   string
+}
+
+// scalarFields lists the fields of t that hold a single value, named after
+// their JSON tags, so tables stay in step with the JSON export.
+func scalarFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		var typ string
+		switch ft.Kind() {
+		case reflect.String:
+			typ = "string"
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			typ = "integer"
+		case reflect.Float32, reflect.Float64:
+			typ = "number"
+		case reflect.Bool:
+			typ = "boolean"
+		default:
+			continue
+		}
+		fields = append(fields, field{name: name, index: i, typ: typ})
+	}
+	return fields
+}
+
+func newTable(name string, keyed bool, fields []field) *table {
+	t := &table{name: name}
+	if keyed {
+		t.columns = append(t.columns, "country_id")
+		t.types = append(t.types, "string")
+	}
+	for _, f := range fields {
+		t.columns = append(t.columns, f.name)
+		t.types = append(t.types, f.typ)
+	}
+	return t
+}
+
+// appendRow adds the scalar fields of v (a struct value), prefixed by key
+func (t *table) appendRow(fields []field, v reflect.Value, key ...interface{}) {
+	row := append([]interface{}{}, key...)
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				row = append(row, nil)
+				continue
+			}
+			fv = fv.Elem()
+		}
+		row = append(row, fv.Interface())
+	}
+	t.rows = append(t.rows, row)
+}
+
+// buildTables flattens countries into countries, anthems and audio tables
+func buildTables(countries []CountryRecord) []*table {
+	countryFields := scalarFields(reflect.TypeOf(CountryRecord{}))
+	anthemFields := scalarFields(reflect.TypeOf(AnthemRecord{}))
+	audioFields := scalarFields(reflect.TypeOf(AudioRecord{}))
+
+	countryTable := newTable("countries", false, countryFields)
+	anthemTable := newTable("anthems", true, anthemFields)
+	audioTable := newTable("audio", true, audioFields)
+
+	for i := range countries {
+		c := &countries[i]
+		countryTable.appendRow(countryFields, reflect.ValueOf(*c))
+		if c.Anthem != nil {
+			anthemTable.appendRow(anthemFields, reflect.ValueOf(*c.Anthem), c.ID)
+		}
+		for _, a := range c.AudioFiles {
+			audioTable.appendRow(audioFields, reflect.ValueOf(a), c.ID)
+		}
+	}
+	return []*table{countryTable, anthemTable, audioTable}
+}
+
+// csvExporter writes one CSV file per entity: countries.csv, anthems.csv
+// and audio.csv. Anthems and audio carry a country_id column.
+type csvExporter struct{}
+
+func (csvExporter) Export(countries []CountryRecord, outputDir string) ([]string, error) {
+	var files []string
+	for _, t := range buildTables(countries) {
+		name := t.name + ".csv"
+		if err := writeCSV(filepath.Join(outputDir, name), t); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+func writeCSV(path string, t *table) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(t.columns); err != nil {
+		return err
+	}
+	record := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for i, v := range row {
+			record[i] = csvValue(v)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// columnarExporter writes one column-oriented JSON file per entity
+// (countries.columns.json, ...), shaped like a Parquet/Arrow schema plus
+// column arrays:
+//
+//	{"name": "audio", "num_rows": 2, "columns": [{"name": "id", "type": "string", "values": [...]}]}
+//
+// In pandas: pd.DataFrame({c["name"]: c["values"] for c in doc["columns"]})
+type columnarExporter struct{}
+
+type columnarFile struct {
+	Name    string           `json:"name"`
+	NumRows int              `json:"num_rows"`
+	Columns []columnarColumn `json:"columns"`
+}
+
+type columnarColumn struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Values []interface{} `json:"values"`
+}
+
+func (columnarExporter) Export(countries []CountryRecord, outputDir string) ([]string, error) {
+	var files []string
+	for _, t := range buildTables(countries) {
+		doc := columnarFile{Name: t.name, NumRows: len(t.rows)}
+		for i, col := range t.columns {
+			values := make([]interface{}, len(t.rows))
+			for r, row := range t.rows {
+				values[r] = row[i]
+			}
+			doc.Columns = append(doc.Columns, columnarColumn{Name: col, Type: t.types[i], Values: values})
+		}
+		name := t.name + ".columns.json"
+		if err := writeJSON(filepath.Join(outputDir, name), doc); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
@@ -0,0 +1,205 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ValidateDir checks an export directory against the schemas of this build:
+// index.json and every JSON file it lists must match their schema, listed
+// files must exist, and their SHA-256 must match index.json. It returns the
+// problems found; the error is only set when the directory can't be read.
+func ValidateDir(dir string) ([]string, error) {
+	schemas := Schemas()
+	var problems []string
+
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read index.json: %w", err)
+	}
+	problems = append(problems, validateJSON("index.json", data, schemas["index.schema.json"])...)
+
+	var idx IndexRecord
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return problems, nil
+	}
+	if idx.SchemaVersion != ExportSchemaVersion {
+		problems = append(problems, fmt.Sprintf("index.json: schema_version is %d, this build writes %d",
+			idx.SchemaVersion, ExportSchemaVersion))
+	}
+
+	var listed []string
+	for _, name := range idx.Files {
+		if name == "index.json" {
+			continue
+		}
+		if !isLocalPath(name) {
+			problems = append(problems, fmt.Sprintf("index.json: file %q is outside the export", name))
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: listed in index.json but missing", name))
+			continue
+		}
+		listed = append(listed, name)
+	}
+
+	hashes, err := hashFiles(dir, listed)
+	if err != nil {
+		return problems, err
+	}
+	for _, name := range listed {
+		if want, ok := idx.Hashes[name]; ok && want != hashes[name] {
+			problems = append(problems, fmt.Sprintf("%s: SHA-256 does not match index.json", name))
+		}
+
+		schema := schemaForFile(name, schemas)
+		if schema == nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return problems, err
+		}
+		if strings.HasSuffix(name, ".ndjson") {
+			problems = append(problems, validateNDJSON(name, data, schema)...)
+		} else {
+			problems = append(problems, validateJSON(name, data, schema)...)
+		}
+	}
+	return problems, nil
+}
+
+// schemaForFile picks the schema for an export file, or nil for files that
+// have none (CSV, the schemas themselves)
+func schemaForFile(name string, schemas map[string]*Schema) *Schema {
+	// Translated exports live under <lang>/ with the same layout
+	base := path.Base(name)
+	switch {
+	case base == "anthems.json":
+		return schemas["anthems.schema.json"]
+	case base == "summary.json":
+		return schemas["summary.schema.json"]
+	case base == "search.json":
+		return schemas["search.schema.json"]
+	case base == "countries.ndjson":
+		return schemas["country.schema.json"]
+	case path.Base(path.Dir(name)) == "countries" && strings.HasSuffix(name, ".json"):
+		return schemas["country.schema.json"]
+	case strings.HasSuffix(name, ".columns.json"):
+		return schemas["columns.schema.json"]
+	}
+	return nil
+}
+
+func validateJSON(name string, data []byte, schema *Schema) []string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return []string{fmt.Sprintf("%s: invalid JSON: %v", name, err)}
+	}
+	var problems []string
+	validateValue(v, schema, schema, "", func(ptr, msg string) {
+		if ptr == "" {
+			ptr = "/"
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s: %s", name, ptr, msg))
+	})
+	return problems
+}
+
+func validateNDJSON(name string, data []byte, schema *Schema) []string {
+	var problems []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		problems = append(problems, validateJSON(fmt.Sprintf("%s:%d", name, line), scanner.Bytes(), schema)...)
+	}
+	return problems
+}
+
+// validateValue checks v against s; root resolves "#/$defs/..." references
+func validateValue(v interface{}, s, root *Schema, ptr string, report func(ptr, msg string)) {
+	if s.Ref != "" {
+		def := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if def == nil {
+			report(ptr, "unresolved schema reference "+s.Ref)
+			return
+		}
+		s = def
+	}
+
+	if s.Type != "" && jsonType(v, s.Type) != s.Type {
+		report(ptr, fmt.Sprintf("expected %s, got %s", s.Type, jsonType(v, s.Type)))
+		return
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				report(ptr, fmt.Sprintf("missing required field %q", key))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := ptr + "/" + key
+			if prop, ok := s.Properties[key]; ok {
+				validateValue(v[key], prop, root, child, report)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					report(ptr, fmt.Sprintf("unexpected field %q", key))
+				}
+			case *Schema:
+				validateValue(v[key], extra, root, child, report)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				validateValue(item, s.Items, root, fmt.Sprintf("%s/%d", ptr, i), report)
+			}
+		}
+	}
+}
+
+// jsonType names the JSON type of a decoded value. Whole numbers count as
+// integers, and integers also satisfy "number".
+func jsonType(v interface{}, want string) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil && want != "number" {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
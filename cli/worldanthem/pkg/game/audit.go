@@ -0,0 +1,459 @@
+package game
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of audit flags
+const (
+	FlagListen    = "listen"    // listen times the voter cannot have had
+	FlagFavourite = "favourite" // a session that keeps voting one country up
+	FlagBurst     = "burst"     // a flood of votes from one voter country
+	FlagJump      = "jump"      // a rating that rose too fast
+)
+
+// AuditOptions are the thresholds of Audit
+type AuditOptions struct {
+	// MaxListenMS is the longest plausible listen of one anthem in a round;
+	// ListenSlack the clock skew allowed between the browser and the server
+	MaxListenMS int64         `json:"max_listen_ms"`
+	ListenSlack time.Duration `json:"listen_slack_ns"`
+
+	// A session favours a country it voted on at least FavourVotes times when
+	// the chance of it winning that often, at its win rate in other sessions,
+	// is below FavourP
+	FavourVotes int     `json:"favour_votes"`
+	FavourP     float64 `json:"favour_p"`
+
+	// A burst is at least BurstVotes votes from one voter country within
+	// BurstWindow; the wins of an anthem taking BurstShare of them are flagged
+	BurstVotes  int           `json:"burst_votes"`
+	BurstWindow time.Duration `json:"burst_window_ns"`
+	BurstShare  float64       `json:"burst_share"`
+
+	// A jump is a country gaining JumpPoints of the game's ELO, with factor
+	// K, within JumpWindow
+	JumpPoints int           `json:"jump_points"`
+	JumpWindow time.Duration `json:"jump_window_ns"`
+	K          float64       `json:"k"`
+}
+
+// DefaultAuditOptions are the defaults of `game audit`
+var DefaultAuditOptions = AuditOptions{
+	MaxListenMS: 10 * 60 * 1000,
+	ListenSlack: 5 * time.Second,
+	FavourVotes: 5,
+	FavourP:     0.001,
+	BurstVotes:  30,
+	BurstWindow: 10 * time.Minute,
+	BurstShare:  0.5,
+	JumpPoints:  100,
+	JumpWindow:  time.Hour,
+	K:           K,
+}
+
+// AuditSession is what the audit knows of the session behind votes
+type AuditSession struct {
+	VoterCountry string
+	IPHash       string
+	CreatedAt    time.Time
+}
+
+// LocalSessions returns the sessions of game_sessions by ID
+func LocalSessions(db *sql.DB) (map[string]AuditSession, error) {
+	rows, err := db.Query(`SELECT session_id, UPPER(COALESCE(user_country,'')), COALESCE(ip_hash,''), created_at FROM game_sessions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := map[string]AuditSession{}
+	for rows.Next() {
+		var id, createdAt string
+		var s AuditSession
+		if err := rows.Scan(&id, &s.VoterCountry, &s.IPHash, &createdAt); err != nil {
+			return nil, err
+		}
+		// Sessions without a parsable creation time only skip the first
+		// listen check
+		s.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		sessions[id] = s
+	}
+	return sessions, rows.Err()
+}
+
+// AuditFlag is one anomaly and the votes it flags
+type AuditFlag struct {
+	Kind         string    `json:"kind"`
+	SessionID    string    `json:"session_id,omitempty"`
+	VoterCountry string    `json:"voter_country,omitempty"`
+	CountryID    string    `json:"country_id,omitempty"` // anthem favoured, winning a burst or jumping
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Votes        []string  `json:"votes"` // IDs of the flagged votes; a burst without a favourite flags none
+	Detail       string    `json:"detail"`
+}
+
+// AuditResult is the outcome of Audit
+type AuditResult struct {
+	Options AuditOptions `json:"options"`
+	Votes   int          `json:"votes"`
+	Flagged int          `json:"flagged"` // votes with at least one flag
+	Flags   []AuditFlag  `json:"flags"`
+}
+
+// FlaggedVotes returns the IDs of every flagged vote
+func (r AuditResult) FlaggedVotes() map[string]bool {
+	flagged := map[string]bool{}
+	for _, f := range r.Flags {
+		for _, id := range f.Votes {
+			flagged[id] = true
+		}
+	}
+	return flagged
+}
+
+// Exclude returns votes without the flagged ones
+func (r AuditResult) Exclude(votes []LoggedVote) []LoggedVote {
+	return ExcludeVotes(votes, r.FlaggedVotes())
+}
+
+// ExcludeVotes returns votes without the ones whose ID is in ids
+func ExcludeVotes(votes []LoggedVote, ids map[string]bool) []LoggedVote {
+	kept := make([]LoggedVote, 0, len(votes))
+	for _, v := range votes {
+		if !ids[v.VoteID] {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// WriteVoteIDs writes the flagged vote IDs one per line, sorted, for
+// ReadVoteIDs to read back
+func (r AuditResult) WriteVoteIDs(w io.Writer) error {
+	var ids []string
+	for id := range r.FlaggedVotes() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	bw := bufio.NewWriter(w)
+	for _, id := range ids {
+		fmt.Fprintln(bw, id)
+	}
+	return bw.Flush()
+}
+
+// ReadVoteIDs reads vote IDs one per line; blank lines and lines starting
+// with # are skipped
+func ReadVoteIDs(r io.Reader) (map[string]bool, error) {
+	ids := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids[line] = true
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// Audit looks for votes the vote Lambda's per-session and per-IP caps let
+// through but that are unlikely to be honest. votes must be in voting order
+// with cumulative listens, as LocalVotes and ReadVoteLog return them;
+// sessions may be nil, which leaves out what needs voter countries.
+//
+// Listen times are reported by the browser, which restores the session's
+// earlier listening of a country before timing a round. So a round's listens
+// are only checked against the time since the session's previous vote when
+// both countries are new to the session.
+func Audit(votes []LoggedVote, sessions map[string]AuditSession, opts AuditOptions) AuditResult {
+	res := AuditResult{Options: opts, Votes: len(votes)}
+	res.Flags = append(res.Flags, auditListens(votes, sessions, opts)...)
+	res.Flags = append(res.Flags, auditFavourites(votes, opts)...)
+	res.Flags = append(res.Flags, auditBursts(votes, sessions, opts)...)
+	res.Flags = append(res.Flags, auditJumps(votes, opts)...)
+	sort.SliceStable(res.Flags, func(i, j int) bool { return res.Flags[i].Start.Before(res.Flags[j].Start) })
+	res.Flagged = len(res.FlaggedVotes())
+	return res
+}
+
+func auditListens(votes []LoggedVote, sessions map[string]AuditSession, opts AuditOptions) []AuditFlag {
+	type state struct {
+		last  time.Time
+		heard map[string]bool
+		votes int
+		flag  *AuditFlag
+	}
+	bySession := map[string]*state{}
+	var order []string
+	for _, v := range votes {
+		s, ok := bySession[v.SessionID]
+		if !ok {
+			s = &state{last: sessions[v.SessionID].CreatedAt, heard: map[string]bool{}}
+			bySession[v.SessionID] = s
+			order = append(order, v.SessionID)
+		}
+		s.votes++
+
+		var reason string
+		switch {
+		case v.ListenAMS < 0 || v.ListenBMS < 0:
+			reason = fmt.Sprintf("negative listen (%dms, %dms)", v.ListenAMS, v.ListenBMS)
+		case v.ListenAMS > opts.MaxListenMS || v.ListenBMS > opts.MaxListenMS:
+			reason = fmt.Sprintf("%s heard in one round", formatMS(max(v.ListenAMS, v.ListenBMS)))
+		case !s.last.IsZero() && !s.heard[v.WinnerID] && !s.heard[v.LoserID]:
+			elapsed := v.VotedAt.Sub(s.last) + opts.ListenSlack
+			if heard := time.Duration(v.ListenAMS+v.ListenBMS) * time.Millisecond; heard > elapsed {
+				reason = fmt.Sprintf("%s heard in %s", formatMS(v.ListenAMS+v.ListenBMS), formatMS((elapsed - opts.ListenSlack).Milliseconds()))
+			}
+		}
+		if reason != "" {
+			if s.flag == nil {
+				s.flag = &AuditFlag{Kind: FlagListen, SessionID: v.SessionID, VoterCountry: sessions[v.SessionID].VoterCountry,
+					Start: v.VotedAt, Detail: reason}
+			}
+			s.flag.End = v.VotedAt
+			s.flag.Votes = append(s.flag.Votes, v.VoteID)
+		}
+		s.last = v.VotedAt
+		s.heard[v.WinnerID], s.heard[v.LoserID] = true, true
+	}
+
+	var flags []AuditFlag
+	for _, id := range order {
+		if s := bySession[id]; s.flag != nil {
+			if s.votes > 1 {
+				s.flag.Detail = fmt.Sprintf("%d of %d votes with impossible listening, e.g. %s", len(s.flag.Votes), s.votes, s.flag.Detail)
+			}
+			flags = append(flags, *s.flag)
+		}
+	}
+	return flags
+}
+
+func auditFavourites(votes []LoggedVote, opts AuditOptions) []AuditFlag {
+	type record struct {
+		votes, wins int
+		won         []int // indexes of the votes won
+	}
+	overall := map[string]*record{}
+	bySession := map[[2]string]*record{}
+	var order [][2]string
+	for i, v := range votes {
+		for _, id := range []string{v.WinnerID, v.LoserID} {
+			key := [2]string{v.SessionID, id}
+			r, ok := bySession[key]
+			if !ok {
+				r = &record{}
+				bySession[key] = r
+				order = append(order, key)
+			}
+			o := overall[id]
+			if o == nil {
+				o = &record{}
+				overall[id] = o
+			}
+			r.votes++
+			o.votes++
+			if id == v.WinnerID {
+				r.wins++
+				o.wins++
+				r.won = append(r.won, i)
+			}
+		}
+	}
+
+	var flags []AuditFlag
+	for _, key := range order {
+		r := bySession[key]
+		if r.votes < opts.FavourVotes || r.wins == 0 {
+			continue
+		}
+		// Win rate everywhere else, smoothed towards a coin flip
+		o := overall[key[1]]
+		p := float64(o.wins-r.wins+1) / float64(o.votes-r.votes+2)
+		if chance := binomialTail(r.votes, r.wins, p); chance < opts.FavourP {
+			f := AuditFlag{Kind: FlagFavourite, SessionID: key[0], CountryID: key[1],
+				Start: votes[r.won[0]].VotedAt, End: votes[r.won[len(r.won)-1]].VotedAt,
+				Detail: fmt.Sprintf("won %d of %d votes, %.0f%% elsewhere (p = %.2g)", r.wins, r.votes, p*100, chance)}
+			for _, i := range r.won {
+				f.Votes = append(f.Votes, votes[i].VoteID)
+			}
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+func auditBursts(votes []LoggedVote, sessions map[string]AuditSession, opts AuditOptions) []AuditFlag {
+	byCountry := map[string][]int{}
+	var countries []string
+	for i, v := range votes {
+		c := sessions[v.SessionID].VoterCountry
+		if c == "" {
+			continue
+		}
+		if byCountry[c] == nil {
+			countries = append(countries, c)
+		}
+		byCountry[c] = append(byCountry[c], i)
+	}
+
+	var flags []AuditFlag
+	for _, c := range countries {
+		idx := byCountry[c]
+		// Windows of BurstVotes votes within BurstWindow, overlapping ones
+		// merged into one burst
+		for _, span := range mergeSpans(len(idx), opts.BurstVotes, func(first, last int) bool {
+			return votes[idx[last]].VotedAt.Sub(votes[idx[first]].VotedAt) <= opts.BurstWindow
+		}) {
+			burst := idx[span[0] : span[1]+1]
+			wins := map[string][]int{}
+			sessionIDs, ips := map[string]bool{}, map[string]bool{}
+			for _, i := range burst {
+				v := votes[i]
+				wins[v.WinnerID] = append(wins[v.WinnerID], i)
+				sessionIDs[v.SessionID] = true
+				if ip := sessions[v.SessionID].IPHash; ip != "" {
+					ips[ip] = true
+				}
+			}
+			start, end := votes[burst[0]].VotedAt, votes[burst[len(burst)-1]].VotedAt
+			f := AuditFlag{Kind: FlagBurst, VoterCountry: c, Start: start, End: end, Votes: []string{},
+				Detail: fmt.Sprintf("%d votes in %s from %d sessions, %d IPs", len(burst), end.Sub(start).Round(time.Second),
+					len(sessionIDs), len(ips))}
+			var top string
+			for id, won := range wins {
+				if len(won) > len(wins[top]) || len(won) == len(wins[top]) && id < top {
+					top = id
+				}
+			}
+			if share := float64(len(wins[top])) / float64(len(burst)); share >= opts.BurstShare {
+				f.CountryID = top
+				f.Detail += fmt.Sprintf("; %s won %d", top, len(wins[top]))
+				for _, i := range wins[top] {
+					f.Votes = append(f.Votes, votes[i].VoteID)
+				}
+			}
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+func auditJumps(votes []LoggedVote, opts AuditOptions) []AuditFlag {
+	type point struct {
+		at     time.Time
+		rating int
+		vote   int  // index of the vote that set the rating
+		won    bool // whether the country won it
+	}
+	rater := NewEloRater(opts.K)
+	history := map[string][]point{}
+	var countries []string
+	weigh := ReplayOptions{}
+	for i, v := range votes {
+		for _, id := range []string{v.WinnerID, v.LoserID} {
+			if history[id] == nil {
+				countries = append(countries, id)
+				history[id] = []point{{at: v.VotedAt, rating: InitialELO, vote: -1}}
+			}
+		}
+		rater.Rate(v.WinnerID, v.LoserID, weigh.Weight(v))
+		history[v.WinnerID] = append(history[v.WinnerID], point{v.VotedAt, rater.rating(v.WinnerID), i, true})
+		history[v.LoserID] = append(history[v.LoserID], point{v.VotedAt, rater.rating(v.LoserID), i, false})
+	}
+
+	var flags []AuditFlag
+	for _, id := range countries {
+		h := history[id]
+		// Spans from a low point to a rating JumpPoints higher within
+		// JumpWindow, overlapping ones merged. The rating a country had when
+		// the window opened counts as a low point.
+		var spans [][2]int
+		for e := range h {
+			low := -1
+			for s := e - 1; s >= 0; s-- {
+				if low < 0 || h[s].rating < h[low].rating {
+					low = s
+				}
+				if h[e].at.Sub(h[s].at) > opts.JumpWindow {
+					break
+				}
+			}
+			if low < 0 || h[e].rating-h[low].rating < opts.JumpPoints {
+				continue
+			}
+			if n := len(spans); n > 0 && low <= spans[n-1][1] {
+				spans[n-1][1] = e
+			} else {
+				spans = append(spans, [2]int{low, e})
+			}
+		}
+		for _, span := range spans {
+			low, first, peak := h[span[0]], h[span[0]+1], h[span[1]]
+			f := AuditFlag{Kind: FlagJump, CountryID: id, Start: first.at, End: peak.at,
+				Detail: fmt.Sprintf("%d → %d in %s", low.rating, peak.rating, peak.at.Sub(first.at).Round(time.Second))}
+			for _, p := range h[span[0]+1 : span[1]+1] {
+				if p.won {
+					f.Votes = append(f.Votes, votes[p.vote].VoteID)
+				}
+			}
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+// mergeSpans returns the spans [first, last] of n ordered items covered by
+// runs of at least size consecutive items for which within holds, overlapping
+// runs merged
+func mergeSpans(n, size int, within func(first, last int) bool) [][2]int {
+	var spans [][2]int
+	if size < 1 {
+		size = 1
+	}
+	for last := size - 1; last < n; last++ {
+		first := last - size + 1
+		if !within(first, last) {
+			continue
+		}
+		if k := len(spans); k > 0 && first <= spans[k-1][1] {
+			spans[k-1][1] = last
+		} else {
+			spans = append(spans, [2]int{first, last})
+		}
+	}
+	return spans
+}
+
+// binomialTail is the probability of at least k successes in n trials of
+// probability p
+func binomialTail(n, k int, p float64) float64 {
+	if p <= 0 {
+		return 0
+	}
+	if p >= 1 {
+		return 1
+	}
+	var sum float64
+	for i := k; i <= n; i++ {
+		lc, _ := math.Lgamma(float64(n + 1))
+		li, _ := math.Lgamma(float64(i + 1))
+		lr, _ := math.Lgamma(float64(n - i + 1))
+		sum += math.Exp(lc - li - lr + float64(i)*math.Log(p) + float64(n-i)*math.Log(1-p))
+	}
+	return math.Min(sum, 1)
+}
+
+func formatMS(ms int64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(ms)/1000), ".0") + "s"
+}
@@ -0,0 +1,150 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var auditStart = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func auditVote(id, session, winner, loser string, at time.Duration, listenA, listenB int64) LoggedVote {
+	return LoggedVote{VoteID: id, SessionID: session, WinnerID: winner, LoserID: loser,
+		ListenAMS: listenA, ListenBMS: listenB, VotedAt: auditStart.Add(at)}
+}
+
+func auditFlags(res AuditResult, kind string) []AuditFlag {
+	var flags []AuditFlag
+	for _, f := range res.Flags {
+		if f.Kind == kind {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+func TestAuditListens(t *testing.T) {
+	votes := []LoggedVote{
+		auditVote("v1", "s1", "FRA", "DEU", 3*time.Second, 10000, 10000),
+		auditVote("v2", "s1", "DEU", "FRA", 4*time.Second, 10000, 10000), // both heard before: not checked
+		auditVote("v3", "s1", "ESP", "PRT", time.Minute, 10000, 10000),
+		auditVote("v4", "s2", "FRA", "ITA", time.Minute, 20*60*1000, 0),
+	}
+	fillListens(votes)
+	sessions := map[string]AuditSession{"s1": {CreatedAt: auditStart}}
+	flags := auditFlags(Audit(votes, sessions, DefaultAuditOptions), FlagListen)
+	if len(flags) != 2 {
+		t.Fatalf("Expected 2 listen flags, got %+v", flags)
+	}
+	if flags[0].SessionID != "s1" || !reflect.DeepEqual(flags[0].Votes, []string{"v1"}) {
+		t.Errorf("Expected v1 of s1 flagged, got %+v", flags[0])
+	}
+	if flags[1].SessionID != "s2" || !reflect.DeepEqual(flags[1].Votes, []string{"v4"}) {
+		t.Errorf("Expected v4 of s2 flagged, got %+v", flags[1])
+	}
+}
+
+func TestAuditFavourites(t *testing.T) {
+	var votes []LoggedVote
+	// Italy wins a third of its votes with everyone else...
+	for i := 0; i < 42; i++ {
+		v := auditVote(fmt.Sprintf("b%d", i), fmt.Sprintf("b%d", i), "FRA", "ITA", time.Duration(i)*time.Hour, 10000, 10000)
+		if i%3 == 0 {
+			v.WinnerID, v.LoserID = "ITA", "FRA"
+		}
+		votes = append(votes, v)
+	}
+	// ...and all of them in one session
+	for i := 0; i < 8; i++ {
+		loser := []string{"FRA", "DEU"}[i%2]
+		votes = append(votes, auditVote(fmt.Sprintf("f%d", i), "fan", "ITA", loser, 100*time.Hour+time.Duration(i)*time.Hour, 10000, 10000))
+	}
+	fillListens(votes)
+
+	flags := auditFlags(Audit(votes, nil, DefaultAuditOptions), FlagFavourite)
+	if len(flags) != 1 || flags[0].SessionID != "fan" || flags[0].CountryID != "ITA" || len(flags[0].Votes) != 8 {
+		t.Fatalf("Expected the fan session's 8 Italy votes flagged, got %+v", flags)
+	}
+	if got := binomialTail(8, 8, 0.5); math.Abs(got-1.0/256) > 1e-12 {
+		t.Errorf("Expected 1/256, got %v", got)
+	}
+}
+
+func TestAuditBursts(t *testing.T) {
+	sessions := map[string]AuditSession{}
+	var votes []LoggedVote
+	for i := 0; i < 30; i++ {
+		id := fmt.Sprintf("p%d", i)
+		sessions[id] = AuditSession{VoterCountry: "DE", IPHash: fmt.Sprintf("ip%d", i%3)}
+		winner, loser := "ITA", "FRA"
+		if i%3 == 0 {
+			winner, loser = "FRA", "ESP"
+		}
+		votes = append(votes, auditVote(id, id, winner, loser, time.Duration(i)*10*time.Second, 10000, 10000))
+	}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("q%d", i)
+		sessions[id] = AuditSession{VoterCountry: "FR"}
+		votes = append(votes, auditVote(id, id, "FRA", "ITA", time.Duration(i)*time.Second, 10000, 10000))
+	}
+	sortVotes(votes)
+	fillListens(votes)
+
+	res := Audit(votes, sessions, DefaultAuditOptions)
+	flags := auditFlags(res, FlagBurst)
+	if len(flags) != 1 || flags[0].VoterCountry != "DE" || flags[0].CountryID != "ITA" || len(flags[0].Votes) != 20 {
+		t.Fatalf("Expected one German burst flagging 20 Italy wins, got %+v", flags)
+	}
+	if kept := res.Exclude(votes); len(kept) != len(votes)-res.Flagged {
+		t.Errorf("Expected %d votes kept, got %d", len(votes)-res.Flagged, len(kept))
+	}
+
+	// The IDs written for replay read back as the same set
+	var buf bytes.Buffer
+	if err := res.WriteVoteIDs(&buf); err != nil {
+		t.Fatalf("WriteVoteIDs failed: %v", err)
+	}
+	ids, err := ReadVoteIDs(strings.NewReader("# flagged\n\n" + buf.String()))
+	if err != nil || !reflect.DeepEqual(ids, res.FlaggedVotes()) {
+		t.Errorf("Expected %d flagged IDs read back, got %d, %v", res.Flagged, len(ids), err)
+	}
+
+	// Without a dominant anthem the burst is reported but flags no votes
+	opts := DefaultAuditOptions
+	opts.BurstShare = 0.9
+	flags = auditFlags(Audit(votes, sessions, opts), FlagBurst)
+	if len(flags) != 1 || flags[0].CountryID != "" {
+		t.Fatalf("Expected one burst without a favourite, got %+v", flags)
+	}
+	if b, _ := json.Marshal(flags[0]); !strings.Contains(string(b), `"votes":[]`) {
+		t.Errorf("Expected an empty votes list, got %s", b)
+	}
+}
+
+func TestAuditJumps(t *testing.T) {
+	var votes []LoggedVote
+	for i := 0; i < 3; i++ {
+		votes = append(votes, auditVote(fmt.Sprintf("a%d", i), fmt.Sprintf("a%d", i), "FRA", "ITA", time.Duration(i)*time.Hour, 10000, 10000))
+	}
+	for i := 0; i < 10; i++ {
+		votes = append(votes, auditVote(fmt.Sprintf("j%d", i), fmt.Sprintf("j%d", i), "ITA", "DEU", 5*time.Hour+time.Duration(i)*time.Minute, 10000, 10000))
+	}
+	fillListens(votes)
+
+	opts := DefaultAuditOptions
+	opts.JumpPoints = 80
+	flags := auditFlags(Audit(votes, nil, opts), FlagJump)
+	if len(flags) != 1 || flags[0].CountryID != "ITA" || len(flags[0].Votes) != 10 {
+		t.Fatalf("Expected Italy's 10 quick wins flagged, got %+v", flags)
+	}
+
+	opts.JumpWindow = time.Minute
+	if flags := auditFlags(Audit(votes, nil, opts), FlagJump); len(flags) != 0 {
+		t.Errorf("Expected no jump within a minute, got %+v", flags)
+	}
+}
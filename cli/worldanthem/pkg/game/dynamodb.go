@@ -0,0 +1,392 @@
+package game
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Base names of the game's DynamoDB tables (sam/game/template.yaml); the
+// deployed tables carry a stage suffix, see TableName
+const (
+	RankingsTable = "anthem-rankings"
+	VotesTable    = "anthem-votes"
+	SessionsTable = "anthem-sessions"
+	ListenTable   = "anthem-listen-history"
+)
+
+// TableName returns the name of a game table in a stage, e.g.
+// anthem-rankings-local
+func TableName(base, stage string) string {
+	return base + "-" + stage
+}
+
+// dynamoBatchSize is the most items BatchWriteItem takes in one request
+const dynamoBatchSize = 25
+
+// DynamoDB is a minimal client of the DynamoDB JSON API, enough to seed and
+// read the game tables on AWS or a local endpoint such as LocalStack or
+// DynamoDB Local. Requests are signed with Signature Version 4.
+type DynamoDB struct {
+	Endpoint        string // e.g. http://localhost:4566
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+}
+
+// NewDynamoDB returns a client for endpoint with credentials from the usual
+// AWS_* environment variables or, failing those, the AWS_PROFILE (or
+// default) profile of the shared credentials file. Only local endpoints
+// such as LocalStack fall back to the dummy "test" credentials they accept;
+// elsewhere missing credentials are an error.
+func NewDynamoDB(endpoint, region string) (*DynamoDB, error) {
+	d := &DynamoDB{
+		Endpoint:        strings.TrimRight(endpoint, "/"),
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Client:          &http.Client{Timeout: 30 * time.Second},
+	}
+	if d.AccessKeyID != "" && d.SecretAccessKey != "" {
+		return d, nil
+	}
+	d.SessionToken = ""
+
+	profile := os.Getenv("AWS_PROFILE")
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+	creds, err := readSharedCredentials(path, profile)
+	switch {
+	case err == nil:
+		d.AccessKeyID, d.SecretAccessKey, d.SessionToken = creds["aws_access_key_id"], creds["aws_secret_access_key"], creds["aws_session_token"]
+		if d.AccessKeyID == "" || d.SecretAccessKey == "" {
+			return nil, fmt.Errorf("profile %q in %s has no aws_access_key_id and aws_secret_access_key", orDefault(profile), path)
+		}
+		return d, nil
+	case profile != "" || !errors.Is(err, errNoCredentials):
+		return nil, err
+	case isLocalEndpoint(d.Endpoint):
+		d.AccessKeyID, d.SecretAccessKey = "test", "test"
+		return d, nil
+	}
+	return nil, fmt.Errorf("no AWS credentials for %s: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_PROFILE", d.Endpoint)
+}
+
+// errNoCredentials is returned by readSharedCredentials when the file or
+// the profile does not exist
+var errNoCredentials = errors.New("no shared credentials")
+
+// readSharedCredentials returns the keys of a profile ("default" when
+// empty) in an AWS shared credentials file
+func readSharedCredentials(path, profile string) (map[string]string, error) {
+	profile = orDefault(profile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) || path == "" {
+		return nil, fmt.Errorf("%w: profile %q: no file %s", errNoCredentials, profile, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var creds map[string]string
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == profile && creds == nil {
+				creds = map[string]string{}
+			}
+		case section == profile:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				creds[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if creds == nil {
+		return nil, fmt.Errorf("%w: profile %q not in %s", errNoCredentials, profile, path)
+	}
+	return creds, nil
+}
+
+func orDefault(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+	return profile
+}
+
+// isLocalEndpoint reports whether endpoint is on this machine
+func isLocalEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// AttributeValue is a DynamoDB attribute in the JSON API's typed form
+type AttributeValue struct {
+	S    *string `json:"S,omitempty"`
+	N    *string `json:"N,omitempty"`
+	BOOL *bool   `json:"BOOL,omitempty"`
+	NULL *bool   `json:"NULL,omitempty"`
+}
+
+// Item is a DynamoDB item
+type Item map[string]AttributeValue
+
+func attrS(s string) AttributeValue { return AttributeValue{S: &s} }
+
+func attrN(n int64) AttributeValue {
+	s := strconv.FormatInt(n, 10)
+	return AttributeValue{N: &s}
+}
+
+// String returns the item's string attribute name, "" if it has none
+func (it Item) String(name string) string {
+	if v, ok := it[name]; ok && v.S != nil {
+		return *v.S
+	}
+	return ""
+}
+
+// Int returns the item's number attribute name, 0 if it has none
+func (it Item) Int(name string) int64 {
+	v, ok := it[name]
+	if !ok || v.N == nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(*v.N, 10, 64)
+	if err != nil {
+		f, _ := strconv.ParseFloat(*v.N, 64)
+		n = int64(f)
+	}
+	return n
+}
+
+// DynamoError is an error answer of the API
+type DynamoError struct {
+	StatusCode int
+	Type       string // e.g. ResourceNotFoundException
+	Message    string
+}
+
+func (e *DynamoError) Error() string {
+	return fmt.Sprintf("dynamodb: %s: %s (HTTP %d)", e.Type, e.Message, e.StatusCode)
+}
+
+// do calls the API operation op with the JSON of in and decodes the answer
+// into out
+func (d *DynamoDB) do(op string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, d.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+op)
+	d.sign(req, body, time.Now().UTC())
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+			Msg     string `json:"Message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		e := &DynamoError{StatusCode: resp.StatusCode, Type: apiErr.Type, Message: apiErr.Message}
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		if e.Message == "" {
+			e.Message = apiErr.Msg
+		}
+		if e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// sign adds a Signature Version 4 Authorization header for the dynamodb
+// service to req
+func (d *DynamoDB) sign(req *http.Request, body []byte, now time.Time) {
+	if d.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", d.SessionToken)
+	}
+	signV4(req, body, now, d.Region, "dynamodb", d.AccessKeyID, d.SecretAccessKey)
+}
+
+// signV4 sets X-Amz-Date and a Signature Version 4 Authorization header on
+// req, signing the host and every header already set. The query must be in
+// canonical order, as the API's requests (which have none) are.
+func signV4(req *http.Request, body []byte, now time.Time, region, service, accessKeyID, secretAccessKey string) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := now.UTC().Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := []string{"host"}
+	for name := range req.Header {
+		headers = append(headers, strings.ToLower(name))
+	}
+	sort.Strings(headers)
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		value := strings.Join(req.Header.Values(h), ",")
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+type writeRequest struct {
+	PutRequest struct {
+		Item Item `json:"Item"`
+	} `json:"PutRequest"`
+}
+
+// BatchPut writes items to table, 25 per request, retrying items the
+// service leaves unprocessed
+func (d *DynamoDB) BatchPut(table string, items []Item) error {
+	for start := 0; start < len(items); start += dynamoBatchSize {
+		end := start + dynamoBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		pending := make([]writeRequest, end-start)
+		for i, item := range items[start:end] {
+			pending[i].PutRequest.Item = item
+		}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == 5 {
+				return fmt.Errorf("dynamodb: %d items of %s left unprocessed", len(pending), table)
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(100<<attempt) * time.Millisecond)
+			}
+			var out struct {
+				UnprocessedItems map[string][]writeRequest `json:"UnprocessedItems"`
+			}
+			if err := d.do("BatchWriteItem", map[string]interface{}{
+				"RequestItems": map[string][]writeRequest{table: pending},
+			}, &out); err != nil {
+				return err
+			}
+			pending = out.UnprocessedItems[table]
+		}
+	}
+	return nil
+}
+
+// UpdateItem applies an UpdateExpression to the item of table with key.
+// names and values are its ExpressionAttributeNames and Values, if any.
+func (d *DynamoDB) UpdateItem(table string, key Item, update string, names map[string]string, values Item) error {
+	in := map[string]interface{}{"TableName": table, "Key": key, "UpdateExpression": update}
+	if len(names) > 0 {
+		in["ExpressionAttributeNames"] = names
+	}
+	if len(values) > 0 {
+		in["ExpressionAttributeValues"] = values
+	}
+	return d.do("UpdateItem", in, nil)
+}
+
+// Scan calls fn with every item of table, following the pagination.
+// projection, when not empty, is a ProjectionExpression of plain names.
+func (d *DynamoDB) Scan(table, projection string, fn func(Item) error) error {
+	in := map[string]interface{}{"TableName": table}
+	if projection != "" {
+		in["ProjectionExpression"] = projection
+	}
+	for {
+		var out struct {
+			Items            []Item `json:"Items"`
+			LastEvaluatedKey Item   `json:"LastEvaluatedKey"`
+		}
+		if err := d.do("Scan", in, &out); err != nil {
+			return err
+		}
+		for _, item := range out.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		in["ExclusiveStartKey"] = out.LastEvaluatedKey
+	}
+}
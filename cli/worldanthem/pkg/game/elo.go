@@ -0,0 +1,56 @@
+package game
+
+import "math"
+
+// Rating constants, the same as sam/game/functions/shared/elo.js
+const (
+	K          = 32   // standard for a new, volatile system
+	InitialELO = 1500 // rating of a country that has not been voted on
+	// FullListenMS is the cumulative time a voter must have heard an anthem
+	// (over any rounds of a session) for their vote to carry full weight
+	FullListenMS = 10_000
+)
+
+// ExpectedScore is the probability that a player rated a beats one rated b
+func ExpectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// ListenWeight is the weight [0, 1] of an opinion on an anthem heard for
+// totalListenMS: totalListenMS / FullListenMS, capped at 1
+func ListenWeight(totalListenMS int64) float64 {
+	return listenWeight(totalListenMS, FullListenMS)
+}
+
+func listenWeight(totalListenMS, fullListenMS int64) float64 {
+	return math.Max(0, math.Min(float64(totalListenMS)/float64(fullListenMS), 1))
+}
+
+// EloResult is the outcome of one vote
+type EloResult struct {
+	Winner     int     // new rating of the winner
+	Loser      int     // new rating of the loser
+	VoteWeight float64 // listen weight of the vote, rounded to 0.01
+}
+
+// UpdateELO computes the ratings after a vote, scaled by how well the voter
+// heard both anthems: the weight is ListenWeight(winner) × ListenWeight(loser).
+// Ratings are rounded as elo.js rounds them, so both engines agree.
+func UpdateELO(winner, loser int, listenWinnerMS, listenLoserMS int64) EloResult {
+	weight := ListenWeight(listenWinnerMS) * ListenWeight(listenLoserMS)
+	newWinner, newLoser := applyELO(K, winner, loser, weight)
+	return EloResult{Winner: newWinner, Loser: newLoser, VoteWeight: jsRound(weight*100) / 100}
+}
+
+// applyELO returns both ratings after a vote of the given weight with factor k
+func applyELO(k float64, winner, loser int, weight float64) (int, int) {
+	eW := ExpectedScore(float64(winner), float64(loser))
+	eL := ExpectedScore(float64(loser), float64(winner))
+	return int(jsRound(float64(winner) + k*weight*(1-eW))),
+		int(jsRound(float64(loser) + k*weight*(0-eL)))
+}
+
+// jsRound rounds like JavaScript's Math.round: halves towards +∞
+func jsRound(x float64) float64 {
+	return math.Floor(x + 0.5)
+}
@@ -0,0 +1,440 @@
+package game
+
+import (
+	crand "crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+// Matchmaking constants, the same as sam/game/functions/matchup. The
+// Lambdas are canonical: the engine follows them, counting every vote of a
+// session in vote_count as the vote Lambda does and picking wildcards from it
+// as the matchup Lambda does.
+const (
+	MatchupWindow = 200 // ELO distance of a regular opponent
+	WildcardEvery = 10  // every 10th matchup of a session pairs any two countries
+	// MaxVotesPerDay is the default daily vote cap of a session
+	MaxVotesPerDay = 100
+)
+
+// Errors returned by the engine, matching the API's 403/400/429 answers
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrMatchupMismatch    = errors.New("matchup_id does not match the session's current matchup")
+	ErrInvalidPair        = errors.New("winner and loser do not match the matchup countries")
+	ErrRateLimited        = errors.New("daily vote limit reached")
+	ErrNotEnoughCountries = errors.New("not enough countries with audio in game_rankings")
+)
+
+// Ranking is a row of game_rankings
+type Ranking struct {
+	CountryID  string `json:"country_id"` // ISO alpha-3
+	Name       string `json:"name"`
+	FlagURL    string `json:"flag_url,omitempty"`
+	AnthemName string `json:"anthem_name,omitempty"`
+	AudioURL   string `json:"audio_url,omitempty"`
+	EloScore   int    `json:"elo_score"`
+	Wins       int    `json:"wins"`
+	Losses     int    `json:"losses"`
+}
+
+// Engine plays the anthem game against the game_* tables of the local
+// database, the same way the Lambda functions play it against DynamoDB.
+// With a fixed seed its matchups are deterministic.
+type Engine struct {
+	db  *sql.DB
+	rng *rand.Rand
+
+	Now            func() time.Time
+	MaxVotesPerDay int
+}
+
+// NewEngine returns an engine over db whose random choices follow seed
+func NewEngine(db *sql.DB, seed int64) *Engine {
+	return &Engine{
+		db:             db,
+		rng:            rand.New(rand.NewSource(seed)),
+		Now:            time.Now,
+		MaxVotesPerDay: MaxVotesPerDay,
+	}
+}
+
+// newID returns a random UUID (version 4). IDs do not come from the seeded
+// source, so a fixed seed repeats matchups but never session or vote IDs.
+func (e *Engine) newID() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Session is a row of game_sessions
+type Session struct {
+	ID          string
+	UserCountry string
+	CreatedAt   time.Time
+	VoteCount   int
+}
+
+// CreateSession starts an anonymous session, as POST /session does
+func (e *Engine) CreateSession(userCountry string) (Session, error) {
+	s := Session{ID: e.newID(), UserCountry: userCountry, CreatedAt: e.Now().UTC()}
+	_, err := e.db.Exec(`INSERT INTO game_sessions (session_id, user_country, created_at) VALUES (?, ?, ?)`,
+		s.ID, nullIfEmpty(userCountry), s.CreatedAt.Format(time.RFC3339))
+	return s, err
+}
+
+// MatchupCountry is one side of a matchup
+type MatchupCountry struct {
+	Ranking
+	ListenMS int64 `json:"listen_ms"` // cumulative ms heard this session
+}
+
+// Matchup is the answer of GET /matchup
+type Matchup struct {
+	ID         string         `json:"matchup_id"`
+	IsWildcard bool           `json:"is_wildcard"`
+	CountryA   MatchupCountry `json:"country_a"`
+	CountryB   MatchupCountry `json:"country_b"`
+}
+
+// NextMatchup picks two countries for a session and makes them its current
+// matchup. Country A is random; B is a random country within MatchupWindow
+// of A, or any country on every WildcardEvery-th vote of the session.
+func (e *Engine) NextMatchup(sessionID string) (Matchup, error) {
+	var voteCount int
+	err := e.db.QueryRow(`SELECT vote_count FROM game_sessions WHERE session_id = ?`, sessionID).Scan(&voteCount)
+	if err == sql.ErrNoRows {
+		return Matchup{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return Matchup{}, err
+	}
+
+	if err := e.addMissingRankings(); err != nil {
+		return Matchup{}, err
+	}
+	rankings, err := Rankings(e.db)
+	if err != nil {
+		return Matchup{}, err
+	}
+	var playable []Ranking
+	for _, r := range rankings {
+		if r.AudioURL != "" {
+			playable = append(playable, r)
+		}
+	}
+	a, b, wildcard, err := PickMatchup(playable, voteCount, e.rng)
+	if err != nil {
+		return Matchup{}, err
+	}
+
+	m := Matchup{ID: e.newID(), IsWildcard: wildcard, CountryA: MatchupCountry{Ranking: a}, CountryB: MatchupCountry{Ranking: b}}
+	if m.CountryA.ListenMS, err = e.listenMS(sessionID, a.CountryID); err != nil {
+		return Matchup{}, err
+	}
+	if m.CountryB.ListenMS, err = e.listenMS(sessionID, b.CountryID); err != nil {
+		return Matchup{}, err
+	}
+	_, err = e.db.Exec(`UPDATE game_sessions SET matchup_id = ?, matchup_a = ?, matchup_b = ? WHERE session_id = ?`,
+		m.ID, a.CountryID, b.CountryID, sessionID)
+	return m, err
+}
+
+// PickMatchup chooses two countries the way the matchup Lambda does
+func PickMatchup(countries []Ranking, voteCount int, rng *rand.Rand) (a, b Ranking, wildcard bool, err error) {
+	if len(countries) < 2 {
+		return a, b, false, ErrNotEnoughCountries
+	}
+	wildcard = voteCount > 0 && voteCount%WildcardEvery == 0
+
+	idxA := rng.Intn(len(countries))
+	a = countries[idxA]
+	if wildcard {
+		idxB := idxA
+		for idxB == idxA {
+			idxB = rng.Intn(len(countries))
+		}
+		return a, countries[idxB], true, nil
+	}
+
+	var candidates, others []Ranking
+	for i, c := range countries {
+		if i == idxA {
+			continue
+		}
+		others = append(others, c)
+		if abs(c.EloScore-a.EloScore) <= MatchupWindow {
+			candidates = append(candidates, c)
+		}
+	}
+	pool := candidates
+	if len(pool) < 2 {
+		pool = others
+	}
+	return a, pool[rng.Intn(len(pool))], false, nil
+}
+
+// VoteRequest is the body of POST /vote
+type VoteRequest struct {
+	SessionID string `json:"session_id"`
+	MatchupID string `json:"matchup_id"`
+	WinnerID  string `json:"winner_id"`
+	LoserID   string `json:"loser_id"`
+	ListenAMS int64  `json:"listen_a_ms"` // time heard this round of country A
+	ListenBMS int64  `json:"listen_b_ms"`
+}
+
+// RatingChange is one side of a VoteResult
+type RatingChange struct {
+	CountryID string `json:"country_id"`
+	OldELO    int    `json:"old_elo"`
+	NewELO    int    `json:"new_elo"`
+}
+
+// VoteResult is the answer of POST /vote
+type VoteResult struct {
+	VoteID     string       `json:"vote_id"`
+	VoteWeight float64      `json:"vote_weight"`
+	Winner     RatingChange `json:"winner"`
+	Loser      RatingChange `json:"loser"`
+}
+
+// Vote records a vote on the session's current matchup and updates both
+// ratings, weighted by the session's cumulative listen time of each anthem
+func (e *Engine) Vote(req VoteRequest) (VoteResult, error) {
+	switch {
+	case req.SessionID == "", req.MatchupID == "", req.WinnerID == "", req.LoserID == "":
+		return VoteResult{}, fmt.Errorf("session, matchup, winner and loser are required")
+	case req.WinnerID == req.LoserID:
+		return VoteResult{}, fmt.Errorf("winner and loser must be different")
+	case req.ListenAMS < 0 || req.ListenBMS < 0:
+		return VoteResult{}, fmt.Errorf("listen times must not be negative")
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return VoteResult{}, err
+	}
+	defer tx.Rollback()
+
+	var matchupID, countryA, countryB, voteDate sql.NullString
+	var voteCountToday int
+	err = tx.QueryRow(`
+		SELECT matchup_id, matchup_a, matchup_b, vote_date, vote_count_today
+		FROM game_sessions WHERE session_id = ?
+	`, req.SessionID).Scan(&matchupID, &countryA, &countryB, &voteDate, &voteCountToday)
+	if err == sql.ErrNoRows {
+		return VoteResult{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return VoteResult{}, err
+	}
+	if !matchupID.Valid || matchupID.String != req.MatchupID {
+		return VoteResult{}, ErrMatchupMismatch
+	}
+	a, b := countryA.String, countryB.String
+	if !(req.WinnerID == a && req.LoserID == b) && !(req.WinnerID == b && req.LoserID == a) {
+		return VoteResult{}, ErrInvalidPair
+	}
+
+	now := e.Now().UTC()
+	today := now.Format("2006-01-02")
+	if voteDate.String != today {
+		voteCountToday = 0
+	}
+	if voteCountToday >= e.MaxVotesPerDay {
+		return VoteResult{}, ErrRateLimited
+	}
+
+	listenWinner, listenLoser := req.ListenAMS, req.ListenBMS
+	if req.WinnerID != a {
+		listenWinner, listenLoser = req.ListenBMS, req.ListenAMS
+	}
+	var priorWinner, priorLoser int64
+	for _, p := range []struct {
+		country string
+		dst     *int64
+	}{{req.WinnerID, &priorWinner}, {req.LoserID, &priorLoser}} {
+		if err := tx.QueryRow(`SELECT COALESCE(SUM(total_listen_ms), 0) FROM game_listens WHERE session_id = ? AND country_id = ?`,
+			req.SessionID, p.country).Scan(p.dst); err != nil {
+			return VoteResult{}, err
+		}
+	}
+	totalWinner, totalLoser := priorWinner+listenWinner, priorLoser+listenLoser
+
+	winnerELO, err := ratingTx(tx, req.WinnerID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	loserELO, err := ratingTx(tx, req.LoserID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	elo := UpdateELO(winnerELO, loserELO, totalWinner, totalLoser)
+
+	res := VoteResult{
+		VoteID:     e.newID(),
+		VoteWeight: elo.VoteWeight,
+		Winner:     RatingChange{CountryID: req.WinnerID, OldELO: winnerELO, NewELO: elo.Winner},
+		Loser:      RatingChange{CountryID: req.LoserID, OldELO: loserELO, NewELO: elo.Loser},
+	}
+	votedAt := now.Format(time.RFC3339Nano)
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO game_votes (vote_id, session_id, matchup_id, country_a, winner_id, loser_id,
+			listen_a_ms, listen_b_ms, listen_winner_ms, listen_loser_ms, vote_weight,
+			winner_elo_before, winner_elo_after, loser_elo_before, loser_elo_after, voted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			[]interface{}{res.VoteID, req.SessionID, req.MatchupID, a, req.WinnerID, req.LoserID,
+				req.ListenAMS, req.ListenBMS, totalWinner, totalLoser, elo.VoteWeight,
+				winnerELO, elo.Winner, loserELO, elo.Loser, votedAt}},
+		{`UPDATE game_rankings SET elo_score = ?, wins = wins + 1, updated_at = ? WHERE country_id = ?`,
+			[]interface{}{elo.Winner, votedAt, req.WinnerID}},
+		{`UPDATE game_rankings SET elo_score = ?, losses = losses + 1, updated_at = ? WHERE country_id = ?`,
+			[]interface{}{elo.Loser, votedAt, req.LoserID}},
+		{`UPDATE game_sessions SET vote_count = vote_count + 1, vote_count_today = ?, vote_date = ?,
+			matchup_id = NULL, matchup_a = NULL, matchup_b = NULL WHERE session_id = ?`,
+			[]interface{}{voteCountToday + 1, today, req.SessionID}},
+		{`INSERT INTO game_listens (session_id, country_id, total_listen_ms) VALUES (?, ?, ?)
+			ON CONFLICT(session_id, country_id) DO UPDATE SET total_listen_ms = excluded.total_listen_ms`,
+			[]interface{}{req.SessionID, req.WinnerID, totalWinner}},
+		{`INSERT INTO game_listens (session_id, country_id, total_listen_ms) VALUES (?, ?, ?)
+			ON CONFLICT(session_id, country_id) DO UPDATE SET total_listen_ms = excluded.total_listen_ms`,
+			[]interface{}{req.SessionID, req.LoserID, totalLoser}},
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s.query, s.args...); err != nil {
+			return VoteResult{}, fmt.Errorf("failed to record vote: %w", err)
+		}
+	}
+	return res, tx.Commit()
+}
+
+// LeaderboardEntry is a row of GET /leaderboard
+type LeaderboardEntry struct {
+	Rank int `json:"rank"`
+	Ranking
+	TotalVotes int  `json:"total_votes"`
+	WinRate    *int `json:"win_rate"` // percent, nil before the first vote
+}
+
+// Leaderboard returns the top limit countries by rating, ties by name, and
+// the number of ranked countries. limit <= 0 returns all of them.
+func Leaderboard(db *sql.DB, limit int) ([]LeaderboardEntry, int, error) {
+	rankings, err := Rankings(db)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.SliceStable(rankings, func(i, j int) bool {
+		if rankings[i].EloScore != rankings[j].EloScore {
+			return rankings[i].EloScore > rankings[j].EloScore
+		}
+		return rankings[i].Name < rankings[j].Name
+	})
+
+	n := len(rankings)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	entries := make([]LeaderboardEntry, n)
+	for i, r := range rankings[:n] {
+		entries[i] = LeaderboardEntry{Rank: i + 1, Ranking: r, TotalVotes: r.Wins + r.Losses}
+		if total := r.Wins + r.Losses; total > 0 {
+			rate := int(jsRound(float64(r.Wins) / float64(total) * 100))
+			entries[i].WinRate = &rate
+		}
+	}
+	return entries, len(rankings), nil
+}
+
+// Rankings returns every row of game_rankings, ordered by country ID
+func Rankings(db *sql.DB) ([]Ranking, error) {
+	rows, err := db.Query(`
+		SELECT country_id, name, COALESCE(flag_url,''), COALESCE(anthem_name,''), COALESCE(audio_url,''),
+			elo_score, wins, losses
+		FROM game_rankings ORDER BY country_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rankings []Ranking
+	for rows.Next() {
+		var r Ranking
+		if err := rows.Scan(&r.CountryID, &r.Name, &r.FlagURL, &r.AnthemName, &r.AudioURL,
+			&r.EloScore, &r.Wins, &r.Losses); err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, r)
+	}
+	return rankings, rows.Err()
+}
+
+// addMissingRankings adds every country with a playable recording that is
+// not yet in game_rankings, at InitialELO. Existing rows are left alone;
+// `game seed` refreshes them.
+func (e *Engine) addMissingRankings() error {
+	countries, err := format.Countries(e.db)
+	if err != nil {
+		return fmt.Errorf("failed to query countries: %w", err)
+	}
+	rows, _ := SeedRankings(countries)
+	for _, r := range rows {
+		if _, err := e.db.Exec(`
+			INSERT OR IGNORE INTO game_rankings (country_id, name, flag_url, anthem_name, audio_url, elo_score)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, r.CountryID, r.Name, nullIfEmpty(r.FlagURL), nullIfEmpty(r.AnthemName), r.AudioURL, r.EloScore); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Engine) listenMS(sessionID, countryID string) (int64, error) {
+	var ms int64
+	err := e.db.QueryRow(`SELECT COALESCE(SUM(total_listen_ms), 0) FROM game_listens WHERE session_id = ? AND country_id = ?`,
+		sessionID, countryID).Scan(&ms)
+	return ms, err
+}
+
+// ratingTx returns a country's rating, InitialELO if it has none
+func ratingTx(tx *sql.Tx, countryID string) (int, error) {
+	var elo int
+	err := tx.QueryRow(`SELECT elo_score FROM game_rankings WHERE country_id = ?`, countryID).Scan(&elo)
+	if err == sql.ErrNoRows {
+		return InitialELO, nil
+	}
+	return elo, err
+}
+
+func anthemName(c *format.CountryRecord) string {
+	if c.Anthem == nil {
+		return ""
+	}
+	return c.Anthem.Name
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
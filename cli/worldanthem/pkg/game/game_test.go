@@ -0,0 +1,156 @@
+package game
+
+import (
+	"database/sql"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/anthemworld/cli/internal/testdb"
+)
+
+func TestUpdateELOMatchesElojs(t *testing.T) {
+	// Expected values computed with sam/game/functions/shared/elo.js
+	tests := []struct {
+		winner, loser  int
+		listenW, listL int64
+		want           EloResult
+	}{
+		{1500, 1500, FullListenMS, FullListenMS, EloResult{1516, 1484, 1}},
+		{1600, 1400, 5000, 10000, EloResult{1604, 1396, 0.5}},
+		{1400, 1600, 20000, 3000, EloResult{1407, 1593, 0.3}},
+		{1500, 1500, 0, 10000, EloResult{1500, 1500, 0}},
+		{1713, 1288, 9999, 10000, EloResult{1716, 1285, 1}},
+	}
+	for _, tt := range tests {
+		got := UpdateELO(tt.winner, tt.loser, tt.listenW, tt.listL)
+		if got != tt.want {
+			t.Errorf("UpdateELO(%d, %d, %d, %d): expected %+v, got %+v",
+				tt.winner, tt.loser, tt.listenW, tt.listL, tt.want, got)
+		}
+	}
+}
+
+func TestPickMatchup(t *testing.T) {
+	countries := []Ranking{
+		{CountryID: "AAA", EloScore: 1500},
+		{CountryID: "BBB", EloScore: 1550},
+		{CountryID: "CCC", EloScore: 1600},
+		{CountryID: "DDD", EloScore: 2200},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		a, b, wildcard, err := PickMatchup(countries, 3, rng)
+		if err != nil {
+			t.Fatalf("PickMatchup failed: %v", err)
+		}
+		if wildcard || a.CountryID == b.CountryID {
+			t.Fatalf("Expected a regular pairing of two countries, got %s vs %s", a.CountryID, b.CountryID)
+		}
+		// DDD has no opponent within the window, so it may meet anyone; the
+		// others have two candidates and must stay within 200 points
+		if a.CountryID != "DDD" && abs(a.EloScore-b.EloScore) > MatchupWindow {
+			t.Errorf("Expected opponents within %d, got %s %d vs %s %d",
+				MatchupWindow, a.CountryID, a.EloScore, b.CountryID, b.EloScore)
+		}
+	}
+	if _, _, wildcard, _ := PickMatchup(countries, 20, rng); !wildcard {
+		t.Error("Expected the 20th vote to be a wildcard")
+	}
+	if _, _, _, err := PickMatchup(countries[:1], 0, rng); err != ErrNotEnoughCountries {
+		t.Errorf("Expected ErrNotEnoughCountries, got %v", err)
+	}
+}
+
+// setupTestDB opens a fully migrated database under a temporary HOME with
+// three countries that have a recording and one that has none
+func setupTestDB(t *testing.T) *sql.DB {
+	return testdb.Open(t, `
+		INSERT INTO countries (id, name, common_name, iso_alpha3) VALUES
+			('fra', 'French Republic', 'France', 'FRA'),
+			('deu', 'Federal Republic of Germany', 'Germany', 'DEU'),
+			('ita', 'Italian Republic', 'Italy', 'ITA'),
+			('ata', 'Antarctica', 'Antarctica', 'ATA');
+		INSERT INTO anthems (country_id, name) VALUES ('fra', 'La Marseillaise'), ('deu', 'Das Lied der Deutschen'), ('ita', 'Il Canto degli Italiani');
+		INSERT INTO audio_recordings (id, country_id, title, url, source) VALUES
+			('r-fra', 'fra', 'fra.ogg', 'https://example.org/fra.ogg', 'test'),
+			('r-deu', 'deu', 'deu.ogg', 'https://example.org/deu.ogg', 'test'),
+			('r-ita', 'ita', 'ita.ogg', 'https://example.org/ita.ogg', 'test');
+	`)
+}
+
+func TestEngineVote(t *testing.T) {
+	database := setupTestDB(t)
+	e := NewEngine(database, 42)
+	e.Now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+	e.MaxVotesPerDay = 2
+
+	s, err := e.CreateSession("FR")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if _, err := e.NextMatchup("missing"); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	m, err := e.NextMatchup(s.ID)
+	if err != nil {
+		t.Fatalf("NextMatchup failed: %v", err)
+	}
+	if m.CountryA.CountryID == "ATA" || m.CountryB.CountryID == "ATA" {
+		t.Error("Expected countries without audio to be left out")
+	}
+
+	a, b := m.CountryA.CountryID, m.CountryB.CountryID
+	if _, err := e.Vote(VoteRequest{SessionID: s.ID, MatchupID: "other", WinnerID: a, LoserID: b}); err != ErrMatchupMismatch {
+		t.Errorf("Expected ErrMatchupMismatch, got %v", err)
+	}
+
+	// Half of A heard now and the rest in the next round
+	res, err := e.Vote(VoteRequest{SessionID: s.ID, MatchupID: m.ID, WinnerID: b, LoserID: a, ListenAMS: 5000, ListenBMS: 10000})
+	if err != nil {
+		t.Fatalf("Vote failed: %v", err)
+	}
+	if res.VoteWeight != 0.5 || res.Winner.NewELO != 1508 || res.Loser.NewELO != 1492 {
+		t.Errorf("Expected weight 0.5 and 1508/1492, got %+v", res)
+	}
+
+	m2, err := e.NextMatchup(s.ID)
+	if err != nil {
+		t.Fatalf("NextMatchup failed: %v", err)
+	}
+	for _, c := range []MatchupCountry{m2.CountryA, m2.CountryB} {
+		want := map[string]int64{a: 5000, b: 10000}[c.CountryID]
+		if c.ListenMS != want {
+			t.Errorf("Expected %s heard for %d ms, got %d", c.CountryID, want, c.ListenMS)
+		}
+	}
+	if _, err := e.Vote(VoteRequest{SessionID: s.ID, MatchupID: m2.ID, WinnerID: m2.CountryA.CountryID,
+		LoserID: m2.CountryB.CountryID, ListenAMS: 10000, ListenBMS: 10000}); err != nil {
+		t.Fatalf("Vote failed: %v", err)
+	}
+
+	m3, _ := e.NextMatchup(s.ID)
+	if _, err := e.Vote(VoteRequest{SessionID: s.ID, MatchupID: m3.ID, WinnerID: m3.CountryA.CountryID,
+		LoserID: m3.CountryB.CountryID}); err != ErrRateLimited {
+		t.Errorf("Expected ErrRateLimited on the third vote of the day, got %v", err)
+	}
+
+	entries, total, err := Leaderboard(database, 0)
+	if err != nil {
+		t.Fatalf("Leaderboard failed: %v", err)
+	}
+	if total != 3 || len(entries) != 3 || entries[0].Rank != 1 {
+		t.Fatalf("Expected three ranked countries, got %d %+v", total, entries)
+	}
+	votes := 0
+	for i, entry := range entries {
+		votes += entry.TotalVotes
+		if i > 0 && entry.EloScore > entries[i-1].EloScore {
+			t.Errorf("Expected leaderboard sorted by rating, got %+v", entries)
+		}
+	}
+	if votes != 4 {
+		t.Errorf("Expected 4 wins and losses in total, got %d", votes)
+	}
+}
@@ -0,0 +1,328 @@
+package game
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GameTables are the items of the four game tables
+type GameTables struct {
+	Rankings []Item
+	Votes    []Item
+	Sessions []Item
+	Listens  []Item
+}
+
+// ScanTables reads every game table of a stage from DynamoDB
+func ScanTables(d *DynamoDB, stage string) (GameTables, error) {
+	var t GameTables
+	for _, table := range []struct {
+		base  string
+		items *[]Item
+	}{
+		{RankingsTable, &t.Rankings}, {VotesTable, &t.Votes}, {SessionsTable, &t.Sessions}, {ListenTable, &t.Listens},
+	} {
+		name := TableName(table.base, stage)
+		if err := d.Scan(name, "", func(item Item) error {
+			*table.items = append(*table.items, item)
+			return nil
+		}); err != nil {
+			return t, fmt.Errorf("failed to scan %s: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+// ReadExportDir reads a JSON export of the game tables: files in dir named
+// after a table (anthem-votes-prod.json, anthem-rankings.jsonl, …), holding
+// `aws dynamodb scan` output, DynamoDB export lines ({"Item": …}), or items
+// one per line in typed or plain JSON. Tables without a file stay empty.
+func ReadExportDir(dir string) (GameTables, error) {
+	var t GameTables
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return t, err
+	}
+	for _, table := range []struct {
+		base  string
+		items *[]Item
+	}{
+		{RankingsTable, &t.Rankings}, {VotesTable, &t.Votes}, {SessionsTable, &t.Sessions}, {ListenTable, &t.Listens},
+	} {
+		for _, e := range entries {
+			name := e.Name()
+			ext := filepath.Ext(name)
+			if e.IsDir() || !strings.HasPrefix(name, table.base) || (ext != ".json" && ext != ".jsonl" && ext != ".ndjson") {
+				continue
+			}
+			f, err := os.Open(filepath.Join(dir, name))
+			if err != nil {
+				return t, err
+			}
+			items, err := ParseItems(f)
+			f.Close()
+			if err != nil {
+				return t, fmt.Errorf("%s: %w", name, err)
+			}
+			*table.items = append(*table.items, items...)
+		}
+	}
+	return t, nil
+}
+
+// ParseItems reads DynamoDB items in any of the layouts ReadExportDir takes
+func ParseItems(r io.Reader) ([]Item, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var raws []map[string]json.RawMessage
+	var scan struct {
+		Items []map[string]json.RawMessage `json:"Items"`
+	}
+	switch {
+	case data[0] == '[':
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+	case json.Unmarshal(data, &scan) == nil && scan.Items != nil:
+		raws = scan.Items
+	default:
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal(line, &raw); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if item, ok := raw["Item"]; ok && len(raw) == 1 {
+				raw = nil
+				if err := json.Unmarshal(item, &raw); err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+			}
+			raws = append(raws, raw)
+		}
+	}
+
+	items := make([]Item, len(raws))
+	for i, raw := range raws {
+		items[i] = itemFromJSON(raw)
+	}
+	return items, nil
+}
+
+// itemFromJSON converts a typed ({"S": "FRA"}) or plain ("FRA") JSON item.
+// Maps, lists and sets are left out; the game tables do not need them.
+func itemFromJSON(raw map[string]json.RawMessage) Item {
+	item := Item{}
+	for name, value := range raw {
+		var typed map[string]json.RawMessage
+		if json.Unmarshal(value, &typed) == nil {
+			var v AttributeValue
+			if len(typed) == 1 && json.Unmarshal(value, &v) == nil && (v.S != nil || v.N != nil || v.BOOL != nil || v.NULL != nil) {
+				item[name] = v
+			}
+			continue
+		}
+		var plain interface{}
+		dec := json.NewDecoder(bytes.NewReader(value))
+		dec.UseNumber()
+		if dec.Decode(&plain) != nil {
+			continue
+		}
+		switch p := plain.(type) {
+		case string:
+			item[name] = attrS(p)
+		case json.Number:
+			s := p.String()
+			item[name] = AttributeValue{N: &s}
+		case bool:
+			item[name] = AttributeValue{BOOL: &p}
+		case nil:
+			null := true
+			item[name] = AttributeValue{NULL: &null}
+		}
+	}
+	return item
+}
+
+// ImportResult counts the rows an import wrote
+type ImportResult struct {
+	Rankings int `json:"rankings"`
+	Votes    int `json:"votes"`
+	Sessions int `json:"sessions"`
+	Listens  int `json:"listens"`
+	// Unweighted are votes imported without listen totals and weight, which
+	// the vote Lambda only stores since it records country_a
+	Unweighted int `json:"unweighted"`
+	// UnknownCountries are country IDs of rankings and votes that match no
+	// ISO alpha-3 code in countries
+	UnknownCountries []string `json:"unknown_countries,omitempty"`
+}
+
+// Import writes game table items into the game_* tables, replacing rows
+// with the same key; with replace the tables are emptied first. Votes keep
+// the cumulative listen times and weight the vote Lambda stored with them;
+// older votes lack them and are imported with NULL ones (see Unweighted).
+// Sessions get their number of votes.
+func Import(db *sql.DB, t GameTables, replace bool) (ImportResult, error) {
+	var res ImportResult
+	tx, err := db.Begin()
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		for _, table := range []string{"game_votes", "game_sessions", "game_listens", "game_rankings"} {
+			if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	for _, it := range t.Rankings {
+		if it.String("country_id") == "" {
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO game_rankings (country_id, name, flag_url, anthem_name, audio_url, elo_score, wins, losses, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(country_id) DO UPDATE SET name = excluded.name, flag_url = excluded.flag_url,
+				anthem_name = excluded.anthem_name, audio_url = excluded.audio_url, elo_score = excluded.elo_score,
+				wins = excluded.wins, losses = excluded.losses, updated_at = excluded.updated_at
+		`, it.String("country_id"), it.String("name"), nullIfEmpty(it.String("flag_url")),
+			nullIfEmpty(it.String("anthem_name")), nullIfEmpty(it.String("audio_url")),
+			itemIntOr(it, "elo_score", InitialELO), it.Int("wins"), it.Int("losses"),
+			nullIfEmpty(it.String("updated_at"))); err != nil {
+			return res, fmt.Errorf("failed to import ranking %s: %w", it.String("country_id"), err)
+		}
+		res.Rankings++
+	}
+
+	for _, it := range t.Sessions {
+		if it.String("session_id") == "" {
+			continue
+		}
+		createdAt := it.String("created_date")
+		if createdAt == "" {
+			createdAt = it.String("created_at")
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO game_sessions (session_id, user_country, created_at, ip_hash, vote_date, vote_count_today)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(session_id) DO UPDATE SET user_country = excluded.user_country, created_at = excluded.created_at,
+				ip_hash = excluded.ip_hash, vote_date = excluded.vote_date, vote_count_today = excluded.vote_count_today
+		`, it.String("session_id"), nullIfEmpty(strings.ToUpper(it.String("user_country"))), createdAt,
+			nullIfEmpty(it.String("ip_hash")), nullIfEmpty(it.String("vote_date")), it.Int("vote_count_today")); err != nil {
+			return res, fmt.Errorf("failed to import session %s: %w", it.String("session_id"), err)
+		}
+		res.Sessions++
+	}
+
+	for _, it := range t.Listens {
+		session, country, ok := cutLast(it.String("pk"), "#")
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO game_listens (session_id, country_id, total_listen_ms) VALUES (?, ?, ?)`,
+			session, country, it.Int("total_listen_ms")); err != nil {
+			return res, err
+		}
+		res.Listens++
+	}
+
+	for _, it := range t.Votes {
+		id := it.String("vote_id")
+		if id == "" || it.String("winner_id") == "" || it.String("loser_id") == "" {
+			continue
+		}
+		votedAt, err := time.Parse(time.RFC3339Nano, it.String("voted_at"))
+		if err != nil {
+			return res, fmt.Errorf("vote %s: %w", id, err)
+		}
+		// Only the totals the Lambda stored are trusted: which listen belongs
+		// to the winner, and what the session had heard before, cannot be
+		// rebuilt from older items
+		var listenWinner, listenLoser, weight interface{}
+		_, hasWinner := it["listen_winner_ms"]
+		_, hasLoser := it["listen_loser_ms"]
+		if w, ok := it["vote_weight"]; ok && w.N != nil && hasWinner && hasLoser {
+			if weight, err = strconv.ParseFloat(*w.N, 64); err != nil {
+				return res, fmt.Errorf("vote %s: %w", id, err)
+			}
+			listenWinner, listenLoser = it.Int("listen_winner_ms"), it.Int("listen_loser_ms")
+		} else {
+			res.Unweighted++
+		}
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO game_votes (vote_id, session_id, matchup_id, country_a, winner_id, loser_id,
+				listen_a_ms, listen_b_ms, listen_winner_ms, listen_loser_ms, vote_weight, voted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, id, it.String("session_id"), it.String("matchup_id"), nullIfEmpty(it.String("country_a")),
+			it.String("winner_id"), it.String("loser_id"), it.Int("listen_a_ms"), it.Int("listen_b_ms"),
+			listenWinner, listenLoser, weight, votedAt.UTC().Format(time.RFC3339Nano)); err != nil {
+			return res, fmt.Errorf("failed to import vote %s: %w", id, err)
+		}
+		res.Votes++
+	}
+
+	if _, err := tx.Exec(`UPDATE game_sessions SET vote_count =
+		(SELECT COUNT(*) FROM game_votes v WHERE v.session_id = game_sessions.session_id)`); err != nil {
+		return res, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id FROM (
+			SELECT country_id AS id FROM game_rankings
+			UNION SELECT winner_id FROM game_votes
+			UNION SELECT loser_id FROM game_votes
+		) WHERE id NOT IN (SELECT iso_alpha3 FROM countries WHERE iso_alpha3 IS NOT NULL)
+	`)
+	if err != nil {
+		return res, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return res, err
+		}
+		res.UnknownCountries = append(res.UnknownCountries, id)
+	}
+	rows.Close()
+	sort.Strings(res.UnknownCountries)
+	return res, tx.Commit()
+}
+
+func itemIntOr(it Item, name string, def int64) int64 {
+	if _, ok := it[name]; !ok {
+		return def
+	}
+	return it.Int(name)
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
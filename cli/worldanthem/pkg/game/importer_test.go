@@ -0,0 +1,148 @@
+package game
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeExport(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseItems(t *testing.T) {
+	for name, input := range map[string]string{
+		"scan":   `{"Items": [{"country_id": {"S": "FRA"}, "elo_score": {"N": "1516"}}], "Count": 1}`,
+		"export": `{"Item": {"country_id": {"S": "FRA"}, "elo_score": {"N": "1516"}}}`,
+		"plain":  `{"country_id": "FRA", "elo_score": 1516}` + "\n",
+		"array":  `[{"country_id": "FRA", "elo_score": {"N": "1516"}}]`,
+	} {
+		items, err := ParseItems(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("%s: ParseItems failed: %v", name, err)
+			continue
+		}
+		if len(items) != 1 || items[0].String("country_id") != "FRA" || items[0].Int("elo_score") != 1516 {
+			t.Errorf("%s: Expected FRA at 1516, got %+v", name, items)
+		}
+	}
+	if _, err := ParseItems(strings.NewReader("{\"a\": 1}\nnot json")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	database := setupTestDB(t)
+	_, err := database.Exec(`
+		UPDATE countries SET iso_alpha2 = UPPER(SUBSTR(id, 1, 2)), region = 'Europe';
+		UPDATE countries SET iso_alpha2 = 'DE' WHERE id = 'deu';
+		UPDATE countries SET region = 'Other' WHERE id = 'ita';
+	`)
+	if err != nil {
+		t.Fatalf("Failed to update countries: %v", err)
+	}
+
+	dir := writeExport(t, map[string]string{
+		"anthem-rankings-prod.json": `{"Items": [
+			{"country_id": {"S": "FRA"}, "name": {"S": "France"}, "elo_score": {"N": "1516"}, "wins": {"N": "2"}, "losses": {"N": "2"}},
+			{"country_id": {"S": "DEU"}, "name": {"S": "Germany"}, "elo_score": {"N": "1500"}, "wins": {"N": "1"}, "losses": {"N": "1"}},
+			{"country_id": {"S": "ITA"}, "name": {"S": "Italy"}, "elo_score": {"N": "1484"}, "wins": {"N": "1"}, "losses": {"N": "1"}},
+			{"country_id": {"S": "XKX"}, "name": {"S": "Kosovo"}}
+		]}`,
+		"anthem-sessions-prod.jsonl": `{"Item": {"session_id": {"S": "s1"}, "user_country": {"S": "fr"}, "created_date": {"S": "2026-10-01T10:00:00Z"}, "ip_hash": {"S": "h1"}}}
+{"Item": {"session_id": {"S": "s2"}, "user_country": {"S": "DE"}, "created_date": {"S": "2026-10-01T11:00:00Z"}}}
+`,
+		"anthem-votes-prod.jsonl": `{"vote_id": "v1", "session_id": "s1", "winner_id": "FRA", "loser_id": "DEU", "listen_a_ms": 10000, "listen_b_ms": 10000, "voted_at": "2026-10-01T10:01:00Z"}
+{"vote_id": "v2", "session_id": "s1", "winner_id": "FRA", "loser_id": "ITA", "listen_a_ms": 10000, "listen_b_ms": 2000, "voted_at": "2026-10-01T10:02:00Z"}
+{"vote_id": "v3", "session_id": "s2", "country_a": "FRA", "winner_id": "DEU", "loser_id": "FRA", "listen_a_ms": 3000, "listen_b_ms": 10000, "listen_winner_ms": 10000, "listen_loser_ms": 3000, "vote_weight": 0.3, "voted_at": "2026-10-01T11:01:00Z"}
+{"vote_id": "v4", "session_id": "s2", "winner_id": "ITA", "loser_id": "FRA", "listen_a_ms": 10000, "listen_b_ms": 0, "voted_at": "2026-10-01T11:02:00Z"}
+`,
+		"anthem-listen-history-prod.json": `[{"pk": "s1#FRA", "total_listen_ms": 20000}]`,
+		"notes.txt":                       "not an export",
+	})
+	tables, err := ReadExportDir(dir)
+	if err != nil {
+		t.Fatalf("ReadExportDir failed: %v", err)
+	}
+	res, err := Import(database, tables, true)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if res.Rankings != 4 || res.Votes != 4 || res.Sessions != 2 || res.Listens != 1 {
+		t.Errorf("Unexpected counts %+v", res)
+	}
+	if !reflect.DeepEqual(res.UnknownCountries, []string{"XKX"}) {
+		t.Errorf("Expected XKX unknown, got %v", res.UnknownCountries)
+	}
+
+	if res.Unweighted != 3 {
+		t.Errorf("Expected 3 votes without listen totals, got %d", res.Unweighted)
+	}
+	var count int
+	database.QueryRow(`SELECT vote_count FROM game_sessions WHERE session_id = 's1'`).Scan(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 votes in s1, got %d", count)
+	}
+	// B won v3: the stored totals are kept as the Lambda computed them
+	var listenWinner, listenLoser sql.NullInt64
+	var weight sql.NullFloat64
+	database.QueryRow(`SELECT listen_winner_ms, listen_loser_ms, vote_weight FROM game_votes WHERE vote_id = 'v3'`).
+		Scan(&listenWinner, &listenLoser, &weight)
+	if listenWinner.Int64 != 10000 || listenLoser.Int64 != 3000 || weight.Float64 != 0.3 {
+		t.Errorf("Expected v3 at 10000/3000ms with weight 0.3, got %v, %v, %v", listenWinner, listenLoser, weight)
+	}
+	// v2 predates the stored totals; listen_a_ms may not be the winner's
+	database.QueryRow(`SELECT listen_winner_ms, vote_weight FROM game_votes WHERE vote_id = 'v2'`).Scan(&listenWinner, &weight)
+	if listenWinner.Valid || weight.Valid {
+		t.Errorf("Expected v2 without listen totals, got %v, %v", listenWinner, weight)
+	}
+	votes, err := LocalVotes(database)
+	if err != nil || len(votes) != 4 || votes[0].VoteID != "v1" {
+		t.Fatalf("Expected imported votes to replay, got %d votes, %v", len(votes), err)
+	}
+	if w := (ReplayOptions{}).Weight(votes[1]); w != 0.2 {
+		t.Errorf("Expected v2 weighed by its round's listens at 0.2, got %v", w)
+	}
+	if w := (ReplayOptions{}).Weight(votes[2]); w != 0.3 {
+		t.Errorf("Expected v3 at its stored weight 0.3, got %v", w)
+	}
+
+	rates, err := WinRates(database, false)
+	if err != nil {
+		t.Fatalf("WinRates failed: %v", err)
+	}
+	want := []WinRate{
+		{Voter: "DE", AnthemRegion: "Europe", Matchups: 3, Wins: 1, WinRate: 1.0 / 3},
+		{Voter: "DE", AnthemRegion: "Other", Matchups: 1, Wins: 1, WinRate: 1},
+		{Voter: "FR", AnthemRegion: "Europe", Matchups: 3, Wins: 2, WinRate: 2.0 / 3},
+		{Voter: "FR", AnthemRegion: "Other", Matchups: 1, Wins: 0, WinRate: 0},
+	}
+	if !reflect.DeepEqual(rates, want) {
+		t.Errorf("Expected %+v, got %+v", want, rates)
+	}
+
+	bias, err := HomeCountryBias(database)
+	if err != nil {
+		t.Fatalf("HomeCountryBias failed: %v", err)
+	}
+	if bias.HomeVotes != 3 || bias.HomeWins != 3 || bias.ExpectedRate != 0 || bias.PlacedVotes != 4 {
+		t.Errorf("Unexpected home bias %+v", bias)
+	}
+	if bias.RegionVotes != 2 || bias.RegionWins != 1 {
+		t.Errorf("Expected own region to win 1 of 2, got %d of %d", bias.RegionWins, bias.RegionVotes)
+	}
+	if len(bias.Countries) != 2 || bias.Countries[0].CountryID != "DEU" || bias.Countries[0].Bias != 1 ||
+		bias.Countries[1].Name != "France" || bias.Countries[1].OtherVotes != 2 {
+		t.Errorf("Unexpected countries %+v", bias.Countries)
+	}
+}
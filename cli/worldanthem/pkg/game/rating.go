@@ -0,0 +1,282 @@
+package game
+
+import (
+	"math"
+	"sort"
+)
+
+// Rater is a rating algorithm votes can be replayed through. Votes arrive
+// in order; weight is the listen weight of the vote in [0, 1].
+type Rater interface {
+	// Rate records that winner was preferred over loser
+	Rate(winner, loser string, weight float64)
+	// WinProbability is the predicted probability that a is preferred over b
+	WinProbability(a, b string) float64
+	// Scores returns the rating of every country seen, higher is better
+	Scores() map[string]float64
+}
+
+// RaterOptions tune the algorithms that have parameters
+type RaterOptions struct {
+	K float64 // ELO factor, K when 0
+}
+
+// Algorithms are the rating algorithms replay can run, by name
+var Algorithms = map[string]func(opts RaterOptions) Rater{
+	"elo":           func(opts RaterOptions) Rater { return NewEloRater(opts.K) },
+	"glicko2":       func(RaterOptions) Rater { return NewGlicko2Rater() },
+	"bradley-terry": func(RaterOptions) Rater { return NewBradleyTerryRater() },
+}
+
+// AlgorithmNames returns the names of Algorithms, sorted
+func AlgorithmNames() []string {
+	names := make([]string, 0, len(Algorithms))
+	for name := range Algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EloRater is the game's own rating: integer ELO from InitialELO, rounded
+// after every vote as the vote Lambda does
+type EloRater struct {
+	k       float64
+	ratings map[string]int
+}
+
+// NewEloRater returns an ELO rater with factor k, K when k is 0
+func NewEloRater(k float64) *EloRater {
+	if k == 0 {
+		k = K
+	}
+	return &EloRater{k: k, ratings: map[string]int{}}
+}
+
+func (r *EloRater) rating(id string) int {
+	if elo, ok := r.ratings[id]; ok {
+		return elo
+	}
+	return InitialELO
+}
+
+func (r *EloRater) Rate(winner, loser string, weight float64) {
+	r.ratings[winner], r.ratings[loser] = applyELO(r.k, r.rating(winner), r.rating(loser), weight)
+}
+
+func (r *EloRater) WinProbability(a, b string) float64 {
+	return ExpectedScore(float64(r.rating(a)), float64(r.rating(b)))
+}
+
+func (r *EloRater) Scores() map[string]float64 {
+	scores := make(map[string]float64, len(r.ratings))
+	for id, elo := range r.ratings {
+		scores[id] = float64(elo)
+	}
+	return scores
+}
+
+// Glicko-2 system constants (Glickman, "Example of the Glicko-2 system")
+const (
+	glickoScale      = 173.7178
+	glickoInitialRD  = 350
+	glickoInitialVol = 0.06
+	glickoTau        = 0.5
+	glickoEpsilon    = 0.000001
+)
+
+type glickoPlayer struct {
+	mu, phi, sigma float64
+}
+
+// Glicko2Rater is Glicko-2 with every vote a rating period of its own for
+// the two countries in it. The listen weight scales the vote's information
+// (its share of v⁻¹ and of the rating step), so a half-heard vote moves
+// ratings and shrinks deviations less. Scores are on the ELO scale.
+type Glicko2Rater struct {
+	players map[string]*glickoPlayer
+}
+
+// NewGlicko2Rater returns a Glicko-2 rater starting every country at 1500 ± 350
+func NewGlicko2Rater() *Glicko2Rater {
+	return &Glicko2Rater{players: map[string]*glickoPlayer{}}
+}
+
+func (r *Glicko2Rater) player(id string) *glickoPlayer {
+	p, ok := r.players[id]
+	if !ok {
+		p = &glickoPlayer{mu: 0, phi: glickoInitialRD / glickoScale, sigma: glickoInitialVol}
+		r.players[id] = p
+	}
+	return p
+}
+
+func (r *Glicko2Rater) Rate(winner, loser string, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	w, l := r.player(winner), r.player(loser)
+	newW := glickoUpdate(*w, *l, 1, weight)
+	newL := glickoUpdate(*l, *w, 0, weight)
+	*w, *l = newW, newL
+}
+
+func (r *Glicko2Rater) WinProbability(a, b string) float64 {
+	pa, pb := r.player(a), r.player(b)
+	return 1 / (1 + math.Exp(-glickoG(math.Hypot(pa.phi, pb.phi))*(pa.mu-pb.mu)))
+}
+
+func (r *Glicko2Rater) Scores() map[string]float64 {
+	scores := make(map[string]float64, len(r.players))
+	for id, p := range r.players {
+		scores[id] = InitialELO + glickoScale*p.mu
+	}
+	return scores
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// glickoUpdate is steps 3 to 8 of Glicko-2 for p after one game with score s
+// against opp, counted with the given weight
+func glickoUpdate(p, opp glickoPlayer, s, weight float64) glickoPlayer {
+	g := glickoG(opp.phi)
+	e := 1 / (1 + math.Exp(-g*(p.mu-opp.mu)))
+	v := 1 / (weight * g * g * e * (1 - e))
+	delta := v * weight * g * (s - e)
+
+	// New volatility by the Illinois algorithm
+	a := math.Log(p.sigma * p.sigma)
+	phi2 := p.phi * p.phi
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		return ex*(delta*delta-phi2-v-ex)/(2*(phi2+v+ex)*(phi2+v+ex)) - (x-a)/(glickoTau*glickoTau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi2+v {
+		B = math.Log(delta*delta - phi2 - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	sigma := math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi2 + sigma*sigma)
+	phi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	return glickoPlayer{
+		mu:    p.mu + phi*phi*weight*g*(s-e),
+		phi:   phi,
+		sigma: sigma,
+	}
+}
+
+// Bradley-Terry fitting limits
+const (
+	btMaxIterations = 1000
+	btTolerance     = 1e-9
+	// btPrior is the weight of one win and one loss every country is given
+	// against an average opponent, so unbeaten countries have a finite strength
+	btPrior = 0.5
+)
+
+// BradleyTerryRater fits a Bradley-Terry model to all votes so far, by
+// weighted maximum likelihood (Hunter's MM algorithm). Unlike ELO the order
+// of votes does not matter. Scores are on the ELO scale: 1500 + 400·log10 of
+// the strength relative to the geometric mean.
+type BradleyTerryRater struct {
+	wins     map[string]float64            // weighted wins per country
+	games    map[string]map[string]float64 // weighted games per pair
+	strength map[string]float64            // nil when votes were added since the last fit
+}
+
+// NewBradleyTerryRater returns an empty Bradley-Terry rater
+func NewBradleyTerryRater() *BradleyTerryRater {
+	return &BradleyTerryRater{wins: map[string]float64{}, games: map[string]map[string]float64{}}
+}
+
+func (r *BradleyTerryRater) Rate(winner, loser string, weight float64) {
+	for _, id := range []string{winner, loser} {
+		if r.games[id] == nil {
+			r.games[id] = map[string]float64{}
+		}
+	}
+	r.wins[winner] += weight
+	r.games[winner][loser] += weight
+	r.games[loser][winner] += weight
+	r.strength = nil
+}
+
+func (r *BradleyTerryRater) fit() map[string]float64 {
+	if r.strength != nil {
+		return r.strength
+	}
+	p := make(map[string]float64, len(r.games))
+	for id := range r.games {
+		p[id] = 1
+	}
+	for iter := 0; iter < btMaxIterations; iter++ {
+		next := make(map[string]float64, len(p))
+		change := 0.0
+		for id, opponents := range r.games {
+			// The prior is a game against a virtual opponent of strength 1
+			denom := 2 * btPrior / (p[id] + 1)
+			for opp, n := range opponents {
+				denom += n / (p[id] + p[opp])
+			}
+			next[id] = (r.wins[id] + btPrior) / denom
+		}
+		// Normalise to a geometric mean of 1
+		logMean := 0.0
+		for _, s := range next {
+			logMean += math.Log(s)
+		}
+		logMean /= float64(len(next))
+		for id, s := range next {
+			next[id] = s / math.Exp(logMean)
+			change = math.Max(change, math.Abs(math.Log(next[id]/p[id])))
+		}
+		p = next
+		if change < btTolerance {
+			break
+		}
+	}
+	r.strength = p
+	return p
+}
+
+func (r *BradleyTerryRater) WinProbability(a, b string) float64 {
+	p := r.fit()
+	pa, pb := p[a], p[b]
+	if pa == 0 {
+		pa = 1
+	}
+	if pb == 0 {
+		pb = 1
+	}
+	return pa / (pa + pb)
+}
+
+func (r *BradleyTerryRater) Scores() map[string]float64 {
+	p := r.fit()
+	scores := make(map[string]float64, len(p))
+	for id, s := range p {
+		scores[id] = InitialELO + 400*math.Log10(s)
+	}
+	return scores
+}
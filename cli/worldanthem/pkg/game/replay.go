@@ -0,0 +1,348 @@
+package game
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoggedVote is one vote of a vote log: an item of the anthem-votes table or
+// a game_votes row. Items written before the vote Lambda stored country_a do
+// not say which country was A or how long each anthem had been heard before
+// the vote; see ReadVoteLog.
+type LoggedVote struct {
+	VoteID         string    `json:"vote_id"`
+	SessionID      string    `json:"session_id"`
+	MatchupID      string    `json:"matchup_id,omitempty"`
+	CountryA       string    `json:"country_a,omitempty"`
+	WinnerID       string    `json:"winner_id"`
+	LoserID        string    `json:"loser_id"`
+	ListenAMS      int64     `json:"listen_a_ms"`
+	ListenBMS      int64     `json:"listen_b_ms"`
+	ListenWinnerMS *int64    `json:"listen_winner_ms,omitempty"` // cumulative for the session
+	ListenLoserMS  *int64    `json:"listen_loser_ms,omitempty"`
+	VotedAt        time.Time `json:"voted_at"`
+}
+
+// ReadVoteLog reads a vote log, one JSON vote per line, and returns it in
+// voting order with the cumulative listen times filled in where they can be
+// (see fillListens)
+func ReadVoteLog(r io.Reader) ([]LoggedVote, error) {
+	var votes []LoggedVote
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var v LoggedVote
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if v.WinnerID == "" || v.LoserID == "" {
+			return nil, fmt.Errorf("line %d: winner_id and loser_id are required", line)
+		}
+		votes = append(votes, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sortVotes(votes)
+	fillListens(votes)
+	return votes, nil
+}
+
+// LocalVotes returns the game_votes table in voting order. Votes imported
+// without listen totals keep nil ones.
+func LocalVotes(db *sql.DB) ([]LoggedVote, error) {
+	rows, err := db.Query(`
+		SELECT vote_id, session_id, matchup_id, COALESCE(country_a,''), winner_id, loser_id,
+			listen_a_ms, listen_b_ms, listen_winner_ms, listen_loser_ms, voted_at
+		FROM game_votes
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var votes []LoggedVote
+	for rows.Next() {
+		var v LoggedVote
+		var listenWinner, listenLoser sql.NullInt64
+		var votedAt string
+		if err := rows.Scan(&v.VoteID, &v.SessionID, &v.MatchupID, &v.CountryA, &v.WinnerID, &v.LoserID,
+			&v.ListenAMS, &v.ListenBMS, &listenWinner, &listenLoser, &votedAt); err != nil {
+			return nil, err
+		}
+		if listenWinner.Valid && listenLoser.Valid {
+			v.ListenWinnerMS, v.ListenLoserMS = &listenWinner.Int64, &listenLoser.Int64
+		}
+		if v.VotedAt, err = time.Parse(time.RFC3339Nano, votedAt); err != nil {
+			return nil, fmt.Errorf("vote %s: %w", v.VoteID, err)
+		}
+		votes = append(votes, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortVotes(votes)
+	return votes, nil
+}
+
+func sortVotes(votes []LoggedVote) {
+	sort.SliceStable(votes, func(i, j int) bool { return votes[i].VotedAt.Before(votes[j].VotedAt) })
+}
+
+// fillListens adds up the listen times of each session for votes that do
+// not carry cumulative ones, as the vote Lambda does. Only votes with
+// country_a can be filled in: without it listen_a_ms may be the loser's.
+// Those keep nil totals, and a session's later votes on the same countries
+// miss what was heard in them.
+func fillListens(votes []LoggedVote) {
+	heard := map[[2]string]int64{}
+	for i := range votes {
+		v := &votes[i]
+		winnerKey, loserKey := [2]string{v.SessionID, v.WinnerID}, [2]string{v.SessionID, v.LoserID}
+		if v.ListenWinnerMS == nil || v.ListenLoserMS == nil {
+			if v.CountryA == "" {
+				v.ListenWinnerMS, v.ListenLoserMS = nil, nil
+				continue
+			}
+			listenWinner, listenLoser := v.ListenAMS, v.ListenBMS
+			if v.CountryA != v.WinnerID {
+				listenWinner, listenLoser = v.ListenBMS, v.ListenAMS
+			}
+			totalWinner, totalLoser := heard[winnerKey]+listenWinner, heard[loserKey]+listenLoser
+			v.ListenWinnerMS, v.ListenLoserMS = &totalWinner, &totalLoser
+		}
+		heard[winnerKey], heard[loserKey] = *v.ListenWinnerMS, *v.ListenLoserMS
+	}
+}
+
+// ReplayOptions configure Replay
+type ReplayOptions struct {
+	RaterOptions
+	FullListenMS int64   // listen time of a full-weight vote, FullListenMS when 0
+	Holdout      float64 // fraction of the latest votes predicted, not trained on
+}
+
+// Weight returns the listen weight of v under the options. Votes without
+// cumulative listens are weighed by the listens of their round alone: the
+// weight is the same whichever of them was the winner's, and at most what
+// the session's earlier listening would have added.
+func (o ReplayOptions) Weight(v LoggedVote) float64 {
+	full := o.FullListenMS
+	if full <= 0 {
+		full = FullListenMS
+	}
+	winner, loser := v.ListenAMS, v.ListenBMS
+	if v.ListenWinnerMS != nil && v.ListenLoserMS != nil {
+		winner, loser = *v.ListenWinnerMS, *v.ListenLoserMS
+	}
+	return listenWeight(winner, full) * listenWeight(loser, full)
+}
+
+// RatedCountry is a country in the final rankings of a replay
+type RatedCountry struct {
+	Rank      int     `json:"rank"`
+	CountryID string  `json:"country_id"`
+	Score     float64 `json:"score"`
+	Votes     int     `json:"votes"`
+}
+
+// Evaluation is how well ratings trained on the head of a vote log predict
+// its tail. Only tail votes with a listen weight above 0 are predicted.
+type Evaluation struct {
+	TrainVotes int     `json:"train_votes"`
+	TestVotes  int     `json:"test_votes"`
+	Accuracy   float64 `json:"accuracy"` // share of winners given > 50%, ties count half
+	LogLoss    float64 `json:"log_loss"` // mean -ln P(winner)
+	Brier      float64 `json:"brier"`    // mean (1 - P(winner))²
+}
+
+// ReplayResult is the outcome of replaying a vote log through one algorithm
+type ReplayResult struct {
+	Algorithm  string         `json:"algorithm"`
+	Rankings   []RatedCountry `json:"rankings"`
+	Evaluation *Evaluation    `json:"evaluation,omitempty"` // nil without a holdout
+}
+
+// Replay rates votes, in order, with the named algorithm. The rankings come
+// from all votes; the evaluation from a separate run on the votes before the
+// holdout.
+func Replay(votes []LoggedVote, algorithm string, opts ReplayOptions) (ReplayResult, error) {
+	newRater, ok := Algorithms[algorithm]
+	if !ok {
+		return ReplayResult{}, fmt.Errorf("unknown algorithm %q (known: %s)", algorithm, strings.Join(AlgorithmNames(), ", "))
+	}
+	if opts.Holdout < 0 || opts.Holdout >= 1 {
+		return ReplayResult{}, fmt.Errorf("holdout must be at least 0 and below 1")
+	}
+
+	rater := newRater(opts.RaterOptions)
+	counts := map[string]int{}
+	for _, v := range votes {
+		rater.Rate(v.WinnerID, v.LoserID, opts.Weight(v))
+		counts[v.WinnerID]++
+		counts[v.LoserID]++
+	}
+	res := ReplayResult{Algorithm: algorithm, Rankings: rankScores(rater.Scores(), counts)}
+
+	split := len(votes) - int(math.Round(float64(len(votes))*opts.Holdout))
+	if split == len(votes) {
+		return res, nil
+	}
+	rater = newRater(opts.RaterOptions)
+	for _, v := range votes[:split] {
+		rater.Rate(v.WinnerID, v.LoserID, opts.Weight(v))
+	}
+	eval := &Evaluation{TrainVotes: split}
+	for _, v := range votes[split:] {
+		if opts.Weight(v) == 0 {
+			continue
+		}
+		p := rater.WinProbability(v.WinnerID, v.LoserID)
+		switch {
+		case p > 0.5:
+			eval.Accuracy++
+		case p == 0.5:
+			eval.Accuracy += 0.5
+		}
+		eval.LogLoss -= math.Log(math.Max(p, 1e-15))
+		eval.Brier += (1 - p) * (1 - p)
+		eval.TestVotes++
+	}
+	if eval.TestVotes > 0 {
+		n := float64(eval.TestVotes)
+		eval.Accuracy /= n
+		eval.LogLoss /= n
+		eval.Brier /= n
+	}
+	res.Evaluation = eval
+	return res, nil
+}
+
+// rankScores ranks countries by score, ties sharing a rank and listed by ID
+func rankScores(scores map[string]float64, counts map[string]int) []RatedCountry {
+	ranked := make([]RatedCountry, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, RatedCountry{CountryID: id, Score: score, Votes: counts[id]})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].CountryID < ranked[j].CountryID
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+		if i > 0 && ranked[i].Score == ranked[i-1].Score {
+			ranked[i].Rank = ranked[i-1].Rank
+		}
+	}
+	return ranked
+}
+
+// RankCorrelation compares two rankings over the countries they share.
+// Both coefficients are 0 when undefined, e.g. for fewer than two countries.
+type RankCorrelation struct {
+	Countries int     `json:"countries"`
+	Spearman  float64 `json:"spearman"` // ρ of the ranks, ties averaged
+	Kendall   float64 `json:"kendall"`  // τ-b
+}
+
+// CorrelateRankings returns the rank correlation of two replay rankings
+func CorrelateRankings(a, b []RatedCountry) RankCorrelation {
+	scoresB := make(map[string]float64, len(b))
+	for _, c := range b {
+		scoresB[c.CountryID] = c.Score
+	}
+	var x, y []float64
+	for _, c := range a {
+		if s, ok := scoresB[c.CountryID]; ok {
+			x = append(x, c.Score)
+			y = append(y, s)
+		}
+	}
+	return RankCorrelation{
+		Countries: len(x),
+		Spearman:  pearson(averageRanks(x), averageRanks(y)),
+		Kendall:   kendallTauB(x, y),
+	}
+}
+
+// averageRanks returns the rank of each value, ties getting their mean rank
+func averageRanks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] > values[order[j]] })
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			ranks[order[k]] = float64(i+j)/2 + 1
+		}
+		i = j + 1
+	}
+	return ranks
+}
+
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	if n < 2 {
+		return 0
+	}
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx, my = mx/n, my/n
+	var sxy, sxx, syy float64
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+func kendallTauB(x, y []float64) float64 {
+	var concordant, discordant, tiesX, tiesY float64
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			dx, dy := x[i]-x[j], y[i]-y[j]
+			switch {
+			case dx == 0 && dy == 0:
+			case dx == 0:
+				tiesX++
+			case dy == 0:
+				tiesY++
+			case (dx > 0) == (dy > 0):
+				concordant++
+			default:
+				discordant++
+			}
+		}
+	}
+	denom := math.Sqrt((concordant + discordant + tiesX) * (concordant + discordant + tiesY))
+	if denom == 0 {
+		return 0
+	}
+	return (concordant - discordant) / denom
+}
@@ -0,0 +1,177 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayMatchesEngine(t *testing.T) {
+	database := setupTestDB(t)
+	e := NewEngine(database, 7)
+	rng := rand.New(rand.NewSource(7))
+
+	for i := 0; i < 30; i++ {
+		s, err := e.CreateSession("")
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		for j := 0; j < 3; j++ {
+			m, err := e.NextMatchup(s.ID)
+			if err != nil {
+				t.Fatalf("NextMatchup failed: %v", err)
+			}
+			winner, loser := m.CountryA.CountryID, m.CountryB.CountryID
+			if rng.Intn(3) == 0 {
+				winner, loser = loser, winner
+			}
+			if _, err := e.Vote(VoteRequest{SessionID: s.ID, MatchupID: m.ID, WinnerID: winner, LoserID: loser,
+				ListenAMS: rng.Int63n(12000), ListenBMS: rng.Int63n(12000)}); err != nil {
+				t.Fatalf("Vote failed: %v", err)
+			}
+		}
+	}
+
+	votes, err := LocalVotes(database)
+	if err != nil {
+		t.Fatalf("LocalVotes failed: %v", err)
+	}
+	if len(votes) != 90 {
+		t.Fatalf("Expected 90 votes, got %d", len(votes))
+	}
+	res, err := Replay(votes, "elo", ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	rankings, _ := Rankings(database)
+	for _, r := range rankings {
+		found := false
+		for _, c := range res.Rankings {
+			if c.CountryID == r.CountryID {
+				found = true
+				if int(c.Score) != r.EloScore || c.Votes != r.Wins+r.Losses {
+					t.Errorf("Expected %s at %d with %d votes, got %+v", r.CountryID, r.EloScore, r.Wins+r.Losses, c)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s in the replay", r.CountryID)
+		}
+	}
+}
+
+func TestReadVoteLog(t *testing.T) {
+	// anthem-votes items, out of order; v3 from before votes had country_a
+	log := `{"vote_id":"v2","session_id":"s1","country_a":"FRA","winner_id":"DEU","loser_id":"FRA","listen_a_ms":6000,"listen_b_ms":5000,"voted_at":"2026-10-01T10:01:00.000Z"}
+
+{"vote_id":"v1","session_id":"s1","country_a":"FRA","winner_id":"FRA","loser_id":"DEU","listen_a_ms":4000,"listen_b_ms":4000,"voted_at":"2026-10-01T10:00:00.000Z"}
+{"vote_id":"v3","session_id":"s2","winner_id":"FRA","loser_id":"ITA","listen_a_ms":9000,"listen_b_ms":20000,"voted_at":"2026-10-01T09:00:00.000Z"}
+`
+	votes, err := ReadVoteLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("ReadVoteLog failed: %v", err)
+	}
+	var ids []string
+	for _, v := range votes {
+		ids = append(ids, v.VoteID)
+	}
+	if got := strings.Join(ids, ","); got != "v3,v1,v2" {
+		t.Errorf("Expected votes in voting order v3,v1,v2, got %s", got)
+	}
+	// B won v2, so the winner's listen is listen_b_ms
+	if *votes[2].ListenWinnerMS != 9000 || *votes[2].ListenLoserMS != 10000 {
+		t.Errorf("Expected cumulative listens 9000/10000, got %d/%d", *votes[2].ListenWinnerMS, *votes[2].ListenLoserMS)
+	}
+	if votes[0].ListenWinnerMS != nil {
+		t.Errorf("Expected no cumulative listens without country_a, got %d", *votes[0].ListenWinnerMS)
+	}
+	opts := ReplayOptions{}
+	if w := opts.Weight(votes[0]); w != 0.9 {
+		t.Errorf("Expected weight 0.9, got %v", w)
+	}
+	opts.FullListenMS = 20000
+	if w := opts.Weight(votes[0]); w != 0.45 {
+		t.Errorf("Expected weight 0.45 with a 20s full listen, got %v", w)
+	}
+
+	if _, err := ReadVoteLog(strings.NewReader(`{"vote_id":"v1"}`)); err == nil {
+		t.Error("Expected an error for a vote without winner and loser")
+	}
+}
+
+func TestReplayAlgorithms(t *testing.T) {
+	// Votes drawn from a Bradley-Terry model with known strengths
+	rng := rand.New(rand.NewSource(1))
+	const n = 12
+	strength := make([]float64, n)
+	for i := range strength {
+		strength[i] = 1500 + float64(i)*40
+	}
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	full := int64(FullListenMS)
+	var votes []LoggedVote
+	for i := 0; i < 4000; i++ {
+		a, b := rng.Intn(n), rng.Intn(n)
+		if a == b {
+			continue
+		}
+		if rng.Float64() > ExpectedScore(strength[a], strength[b]) {
+			a, b = b, a
+		}
+		votes = append(votes, LoggedVote{
+			VoteID: fmt.Sprint(i), WinnerID: fmt.Sprintf("C%02d", a), LoserID: fmt.Sprintf("C%02d", b),
+			ListenWinnerMS: &full, ListenLoserMS: &full, VotedAt: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	results := map[string]ReplayResult{}
+	for _, algo := range AlgorithmNames() {
+		res, err := Replay(votes, algo, ReplayOptions{Holdout: 0.2})
+		if err != nil {
+			t.Fatalf("Replay %s failed: %v", algo, err)
+		}
+		results[algo] = res
+		if res.Rankings[0].CountryID != "C11" {
+			t.Errorf("Expected %s to rank the strongest country first, got %+v", algo, res.Rankings[0])
+		}
+		e := res.Evaluation
+		if e == nil || e.TestVotes == 0 || e.TrainVotes+e.TestVotes != len(votes) {
+			t.Fatalf("Expected %s evaluated on the held-out votes, got %+v", algo, e)
+		}
+		if e.Accuracy < 0.6 || e.LogLoss > math.Ln2 {
+			t.Errorf("Expected %s to beat a coin flip, got %+v", algo, e)
+		}
+	}
+
+	truth := make([]RatedCountry, n)
+	for i := range truth {
+		truth[i] = RatedCountry{CountryID: fmt.Sprintf("C%02d", i), Score: strength[i]}
+	}
+	for algo, res := range results {
+		if c := CorrelateRankings(res.Rankings, truth); c.Countries != n || c.Spearman < 0.9 {
+			t.Errorf("Expected %s to recover the true order, got %+v", algo, c)
+		}
+	}
+
+	if _, err := Replay(votes, "trueskill", ReplayOptions{}); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+}
+
+func TestCorrelateRankings(t *testing.T) {
+	a := []RatedCountry{{CountryID: "A", Score: 3}, {CountryID: "B", Score: 2}, {CountryID: "C", Score: 1}, {CountryID: "D", Score: 0}}
+	reversed := []RatedCountry{{CountryID: "A", Score: 0}, {CountryID: "B", Score: 1}, {CountryID: "C", Score: 2}}
+
+	if c := CorrelateRankings(a, a); c.Spearman != 1 || c.Kendall != 1 {
+		t.Errorf("Expected perfect correlation, got %+v", c)
+	}
+	if c := CorrelateRankings(a, reversed); c.Countries != 3 || c.Spearman != -1 || c.Kendall != -1 {
+		t.Errorf("Expected -1 over three shared countries, got %+v", c)
+	}
+	if c := CorrelateRankings(a, a[:1]); c.Spearman != 0 || c.Kendall != 0 {
+		t.Errorf("Expected 0 for a single shared country, got %+v", c)
+	}
+}
@@ -0,0 +1,161 @@
+package game
+
+import (
+	"database/sql"
+	"sort"
+)
+
+// voteSides is game_vote_details with one row per anthem of each vote: the
+// winner with won = 1 and the loser with won = 0
+const voteSides = `
+	SELECT vote_id, voter_country, voter_region, winner_id AS anthem_id, winner_iso2 AS anthem_iso2,
+		winner_region AS anthem_region, loser_region AS opponent_region, 1 AS won
+	FROM game_vote_details
+	UNION ALL
+	SELECT vote_id, voter_country, voter_region, loser_id, loser_iso2, loser_region, winner_region, 0
+	FROM game_vote_details
+`
+
+// WinRate is how often anthems of a region won with voters of a country or
+// region. Empty names are votes that could not be placed.
+type WinRate struct {
+	Voter        string  `json:"voter"` // country code or region
+	AnthemRegion string  `json:"anthem_region"`
+	Matchups     int     `json:"matchups"`
+	Wins         int     `json:"wins"`
+	WinRate      float64 `json:"win_rate"`
+}
+
+// WinRates returns the win rate of each anthem region by voter country, or
+// by voter region with byRegion, ordered by voter and region
+func WinRates(db *sql.DB, byRegion bool) ([]WinRate, error) {
+	voter := "voter_country"
+	if byRegion {
+		voter = "voter_region"
+	}
+	rows, err := db.Query(`
+		SELECT COALESCE(` + voter + `,''), COALESCE(anthem_region,''), COUNT(*), SUM(won)
+		FROM (` + voteSides + `)
+		GROUP BY 1, 2 ORDER BY 1, 2
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []WinRate
+	for rows.Next() {
+		var r WinRate
+		if err := rows.Scan(&r.Voter, &r.AnthemRegion, &r.Matchups, &r.Wins); err != nil {
+			return nil, err
+		}
+		r.WinRate = float64(r.Wins) / float64(r.Matchups)
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// HomeBias compares how an anthem fares with voters from its own country
+// against everyone else
+type HomeBias struct {
+	CountryID  string  `json:"country_id"`
+	Name       string  `json:"name"`
+	HomeVotes  int     `json:"home_votes"`
+	HomeWins   int     `json:"home_wins"`
+	HomeRate   float64 `json:"home_rate"`
+	OtherVotes int     `json:"other_votes"`
+	OtherWins  int     `json:"other_wins"`
+	OtherRate  float64 `json:"other_rate"`
+	Bias       float64 `json:"bias"` // HomeRate - OtherRate
+}
+
+// BiasSummary is the home and own-region bias over all votes. ExpectedRate
+// is the rate the home anthems would have had with other voters, their
+// OtherRate weighted by home votes; anthems no one else voted on are left
+// out of it.
+type BiasSummary struct {
+	HomeVotes     int        `json:"home_votes"`
+	HomeWins      int        `json:"home_wins"`
+	HomeRate      float64    `json:"home_rate"`
+	ExpectedRate  float64    `json:"expected_rate"`
+	RegionVotes   int        `json:"region_votes"` // matchups of one anthem of the voter's region against another region
+	RegionWins    int        `json:"region_wins"`
+	RegionRate    float64    `json:"region_rate"`
+	PlacedVotes   int        `json:"placed_votes"` // votes whose voter country is known
+	UnplacedVotes int        `json:"unplaced_votes"`
+	Countries     []HomeBias `json:"countries"`
+}
+
+// HomeCountryBias returns the home bias of every anthem voted on by someone
+// from its country, largest bias first, and the summary over all votes
+func HomeCountryBias(db *sql.DB) (BiasSummary, error) {
+	var s BiasSummary
+	rows, err := db.Query(`
+		SELECT sides.anthem_id, COALESCE(r.name, c.common_name, c.name, sides.anthem_id),
+			SUM(home), SUM(home * won), SUM(1 - home), SUM((1 - home) * won)
+		FROM (
+			SELECT anthem_id, won, CASE WHEN voter_country = anthem_iso2 THEN 1 ELSE 0 END AS home
+			FROM (` + voteSides + `) WHERE voter_country IS NOT NULL
+		) sides
+		LEFT JOIN game_rankings r ON r.country_id = sides.anthem_id
+		LEFT JOIN countries c ON c.iso_alpha3 = sides.anthem_id
+		GROUP BY sides.anthem_id HAVING SUM(home) > 0
+	`)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	var expected, expectedVotes float64
+	for rows.Next() {
+		var b HomeBias
+		if err := rows.Scan(&b.CountryID, &b.Name, &b.HomeVotes, &b.HomeWins, &b.OtherVotes, &b.OtherWins); err != nil {
+			return s, err
+		}
+		b.HomeRate = float64(b.HomeWins) / float64(b.HomeVotes)
+		if b.OtherVotes > 0 {
+			b.OtherRate = float64(b.OtherWins) / float64(b.OtherVotes)
+			b.Bias = b.HomeRate - b.OtherRate
+			expected += b.OtherRate * float64(b.HomeVotes)
+			expectedVotes += float64(b.HomeVotes)
+		}
+		s.HomeVotes += b.HomeVotes
+		s.HomeWins += b.HomeWins
+		s.Countries = append(s.Countries, b)
+	}
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+	if s.HomeVotes > 0 {
+		s.HomeRate = float64(s.HomeWins) / float64(s.HomeVotes)
+	}
+	if expectedVotes > 0 {
+		s.ExpectedRate = expected / expectedVotes
+	}
+	sortHomeBias(s.Countries)
+
+	if err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(won), 0) FROM (`+voteSides+`)
+		WHERE voter_region IS NOT NULL AND anthem_region = voter_region
+			AND opponent_region IS NOT NULL AND opponent_region != voter_region
+	`).Scan(&s.RegionVotes, &s.RegionWins); err != nil {
+		return s, err
+	}
+	if s.RegionVotes > 0 {
+		s.RegionRate = float64(s.RegionWins) / float64(s.RegionVotes)
+	}
+	err = db.QueryRow(`
+		SELECT COALESCE(SUM(voter_country IS NOT NULL), 0), COALESCE(SUM(voter_country IS NULL), 0)
+		FROM game_vote_details
+	`).Scan(&s.PlacedVotes, &s.UnplacedVotes)
+	return s, err
+}
+
+func sortHomeBias(b []HomeBias) {
+	sort.Slice(b, func(i, j int) bool {
+		if b[i].Bias != b[j].Bias {
+			return b[i].Bias > b[j].Bias
+		}
+		return b[i].CountryID < b[j].CountryID
+	})
+}
@@ -0,0 +1,289 @@
+package game
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/audio"
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+// Excluded is a country left out of the game, with the reason
+type Excluded struct {
+	CountryID string `json:"country_id"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+// SeedRankings returns an initial ranking row, at InitialELO, for every
+// country with a playable recording, and the countries left out. The audio
+// URL is the first playable recording, primary ones coming first.
+func SeedRankings(countries []format.CountryRecord) ([]Ranking, []Excluded) {
+	var rows []Ranking
+	var excluded []Excluded
+	for i := range countries {
+		c := &countries[i]
+		var url string
+		for _, a := range c.AudioFiles {
+			if playable(a) {
+				url = a.URL
+				break
+			}
+		}
+		if url == "" {
+			reason := "no recording"
+			if len(c.AudioFiles) > 0 {
+				reason = "no playable recording"
+			}
+			excluded = append(excluded, Excluded{CountryID: format.CountryKey(c), Name: format.DisplayName(c), Reason: reason})
+			continue
+		}
+		rows = append(rows, Ranking{
+			CountryID:  format.CountryKey(c),
+			Name:       format.DisplayName(c),
+			FlagURL:    c.FlagURL,
+			AnthemName: anthemName(c),
+			AudioURL:   url,
+			EloScore:   InitialELO,
+		})
+	}
+	return rows, excluded
+}
+
+// playable reports whether a browser can play a recording: it has a URL and
+// is audio (or video with sound) other than MIDI. Recordings of unknown
+// format are given the benefit of the doubt.
+func playable(a format.AudioRecord) bool {
+	if a.URL == "" {
+		return false
+	}
+	t := audio.ContentType(a.Format, a.URL)
+	switch {
+	case strings.HasPrefix(t, "audio/midi"):
+		return false
+	case strings.HasPrefix(t, "audio/"), strings.HasPrefix(t, "video/"), t == "application/octet-stream":
+		return true
+	}
+	return false
+}
+
+// SeedResult counts what a seed changed
+type SeedResult struct {
+	Added    int // countries new to the rankings
+	Updated  int // existing countries whose name, flag, anthem or audio were refreshed
+	Disabled int // existing countries without playable audio any more
+	Reset    int // countries put back to InitialELO
+}
+
+// SeedLocal writes rows to game_rankings. Countries already ranked keep
+// their rating unless reset is set, but get the current name, flag, anthem
+// and audio; ranked countries not in rows lose their audio URL, so they are
+// no longer matched. With reset every rating starts over at InitialELO.
+func SeedLocal(db *sql.DB, rows []Ranking, reset bool) (SeedResult, error) {
+	var res SeedResult
+	tx, err := db.Begin()
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	seeded := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		seeded[r.CountryID] = true
+		result, err := tx.Exec(`
+			UPDATE game_rankings SET name = ?, flag_url = ?, anthem_name = ?, audio_url = ?, updated_at = ?
+			WHERE country_id = ? AND (name IS NOT ? OR flag_url IS NOT ? OR anthem_name IS NOT ? OR audio_url IS NOT ?)
+		`, r.Name, nullIfEmpty(r.FlagURL), nullIfEmpty(r.AnthemName), r.AudioURL, now,
+			r.CountryID, r.Name, nullIfEmpty(r.FlagURL), nullIfEmpty(r.AnthemName), r.AudioURL)
+		if err != nil {
+			return res, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			res.Updated++
+		}
+		result, err = tx.Exec(`
+			INSERT OR IGNORE INTO game_rankings (country_id, name, flag_url, anthem_name, audio_url, elo_score, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, r.CountryID, r.Name, nullIfEmpty(r.FlagURL), nullIfEmpty(r.AnthemName), r.AudioURL, r.EloScore, now)
+		if err != nil {
+			return res, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			res.Added++
+		}
+	}
+
+	existing, err := tx.Query(`SELECT country_id FROM game_rankings WHERE audio_url IS NOT NULL`)
+	if err != nil {
+		return res, err
+	}
+	var gone []string
+	for existing.Next() {
+		var id string
+		if err := existing.Scan(&id); err != nil {
+			existing.Close()
+			return res, err
+		}
+		if !seeded[id] {
+			gone = append(gone, id)
+		}
+	}
+	existing.Close()
+	for _, id := range gone {
+		if _, err := tx.Exec(`UPDATE game_rankings SET audio_url = NULL, updated_at = ? WHERE country_id = ?`, now, id); err != nil {
+			return res, err
+		}
+		res.Disabled++
+	}
+
+	if reset {
+		result, err := tx.Exec(`UPDATE game_rankings SET elo_score = ?, wins = 0, losses = 0, updated_at = ?
+			WHERE elo_score != ? OR wins != 0 OR losses != 0`, InitialELO, now, InitialELO)
+		if err != nil {
+			return res, err
+		}
+		n, _ := result.RowsAffected()
+		res.Reset = int(n)
+	}
+	return res, tx.Commit()
+}
+
+// WriteRankingsJSONL writes rows as JSON lines, one anthem-rankings item each
+func WriteRankingsJSONL(w io.Writer, rows []Ranking) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rankingItem is a ranking row as an anthem-rankings item, optional
+// attributes left out when empty as seed-rankings.sh does
+func rankingItem(r Ranking) Item {
+	item := Item{
+		"country_id": attrS(r.CountryID),
+		"name":       attrS(r.Name),
+		"elo_score":  attrN(int64(r.EloScore)),
+		"wins":       attrN(int64(r.Wins)),
+		"losses":     attrN(int64(r.Losses)),
+	}
+	for name, value := range map[string]string{"flag_url": r.FlagURL, "anthem_name": r.AnthemName, "audio_url": r.AudioURL} {
+		if value != "" {
+			item[name] = attrS(value)
+		}
+	}
+	return item
+}
+
+// SeedDynamoDB writes rows to a rankings table the way SeedLocal writes
+// game_rankings: new countries are added, ranked ones keep their rating
+// unless reset is set but get the current name, flag, anthem and audio, and
+// ranked countries not in rows lose their audio URL. Existing items are
+// changed with UpdateItem, so votes cast meanwhile are not overwritten.
+func SeedDynamoDB(d *DynamoDB, table string, rows []Ranking, reset bool) (SeedResult, error) {
+	var res SeedResult
+	existing := map[string]Item{}
+	if err := d.Scan(table, "", func(item Item) error {
+		existing[item.String("country_id")] = item
+		return nil
+	}); err != nil {
+		return res, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	seeded := make(map[string]bool, len(rows))
+	var added []Item
+	for _, r := range rows {
+		seeded[r.CountryID] = true
+		old, ok := existing[r.CountryID]
+		if !ok {
+			item := rankingItem(r)
+			item["updated_at"] = attrS(now)
+			added = append(added, item)
+			res.Added++
+			continue
+		}
+		fields := map[string]string{"name": r.Name, "flag_url": r.FlagURL, "anthem_name": r.AnthemName, "audio_url": r.AudioURL}
+		changed := false
+		for name, value := range fields {
+			changed = changed || old.String(name) != value
+		}
+		if !changed {
+			continue
+		}
+		if err := updateRanking(d, table, r.CountryID, fields, now); err != nil {
+			return res, err
+		}
+		res.Updated++
+	}
+
+	ids := make([]string, 0, len(existing))
+	for id := range existing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if seeded[id] || existing[id].String("audio_url") == "" {
+			continue
+		}
+		if err := updateRanking(d, table, id, map[string]string{"audio_url": ""}, now); err != nil {
+			return res, err
+		}
+		res.Disabled++
+	}
+
+	if reset {
+		for _, id := range ids {
+			old := existing[id]
+			if _, ok := old["elo_score"]; ok && old.Int("elo_score") == InitialELO && old.Int("wins") == 0 && old.Int("losses") == 0 {
+				continue
+			}
+			if err := d.UpdateItem(table, Item{"country_id": attrS(id)},
+				"SET elo_score = :elo, wins = :zero, losses = :zero, updated_at = :t", nil,
+				Item{":elo": attrN(InitialELO), ":zero": attrN(0), ":t": attrS(now)}); err != nil {
+				return res, err
+			}
+			res.Reset++
+		}
+	}
+	return res, d.BatchPut(table, added)
+}
+
+// updateRanking sets the given attributes of a ranking item, removing the
+// ones that are empty, and its updated_at
+func updateRanking(d *DynamoDB, table, countryID string, fields map[string]string, now string) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := []string{"updated_at = :t"}
+	var removes []string
+	attrNames := map[string]string{}
+	values := Item{":t": attrS(now)}
+	for i, name := range names {
+		// "name" is a reserved word, so every attribute goes through a placeholder
+		placeholder := fmt.Sprintf("#a%d", i)
+		attrNames[placeholder] = name
+		if fields[name] == "" {
+			removes = append(removes, placeholder)
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = :v%d", placeholder, i))
+		values[fmt.Sprintf(":v%d", i)] = attrS(fields[name])
+	}
+	update := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		update += " REMOVE " + strings.Join(removes, ", ")
+	}
+	return d.UpdateItem(table, Item{"country_id": attrS(countryID)}, update, attrNames, values)
+}
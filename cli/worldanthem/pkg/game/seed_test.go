@@ -0,0 +1,261 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+func TestSeedRankings(t *testing.T) {
+	countries := []format.CountryRecord{
+		{ID: "fra", ISOAlpha3: "FRA", Name: "French Republic", CommonName: "France", FlagURL: "https://example.org/fr.svg",
+			Anthem: &format.AnthemRecord{Name: "La Marseillaise"},
+			AudioFiles: []format.AudioRecord{
+				{URL: "https://example.org/fra.mid", Format: "audio/midi"},
+				{URL: "https://example.org/fra.ogg", Format: "audio/ogg"},
+			}},
+		{ID: "deu", ISOAlpha3: "DEU", Name: "Germany", AudioFiles: []format.AudioRecord{{URL: "https://example.org/deu.mid"}}},
+		{ID: "ata", ISOAlpha3: "ATA", Name: "Antarctica"},
+	}
+	rows, excluded := SeedRankings(countries)
+	if len(rows) != 1 || rows[0].CountryID != "FRA" || rows[0].AudioURL != "https://example.org/fra.ogg" ||
+		rows[0].Name != "France" || rows[0].AnthemName != "La Marseillaise" || rows[0].EloScore != InitialELO {
+		t.Errorf("Expected France with its Ogg recording, got %+v", rows)
+	}
+	want := []Excluded{{"DEU", "Germany", "no playable recording"}, {"ATA", "Antarctica", "no recording"}}
+	if len(excluded) != 2 || excluded[0] != want[0] || excluded[1] != want[1] {
+		t.Errorf("Expected %+v excluded, got %+v", want, excluded)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRankingsJSONL(&buf, rows); err != nil {
+		t.Fatalf("WriteRankingsJSONL failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"country_id":"FRA"`) || !strings.Contains(buf.String(), `"elo_score":1500`) {
+		t.Errorf("Expected an anthem-rankings item, got %s", buf.String())
+	}
+}
+
+func TestSeedLocal(t *testing.T) {
+	database := setupTestDB(t)
+	countries, err := format.Countries(database)
+	if err != nil {
+		t.Fatalf("Failed to query countries: %v", err)
+	}
+	rows, _ := SeedRankings(countries)
+
+	res, err := SeedLocal(database, rows, false)
+	if err != nil || res.Added != 3 {
+		t.Fatalf("Expected 3 countries added, got %+v, %v", res, err)
+	}
+	database.Exec(`UPDATE game_rankings SET elo_score = 1600, wins = 3 WHERE country_id = 'FRA'`)
+
+	// Italy loses its recording and Germany's anthem gets a name
+	for i := range rows {
+		if rows[i].CountryID == "DEU" {
+			rows[i].AnthemName = "Deutschlandlied"
+		}
+	}
+	res, err = SeedLocal(database, rows[:2], false)
+	if err != nil || res != (SeedResult{Updated: 1, Disabled: 1}) {
+		t.Fatalf("Expected one update and one disabled country, got %+v, %v", res, err)
+	}
+	var elo int
+	var audioURL *string
+	database.QueryRow(`SELECT elo_score FROM game_rankings WHERE country_id = 'FRA'`).Scan(&elo)
+	database.QueryRow(`SELECT audio_url FROM game_rankings WHERE country_id = 'ITA'`).Scan(&audioURL)
+	if elo != 1600 || audioURL != nil {
+		t.Errorf("Expected France's rating kept and Italy unmatched, got %d and %v", elo, audioURL)
+	}
+
+	if res, err = SeedLocal(database, rows, true); err != nil || res.Reset != 1 {
+		t.Errorf("Expected France reset, got %+v, %v", res, err)
+	}
+}
+
+func TestSeedDynamoDB(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "none"))
+	stored := map[string]Item{
+		"FRA": {"country_id": attrS("FRA"), "name": attrS("France"), "elo_score": attrN(1600), "wins": attrN(3),
+			"audio_url": attrS("https://example.org/fra.ogg")},
+		"DEU": {"country_id": attrS("DEU"), "name": attrS("Germany"), "elo_score": attrN(1450),
+			"audio_url": attrS("https://example.org/old.ogg")},
+		"AUT": {"country_id": attrS("AUT"), "name": attrS("Austria"), "elo_score": attrN(1500),
+			"audio_url": attrS("https://example.org/aut.ogg")},
+	}
+	unprocessedOnce := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test/") {
+			t.Errorf("Expected a signed request, got %q", r.Header.Get("Authorization"))
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.Scan":
+			var in struct{ TableName string }
+			json.NewDecoder(r.Body).Decode(&in)
+			if in.TableName != "anthem-rankings-local" {
+				t.Errorf("Unexpected scan %+v", in)
+			}
+			var items []Item
+			for _, item := range stored {
+				items = append(items, item)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Items": items})
+		case "DynamoDB_20120810.UpdateItem":
+			var in struct {
+				Key                       Item
+				UpdateExpression          string
+				ExpressionAttributeNames  map[string]string
+				ExpressionAttributeValues Item
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			item := stored[in.Key.String("country_id")]
+			set, remove, _ := strings.Cut(strings.TrimPrefix(in.UpdateExpression, "SET "), " REMOVE ")
+			for _, assignment := range strings.Split(set, ", ") {
+				name, value, _ := strings.Cut(assignment, " = ")
+				if n, ok := in.ExpressionAttributeNames[name]; ok {
+					name = n
+				}
+				item[name] = in.ExpressionAttributeValues[value]
+			}
+			for _, name := range strings.Split(remove, ", ") {
+				delete(item, in.ExpressionAttributeNames[name])
+			}
+			w.Write([]byte(`{}`))
+		case "DynamoDB_20120810.BatchWriteItem":
+			var in struct {
+				RequestItems map[string][]writeRequest
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			reqs := in.RequestItems["anthem-rankings-local"]
+			if unprocessedOnce && len(reqs) > 1 {
+				// Leave the last item for a retry
+				unprocessedOnce = false
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"UnprocessedItems": map[string][]writeRequest{"anthem-rankings-local": reqs[len(reqs)-1:]},
+				})
+				reqs = reqs[:len(reqs)-1]
+			} else {
+				w.Write([]byte(`{"UnprocessedItems":{}}`))
+			}
+			for _, req := range reqs {
+				stored[req.PutRequest.Item.String("country_id")] = req.PutRequest.Item
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#UnknownOperationException","message":"unknown"}`))
+		}
+	}))
+	defer srv.Close()
+
+	d, err := NewDynamoDB(srv.URL, "us-east-1")
+	if err != nil {
+		t.Fatalf("Expected dummy credentials for a local endpoint, got %v", err)
+	}
+	// Germany has new audio, Italy and Spain are new, Austria lost its audio
+	rows := []Ranking{
+		{CountryID: "FRA", Name: "France", EloScore: InitialELO, AudioURL: "https://example.org/fra.ogg"},
+		{CountryID: "DEU", Name: "Germany", EloScore: InitialELO, AudioURL: "https://example.org/deu.ogg", AnthemName: "Deutschlandlied"},
+		{CountryID: "ITA", Name: "Italy", EloScore: InitialELO, AudioURL: "https://example.org/ita.ogg"},
+		{CountryID: "ESP", Name: "Spain", EloScore: InitialELO, AudioURL: "https://example.org/esp.ogg"},
+	}
+	table := TableName(RankingsTable, "local")
+	res, err := SeedDynamoDB(d, table, rows, false)
+	if err != nil || res != (SeedResult{Added: 2, Updated: 1, Disabled: 1}) {
+		t.Fatalf("Expected 2 added, 1 updated and 1 disabled, got %+v, %v", res, err)
+	}
+	if len(stored) != 5 || stored["FRA"].Int("elo_score") != 1600 || stored["ITA"].String("audio_url") == "" {
+		t.Errorf("Expected Italy and Spain added and France kept, got %+v", stored)
+	}
+	if deu := stored["DEU"]; deu.Int("elo_score") != 1450 || deu.String("audio_url") != "https://example.org/deu.ogg" ||
+		deu.String("anthem_name") != "Deutschlandlied" {
+		t.Errorf("Expected Germany's audio and anthem refreshed with its rating kept, got %+v", deu)
+	}
+	if _, ok := stored["AUT"]["audio_url"]; ok || stored["AUT"].String("name") != "Austria" {
+		t.Errorf("Expected Austria without audio, got %+v", stored["AUT"])
+	}
+
+	if res, err = SeedDynamoDB(d, table, rows, true); err != nil || res != (SeedResult{Reset: 2}) {
+		t.Errorf("Expected France and Germany reset, got %+v, %v", res, err)
+	}
+	if stored["FRA"].Int("elo_score") != InitialELO || stored["FRA"].Int("wins") != 0 {
+		t.Errorf("Expected France back at %d, got %+v", InitialELO, stored["FRA"])
+	}
+
+	err = d.do("DeleteTable", map[string]string{}, nil)
+	if apiErr, ok := err.(*DynamoError); !ok || apiErr.Type != "UnknownOperationException" {
+		t.Errorf("Expected an UnknownOperationException, got %v", err)
+	}
+}
+
+func TestNewDynamoDBCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+	path := filepath.Join(t.TempDir(), "credentials")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	const remote = "https://dynamodb.eu-west-1.amazonaws.com"
+
+	if _, err := NewDynamoDB(remote, "eu-west-1"); err == nil {
+		t.Error("Expected an error without credentials for a remote endpoint")
+	}
+	if d, err := NewDynamoDB("http://localhost:4566", "us-east-1"); err != nil || d.AccessKeyID != "test" {
+		t.Errorf("Expected dummy credentials for LocalStack, got %v", err)
+	}
+
+	os.WriteFile(path, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = secret\n\n"+
+		"[game]\naws_access_key_id=AKIDGAME\naws_secret_access_key=secret\naws_session_token=token\n"), 0600)
+	if d, err := NewDynamoDB(remote, "eu-west-1"); err != nil || d.AccessKeyID != "AKIDDEFAULT" {
+		t.Errorf("Expected the default profile, got %v", err)
+	}
+	t.Setenv("AWS_PROFILE", "game")
+	if d, err := NewDynamoDB(remote, "eu-west-1"); err != nil || d.AccessKeyID != "AKIDGAME" || d.SessionToken != "token" {
+		t.Errorf("Expected the game profile, got %+v, %v", d, err)
+	}
+	t.Setenv("AWS_PROFILE", "missing")
+	if _, err := NewDynamoDB("http://localhost:4566", "us-east-1"); err == nil {
+		t.Error("Expected an error for a missing profile, even locally")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	if d, err := NewDynamoDB(remote, "eu-west-1"); err != nil || d.AccessKeyID != "AKIDENV" {
+		t.Errorf("Expected the environment's credentials first, got %v", err)
+	}
+}
+
+// TestSignV4 checks signatures against the AWS Signature Version 4 test
+// suite (get-vanilla) and the IAM ListUsers example of the AWS documentation
+func TestSignV4(t *testing.T) {
+	const secret = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		url, contentType, service, expected string
+	}{
+		{"https://example.amazonaws.com/", "", "service",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+				"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", "application/x-www-form-urlencoded; charset=utf-8", "iam",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, " +
+				"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		req.Header = http.Header{}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		signV4(req, nil, now, "us-east-1", tc.service, "AKIDEXAMPLE", secret)
+		if got := req.Header.Get("Authorization"); got != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+// SimulationOptions configure Simulate. Biases and noise are in units of the
+// spread of anthem quality, which is drawn from a standard normal.
+type SimulationOptions struct {
+	Voters int   `json:"voters"`
+	Votes  int   `json:"votes"`
+	Seed   int64 `json:"seed"`
+
+	RegionalBias   float64 `json:"regional_bias"`   // preference for anthems of the voter's own region
+	PopularityBias float64 `json:"popularity_bias"` // preference for well-known anthems
+	Noise          float64 `json:"noise"`           // spread of each voter's judgement per vote
+
+	ListenMS    int64   `json:"listen_ms"`   // time each anthem is heard per round
+	Checkpoints int     `json:"checkpoints"` // times the ranking is compared during the run
+	Converged   float64 `json:"converged"`   // Spearman ρ counted as converged
+}
+
+// DefaultSimulationOptions are the defaults of `game simulate`
+var DefaultSimulationOptions = SimulationOptions{
+	Voters:         100,
+	Votes:          5000,
+	Seed:           1,
+	RegionalBias:   0.5,
+	PopularityBias: 0.3,
+	Noise:          1,
+	ListenMS:       FullListenMS,
+	Checkpoints:    20,
+	Converged:      0.9,
+}
+
+// SimulatedCountry is a country of a simulation with its hidden preference
+// and what the engine made of it
+type SimulatedCountry struct {
+	CountryID   string  `json:"country_id"`
+	Name        string  `json:"name"`
+	Region      string  `json:"region,omitempty"`
+	Quality     float64 `json:"quality"`
+	Popularity  float64 `json:"popularity"`
+	Truth       float64 `json:"truth"` // mean utility over the voter population
+	TruthRank   int     `json:"truth_rank"`
+	EloScore    int     `json:"elo_score"`
+	Rank        int     `json:"rank"`
+	Appearances int     `json:"appearances"` // matchups the country was in
+}
+
+// Checkpoint is how close the engine's ranking was to the truth after a
+// number of votes
+type Checkpoint struct {
+	Votes         int     `json:"votes"`
+	Spearman      float64 `json:"spearman"`
+	Kendall       float64 `json:"kendall"`
+	MeanRankError float64 `json:"mean_rank_error"`
+	MaxRankError  int     `json:"max_rank_error"`
+}
+
+// SimulationResult is the outcome of Simulate. Countries are in truth order.
+type SimulationResult struct {
+	Options     SimulationOptions  `json:"options"`
+	Countries   []SimulatedCountry `json:"countries"`
+	Checkpoints []Checkpoint       `json:"checkpoints"`
+	Final       Checkpoint         `json:"final"`
+	ConvergedAt int                `json:"converged_at"` // votes from which ρ stayed ≥ Converged, 0 if it did not
+	Wildcards   int                `json:"wildcards"`
+	// Coverage by truth quartile: share of all appearances of the top
+	// quarter of countries first, the bottom quarter last
+	QuartileShare [4]float64 `json:"quartile_share"`
+	Unseen        int        `json:"unseen"` // countries never matched
+}
+
+type simVoter struct {
+	sessionID string
+	region    string
+}
+
+// Simulate runs synthetic voters through the game engine, on a scratch
+// database seeded from countries, and compares the resulting ranking with
+// the voters' hidden preferences.
+//
+// Every playable country gets a quality and a popularity from a standard
+// normal. Each voter comes from a random country; they value an anthem at
+// quality + PopularityBias·popularity, plus RegionalBias if it is from their
+// region, and pick the higher valued anthem of a matchup after adding Noise
+// to each. The truth ranking orders countries by that value averaged over
+// the voter population.
+func Simulate(countries []format.CountryRecord, opts SimulationOptions) (SimulationResult, error) {
+	if opts.Voters < 1 || opts.Votes < 1 {
+		return SimulationResult{}, fmt.Errorf("voters and votes must be at least 1")
+	}
+	if opts.Checkpoints < 1 {
+		opts.Checkpoints = 1
+	}
+	rows, _ := SeedRankings(countries)
+	if len(rows) < 2 {
+		return SimulationResult{}, ErrNotEnoughCountries
+	}
+	regions := map[string]string{}
+	alpha2 := map[string]string{}
+	for i := range countries {
+		regions[format.CountryKey(&countries[i])] = countries[i].Region
+		alpha2[format.CountryKey(&countries[i])] = countries[i].ISOAlpha2
+	}
+
+	scratch, err := db.OpenScratch()
+	if err != nil {
+		return SimulationResult{}, err
+	}
+	defer scratch.Close()
+	if _, err := SeedLocal(scratch, rows, false); err != nil {
+		return SimulationResult{}, fmt.Errorf("failed to seed rankings: %w", err)
+	}
+
+	// The engine draws its own seed, so matchups and votes are not driven by
+	// the same sequence of numbers
+	rng := rand.New(rand.NewSource(opts.Seed))
+	engine := NewEngine(scratch, rng.Int63())
+	engine.MaxVotesPerDay = opts.Votes
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine.Now = func() time.Time { return clock }
+
+	// Hidden preferences
+	sim := make([]SimulatedCountry, len(rows))
+	index := make(map[string]int, len(rows))
+	for i, r := range rows {
+		sim[i] = SimulatedCountry{
+			CountryID: r.CountryID, Name: r.Name, Region: regions[r.CountryID],
+			Quality: rng.NormFloat64(), Popularity: rng.NormFloat64(),
+		}
+		index[r.CountryID] = i
+	}
+	voters := make([]simVoter, opts.Voters)
+	regionShare := map[string]float64{}
+	for i := range voters {
+		home := rows[rng.Intn(len(rows))].CountryID
+		s, err := engine.CreateSession(alpha2[home])
+		if err != nil {
+			return SimulationResult{}, err
+		}
+		voters[i] = simVoter{sessionID: s.ID, region: regions[home]}
+		regionShare[regions[home]] += 1 / float64(opts.Voters)
+	}
+	value := func(c *SimulatedCountry, voterRegion string) float64 {
+		v := c.Quality + opts.PopularityBias*c.Popularity
+		if c.Region != "" && c.Region == voterRegion {
+			v += opts.RegionalBias
+		}
+		return v
+	}
+	truthScores := make(map[string]float64, len(sim))
+	for i := range sim {
+		c := &sim[i]
+		c.Truth = c.Quality + opts.PopularityBias*c.Popularity
+		if c.Region != "" {
+			c.Truth += opts.RegionalBias * regionShare[c.Region]
+		}
+		truthScores[c.CountryID] = c.Truth
+	}
+	truth := rankScores(truthScores, nil)
+	for _, t := range truth {
+		sim[index[t.CountryID]].TruthRank = t.Rank
+	}
+
+	res := SimulationResult{Options: opts}
+	every := opts.Votes / opts.Checkpoints
+	if every < 1 {
+		every = 1
+	}
+	for n := 1; n <= opts.Votes; n++ {
+		voter := voters[rng.Intn(len(voters))]
+		m, err := engine.NextMatchup(voter.sessionID)
+		if err != nil {
+			return res, err
+		}
+		if m.IsWildcard {
+			res.Wildcards++
+		}
+		a, b := &sim[index[m.CountryA.CountryID]], &sim[index[m.CountryB.CountryID]]
+		a.Appearances++
+		b.Appearances++
+
+		winner, loser := a, b
+		if value(a, voter.region)+opts.Noise*rng.NormFloat64() < value(b, voter.region)+opts.Noise*rng.NormFloat64() {
+			winner, loser = b, a
+		}
+		if _, err := engine.Vote(VoteRequest{
+			SessionID: voter.sessionID, MatchupID: m.ID, WinnerID: winner.CountryID, LoserID: loser.CountryID,
+			ListenAMS: opts.ListenMS, ListenBMS: opts.ListenMS,
+		}); err != nil {
+			return res, err
+		}
+		clock = clock.Add(30 * time.Second)
+
+		if n%every == 0 || n == opts.Votes {
+			cp, err := simCheckpoint(engine, sim, index, truth, n)
+			if err != nil {
+				return res, err
+			}
+			res.Checkpoints = append(res.Checkpoints, cp)
+		}
+	}
+	res.Final = res.Checkpoints[len(res.Checkpoints)-1]
+	for i := len(res.Checkpoints) - 1; i >= 0 && res.Checkpoints[i].Spearman >= opts.Converged; i-- {
+		res.ConvergedAt = res.Checkpoints[i].Votes
+	}
+
+	sort.Slice(sim, func(i, j int) bool { return sim[i].TruthRank < sim[j].TruthRank })
+	total := 0
+	for i, c := range sim {
+		total += c.Appearances
+		res.QuartileShare[i*4/len(sim)] += float64(c.Appearances)
+		if c.Appearances == 0 {
+			res.Unseen++
+		}
+	}
+	for q := range res.QuartileShare {
+		res.QuartileShare[q] /= float64(total)
+	}
+	res.Countries = sim
+	return res, nil
+}
+
+// simCheckpoint compares the engine's current ranking with the truth and
+// stores each country's rating and rank in sim
+func simCheckpoint(e *Engine, sim []SimulatedCountry, index map[string]int, truth []RatedCountry, votes int) (Checkpoint, error) {
+	rankings, err := Rankings(e.db)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	scores := make(map[string]float64, len(rankings))
+	for _, r := range rankings {
+		scores[r.CountryID] = float64(r.EloScore)
+	}
+	ranked := rankScores(scores, nil)
+
+	cp := Checkpoint{Votes: votes}
+	corr := CorrelateRankings(ranked, truth)
+	cp.Spearman, cp.Kendall = corr.Spearman, corr.Kendall
+	for _, r := range ranked {
+		c := &sim[index[r.CountryID]]
+		c.EloScore, c.Rank = int(r.Score), r.Rank
+		diff := abs(c.Rank - c.TruthRank)
+		cp.MeanRankError += float64(diff)
+		if diff > cp.MaxRankError {
+			cp.MaxRankError = diff
+		}
+	}
+	cp.MeanRankError /= float64(len(ranked))
+	return cp, nil
+}
@@ -0,0 +1,73 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+func simCountries(n int) []format.CountryRecord {
+	regions := []string{"Africa", "Americas", "Asia", "Europe", "Oceania"}
+	countries := make([]format.CountryRecord, n)
+	for i := range countries {
+		id := fmt.Sprintf("C%02d", i)
+		countries[i] = format.CountryRecord{
+			ID: id, ISOAlpha3: id, Name: id, Region: regions[i%len(regions)],
+			AudioFiles: []format.AudioRecord{{URL: "https://example.org/" + id + ".ogg"}},
+		}
+	}
+	return countries
+}
+
+func TestSimulate(t *testing.T) {
+	opts := DefaultSimulationOptions
+	opts.Voters, opts.Votes, opts.Checkpoints = 20, 1500, 5
+	opts.Noise = 0.3
+
+	res, err := Simulate(simCountries(16), opts)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if len(res.Checkpoints) != 5 || res.Final.Votes != 1500 {
+		t.Errorf("Expected 5 checkpoints ending at 1500 votes, got %+v", res.Checkpoints)
+	}
+	if res.Final.Spearman < 0.8 || res.ConvergedAt == 0 {
+		t.Errorf("Expected the ranking to approach the truth, got %+v (converged at %d)", res.Final, res.ConvergedAt)
+	}
+
+	appearances := 0
+	for i, c := range res.Countries {
+		appearances += c.Appearances
+		if c.TruthRank != i+1 {
+			t.Errorf("Expected countries in truth order, got %s at %d with rank %d", c.CountryID, i, c.TruthRank)
+		}
+	}
+	if appearances != 2*opts.Votes || res.Unseen != 0 {
+		t.Errorf("Expected %d appearances and every country seen, got %d and %d unseen", 2*opts.Votes, appearances, res.Unseen)
+	}
+	if res.Wildcards == 0 {
+		t.Error("Expected wildcard matchups")
+	}
+	share := 0.0
+	for _, q := range res.QuartileShare {
+		share += q
+	}
+	if share < 0.999 || share > 1.001 {
+		t.Errorf("Expected quartile shares to add up to 1, got %v", res.QuartileShare)
+	}
+
+	// Same seed, same run
+	again, err := Simulate(simCountries(16), opts)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if !reflect.DeepEqual(again.Checkpoints, res.Checkpoints) {
+		t.Errorf("Expected a seeded simulation to repeat, got %+v and %+v", res.Checkpoints, again.Checkpoints)
+	}
+
+	if _, err := Simulate(simCountries(1), opts); err != ErrNotEnoughCountries {
+		t.Errorf("Expected ErrNotEnoughCountries, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package lyrics
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImportStats summarises a local import
+type ImportStats struct {
+	Imported int
+	Skipped  []string // files that failed, with the reason
+}
+
+// ImportPath imports one lyrics file, or every *.txt file below a directory.
+// A file starts with "key: value" headers, then a "---" line, then the
+// verses separated by blank lines:
+//
+//	country: fra
+//	lang: en
+//	kind: translation
+//	copyright: licensed
+//	license: CC BY-SA 4.0
+//	attribution: Translated by the Anthem World editors
+//	source-url: https://example.org/marseillaise
+//	---
+//	Arise, children of the Fatherland,
+//	The day of glory has arrived!
+//
+//	Do you hear, in the countryside, ...
+//
+// country is an ISO alpha-2 or alpha-3 code. script defaults to the one
+// detected in the text, kind to "translation" and copyright to "unknown",
+// which keeps the text out of exports.
+func ImportPath(db *sql.DB, path string) (ImportStats, error) {
+	var stats ImportStats
+	info, err := os.Stat(path)
+	if err != nil {
+		return stats, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(p, ".txt") {
+				files = append(files, p)
+			}
+			return err
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	for _, file := range files {
+		if err := importFile(db, file); err != nil {
+			stats.Skipped = append(stats.Skipped, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+		stats.Imported++
+	}
+	return stats, nil
+}
+
+func importFile(db *sql.DB, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	headers, body, err := parseLyricsFile(string(data))
+	if err != nil {
+		return err
+	}
+
+	country := headers["country"]
+	if country == "" {
+		return fmt.Errorf("missing country header")
+	}
+	var anthemID int
+	err = db.QueryRow(`
+		SELECT a.id FROM anthems a JOIN countries c ON c.id = a.country_id
+		WHERE LOWER(c.id) = LOWER(?) OR LOWER(c.iso_alpha2) = LOWER(?) OR LOWER(c.iso_alpha3) = LOWER(?)
+		ORDER BY a.id LIMIT 1
+	`, country, country, country).Scan(&anthemID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no anthem for country %q", country)
+	} else if err != nil {
+		return err
+	}
+
+	v := &Version{
+		AnthemID:        anthemID,
+		Lang:            headers["lang"],
+		Script:          headers["script"],
+		Kind:            orDefault(headers["kind"], KindTranslation),
+		Verses:          SplitVerses(body),
+		Source:          SourceLocal,
+		SourceURL:       headers["source-url"],
+		License:         headers["license"],
+		CopyrightStatus: orDefault(headers["copyright"], StatusUnknown),
+		Attribution:     headers["attribution"],
+	}
+	_, err = Store(db, v)
+	return err
+}
+
+// parseLyricsFile splits a lyrics file into its headers (keys lowercased)
+// and the text after the "---" line
+func parseLyricsFile(data string) (map[string]string, string, error) {
+	head, body, ok := strings.Cut(strings.ReplaceAll(data, "\r\n", "\n"), "\n---\n")
+	if !ok {
+		return nil, "", fmt.Errorf(`missing "---" line after the headers`)
+	}
+	headers := map[string]string{}
+	for i, line := range strings.Split(head, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, "", fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		headers[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return headers, body, nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
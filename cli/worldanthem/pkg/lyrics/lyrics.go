@@ -0,0 +1,222 @@
+package lyrics
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Kinds of lyrics stored in lyrics.kind
+const (
+	KindOriginal        = "original"        // the text as adopted, in its own language and script
+	KindTransliteration = "transliteration" // the original in another script, e.g. romanised Japanese
+	KindTranslation     = "translation"
+)
+
+// Copyright statuses stored in lyrics.copyright_status. Only public-domain
+// and licensed texts are exported.
+const (
+	StatusPublicDomain = "public-domain"
+	StatusLicensed     = "licensed" // free licence, see lyrics.license
+	StatusCopyrighted  = "copyrighted"
+	StatusUnknown      = "unknown"
+)
+
+// Sources stored in lyrics.source
+const (
+	SourceWikisource = "wikisource"
+	SourceLocal      = "local"
+)
+
+// Version is one text of an anthem: a language, script and kind with its
+// verses and provenance
+type Version struct {
+	AnthemID        int
+	Lang            string // BCP 47, e.g. "fr", "ja"
+	Script          string // ISO 15924, e.g. "Latn", "Jpan"
+	Kind            string
+	Verses          []string // stanzas, lines separated by "\n"
+	Source          string
+	SourceURL       string
+	License         string
+	CopyrightStatus string
+	Attribution     string
+}
+
+// Exportable reports whether a text may be published with the given status
+func Exportable(status string) bool {
+	return status == StatusPublicDomain || status == StatusLicensed
+}
+
+// Validate checks the fields Store relies on
+func (v *Version) Validate() error {
+	switch {
+	case v.AnthemID == 0:
+		return fmt.Errorf("no anthem")
+	case v.Lang == "":
+		return fmt.Errorf("no language")
+	case len(v.Verses) == 0:
+		return fmt.Errorf("no verses")
+	}
+	switch v.Kind {
+	case KindOriginal, KindTransliteration, KindTranslation:
+	default:
+		return fmt.Errorf("unknown kind %q", v.Kind)
+	}
+	switch v.CopyrightStatus {
+	case StatusPublicDomain, StatusCopyrighted, StatusUnknown:
+	case StatusLicensed:
+		if v.License == "" {
+			return fmt.Errorf("licensed text without a licence")
+		}
+	default:
+		return fmt.Errorf("unknown copyright status %q", v.CopyrightStatus)
+	}
+	return nil
+}
+
+// Store inserts or replaces a version, keyed by anthem, language, script and
+// kind, and replaces its verses. A version from Wikisource never overwrites
+// one imported locally, since curated texts are edited on purpose.
+// It reports whether anything was written.
+func Store(db *sql.DB, v *Version) (bool, error) {
+	if v.Script == "" {
+		v.Script = DetectScript(strings.Join(v.Verses, "\n"))
+	}
+	if err := v.Validate(); err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var source string
+	err = tx.QueryRow(`SELECT id, source FROM lyrics WHERE anthem_id = ? AND lang = ? AND script = ? AND kind = ?`,
+		v.AnthemID, v.Lang, v.Script, v.Kind).Scan(&id, &source)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec(`
+			INSERT INTO lyrics (anthem_id, lang, script, kind, source, source_url, license, copyright_status, attribution)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, v.AnthemID, v.Lang, v.Script, v.Kind, v.Source, nullIfEmpty(v.SourceURL), nullIfEmpty(v.License),
+			v.CopyrightStatus, nullIfEmpty(v.Attribution))
+		if err != nil {
+			return false, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	case source == SourceLocal && v.Source != SourceLocal:
+		return false, nil
+	default:
+		if _, err := tx.Exec(`
+			UPDATE lyrics SET source = ?, source_url = ?, license = ?, copyright_status = ?, attribution = ?,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, v.Source, nullIfEmpty(v.SourceURL), nullIfEmpty(v.License), v.CopyrightStatus,
+			nullIfEmpty(v.Attribution), id); err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(`DELETE FROM lyric_verses WHERE lyrics_id = ?`, id); err != nil {
+			return false, err
+		}
+	}
+
+	for i, verse := range v.Verses {
+		if _, err := tx.Exec(`INSERT INTO lyric_verses (lyrics_id, position, text) VALUES (?, ?, ?)`,
+			id, i+1, verse); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// SplitVerses splits text into stanzas at blank lines, trimming each line
+func SplitVerses(text string) []string {
+	var verses []string
+	var lines []string
+	flush := func() {
+		if len(lines) > 0 {
+			verses = append(verses, strings.Join(lines, "\n"))
+			lines = nil
+		}
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return verses
+}
+
+// scripts maps Unicode script tables to ISO 15924 codes. Kana counts as
+// Japanese, since Japanese text mixes it with Han.
+var scripts = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"Latn", unicode.Latin},
+	{"Cyrl", unicode.Cyrillic},
+	{"Arab", unicode.Arabic},
+	{"Grek", unicode.Greek},
+	{"Hebr", unicode.Hebrew},
+	{"Deva", unicode.Devanagari},
+	{"Beng", unicode.Bengali},
+	{"Taml", unicode.Tamil},
+	{"Thai", unicode.Thai},
+	{"Geor", unicode.Georgian},
+	{"Armn", unicode.Armenian},
+	{"Ethi", unicode.Ethiopic},
+	{"Hang", unicode.Hangul},
+	{"Jpan", unicode.Hiragana},
+	{"Jpan", unicode.Katakana},
+	{"Hani", unicode.Han},
+}
+
+// DetectScript returns the ISO 15924 code of the script most letters of
+// text are written in, or "Zyyy" (undetermined) when there are none
+func DetectScript(text string) string {
+	counts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.code]++
+				break
+			}
+		}
+	}
+	// Han with any kana is Japanese
+	if counts["Jpan"] > 0 {
+		counts["Jpan"] += counts["Hani"]
+		delete(counts, "Hani")
+	}
+
+	best, bestCount := "Zyyy", 0
+	for _, s := range scripts {
+		if n := counts[s.code]; n > bestCount {
+			best, bestCount = s.code, n
+		}
+	}
+	return best
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
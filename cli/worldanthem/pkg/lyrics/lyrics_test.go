@@ -0,0 +1,101 @@
+package lyrics
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthemworld/cli/internal/testdb"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	return testdb.Open(t, `
+		INSERT INTO countries (id, name, iso_alpha2, iso_alpha3) VALUES ('fra', 'France', 'FR', 'FRA');
+		INSERT INTO anthems (country_id, name) VALUES ('fra', 'La Marseillaise');
+	`)
+}
+
+func TestDetectScript(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Allons enfants de la Patrie", "Latn"},
+		{"Россия — священная наша держава", "Cyrl"},
+		{"君が代は 千代に八千代に", "Jpan"},
+		{"义勇军进行曲", "Hani"},
+		{"동해 물과 백두산이", "Hang"},
+		{"بلادي بلادي", "Arab"},
+		{"123 !?", "Zyyy"},
+	}
+	for _, tt := range tests {
+		if got := DetectScript(tt.text); got != tt.want {
+			t.Errorf("DetectScript(%q): expected %s, got %s", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestSplitVerses(t *testing.T) {
+	verses := SplitVerses("  line one\r\nline two\n\n\n line three \n")
+	if len(verses) != 2 || verses[0] != "line one\nline two" || verses[1] != "line three" {
+		t.Errorf("Unexpected verses %q", verses)
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"fra-en.txt": "country: FR\nlang: en\ncopyright: licensed\nlicense: CC BY-SA 4.0\n---\nArise, children\nof the Fatherland\n\nThe day of glory\n",
+		"bad.txt":    "country: xyz\nlang: en\n---\nverse\n",
+		"notes.md":   "not a lyrics file",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := ImportPath(database, dir)
+	if err != nil {
+		t.Fatalf("ImportPath failed: %v", err)
+	}
+	if stats.Imported != 1 || len(stats.Skipped) != 1 || !strings.Contains(stats.Skipped[0], "bad.txt") {
+		t.Errorf("Expected 1 imported and bad.txt skipped, got %+v", stats)
+	}
+
+	var kind, script, status string
+	var verses int
+	err = database.QueryRow(`
+		SELECT l.kind, l.script, l.copyright_status, (SELECT COUNT(*) FROM lyric_verses WHERE lyrics_id = l.id)
+		FROM lyrics l WHERE l.lang = 'en'`).Scan(&kind, &script, &status, &verses)
+	if err != nil {
+		t.Fatalf("Failed to read imported lyrics: %v", err)
+	}
+	if kind != KindTranslation || script != "Latn" || status != StatusLicensed || verses != 2 {
+		t.Errorf("Unexpected import: kind=%s script=%s status=%s verses=%d", kind, script, status, verses)
+	}
+
+	// Wikisource must not replace a curated text
+	written, err := Store(database, &Version{
+		AnthemID: 1, Lang: "en", Kind: KindTranslation, Verses: []string{"other"},
+		Source: SourceWikisource, CopyrightStatus: StatusPublicDomain,
+	})
+	if err != nil || written {
+		t.Errorf("Expected Wikisource text to be skipped, got written=%v err=%v", written, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v := &Version{AnthemID: 1, Lang: "en", Kind: KindTranslation, Verses: []string{"x"}, CopyrightStatus: StatusLicensed}
+	if err := v.Validate(); err == nil {
+		t.Errorf("Expected licensed text without a licence to be rejected")
+	}
+	v.License = "CC0"
+	if err := v.Validate(); err != nil {
+		t.Errorf("Expected valid version, got %v", err)
+	}
+}
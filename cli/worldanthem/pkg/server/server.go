@@ -0,0 +1,508 @@
+package server
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/audio"
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+// Options configure a Server
+type Options struct {
+	// StaticDir, when set, is served for every path that is not an API
+	// route, e.g. hugo/site/static
+	StaticDir string
+	// AllowOrigin is sent as Access-Control-Allow-Origin; empty means "*"
+	AllowOrigin string
+	// AccessLog receives one line per request; nil disables it
+	AccessLog io.Writer
+}
+
+// Server is the read-only HTTP API behind `worldanthem serve`. It answers
+// from the database on every request, so frontend work sees changes without
+// re-running `data format`.
+type Server struct {
+	db   *sql.DB
+	opts Options
+	mux  *http.ServeMux
+
+	mu     sync.Mutex
+	played map[string]time.Time // client and recording → last counted play
+}
+
+// PlayWindow is how long a client replaying a recording from the start, or
+// a player re-requesting it with bytes=0-, counts as the same play
+const PlayWindow = 30 * time.Minute
+
+// New returns a Server reading from database
+func New(database *sql.DB, opts Options) *Server {
+	if opts.AllowOrigin == "" {
+		opts.AllowOrigin = "*"
+	}
+	s := &Server{db: database, opts: opts, mux: http.NewServeMux(), played: map[string]time.Time{}}
+
+	s.mux.HandleFunc("GET /countries", s.handleCountries)
+	s.mux.HandleFunc("GET /countries/{iso3}", s.handleCountry)
+	s.mux.HandleFunc("GET /anthems/search", s.handleSearch)
+	s.mux.HandleFunc("GET /audio/{id}", s.handleAudio)
+	s.mux.HandleFunc("GET /audio/{id}/stream", s.handleAudioStream)
+	s.mux.HandleFunc("GET /stats", s.handleStats)
+	s.mux.HandleFunc("GET /plays", s.handlePlays)
+	// What the site fetches, so it runs against the live database
+	s.mux.HandleFunc("GET /data/anthems.json", s.handleAnthemsJSON)
+	if opts.StaticDir != "" {
+		s.mux.Handle("GET /", http.FileServer(http.Dir(opts.StaticDir)))
+	}
+	return s
+}
+
+// ServeHTTP adds CORS headers and the access log around the routes. Only
+// GET, HEAD and OPTIONS are allowed; the mux answers anything else with 405.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	h := rec.Header()
+	h.Set("Access-Control-Allow-Origin", s.opts.AllowOrigin)
+	h.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", "If-None-Match, Range")
+	h.Set("Access-Control-Expose-Headers", "ETag, Content-Length, Content-Range, Accept-Ranges")
+	if s.opts.AllowOrigin != "*" {
+		h.Add("Vary", "Origin")
+	}
+
+	if r.Method == http.MethodOptions {
+		h.Set("Access-Control-Max-Age", "86400")
+		rec.WriteHeader(http.StatusNoContent)
+	} else {
+		s.mux.ServeHTTP(rec, r)
+	}
+
+	if s.opts.AccessLog != nil {
+		note := ""
+		if rec.play != "" {
+			note = " play=" + rec.play
+		}
+		fmt.Fprintf(s.opts.AccessLog, "%s %s %s %d %d %s%s\n", start.Format(time.RFC3339), r.Method,
+			r.URL.RequestURI(), rec.status, rec.bytes, time.Since(start).Round(time.Millisecond), note)
+	}
+}
+
+// handleCountries lists countries, optionally filtered like `data format`:
+// ?region=Europe,Oceania&subregion=…&countries=usa,fra&has_audio=1&has_anthem=1&un_member=1
+func (s *Server) handleCountries(w http.ResponseWriter, r *http.Request) {
+	countries, err := format.Countries(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := format.Filter{
+		Regions:    listParam(q.Get("region")),
+		Subregions: listParam(q.Get("subregion")),
+		Countries:  listParam(q.Get("countries")),
+	}
+	for name, dst := range map[string]*bool{
+		"has_audio":  &filter.HasAudio,
+		"has_anthem": &filter.HasAnthem,
+		"un_member":  &filter.UNMembersOnly,
+	} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", name, v))
+				return
+			}
+		}
+	}
+	countries, err = filter.Apply(countries)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if countries == nil {
+		countries = []format.CountryRecord{}
+	}
+	writeJSON(w, r, countries)
+}
+
+// handleCountry returns one country by ISO alpha-3 (or alpha-2) code
+func (s *Server) handleCountry(w http.ResponseWriter, r *http.Request) {
+	countries, err := format.Countries(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	c := format.FindCountry(countries, r.PathValue("iso3"))
+	if c == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no country with code %q", r.PathValue("iso3")))
+		return
+	}
+	writeJSON(w, r, c)
+}
+
+// SearchHit is one result of /anthems/search
+type SearchHit struct {
+	Country *format.CountryRecord `json:"country"`
+	Field   string                `json:"field,omitempty"`   // substring search: field that matched
+	Snippet string                `json:"snippet,omitempty"` // full-text search: hits wrapped in [ ]
+	Rank    float64               `json:"rank,omitempty"`    // full-text search: bm25, lower is better
+}
+
+// SearchResponse is the body of /anthems/search
+type SearchResponse struct {
+	Query    string      `json:"query"`
+	FullText bool        `json:"full_text"` // false when SQLite has no FTS5 or the index is not built
+	Results  []SearchHit `json:"results"`
+}
+
+// handleSearch searches anthems: ?q=marseillaise&limit=20. It uses the
+// full-text index when it is built (serve builds it at startup), else a
+// substring search. Requests never write to the database.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing q"))
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %q", v))
+			return
+		}
+		limit = n
+	}
+
+	countries, err := format.Countries(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := SearchResponse{Query: query, FullText: db.SearchIndexReady(s.db), Results: []SearchHit{}}
+	if resp.FullText {
+		results, err := db.SearchIndexed(s.db, query, limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		for _, res := range results {
+			if c := format.FindCountry(countries, res.CountryID); c != nil {
+				resp.Results = append(resp.Results, SearchHit{Country: c, Snippet: res.Snippet, Rank: res.Rank})
+			}
+		}
+	} else {
+		for _, m := range format.SearchAnthems(countries, query) {
+			if len(resp.Results) == limit {
+				break
+			}
+			resp.Results = append(resp.Results, SearchHit{Country: m.Country, Field: m.Field})
+		}
+	}
+	writeJSON(w, r, resp)
+}
+
+// AudioResponse is the body of /audio/{id}: the recording as exported, with
+// its country and, when it is mirrored, where to stream it from
+type AudioResponse struct {
+	CountryID string `json:"country_id"`
+	StreamURL string `json:"stream_url,omitempty"`
+	format.AudioRecord
+}
+
+// handleAudio returns one recording by ID
+func (s *Server) handleAudio(w http.ResponseWriter, r *http.Request) {
+	countries, err := format.Countries(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	id := r.PathValue("id")
+	for i := range countries {
+		for _, a := range countries[i].AudioFiles {
+			if a.ID == id {
+				resp := AudioResponse{CountryID: countries[i].ID, AudioRecord: a}
+				var localPath string
+				s.db.QueryRow(`SELECT COALESCE(local_path,'') FROM audio_recordings WHERE id = ?`, id).Scan(&localPath)
+				if localPath != "" {
+					resp.StreamURL = "/audio/" + url.PathEscape(id) + "/stream"
+				}
+				writeJSON(w, r, resp)
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("no recording with ID %q", id))
+}
+
+// handleAudioStream serves the mirrored file of a recording. Range requests
+// let players seek, the SHA-1 of the content is its ETag, and the media type
+// comes from audio_recordings.format. A GET from the first byte counts as a
+// play of the recording's country, once per client and recording within
+// PlayWindow; seeks and Safari's bytes=0-1 probe do not.
+func (s *Server) handleAudioStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var countryID, localPath, sha1, audioFormat string
+	err := s.db.QueryRow(`
+		SELECT country_id, COALESCE(local_path,''), COALESCE(sha1,''), COALESCE(format,'')
+		FROM audio_recordings WHERE id = ?
+	`, id).Scan(&countryID, &localPath, &sha1, &audioFormat)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no recording with ID %q", id))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if localPath == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("recording %q is not mirrored (run: worldanthem data audio mirror)", id))
+		return
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("mirrored file of %q is missing: %w", id, err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", audio.ContentType(audioFormat, localPath))
+	h.Set("Cache-Control", "no-cache")
+	if sha1 != "" {
+		h.Set("ETag", `"`+sha1+`"`)
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	http.ServeContent(rec, r, "", info.ModTime(), f)
+
+	if r.Method != http.MethodGet || (rec.status != http.StatusOK && rec.status != http.StatusPartialContent) ||
+		!fromFirstByte(r.Header.Get("Range")) || !s.newPlay(r, id, time.Now()) {
+		return
+	}
+	if err := db.RecordPlay(s.db, countryID, id, time.Now()); err != nil {
+		if s.opts.AccessLog != nil {
+			fmt.Fprintf(s.opts.AccessLog, "failed to record play of %s: %v\n", id, err)
+		}
+		return
+	}
+	if outer, ok := w.(*statusRecorder); ok {
+		outer.play = countryID
+	}
+}
+
+// fromFirstByte reports whether a Range header (or its absence) asks for a
+// recording from the start
+func fromFirstByte(rangeHeader string) bool {
+	return rangeHeader == "" || (strings.HasPrefix(rangeHeader, "bytes=0-") && rangeHeader != "bytes=0-1")
+}
+
+// newPlay reports whether a request starts a play the client has not been
+// counted for within PlayWindow. Clients are told apart by address and
+// User-Agent.
+func (s *Server) newPlay(r *http.Request, recordingID string, now time.Time) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	key := host + "\x00" + r.UserAgent() + "\x00" + recordingID
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.played[key]; ok && now.Sub(last) < PlayWindow {
+		return false
+	}
+	for k, last := range s.played {
+		if now.Sub(last) >= PlayWindow {
+			delete(s.played, k)
+		}
+	}
+	s.played[key] = now
+	return true
+}
+
+// PlaysResponse is the body of /plays
+type PlaysResponse struct {
+	Since     string         `json:"since,omitempty"`
+	Countries []db.PlayCount `json:"countries"`
+}
+
+// handlePlays returns plays per country, most played first: ?since=2026-01-01
+func (s *Server) handlePlays(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = time.Parse("2006-01-02", v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q, want YYYY-MM-DD", v))
+			return
+		}
+	}
+	counts, err := db.GetPlayCounts(s.db, since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := PlaysResponse{Since: r.URL.Query().Get("since"), Countries: counts}
+	if resp.Countries == nil {
+		resp.Countries = []db.PlayCount{}
+	}
+	writeJSON(w, r, resp)
+}
+
+// StatsResponse is the body of /stats
+type StatsResponse struct {
+	SchemaVersion int `json:"schema_version"` // export schema, see format.ExportSchemaVersion
+	format.Totals
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	countries, err := format.Countries(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, r, StatsResponse{SchemaVersion: format.ExportSchemaVersion, Totals: format.CountTotals(countries)})
+}
+
+// handleAnthemsJSON answers /data/anthems.json as `data format` would write
+// it with an audio base URL: mirrored recordings play from /audio/{id}/stream
+func (s *Server) handleAnthemsJSON(w http.ResponseWriter, r *http.Request) {
+	countries, err := format.Countries(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := s.rewriteStreamURLs(countries); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, r, format.ByKey(countries))
+}
+
+// rewriteStreamURLs points mirrored recordings at their stream route. The
+// derived preview and peaks files are not served, so their URLs are dropped.
+func (s *Server) rewriteStreamURLs(countries []format.CountryRecord) error {
+	rows, err := s.db.Query(`SELECT id FROM audio_recordings WHERE local_path IS NOT NULL AND local_path != ''`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	mirrored := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		mirrored[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range countries {
+		for j := range countries[i].AudioFiles {
+			a := &countries[i].AudioFiles[j]
+			if mirrored[a.ID] {
+				a.URL = "/audio/" + url.PathEscape(a.ID) + "/stream"
+			}
+			a.PreviewURL, a.PeaksURL = "", ""
+		}
+	}
+	return nil
+}
+
+// writeJSON writes v with an ETag of its content, or 304 Not Modified when
+// the client already has it. Responses are revalidated on every use, so an
+// unchanged database costs a round trip but no body.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(body)+1))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header names etag, using the
+// weak comparison RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// listParam splits a comma-separated query parameter
+func listParam(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// statusRecorder remembers the status and size of a response for the log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	play   string // country whose play the request counted
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
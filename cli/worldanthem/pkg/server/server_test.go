@@ -0,0 +1,266 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthemworld/cli/internal/testdb"
+	"github.com/anthemworld/cli/pkg/db"
+	"github.com/anthemworld/cli/pkg/format"
+)
+
+// setupTestDB opens a fully migrated database under a temporary HOME with
+// France (anthem, one recording) and Antarctica.
+func setupTestDB(t *testing.T) *sql.DB {
+	return testdb.Open(t, `
+		INSERT INTO countries (id, name, common_name, iso_alpha2, iso_alpha3, un_member, region)
+		VALUES ('fra', 'French Republic', 'France', 'FR', 'FRA', 1, 'Europe'),
+		       ('ata', 'Antarctica', 'Antarctica', 'AQ', 'ATA', 0, 'Antarctic');
+		INSERT INTO anthems (country_id, name, composer) VALUES ('fra', 'La Marseillaise', 'Rouget de Lisle');
+		INSERT INTO audio_recordings (id, country_id, title, url, format, type, source)
+		VALUES ('rec-a', 'fra', 'La Marseillaise.ogg', 'https://example.org/a.ogg', 'ogg', 'vocal', 'wikimedia-commons');
+	`)
+}
+
+func get(t *testing.T, h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCountries(t *testing.T) {
+	s := New(setupTestDB(t), Options{})
+
+	rec := get(t, s, "/countries?un_member=1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected CORS origin *, got %q", got)
+	}
+	var countries []format.CountryRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &countries); err != nil {
+		t.Fatalf("Failed to decode countries: %v", err)
+	}
+	if len(countries) != 1 || countries[0].ID != "fra" {
+		t.Errorf("Expected only France, got %+v", countries)
+	}
+
+	rec = get(t, s, "/countries/fra", nil)
+	var c format.CountryRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatalf("Failed to decode country: %v", err)
+	}
+	if c.Anthem == nil || c.Anthem.Name != "La Marseillaise" || len(c.AudioFiles) != 1 {
+		t.Errorf("Expected France with anthem and recording, got %+v", c)
+	}
+
+	if rec := get(t, s, "/countries/xyz", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown country, got %d", rec.Code)
+	}
+	if rec := get(t, s, "/countries?has_audio=maybe", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid has_audio, got %d", rec.Code)
+	}
+}
+
+func TestETag(t *testing.T) {
+	database := setupTestDB(t)
+	s := New(database, Options{})
+
+	rec := get(t, s, "/stats", nil)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+	if !strings.Contains(rec.Body.String(), `"total_anthems":1`) {
+		t.Errorf("Expected one anthem in stats, got %s", rec.Body)
+	}
+
+	rec = get(t, s, "/stats", http.Header{"If-None-Match": {`"other", ` + etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Expected 304 without body, got %d (%d bytes)", rec.Code, rec.Body.Len())
+	}
+
+	// A database change changes the ETag
+	if _, err := database.Exec(`DELETE FROM anthems`); err != nil {
+		t.Fatalf("Failed to delete anthem: %v", err)
+	}
+	rec = get(t, s, "/stats", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with a new ETag after a change, got %d", rec.Code)
+	}
+}
+
+func TestSearchAndAudio(t *testing.T) {
+	s := New(setupTestDB(t), Options{})
+
+	var resp SearchResponse
+	rec := get(t, s, "/anthems/search?q=marseillaise", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode search: %v (%s)", err, rec.Body)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Country.ID != "fra" {
+		t.Errorf("Expected France, got %+v", resp.Results)
+	}
+	if rec := get(t, s, "/anthems/search", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without q, got %d", rec.Code)
+	}
+
+	var audio AudioResponse
+	rec = get(t, s, "/audio/rec-a", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &audio); err != nil {
+		t.Fatalf("Failed to decode audio: %v", err)
+	}
+	if audio.CountryID != "fra" || audio.URL != "https://example.org/a.ogg" {
+		t.Errorf("Expected rec-a of France, got %+v", audio)
+	}
+}
+
+func TestSearchWithoutIndex(t *testing.T) {
+	database := setupTestDB(t)
+	s := New(database, Options{})
+
+	// Without the index's triggers a request falls back to substring search
+	// rather than rebuilding the index
+	_, err := database.Exec(`
+		DROP TRIGGER IF EXISTS search_countries_ai;
+		INSERT INTO meta (key, value) VALUES ('search_index_stale', '2026-01-01T00:00:00Z');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to mark the index stale: %v", err)
+	}
+
+	var resp SearchResponse
+	rec := get(t, s, "/anthems/search?q=marseillaise", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode search: %v (%s)", err, rec.Body)
+	}
+	if resp.FullText || len(resp.Results) != 1 || resp.Results[0].Field == "" {
+		t.Errorf("Expected one substring match, got %+v", resp)
+	}
+	if since, err := db.SearchIndexStale(database); err != nil || since == "" {
+		t.Errorf("Expected the request to leave the index stale, got %q (%v)", since, err)
+	}
+}
+
+func TestStaticAndMethods(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644)
+	s := New(setupTestDB(t), Options{StaticDir: dir, AllowOrigin: "http://localhost:1313"})
+
+	rec := get(t, s, "/hello.txt", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("Expected static file, got %d %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:1313" {
+		t.Errorf("Expected configured CORS origin, got %q", got)
+	}
+
+	// The site's data file comes from the database, not the static tree
+	rec = get(t, s, "/data/anthems.json", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"FRA"`) {
+		t.Errorf("Expected live anthems.json, got %d", rec.Code)
+	}
+
+	for method, want := range map[string]int{
+		http.MethodOptions: http.StatusNoContent,
+		http.MethodPost:    http.StatusMethodNotAllowed,
+	} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(method, "/countries", nil))
+		if rec.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, method, rec.Code)
+		}
+	}
+}
+
+func TestAudioStream(t *testing.T) {
+	database := setupTestDB(t)
+	s := New(database, Options{})
+
+	file := filepath.Join(t.TempDir(), "a.ogg")
+	os.WriteFile(file, []byte("OggS0123456789"), 0644)
+	if _, err := database.Exec(`UPDATE audio_recordings SET local_path = ?, sha1 = 'abc123', format = 'audio/ogg' WHERE id = 'rec-a'`, file); err != nil {
+		t.Fatalf("Failed to mirror recording: %v", err)
+	}
+
+	rec := get(t, s, "/audio/rec-a/stream", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "OggS0123456789" {
+		t.Fatalf("Expected the whole file, got %d %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "audio/ogg" {
+		t.Errorf("Expected audio/ogg, got %q", got)
+	}
+	if got := rec.Header().Get("ETag"); got != `"abc123"` {
+		t.Errorf("Expected SHA-1 ETag, got %q", got)
+	}
+
+	// Seeking is a partial response and not another play
+	rec = get(t, s, "/audio/rec-a/stream", http.Header{"Range": {"bytes=4-7"}})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "0123" {
+		t.Errorf("Expected 206 with bytes 4-7, got %d %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 4-7/14" {
+		t.Errorf("Expected Content-Range bytes 4-7/14, got %q", got)
+	}
+	rec = get(t, s, "/audio/rec-a/stream", http.Header{"If-None-Match": {`"abc123"`}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	// A player re-requesting from the first byte is the same play
+	get(t, s, "/audio/rec-a/stream", http.Header{"Range": {"bytes=0-"}})
+	get(t, s, "/audio/rec-a/stream", nil)
+
+	var plays PlaysResponse
+	rec = get(t, s, "/plays", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &plays); err != nil {
+		t.Fatalf("Failed to decode plays: %v", err)
+	}
+	if len(plays.Countries) != 1 || plays.Countries[0].CountryID != "fra" || plays.Countries[0].Plays != 1 {
+		t.Errorf("Expected one play for France, got %+v", plays.Countries)
+	}
+
+	// Another client is another play
+	req := httptest.NewRequest(http.MethodGet, "/audio/rec-a/stream", nil)
+	req.RemoteAddr = "198.51.100.7:4321"
+	s.ServeHTTP(httptest.NewRecorder(), req)
+	json.Unmarshal(get(t, s, "/plays", nil).Body.Bytes(), &plays)
+	if len(plays.Countries) != 1 || plays.Countries[0].Plays != 2 {
+		t.Errorf("Expected a second play from another client, got %+v", plays.Countries)
+	}
+	if s.newPlay(req, "rec-a", time.Now().Add(PlayWindow)) != true {
+		t.Error("Expected a play after PlayWindow to count again")
+	}
+
+	var anthems map[string]format.CountryRecord
+	json.Unmarshal(get(t, s, "/data/anthems.json", nil).Body.Bytes(), &anthems)
+	if c, ok := anthems["FRA"]; !ok || len(c.AudioFiles) != 1 || c.AudioFiles[0].URL != "/audio/rec-a/stream" {
+		t.Errorf("Expected anthems.json to stream the mirrored recording, got %+v", c.AudioFiles)
+	}
+
+	var audio AudioResponse
+	json.Unmarshal(get(t, s, "/audio/rec-a", nil).Body.Bytes(), &audio)
+	if audio.StreamURL != "/audio/rec-a/stream" {
+		t.Errorf("Expected stream URL for a mirrored recording, got %q", audio.StreamURL)
+	}
+
+	if _, err := database.Exec(`UPDATE audio_recordings SET local_path = NULL`); err != nil {
+		t.Fatalf("Failed to unmirror recording: %v", err)
+	}
+	if rec := get(t, s, "/audio/rec-a/stream", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unmirrored recording, got %d", rec.Code)
+	}
+}
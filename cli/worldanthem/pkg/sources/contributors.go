@@ -0,0 +1,300 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/dates"
+	"github.com/anthemworld/cli/pkg/jobs"
+)
+
+// Contributor roles stored in anthem_contributors.role, with the Wikidata
+// property each is read from
+const (
+	RoleComposer = "composer" // P86
+	RoleLyricist = "lyricist" // P676
+)
+
+var contributorProperties = []struct {
+	role     string
+	property string
+}{
+	{RoleComposer, "P86"},
+	{RoleLyricist, "P676"},
+}
+
+// ContributorStats summarises a contributor fetch
+type ContributorStats struct {
+	Anthems      int // anthems with at least one contributor
+	People       int // people stored
+	AdoptedDates int // adoption dates set or made more precise
+}
+
+// wikidataTime is the value of a time statement or qualifier
+type wikidataTime struct {
+	Time      string `json:"time"`
+	Precision int    `json:"precision"`
+}
+
+// FetchContributors reads the composers (P86) and lyricists (P676) of every
+// anthem with a Wikidata item into people and anthem_contributors, with
+// birth and death years. The adoption date comes from the start time (P580)
+// of the country's anthem statement (P85), else the anthem's inception
+// (P571), and replaces adopted_on when it is more precise. Free-text composer
+// and lyricist columns that are empty or hold a Wikidata URL are set to the
+// contributors' names. It is safe to re-run.
+func FetchContributors(ctx context.Context, db *sql.DB, logger *jobs.JobLogger) (ContributorStats, error) {
+	var stats ContributorStats
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	type anthem struct {
+		id          int
+		qid         string
+		countryQID  string
+		adoptedOn   string
+		adoptedPrec string
+		composer    string
+		lyricist    string
+	}
+	rows, err := db.Query(`
+		SELECT a.id, a.wikidata_id, COALESCE(c.wikidata_id,''), COALESCE(a.adopted_on,''), COALESCE(a.adopted_precision,''),
+			COALESCE(a.composer,''), COALESCE(a.lyricist,'')
+		FROM anthems a JOIN countries c ON c.id = a.country_id
+		WHERE a.wikidata_id IS NOT NULL AND a.wikidata_id != ''
+		ORDER BY a.id
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query anthems: %w", err)
+	}
+	var anthems []anthem
+	for rows.Next() {
+		var a anthem
+		if err := rows.Scan(&a.id, &a.qid, &a.countryQID, &a.adoptedOn, &a.adoptedPrec, &a.composer, &a.lyricist); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		anthems = append(anthems, a)
+	}
+	rows.Close()
+
+	anthemQIDs := make([]string, 0, len(anthems))
+	countryQIDs := map[string]bool{}
+	for _, a := range anthems {
+		anthemQIDs = append(anthemQIDs, a.qid)
+		if a.countryQID != "" {
+			countryQIDs[a.countryQID] = true
+		}
+	}
+	logger.Infof("Fetching contributors for %d anthems", len(anthems))
+
+	items, err := fetchWikidataBatched(ctx, client, anthemQIDs, "claims")
+	if err != nil {
+		return stats, fmt.Errorf("failed to fetch anthems: %w", err)
+	}
+	countries, err := fetchWikidataBatched(ctx, client, setKeys(countryQIDs), "claims")
+	if err != nil {
+		return stats, fmt.Errorf("failed to fetch countries: %w", err)
+	}
+
+	// Contributors of each anthem by role, in statement order
+	contributors := make(map[string]map[string][]string)
+	personQIDs := map[string]bool{}
+	for _, a := range anthems {
+		e, ok := items.Entities[a.qid]
+		if !ok {
+			continue
+		}
+		byRole := map[string][]string{}
+		for _, p := range contributorProperties {
+			for _, qid := range orderedItemIDs(e.Claims[p.property]) {
+				byRole[p.role] = append(byRole[p.role], qid)
+				personQIDs[qid] = true
+			}
+		}
+		contributors[a.qid] = byRole
+	}
+
+	people, err := fetchWikidataBatched(ctx, client, setKeys(personQIDs), "labels|claims")
+	if err != nil {
+		return stats, fmt.Errorf("failed to fetch people: %w", err)
+	}
+	names := map[string]string{}
+	for _, qid := range setKeys(personQIDs) {
+		e := people.Entities[qid]
+		name := e.Labels["en"].Value
+		names[qid] = name
+		birth, _ := claimYear(e.Claims["P569"])
+		death, _ := claimYear(e.Claims["P570"])
+		if _, err := db.Exec(`
+			INSERT INTO people (id, name, birth_year, death_year, updated_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(id) DO UPDATE SET name = COALESCE(excluded.name, people.name),
+				birth_year = excluded.birth_year, death_year = excluded.death_year, updated_at = excluded.updated_at
+		`, qid, nullIfEmpty(name), nullIfZero(birth), nullIfZero(death)); err != nil {
+			return stats, fmt.Errorf("failed to store person: %w", err)
+		}
+		stats.People++
+	}
+
+	for _, a := range anthems {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return stats, err
+		}
+		if err := storeContributors(tx, a.id, contributors[a.qid]); err != nil {
+			tx.Rollback()
+			return stats, fmt.Errorf("failed to store contributors: %w", err)
+		}
+		if len(contributors[a.qid]) > 0 {
+			stats.Anthems++
+		}
+
+		// Keep the free-text columns readable for older consumers
+		for role, current := range map[string]string{RoleComposer: a.composer, RoleLyricist: a.lyricist} {
+			qids := contributors[a.qid][role]
+			if len(qids) == 0 || (current != "" && !strings.Contains(current, "wikidata.org/")) {
+				continue
+			}
+			var list []string
+			for _, qid := range qids {
+				if names[qid] != "" {
+					list = append(list, names[qid])
+				}
+			}
+			if len(list) == 0 {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE anthems SET `+role+` = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+				strings.Join(list, ", "), a.id); err != nil {
+				tx.Rollback()
+				return stats, err
+			}
+		}
+
+		if d, ok := adoptionDate(countries.Entities[a.countryQID].Claims["P85"], a.qid, items.Entities[a.qid].Claims["P571"]); ok &&
+			precisionRank(d.Precision) > precisionRank(a.adoptedPrec) {
+			if _, err := tx.Exec(`UPDATE anthems SET adopted_on = ?, adopted_precision = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+				d.Value, d.Precision, a.id); err != nil {
+				tx.Rollback()
+				return stats, err
+			}
+			stats.AdoptedDates++
+		}
+		if err := tx.Commit(); err != nil {
+			return stats, err
+		}
+	}
+
+	logger.Infof("✓ Stored %d people for %d anthems, %d adoption dates", stats.People, stats.Anthems, stats.AdoptedDates)
+	return stats, nil
+}
+
+// storeContributors replaces the composers and lyricists of an anthem
+func storeContributors(tx *sql.Tx, anthemID int, byRole map[string][]string) error {
+	if len(byRole) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(`DELETE FROM anthem_contributors WHERE anthem_id = ? AND role IN (?, ?)`,
+		anthemID, RoleComposer, RoleLyricist); err != nil {
+		return err
+	}
+	for role, qids := range byRole {
+		for i, qid := range qids {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO anthem_contributors (anthem_id, person_id, role, position) VALUES (?, ?, ?, ?)`,
+				anthemID, qid, role, i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// orderedItemIDs returns the item values of a property's statements, sorted
+// by their series ordinal (P1545) qualifier when they have one
+func orderedItemIDs(claims []wikidataClaim) []string {
+	type ordered struct {
+		id  string
+		ord int
+	}
+	var items []ordered
+	for i, c := range claims {
+		ids := claimItemIDs([]wikidataClaim{c})
+		if len(ids) == 0 {
+			continue
+		}
+		ord := len(claims) + i
+		for _, q := range c.Qualifiers["P1545"] {
+			var s string
+			if json.Unmarshal(q.DataValue.Value, &s) == nil {
+				if n, err := strconv.Atoi(s); err == nil {
+					ord = n
+				}
+			}
+		}
+		items = append(items, ordered{ids[0], ord})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].ord < items[j].ord })
+	out := make([]string, len(items))
+	for i, it := range items {
+		out[i] = it.id
+	}
+	return out
+}
+
+// adoptionDate prefers the start time (P580) qualifier on the country's
+// anthem statement (P85) for this anthem, then the anthem's inception (P571)
+func adoptionDate(countryAnthems []wikidataClaim, anthemQID string, inception []wikidataClaim) (dates.Date, bool) {
+	for _, c := range countryAnthems {
+		if ids := claimItemIDs([]wikidataClaim{c}); len(ids) == 0 || ids[0] != anthemQID {
+			continue
+		}
+		for _, q := range c.Qualifiers["P580"] {
+			var t wikidataTime
+			if json.Unmarshal(q.DataValue.Value, &t) == nil {
+				if d, ok := dates.FromWikidata(t.Time, t.Precision); ok {
+					return d, true
+				}
+			}
+		}
+	}
+	for _, c := range inception {
+		var t wikidataTime
+		if json.Unmarshal(c.Mainsnak.DataValue.Value, &t) == nil {
+			if d, ok := dates.FromWikidata(t.Time, t.Precision); ok {
+				return d, true
+			}
+		}
+	}
+	return dates.Date{}, false
+}
+
+// claimYear returns the year of the first time statement of a property
+func claimYear(claims []wikidataClaim) (int, bool) {
+	for _, c := range claims {
+		var t wikidataTime
+		if json.Unmarshal(c.Mainsnak.DataValue.Value, &t) == nil {
+			if year, ok := dates.WikidataYear(t.Time); ok {
+				return year, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func precisionRank(p string) int {
+	switch p {
+	case dates.PrecisionDay:
+		return 3
+	case dates.PrecisionMonth:
+		return 2
+	case dates.PrecisionYear:
+		return 1
+	}
+	return 0
+}
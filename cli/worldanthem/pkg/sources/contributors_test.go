@@ -0,0 +1,71 @@
+package sources
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func parseClaims(t *testing.T, s string) []wikidataClaim {
+	t.Helper()
+	var claims []wikidataClaim
+	if err := json.Unmarshal([]byte(s), &claims); err != nil {
+		t.Fatalf("Invalid claims: %v", err)
+	}
+	return claims
+}
+
+func TestOrderedItemIDs(t *testing.T) {
+	// Ordinals sort, statements without one keep their order after them, and
+	// statements without an item value are dropped
+	claims := parseClaims(t, `[
+		{"mainsnak":{"datavalue":{"value":{"id":"Q3"}}}},
+		{"mainsnak":{"datavalue":{"value":{"id":"Q2"}}},"qualifiers":{"P1545":[{"datavalue":{"value":"2"}}]}},
+		{"mainsnak":{}},
+		{"mainsnak":{"datavalue":{"value":{"id":"Q4"}}}},
+		{"mainsnak":{"datavalue":{"value":{"id":"Q1"}}},"qualifiers":{"P1545":[{"datavalue":{"value":"1"}}]}}
+	]`)
+	if got, want := orderedItemIDs(claims), []string{"Q1", "Q2", "Q3", "Q4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := orderedItemIDs(nil); len(got) != 0 {
+		t.Errorf("Expected no IDs, got %v", got)
+	}
+}
+
+func TestAdoptionDate(t *testing.T) {
+	countryAnthems := parseClaims(t, `[
+		{"mainsnak":{"datavalue":{"value":{"id":"Q100"}}},
+		 "qualifiers":{"P580":[{"datavalue":{"value":{"time":"+1944-01-01T00:00:00Z","precision":9}}}]}},
+		{"mainsnak":{"datavalue":{"value":{"id":"Q200"}}},
+		 "qualifiers":{"P580":[{"datavalue":{"value":{"time":"+1879-02-14T00:00:00Z","precision":11}}}]}}
+	]`)
+	inception := parseClaims(t, `[{"mainsnak":{"datavalue":{"value":{"time":"+1795-07-14T00:00:00Z","precision":10}}}}]`)
+
+	tests := []struct {
+		name      string
+		anthem    string
+		inception []wikidataClaim
+		want      string
+		ok        bool
+	}{
+		{"start time of this anthem", "Q200", inception, "1879-02-14", true},
+		{"other anthem's start time ignored", "Q300", inception, "1795-07", true},
+		{"no date", "Q300", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := adoptionDate(countryAnthems, tt.anthem, tt.inception)
+			if ok != tt.ok || d.Value != tt.want {
+				t.Errorf("Expected %q (%v), got %q (%v)", tt.want, tt.ok, d.Value, ok)
+			}
+		})
+	}
+
+	// An impossible start time falls through to the inception
+	invalid := parseClaims(t, `[{"mainsnak":{"datavalue":{"value":{"id":"Q100"}}},
+		"qualifiers":{"P580":[{"datavalue":{"value":{"time":"+1944-02-30T00:00:00Z","precision":11}}}]}}]`)
+	if d, ok := adoptionDate(invalid, "Q100", inception); !ok || d.Value != "1795-07" {
+		t.Errorf("Expected the inception 1795-07, got %q (%v)", d.Value, ok)
+	}
+}
@@ -0,0 +1,190 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/jobs"
+)
+
+// DefaultLabelLanguages are the UI languages the site is translated into
+var DefaultLabelLanguages = []string{"en", "fr", "es", "ar"}
+
+const wikidataSPARQLURL = "https://query.wikidata.org/sparql"
+
+// wikidataBatchSize is the wbgetentities limit for anonymous clients
+const wikidataBatchSize = 50
+
+// LabelStats summarises a label fetch
+type LabelStats struct {
+	CountriesLinked int // countries matched to a Wikidata item by ISO alpha-3
+	Labels          int // labels stored
+	NativeNames     int // anthem native titles filled in
+}
+
+// FetchWikidataLabels stores Wikidata labels in langs for every country and
+// anthem in the labels table, keyed by Wikidata ID. Countries are first
+// matched to their Wikidata item by ISO alpha-3 (P298), and anthems without a
+// native name get their official title (P1476). It is safe to re-run.
+func FetchWikidataLabels(ctx context.Context, db *sql.DB, langs []string, logger *jobs.JobLogger) (LabelStats, error) {
+	var stats LabelStats
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	linked, err := linkCountryItems(ctx, db, client)
+	if err != nil {
+		return stats, fmt.Errorf("failed to link countries to Wikidata: %w", err)
+	}
+	stats.CountriesLinked = linked
+	logger.Infof("Linked %d countries to Wikidata items", linked)
+
+	rows, err := db.Query(`
+		SELECT wikidata_id FROM countries WHERE wikidata_id IS NOT NULL AND wikidata_id != ''
+		UNION
+		SELECT wikidata_id FROM anthems WHERE wikidata_id IS NOT NULL AND wikidata_id != ''
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query Wikidata IDs: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	logger.Infof("Fetching labels in %s for %d Wikidata items", strings.Join(langs, ", "), len(ids))
+	for start := 0; start < len(ids); start += wikidataBatchSize {
+		end := min(start+wikidataBatchSize, len(ids))
+		entities, err := fetchEntityLabels(ctx, client, ids[start:end], langs)
+		if err != nil {
+			return stats, fmt.Errorf("failed to fetch labels: %w", err)
+		}
+
+		for id, e := range entities.Entities {
+			for lang, l := range e.Labels {
+				if _, err := db.Exec(`
+					INSERT INTO labels (entity, lang, value, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+					ON CONFLICT(entity, lang) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+				`, id, lang, l.Value); err != nil {
+					return stats, fmt.Errorf("failed to store label: %w", err)
+				}
+				stats.Labels++
+			}
+
+			if title := nativeTitle(e.Claims["P1476"]); title != "" {
+				res, err := db.Exec(`
+					UPDATE anthems SET native_name = ?
+					WHERE wikidata_id = ? AND (native_name IS NULL OR native_name = '')
+				`, title, id)
+				if err != nil {
+					return stats, fmt.Errorf("failed to store native name: %w", err)
+				}
+				n, _ := res.RowsAffected()
+				stats.NativeNames += int(n)
+			}
+		}
+
+		if end < len(ids) {
+			time.Sleep(time.Second) // be polite to the API
+		}
+	}
+
+	logger.Infof("✓ Stored %d labels, filled %d native anthem titles", stats.Labels, stats.NativeNames)
+	return stats, nil
+}
+
+// linkCountryItems sets countries.wikidata_id from the Wikidata items holding
+// each ISO 3166-1 alpha-3 code, ignoring dissolved states
+func linkCountryItems(ctx context.Context, db *sql.DB, client *http.Client) (int, error) {
+	query := `SELECT ?item ?iso WHERE { ?item wdt:P298 ?iso . FILTER NOT EXISTS { ?item wdt:P576 ?dissolved } }`
+	req, err := http.NewRequestWithContext(ctx, "GET", wikidataSPARQLURL+"?format=json&query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	req.Header.Set("Accept", "application/sparql-results+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("SPARQL endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results struct {
+			Bindings []map[string]struct {
+				Value string `json:"value"`
+			} `json:"bindings"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+
+	linked := 0
+	for _, b := range result.Results.Bindings {
+		item := b["item"].Value
+		qid := item[strings.LastIndex(item, "/")+1:]
+		res, err := db.Exec(`UPDATE countries SET wikidata_id = ? WHERE UPPER(iso_alpha3) = ?`, qid, strings.ToUpper(b["iso"].Value))
+		if err != nil {
+			return linked, err
+		}
+		n, _ := res.RowsAffected()
+		linked += int(n)
+	}
+	return linked, nil
+}
+
+func fetchEntityLabels(ctx context.Context, client *http.Client, ids, langs []string) (*wikidataEntityResponse, error) {
+	apiURL := fmt.Sprintf("%s?action=wbgetentities&ids=%s&props=labels|claims&languages=%s&format=json",
+		wikidataAPIURL, url.QueryEscape(strings.Join(ids, "|")), url.QueryEscape(strings.Join(langs, "|")))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var result wikidataEntityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// nativeTitle returns the text of the first P1476 (title) statement, which
+// Wikidata records in the anthem's own language
+func nativeTitle(claims []wikidataClaim) string {
+	for _, c := range claims {
+		var text struct {
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(c.Mainsnak.DataValue.Value, &text) == nil && text.Text != "" {
+			return text.Text
+		}
+	}
+	return ""
+}
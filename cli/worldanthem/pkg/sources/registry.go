@@ -7,6 +7,7 @@ var AllSources = []DataSource{
 	NewWikidataSource(),
 	NewWikimediaSource(),
 	NewFactbookSource(),
+	NewWikisourceSource(),
 }
 
 // GetSourceByID retrieves a data source by its ID
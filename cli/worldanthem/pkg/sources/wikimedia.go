@@ -9,9 +9,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/anthemworld/cli/pkg/audio"
 	"github.com/anthemworld/cli/pkg/jobs"
 )
 
@@ -130,16 +132,56 @@ type ImageInfoResponse struct {
 			PageID    int    `json:"pageid"`
 			Title     string `json:"title"`
 			ImageInfo []struct {
-				URL       string `json:"url"`
-				Size      int    `json:"size"`
-				Mime      string `json:"mime"`
-				MediaType string `json:"mediatype"`
-				Duration  float64 `json:"duration,omitempty"`
+				URL         string  `json:"url"`
+				Size        int     `json:"size"`
+				Mime        string  `json:"mime"`
+				MediaType   string  `json:"mediatype"`
+				Duration    float64 `json:"duration,omitempty"`
+				ExtMetadata map[string]struct {
+					Value interface{} `json:"value"`
+				} `json:"extmetadata"`
+				Metadata []commonsMetadataItem `json:"metadata"`
 			} `json:"imageinfo"`
+			Categories []struct {
+				Title string `json:"title"`
+			} `json:"categories"`
 		} `json:"pages"`
 	} `json:"query"`
 }
 
+// commonsMetadataItem is one name/value pair from iiprop=metadata.
+// Values nest arbitrarily (Ogg streams contain their own name/value lists).
+type commonsMetadataItem struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// wikidataEntityResponse represents the subset of wbgetentities we use
+type wikidataEntityResponse struct {
+	Entities map[string]struct {
+		Labels map[string]struct {
+			Value string `json:"value"`
+		} `json:"labels"`
+		Claims    map[string][]wikidataClaim `json:"claims"`
+		Sitelinks map[string]struct {
+			Title string `json:"title"`
+		} `json:"sitelinks"`
+	} `json:"entities"`
+}
+
+type wikidataClaim struct {
+	Mainsnak   wikidataSnak              `json:"mainsnak"`
+	Qualifiers map[string][]wikidataSnak `json:"qualifiers"`
+}
+
+type wikidataSnak struct {
+	DataValue struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"datavalue"`
+}
+
+const wikidataAPIURL = "https://www.wikidata.org/w/api.php"
+
 // Download fetches audio files from Wikimedia Commons
 func (w *WikimediaSource) Download(ctx context.Context, db *sql.DB, logger *jobs.JobLogger) error {
 	logger.Info("Starting Wikimedia Commons download")
@@ -188,6 +230,7 @@ func (w *WikimediaSource) Download(ctx context.Context, db *sql.DB, logger *jobs
 	inserted := 0
 	skipped := 0
 	alreadyHave := 0
+	reclassified := 0
 	errors := 0
 
 	// For each country, search for audio files in Wikimedia Commons
@@ -198,10 +241,23 @@ func (w *WikimediaSource) Download(ctx context.Context, db *sql.DB, logger *jobs
 			time.Sleep(3 * time.Second)
 		}
 
-		// Skip countries that already have audio recordings (makes re-runs resumable)
+		// Files the anthem's Wikidata item links as its audio (P51), with qualifier labels
+		linked, err := w.getWikidataAudio(ctx, client, ca.wikidataID)
+		if err != nil {
+			logger.Infof("Error reading Wikidata audio for %s: %v", ca.wikidataID, err)
+		}
+
+		// Skip countries that already have audio recordings (makes re-runs resumable).
+		// Rows from before classification existed are re-classified in place.
 		var existingCount int
 		_ = db.QueryRow(`SELECT COUNT(*) FROM audio_recordings WHERE country_id = ? AND source = 'wikimedia-commons'`, ca.countryID).Scan(&existingCount)
 		if existingCount > 0 {
+			n, err := w.reclassifyLegacy(ctx, db, client, ca.countryID, linked)
+			if err != nil {
+				logger.Infof("Error re-classifying recordings for %s: %v", ca.countryName, err)
+				errors++
+			}
+			reclassified += n
 			alreadyHave++
 			continue
 		}
@@ -219,10 +275,13 @@ func (w *WikimediaSource) Download(ctx context.Context, db *sql.DB, logger *jobs
 			if err != nil {
 				logger.Infof("Error searching for 'National anthem %s': %v", ca.countryName, err)
 			}
-			if len(audioFiles) == 0 {
-				skipped++
-				continue
-			}
+		}
+
+		// Wikidata-linked files are the most authoritative, so they go first
+		audioFiles = mergeFileTitles(linkedTitles(linked), audioFiles)
+		if len(audioFiles) == 0 {
+			skipped++
+			continue
 		}
 
 		logger.Infof("Found %d audio files for %s (%s)", len(audioFiles), ca.countryName, ca.anthemName)
@@ -240,24 +299,22 @@ func (w *WikimediaSource) Download(ctx context.Context, db *sql.DB, logger *jobs
 				continue
 			}
 
-			// Determine recording type from filename
-			recordingType := "vocal"
-			filenameLower := strings.ToLower(fileName)
-			if strings.Contains(filenameLower, "instrumental") {
-				recordingType = "instrumental"
-			}
+			qualifiers, isLinked := linked[fileName]
+			recordingType, quality := classifyRecording(fileName, fileInfo, qualifiers)
 
-			// Generate a unique ID for the recording
-			recordingID := fmt.Sprintf("%s-%d", ca.countryID, time.Now().UnixNano())
+			// Stable ID derived from the file, so re-downloads hit the duplicate check
+			recordingID := audio.RecordingID(w.id, fileName)
 
 			// Insert audio recording
 			_, err = db.Exec(`
 				INSERT INTO audio_recordings (
 					id, country_id, title, url, format, duration_seconds,
-					type, source, license, file_size_bytes, quality, created_at
-				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+					type, source, license, file_size_bytes, quality,
+					bitrate_kbps, sample_rate_hz, wikidata_linked, created_at
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 			`, recordingID, ca.countryID, fileName, fileInfo.url, fileInfo.mime, int(fileInfo.duration),
-				recordingType, "wikimedia-commons", "CC-BY-SA", fileInfo.size, "standard")
+				recordingType, w.id, "CC-BY-SA", fileInfo.size, quality,
+				nullIfZero(fileInfo.bitrateKbps), nullIfZero(fileInfo.sampleRateHz), isLinked)
 
 			if err != nil {
 				// Check if it's a duplicate
@@ -292,15 +349,19 @@ func (w *WikimediaSource) Download(ctx context.Context, db *sql.DB, logger *jobs
 		return fmt.Errorf("failed to update record count: %w", err)
 	}
 
-	logger.Infof("✓ Inserted %d audio recordings, skipped %d countries (no results), %d already had recordings, %d errors", inserted, skipped, alreadyHave, errors)
+	logger.Infof("✓ Inserted %d audio recordings, skipped %d countries (no results), %d already had recordings (%d re-classified), %d errors", inserted, skipped, alreadyHave, reclassified, errors)
 	return nil
 }
 
 type fileInfo struct {
-	url      string
-	size     int
-	mime     string
-	duration float64
+	url          string
+	size         int
+	mime         string
+	duration     float64
+	description  string
+	categories   []string
+	bitrateKbps  int
+	sampleRateHz int
 }
 
 // searchAudioFiles searches for audio files using MediaWiki search API
@@ -407,7 +468,7 @@ func (w *WikimediaSource) getCategoryAudioFiles(ctx context.Context, client *htt
 
 // getFileInfo retrieves metadata for a specific file
 func (w *WikimediaSource) getFileInfo(ctx context.Context, client *http.Client, fileName string) (*fileInfo, error) {
-	apiURL := fmt.Sprintf("%s?action=query&titles=%s&prop=imageinfo&iiprop=url|size|mime|mediatype&format=json",
+	apiURL := fmt.Sprintf("%s?action=query&titles=%s&prop=imageinfo|categories&iiprop=url|size|mime|mediatype|extmetadata|metadata&iiextmetadatafilter=ImageDescription&cllimit=max&format=json",
 		w.url, url.QueryEscape(fileName))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
@@ -442,18 +503,269 @@ func (w *WikimediaSource) getFileInfo(ctx context.Context, client *http.Client,
 	for _, page := range result.Query.Pages {
 		if len(page.ImageInfo) > 0 {
 			info := page.ImageInfo[0]
-			return &fileInfo{
+			fi := &fileInfo{
 				url:      info.URL,
 				size:     info.Size,
 				mime:     info.Mime,
 				duration: info.Duration,
-			}, nil
+			}
+			if desc, ok := info.ExtMetadata["ImageDescription"].Value.(string); ok {
+				fi.description = stripHTML(desc)
+			}
+			for _, c := range page.Categories {
+				fi.categories = append(fi.categories, strings.TrimPrefix(c.Title, "Category:"))
+			}
+			fi.sampleRateHz = int(findMetadataNumber(info.Metadata, "audio_sample_rate", "sample_rate", "samplerate"))
+			fi.bitrateKbps = int(findMetadataNumber(info.Metadata, "bitrate_nominal", "nominal_bitrate", "bitrate") / 1000)
+			if fi.duration == 0 {
+				fi.duration = findMetadataNumber(info.Metadata, "length", "playtime_seconds", "duration")
+			}
+			// Fall back to the average bitrate when the container doesn't declare one
+			if fi.bitrateKbps == 0 && fi.duration > 0 && fi.size > 0 {
+				fi.bitrateKbps = int(float64(fi.size) * 8 / fi.duration / 1000)
+			}
+			return fi, nil
 		}
 	}
 
 	return nil, fmt.Errorf("no file info found for %s", fileName)
 }
 
+// getWikidataAudio returns the Commons files linked from an anthem item's
+// P51 (audio) statements, keyed by "File:" title, with the English labels of
+// each statement's qualifiers (e.g. "instrumental", "United States Navy Band").
+func (w *WikimediaSource) getWikidataAudio(ctx context.Context, client *http.Client, wikidataID string) (map[string][]string, error) {
+	linked := make(map[string][]string)
+	if wikidataID == "" {
+		return linked, nil
+	}
+
+	entity, err := fetchWikidataEntities(ctx, client, []string{wikidataID}, "claims")
+	if err != nil {
+		return linked, err
+	}
+
+	qualifierIDs := make(map[string]bool)
+	claimQualifiers := make(map[string][]string)
+	for _, e := range entity.Entities {
+		for _, claim := range e.Claims["P51"] {
+			var fileName string
+			if err := json.Unmarshal(claim.Mainsnak.DataValue.Value, &fileName); err != nil || fileName == "" {
+				continue
+			}
+			title := "File:" + fileName
+			linked[title] = nil
+			for _, snaks := range claim.Qualifiers {
+				for _, snak := range snaks {
+					var item struct {
+						ID string `json:"id"`
+					}
+					if json.Unmarshal(snak.DataValue.Value, &item) == nil && item.ID != "" {
+						qualifierIDs[item.ID] = true
+						claimQualifiers[title] = append(claimQualifiers[title], item.ID)
+					}
+				}
+			}
+		}
+	}
+	if len(qualifierIDs) == 0 {
+		return linked, nil
+	}
+
+	ids := make([]string, 0, len(qualifierIDs))
+	for id := range qualifierIDs {
+		ids = append(ids, id)
+	}
+	labels, err := fetchWikidataEntities(ctx, client, ids, "labels")
+	if err != nil {
+		return linked, err
+	}
+	for title, qids := range claimQualifiers {
+		for _, qid := range qids {
+			if l, ok := labels.Entities[qid].Labels["en"]; ok {
+				linked[title] = append(linked[title], l.Value)
+			}
+		}
+	}
+	return linked, nil
+}
+
+func fetchWikidataEntities(ctx context.Context, client *http.Client, ids []string, props string) (*wikidataEntityResponse, error) {
+	apiURL := fmt.Sprintf("%s?action=wbgetentities&ids=%s&props=%s&languages=en&format=json",
+		wikidataAPIURL, url.QueryEscape(strings.Join(ids, "|")), props)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var result wikidataEntityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// reclassifyLegacy updates type and quality for a country's recordings that
+// were stored before classification existed (quality = 'standard').
+func (w *WikimediaSource) reclassifyLegacy(ctx context.Context, db *sql.DB, client *http.Client, countryID string, linked map[string][]string) (int, error) {
+	rows, err := db.Query(`
+		SELECT id, title FROM audio_recordings
+		WHERE country_id = ? AND source = 'wikimedia-commons' AND quality = 'standard'
+	`, countryID)
+	if err != nil {
+		return 0, err
+	}
+	type legacyRow struct{ id, title string }
+	var legacy []legacyRow
+	for rows.Next() {
+		var r legacyRow
+		if err := rows.Scan(&r.id, &r.title); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		legacy = append(legacy, r)
+	}
+	rows.Close()
+
+	updated := 0
+	for _, r := range legacy {
+		fi, err := w.getFileInfo(ctx, client, r.title)
+		if err != nil {
+			return updated, err
+		}
+		qualifiers, isLinked := linked[r.title]
+		recordingType, quality := classifyRecording(r.title, fi, qualifiers)
+		_, err = db.Exec(`
+			UPDATE audio_recordings
+			SET type = ?, quality = ?, bitrate_kbps = ?, sample_rate_hz = ?, wikidata_linked = ?
+			WHERE id = ?
+		`, recordingType, quality, nullIfZero(fi.bitrateKbps), nullIfZero(fi.sampleRateHz), isLinked, r.id)
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// classifyRecording derives the recording type and quality from everything
+// Commons and Wikidata tell us about a file.
+func classifyRecording(fileName string, fi *fileInfo, qualifiers []string) (string, string) {
+	recordingType := audio.ClassifyType(audio.Hints{
+		Qualifiers:  qualifiers,
+		Categories:  fi.categories,
+		FileName:    fileName,
+		Description: fi.description,
+	})
+	return recordingType, audio.ClassifyQuality(fi.bitrateKbps, fi.sampleRateHz)
+}
+
+// findMetadataNumber searches Commons iiprop=metadata (recursively) for the
+// first numeric value stored under any of the given names.
+func findMetadataNumber(items []commonsMetadataItem, names ...string) float64 {
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		want[n] = true
+	}
+	var walk func(v interface{}) (float64, bool)
+	walk = func(v interface{}) (float64, bool) {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, e := range t {
+				if n, ok := walk(e); ok {
+					return n, true
+				}
+			}
+		case map[string]interface{}:
+			if name, ok := t["name"].(string); ok && want[name] {
+				if n, ok := toFloat(t["value"]); ok {
+					return n, true
+				}
+			}
+			for k, e := range t {
+				if want[k] {
+					if n, ok := toFloat(e); ok {
+						return n, true
+					}
+				}
+				if n, ok := walk(e); ok {
+					return n, true
+				}
+			}
+		}
+		return 0, false
+	}
+	for _, item := range items {
+		if want[item.Name] {
+			if n, ok := toFloat(item.Value); ok {
+				return n
+			}
+		}
+		if n, ok := walk(item.Value); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, t > 0
+	case string:
+		var f float64
+		if _, err := fmt.Sscanf(t, "%g", &f); err == nil && f > 0 {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+func linkedTitles(linked map[string][]string) []string {
+	titles := make([]string, 0, len(linked))
+	for t := range linked {
+		titles = append(titles, t)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+// mergeFileTitles concatenates title lists, dropping duplicates and keeping first occurrence order.
+func mergeFileTitles(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, list := range lists {
+		for _, t := range list {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+func nullIfZero(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
 func (w *WikimediaSource) ApplySchema(db *sql.DB) error {
 	_, err := db.Exec(w.GetSchema())
 	return err
@@ -0,0 +1,450 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthemworld/cli/pkg/jobs"
+	"github.com/anthemworld/cli/pkg/lyrics"
+)
+
+//go:embed wikisource.schema.sql
+var wikisourceSchema string
+
+// wikidataPublicDomain is the Wikidata item for "public domain", the value of
+// P6216 (copyright status) on texts that are free to publish
+const wikidataPublicDomain = "Q19652"
+
+// WikisourceSource downloads public-domain anthem lyrics from Wikisource.
+// Texts are found through the Wikisource sitelinks of each anthem's
+// Wikidata item and of its editions (P747), so `wikidata` must run first.
+type WikisourceSource struct {
+	id   string
+	name string
+	url  string
+}
+
+// NewWikisourceSource creates a new Wikisource data source
+func NewWikisourceSource() *WikisourceSource {
+	return &WikisourceSource{
+		id:   "wikisource",
+		name: "Wikisource",
+		url:  "https://wikisource.org",
+	}
+}
+
+func (w *WikisourceSource) ID() string   { return w.id }
+func (w *WikisourceSource) Name() string { return w.name }
+func (w *WikisourceSource) Type() string { return "lyrics" }
+func (w *WikisourceSource) URL() string  { return w.url }
+
+const wikisourceSchemaVersion = 1
+
+func (w *WikisourceSource) GetSchema() string     { return wikisourceSchema }
+func (w *WikisourceSource) GetSchemaVersion() int { return wikisourceSchemaVersion }
+func (w *WikisourceSource) GetTables() []string   { return []string{"wikisource_metadata"} }
+
+func (w *WikisourceSource) HealthCheck(ctx context.Context) HealthStatus {
+	client := &http.Client{Timeout: 10 * time.Second}
+	testURL := "https://en.wikisource.org/w/api.php?action=query&meta=siteinfo&format=json"
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "GET", testURL, nil)
+	if err != nil {
+		return HealthStatus{Healthy: false, Message: err.Error()}
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	resp, err := client.Do(req)
+	elapsed := time.Since(start).Milliseconds()
+	if err != nil {
+		return HealthStatus{Healthy: false, Message: err.Error(), ResponseTime: elapsed}
+	}
+	defer resp.Body.Close()
+	healthy := resp.StatusCode >= 200 && resp.StatusCode < 300
+	msg := "OK"
+	if !healthy {
+		msg = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return HealthStatus{Healthy: healthy, StatusCode: resp.StatusCode, Message: msg, ResponseTime: elapsed}
+}
+
+// wikisourceText is a Wikisource page holding one text of an anthem
+type wikisourceText struct {
+	lang  string // Wikisource subdomain, which is the language of the text
+	title string
+	kind  string
+}
+
+// Download fetches public-domain lyrics for every anthem with a Wikidata item
+func (w *WikisourceSource) Download(ctx context.Context, db *sql.DB, logger *jobs.JobLogger) error {
+	logger.Info("Starting Wikisource lyrics download")
+
+	if err := w.ApplySchema(db); err != nil {
+		return fmt.Errorf("failed to apply schema: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT id, wikidata_id FROM anthems WHERE wikidata_id IS NOT NULL AND wikidata_id != '' ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to query anthems: %w", err)
+	}
+	anthemIDs := make(map[string]int)
+	var qids []string
+	for rows.Next() {
+		var id int
+		var qid string
+		if err := rows.Scan(&id, &qid); err != nil {
+			rows.Close()
+			return err
+		}
+		anthemIDs[qid] = id
+		qids = append(qids, qid)
+	}
+	rows.Close()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	logger.Infof("Looking up Wikisource texts for %d anthems", len(qids))
+
+	stored, notFree, errors := 0, 0, 0
+	for start := 0; start < len(qids); start += wikidataBatchSize {
+		end := min(start+wikidataBatchSize, len(qids))
+		texts, skipped, err := w.findTexts(ctx, client, qids[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to query Wikidata: %w", err)
+		}
+		notFree += skipped
+
+		for _, qid := range qids[start:end] {
+			for _, t := range texts[qid] {
+				verses, err := w.fetchVerses(ctx, client, t.lang, t.title)
+				if err != nil {
+					logger.Warnf("%s (%s): %v", t.title, t.lang, err)
+					errors++
+					continue
+				}
+				if len(verses) == 0 {
+					continue
+				}
+				written, err := lyrics.Store(db, &lyrics.Version{
+					AnthemID:        anthemIDs[qid],
+					Lang:            t.lang,
+					Kind:            t.kind,
+					Verses:          verses,
+					Source:          lyrics.SourceWikisource,
+					SourceURL:       wikisourcePageURL(t.lang, t.title),
+					License:         "Public domain",
+					CopyrightStatus: lyrics.StatusPublicDomain,
+					Attribution:     "Wikisource",
+				})
+				if err != nil {
+					logger.Warnf("%s (%s): %v", t.title, t.lang, err)
+					errors++
+					continue
+				}
+				if written {
+					stored++
+				}
+			}
+		}
+		time.Sleep(time.Second) // be polite to the API
+	}
+
+	_, _ = db.Exec(`INSERT OR REPLACE INTO wikisource_metadata (key, value, updated_at) VALUES ('last_download', ?, CURRENT_TIMESTAMP)`, time.Now().Format(time.RFC3339))
+	_, _ = db.Exec(`INSERT OR REPLACE INTO wikisource_metadata (key, value, updated_at) VALUES ('record_count', ?, CURRENT_TIMESTAMP)`, fmt.Sprintf("%d", stored))
+
+	logger.Infof("✓ Stored %d texts, skipped %d anthems not in the public domain, %d errors", stored, notFree, errors)
+	return nil
+}
+
+// findTexts returns the Wikisource pages of each anthem item that may be
+// published. A text qualifies when the item linking to it is marked public
+// domain (P6216): the anthem item's own page in the anthem's language is the
+// original, and an edition (P747) in another language is a translation. When
+// the anthem's language (P407) is unknown, a lone Wikisource page is taken as
+// the original. It also returns how many anthems were skipped as not free.
+func (w *WikisourceSource) findTexts(ctx context.Context, client *http.Client, qids []string) (map[string][]wikisourceText, int, error) {
+	anthems, err := fetchWikidataEntities(ctx, client, qids, "claims|sitelinks")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Then the editions and the anthems' languages, for all anthems at once
+	editionIDs, languageIDs := map[string]bool{}, map[string]bool{}
+	for _, e := range anthems.Entities {
+		for _, id := range claimItemIDs(e.Claims["P747"]) {
+			editionIDs[id] = true
+		}
+		for _, id := range claimItemIDs(e.Claims["P407"]) {
+			languageIDs[id] = true
+		}
+	}
+	editions := &wikidataEntityResponse{}
+	if len(editionIDs) > 0 {
+		if editions, err = fetchWikidataBatched(ctx, client, setKeys(editionIDs), "claims|sitelinks"); err != nil {
+			return nil, 0, err
+		}
+	}
+	langCodes := map[string]string{}
+	if len(languageIDs) > 0 {
+		languages, err := fetchWikidataBatched(ctx, client, setKeys(languageIDs), "claims")
+		if err != nil {
+			return nil, 0, err
+		}
+		for id, e := range languages.Entities {
+			// P424: Wikimedia language code, which is also the Wikisource subdomain
+			for _, c := range e.Claims["P424"] {
+				var code string
+				if json.Unmarshal(c.Mainsnak.DataValue.Value, &code) == nil && code != "" {
+					langCodes[id] = code
+					break
+				}
+			}
+		}
+	}
+
+	texts := make(map[string][]wikisourceText)
+	notFree := 0
+	for _, qid := range qids {
+		e, ok := anthems.Entities[qid]
+		if !ok {
+			continue
+		}
+		workLang := ""
+		for _, id := range claimItemIDs(e.Claims["P407"]) {
+			if code := langCodes[id]; code != "" {
+				workLang = code
+				break
+			}
+		}
+
+		found := false
+		if isPublicDomain(e.Claims) {
+			pages := wikisourcePages(e.Sitelinks)
+			for _, p := range pages {
+				if p.lang == workLang || (workLang == "" && len(pages) == 1) {
+					p.kind = lyrics.KindOriginal
+					texts[qid] = append(texts[qid], p)
+					found = true
+				}
+			}
+		}
+		for _, id := range claimItemIDs(e.Claims["P747"]) {
+			ed, ok := editions.Entities[id]
+			if !ok || !isPublicDomain(ed.Claims) {
+				continue
+			}
+			for _, p := range wikisourcePages(ed.Sitelinks) {
+				p.kind = lyrics.KindTranslation
+				if p.lang == workLang {
+					p.kind = lyrics.KindOriginal
+				}
+				texts[qid] = append(texts[qid], p)
+				found = true
+			}
+		}
+		if !found && len(wikisourcePages(e.Sitelinks)) > 0 {
+			notFree++
+		}
+	}
+	return texts, notFree, nil
+}
+
+// wikisourcePages lists the Wikisource sitelinks of an item, by language
+func wikisourcePages(sitelinks map[string]struct {
+	Title string `json:"title"`
+}) []wikisourceText {
+	var pages []wikisourceText
+	for site, link := range sitelinks {
+		lang, ok := strings.CutSuffix(site, "wikisource")
+		if !ok || lang == "" {
+			continue
+		}
+		pages = append(pages, wikisourceText{lang: strings.ReplaceAll(lang, "_", "-"), title: link.Title})
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].lang < pages[j].lang })
+	return pages
+}
+
+// claimItemIDs returns the item IDs of a property's statements
+func claimItemIDs(claims []wikidataClaim) []string {
+	var ids []string
+	for _, c := range claims {
+		var item struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(c.Mainsnak.DataValue.Value, &item) == nil && item.ID != "" {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
+
+func isPublicDomain(claims map[string][]wikidataClaim) bool {
+	for _, id := range claimItemIDs(claims["P6216"]) {
+		if id == wikidataPublicDomain {
+			return true
+		}
+	}
+	return false
+}
+
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fetchWikidataBatched fetches any number of items, wikidataBatchSize at a time
+func fetchWikidataBatched(ctx context.Context, client *http.Client, ids []string, props string) (*wikidataEntityResponse, error) {
+	all := &wikidataEntityResponse{}
+	for start := 0; start < len(ids); start += wikidataBatchSize {
+		batch, err := fetchWikidataEntities(ctx, client, ids[start:min(start+wikidataBatchSize, len(ids))], props)
+		if err != nil {
+			return nil, err
+		}
+		if all.Entities == nil {
+			all.Entities = batch.Entities
+			continue
+		}
+		for id, e := range batch.Entities {
+			all.Entities[id] = e
+		}
+	}
+	return all, nil
+}
+
+func wikisourcePageURL(lang, title string) string {
+	return fmt.Sprintf("https://%s.wikisource.org/wiki/%s", lang, url.PathEscape(strings.ReplaceAll(title, " ", "_")))
+}
+
+// fetchVerses returns the stanzas of the poem on a Wikisource page
+func (w *WikisourceSource) fetchVerses(ctx context.Context, client *http.Client, lang, title string) ([]string, error) {
+	apiURL := fmt.Sprintf("https://%s.wikisource.org/w/api.php?action=parse&page=%s&prop=text&formatversion=2&format=json",
+		lang, url.QueryEscape(title))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "AnthemWorld-CLI/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Parse struct {
+			Text string `json:"text"`
+		} `json:"parse"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return poemVerses(result.Parse.Text), nil
+}
+
+var (
+	divTag       = regexp.MustCompile(`(?i)<(/?)div\b([^>]*)>`)
+	poemClass    = regexp.MustCompile(`\bclass="(?:[^"]*\s)?poem(?:\s[^"]*)?"`)
+	footnoteRef  = regexp.MustCompile(`(?s)<sup[^>]*>.*?</sup>`)
+	lineBreak    = regexp.MustCompile(`<br\s*/?>`)
+	paragraphEnd = regexp.MustCompile(`</(?:p|div)>`)
+	anyTag       = regexp.MustCompile(`<[^>]+>`)
+)
+
+// poemVerses extracts the stanzas of the <poem> blocks in rendered Wikisource
+// HTML. Pages without one (prose, disambiguation) yield nothing.
+func poemVerses(page string) []string {
+	var verses []string
+	for _, block := range poemBlocks(page) {
+		// Line structure comes from the markup, not the newlines in the source
+		text := strings.ReplaceAll(footnoteRef.ReplaceAllString(block, ""), "\n", "")
+		text = lineBreak.ReplaceAllString(text, "\n")
+		text = paragraphEnd.ReplaceAllString(text, "\n\n")
+		text = html.UnescapeString(anyTag.ReplaceAllString(text, ""))
+		verses = append(verses, lyrics.SplitVerses(text)...)
+	}
+	return verses
+}
+
+// poemBlocks returns the inner HTML of each <div class="poem">, walking the
+// div tags so a block ends at its own closing tag and not at the first one
+// of a nested div. A block left open runs to the end of the page.
+func poemBlocks(page string) []string {
+	var blocks []string
+	start, depth := -1, 0
+	for _, m := range divTag.FindAllStringSubmatchIndex(page, -1) {
+		closing := m[3] > m[2]
+		switch {
+		case start < 0:
+			if !closing && poemClass.MatchString(page[m[4]:m[5]]) {
+				start, depth = m[1], 1
+			}
+		case closing:
+			if depth--; depth == 0 {
+				blocks = append(blocks, page[start:m[0]])
+				start = -1
+			}
+		default:
+			depth++
+		}
+	}
+	if start >= 0 {
+		blocks = append(blocks, page[start:])
+	}
+	return blocks
+}
+
+func (w *WikisourceSource) ApplySchema(db *sql.DB) error {
+	_, err := db.Exec(w.GetSchema())
+	return err
+}
+
+func (w *WikisourceSource) SchemaExists(db *sql.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type='table' AND name='wikisource_metadata')`).Scan(&exists)
+	return exists, err
+}
+
+func (w *WikisourceSource) GetDataStats(db *sql.DB) (DataStats, error) {
+	stats := DataStats{SchemaVersion: wikisourceSchemaVersion}
+	exists, err := w.SchemaExists(db)
+	if err != nil || !exists {
+		return stats, err
+	}
+	_ = db.QueryRow(`SELECT COUNT(*) FROM lyrics WHERE source = ?`, lyrics.SourceWikisource).Scan(&stats.RecordCount)
+	_ = db.QueryRow(`SELECT value FROM wikisource_metadata WHERE key = 'last_download'`).Scan(&stats.LastUpdated)
+	return stats, nil
+}
+
+func (w *WikisourceSource) NeedsUpdate(db *sql.DB) (bool, error) {
+	exists, err := w.SchemaExists(db)
+	if err != nil || !exists {
+		return true, err
+	}
+	var lastDownload string
+	if err := db.QueryRow(`SELECT value FROM wikisource_metadata WHERE key = 'last_download'`).Scan(&lastDownload); err != nil {
+		return true, nil
+	}
+	lastTime, err := time.Parse(time.RFC3339, lastDownload)
+	if err != nil {
+		return true, nil
+	}
+	return time.Since(lastTime) > 30*24*time.Hour, nil
+}
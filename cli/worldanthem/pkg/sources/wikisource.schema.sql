@@ -0,0 +1,13 @@
+-- Wikisource source schema
+-- Tracks lyrics downloads; the texts themselves live in the core lyrics and
+-- lyric_verses tables (schema version 9)
+
+CREATE TABLE IF NOT EXISTS wikisource_metadata (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO wikisource_metadata (key, value) VALUES ('schema_version', '1');
+INSERT OR IGNORE INTO wikisource_metadata (key, value) VALUES ('last_download', '');
+INSERT OR IGNORE INTO wikisource_metadata (key, value) VALUES ('record_count', '0');
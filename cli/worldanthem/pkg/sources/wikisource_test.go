@@ -0,0 +1,99 @@
+package sources
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anthemworld/cli/pkg/lyrics"
+)
+
+func TestPoemVerses(t *testing.T) {
+	page := `<div class="mw-parser-output"><p>Intro</p>
+<div class="poem"><p>Allons enfants de la Patrie,<br />
+Le jour de gloire est arrivé !<sup class="reference"><a href="#cite_note-1">[1]</a></sup></p>
+<div class="verse"><p>Contre nous de la tyrannie<br>L&#39;étendard sanglant est levé</p></div>
+<p>Aux armes, citoyens !</p>
+</div>
+<p>Notes</p>
+<div class="poem wst-poem">Liberté, liberté chérie</div></div>`
+
+	want := []string{
+		"Allons enfants de la Patrie,\nLe jour de gloire est arrivé !",
+		"Contre nous de la tyrannie\nL'étendard sanglant est levé",
+		"Aux armes, citoyens !",
+		"Liberté, liberté chérie",
+	}
+	if got := poemVerses(page); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := poemVerses(`<div class="prose"><p>No poem here</p></div>`); len(got) != 0 {
+		t.Errorf("Expected no verses without a poem block, got %q", got)
+	}
+	if got := poemVerses(`<div class="poem">Cut off<br/>mid-page`); !reflect.DeepEqual(got, []string{"Cut off\nmid-page"}) {
+		t.Errorf("Expected an unclosed block to run to the end, got %q", got)
+	}
+}
+
+// wikidataFixture answers wbgetentities with the requested entities
+type wikidataFixture map[string]string
+
+func (f wikidataFixture) RoundTrip(req *http.Request) (*http.Response, error) {
+	var entities []string
+	for _, id := range strings.Split(req.URL.Query().Get("ids"), "|") {
+		if e, ok := f[id]; ok {
+			entities = append(entities, `"`+id+`":`+e)
+		}
+	}
+	body := `{"entities":{` + strings.Join(entities, ",") + `}}`
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func itemClaims(prop string, ids ...string) string {
+	var claims []string
+	for _, id := range ids {
+		claims = append(claims, `{"mainsnak":{"datavalue":{"value":{"id":"`+id+`"}}}}`)
+	}
+	return `"` + prop + `":[` + strings.Join(claims, ",") + `]`
+}
+
+func TestFindTexts(t *testing.T) {
+	pd := itemClaims("P6216", wikidataPublicDomain)
+	fixture := wikidataFixture{
+		// French original on the anthem item; an English translation and a
+		// German edition that is not free
+		"Q1": `{"claims":{` + pd + `,` + itemClaims("P407", "Q150") + `,` + itemClaims("P747", "Q10", "Q11") + `},
+			"sitelinks":{"frwikisource":{"title":"La Marseillaise"},"enwikisource":{"title":"La Marseillaise (fr)"}}}`,
+		"Q10":  `{"claims":{` + pd + `},"sitelinks":{"enwikisource":{"title":"The Marseillaise"}}}`,
+		"Q11":  `{"claims":{},"sitelinks":{"dewikisource":{"title":"Die Marseillaise"}}}`,
+		"Q150": `{"claims":{"P424":[{"mainsnak":{"datavalue":{"value":"fr"}}}]}}`,
+		// Linked to Wikisource but not in the public domain
+		"Q2": `{"claims":{},"sitelinks":{"frwikisource":{"title":"Hymne"}}}`,
+		// Language unknown: a lone page is the original
+		"Q3": `{"claims":{` + pd + `},"sitelinks":{"eswikisource":{"title":"Himno"},"eswiki":{"title":"Himno"}}}`,
+	}
+	client := &http.Client{Transport: fixture}
+
+	texts, notFree, err := NewWikisourceSource().findTexts(context.Background(), client, []string{"Q1", "Q2", "Q3", "Q4"})
+	if err != nil {
+		t.Fatalf("findTexts failed: %v", err)
+	}
+
+	want := map[string][]wikisourceText{
+		"Q1": {
+			{lang: "fr", title: "La Marseillaise", kind: lyrics.KindOriginal},
+			{lang: "en", title: "The Marseillaise", kind: lyrics.KindTranslation},
+		},
+		"Q3": {{lang: "es", title: "Himno", kind: lyrics.KindOriginal}},
+	}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("Expected %+v, got %+v", want, texts)
+	}
+	if notFree != 1 {
+		t.Errorf("Expected 1 anthem skipped as not free, got %d", notFree)
+	}
+}
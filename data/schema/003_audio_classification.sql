@@ -0,0 +1,13 @@
+-- Schema Version 3: Audio recording classification
+-- Stores the signals used to classify recordings (type/quality) and to pick
+-- a primary recording per country at export time
+
+-- Technical properties reported by Wikimedia Commons
+ALTER TABLE audio_recordings ADD COLUMN bitrate_kbps INTEGER;   -- Average bitrate in kbit/s
+ALTER TABLE audio_recordings ADD COLUMN sample_rate_hz INTEGER; -- Sample rate in Hz
+
+-- File is referenced by the anthem's Wikidata item (P51 audio)
+ALTER TABLE audio_recordings ADD COLUMN wikidata_linked BOOLEAN DEFAULT 0;
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (3, 'Audio recording classification');
@@ -0,0 +1,15 @@
+-- Schema Version 4: Stable audio recording IDs
+-- Recording IDs used to be "<country>-<unix nanos>", so every re-download
+-- produced new IDs. They are now derived from the source and canonical file
+-- title: "rec-" + first 16 hex chars of SHA-1(source || 0x00 || title).
+--
+-- The ID remap needs SHA-1, which SQLite lacks, so the CLI performs this
+-- migration in Go (pkg/db applyMigration4):
+--   1. Rows sharing (source, url) are deduplicated, keeping the oldest
+--   2. Remaining rows get their content-derived ID
+--   3. The unique index below is created
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audio_source_url ON audio_recordings(source, url);
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (4, 'Stable audio recording IDs');
@@ -0,0 +1,9 @@
+-- Schema Version 5: Local audio mirror
+-- Written by `worldanthem data audio mirror`, which downloads each recording
+-- into a content-addressed store (<sha1[:2]>/<sha1><ext>)
+
+ALTER TABLE audio_recordings ADD COLUMN local_path TEXT;  -- Absolute path of the mirrored file
+ALTER TABLE audio_recordings ADD COLUMN sha1 TEXT;        -- SHA-1 of the file (verified against Commons)
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (5, 'Local audio mirror');
@@ -0,0 +1,11 @@
+-- Schema Version 6: Audio probing
+-- Written by `worldanthem data audio probe`, which reads the headers of
+-- mirrored files (Ogg Vorbis/Opus, MP3, WAV, FLAC). The probe also refreshes
+-- duration_seconds, sample_rate_hz, bitrate_kbps and quality.
+
+ALTER TABLE audio_recordings ADD COLUMN codec TEXT;          -- vorbis, opus, mp3, pcm, flac
+ALTER TABLE audio_recordings ADD COLUMN channels INTEGER;    -- Channel count
+ALTER TABLE audio_recordings ADD COLUMN loudness_lufs REAL;  -- Integrated loudness (EBU R128), when decodable
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (6, 'Audio probing');
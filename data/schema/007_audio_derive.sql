@@ -0,0 +1,10 @@
+-- Schema Version 7: Audio previews and waveforms
+-- Written by `worldanthem data audio derive`. Paths are relative to the audio
+-- store (previews/<sha1[:2]>/<sha1>.wav, peaks/<sha1[:2]>/<sha1>.json) so the
+-- export can prefix them with --audio-base-url.
+
+ALTER TABLE audio_recordings ADD COLUMN preview_path TEXT;  -- Loudness-normalised preview clip (16-bit mono WAV)
+ALTER TABLE audio_recordings ADD COLUMN peaks_path TEXT;    -- Waveform min/max peaks (audiowaveform JSON)
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (7, 'Audio previews and waveforms');
@@ -0,0 +1,17 @@
+-- Schema Version 8: Multilingual labels
+-- Filled by `worldanthem data labels`, which matches countries to their
+-- Wikidata item by ISO alpha-3 (P298) and fetches labels for countries and
+-- anthems. Used by `data format --lang` to write translated exports.
+
+CREATE TABLE IF NOT EXISTS labels (
+    entity TEXT NOT NULL,                   -- Wikidata ID (e.g., 'Q142', 'Q48984')
+    lang TEXT NOT NULL,                     -- Language code (e.g., 'fr', 'es', 'ar')
+    value TEXT NOT NULL,                    -- Label in that language
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity, lang)
+);
+
+ALTER TABLE countries ADD COLUMN wikidata_id TEXT;  -- Wikidata item of the country
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (8, 'Multilingual labels');
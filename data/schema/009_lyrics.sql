@@ -0,0 +1,30 @@
+-- Schema Version 9: Anthem lyrics
+-- Public-domain texts come from Wikisource (the wikisource data source);
+-- curated translations are imported with `worldanthem data lyrics import`.
+-- Only public-domain and licensed texts are exported.
+
+CREATE TABLE IF NOT EXISTS lyrics (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    anthem_id INTEGER NOT NULL REFERENCES anthems(id) ON DELETE CASCADE,
+    lang TEXT NOT NULL,                     -- BCP 47 language (e.g., 'fr', 'ja')
+    script TEXT NOT NULL,                   -- ISO 15924 script (e.g., 'Latn', 'Jpan')
+    kind TEXT NOT NULL,                     -- 'original', 'transliteration', 'translation'
+    source TEXT NOT NULL,                   -- 'wikisource' or 'local'
+    source_url TEXT,                        -- Page the text was taken from
+    license TEXT,                           -- Licence of the text (e.g., 'CC BY-SA 4.0')
+    copyright_status TEXT NOT NULL DEFAULT 'unknown', -- 'public-domain', 'licensed', 'copyrighted', 'unknown'
+    attribution TEXT,                       -- Translator or credit line
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (anthem_id, lang, script, kind)
+);
+
+CREATE TABLE IF NOT EXISTS lyric_verses (
+    lyrics_id INTEGER NOT NULL REFERENCES lyrics(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,              -- Stanza number, from 1
+    text TEXT NOT NULL,                     -- Lines separated by newlines
+    PRIMARY KEY (lyrics_id, position)
+);
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (9, 'Anthem lyrics');
@@ -0,0 +1,28 @@
+-- Schema Version 10: People and structured dates
+-- Composers and lyricists become people keyed by Wikidata ID, filled by
+-- `worldanthem data contributors`. The free-text composer, lyricist and
+-- adopted_date columns are kept for compatibility. Applied in Go: existing
+-- adopted_date values are parsed into adopted_on, and composers or lyricists
+-- stored as Wikidata entity URLs become contributors.
+
+CREATE TABLE IF NOT EXISTS people (
+    id TEXT PRIMARY KEY,                    -- Wikidata ID (e.g., 'Q312750')
+    name TEXT,                              -- English label, NULL until fetched
+    birth_year INTEGER,                     -- Negative for BCE
+    death_year INTEGER,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS anthem_contributors (
+    anthem_id INTEGER NOT NULL REFERENCES anthems(id) ON DELETE CASCADE,
+    person_id TEXT NOT NULL REFERENCES people(id),
+    role TEXT NOT NULL,                     -- 'composer' or 'lyricist'
+    position INTEGER NOT NULL DEFAULT 0,    -- Order within the role
+    PRIMARY KEY (anthem_id, person_id, role)
+);
+
+ALTER TABLE anthems ADD COLUMN adopted_on TEXT;         -- ISO 8601: '1795', '1795-07' or '1795-07-14'
+ALTER TABLE anthems ADD COLUMN adopted_precision TEXT;  -- 'year', 'month' or 'day'
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (10, 'People and structured dates');
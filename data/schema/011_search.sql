@@ -0,0 +1,35 @@
+-- Schema Version 11: Full-text search
+-- Applied in Go (see applyMigration11 and pkg/db/search.go). Needs SQLite
+-- with FTS5, i.e. a build with `-tags sqlite_fts5`; other builds skip the
+-- index and drop its triggers, and it is rebuilt on the next search.
+
+-- Indexed columns the factbook source used to add; created here if missing
+ALTER TABLE anthems ADD COLUMN anthem_title_en TEXT;
+ALTER TABLE anthems ADD COLUMN anthem_history TEXT;
+ALTER TABLE countries ADD COLUMN national_symbols TEXT;
+
+-- One row per anthem (or per country without one)
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+    country_id UNINDEXED,
+    anthem_id UNINDEXED,
+    country,                                -- Common and official name
+    anthem,                                 -- Name and native name
+    title_en,
+    composer,                               -- Free text plus contributor names
+    lyricist,
+    history,                                -- CIA World Factbook paragraph
+    symbols,                                -- National symbols
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- Triggers on countries, anthems, anthem_contributors and people (name)
+-- delete and re-insert the rows of the affected countries, e.g.:
+--
+-- CREATE TRIGGER search_anthems_au AFTER UPDATE ON anthems BEGIN
+--     DELETE FROM search_index WHERE country_id IN (OLD.country_id, NEW.country_id);
+--     INSERT INTO search_index SELECT ... FROM countries c LEFT JOIN anthems a ON a.country_id = c.id
+--     WHERE c.id IN (OLD.country_id, NEW.country_id);
+-- END;
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (11, 'Full-text search');
@@ -0,0 +1,15 @@
+-- Schema Version 12: Audio play counts
+-- `worldanthem serve` counts a play whenever a mirrored recording is
+-- streamed from its first byte, giving popularity data without a cloud
+-- backend. Seeks (Range requests starting later) are not counted.
+
+CREATE TABLE IF NOT EXISTS audio_plays (
+    country_id TEXT NOT NULL,               -- Country of the recording
+    recording_id TEXT NOT NULL,             -- audio_recordings.id
+    day TEXT NOT NULL,                      -- UTC date, YYYY-MM-DD
+    plays INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (country_id, recording_id, day)
+);
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (12, 'Audio play counts');
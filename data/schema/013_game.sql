@@ -0,0 +1,63 @@
+-- Schema Version 13: Anthem game
+-- Offline copy of the DynamoDB tables of sam/game, used by pkg/game and the
+-- `worldanthem game` commands. Country IDs are ISO alpha-3 codes, the keys
+-- of anthems.json, as in the anthem-rankings table.
+
+-- anthem-rankings
+CREATE TABLE IF NOT EXISTS game_rankings (
+    country_id TEXT PRIMARY KEY,            -- ISO alpha-3 (e.g., 'FRA')
+    name TEXT NOT NULL,
+    flag_url TEXT,
+    anthem_name TEXT,
+    audio_url TEXT,                         -- Countries without audio are not matched
+    elo_score INTEGER NOT NULL DEFAULT 1500,
+    wins INTEGER NOT NULL DEFAULT 0,
+    losses INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP
+);
+
+-- anthem-sessions
+CREATE TABLE IF NOT EXISTS game_sessions (
+    session_id TEXT PRIMARY KEY,
+    user_country TEXT,                      -- Voter's country (ISO alpha-2), if known
+    created_at TIMESTAMP NOT NULL,
+    vote_count INTEGER NOT NULL DEFAULT 0,  -- All votes, drives the wildcard every 10th matchup
+    vote_date TEXT,                         -- UTC day of vote_count_today
+    vote_count_today INTEGER NOT NULL DEFAULT 0,
+    matchup_id TEXT,                        -- Current matchup, cleared by a vote
+    matchup_a TEXT,
+    matchup_b TEXT
+);
+
+-- anthem-votes
+CREATE TABLE IF NOT EXISTS game_votes (
+    vote_id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    matchup_id TEXT NOT NULL,
+    country_a TEXT,                         -- Country listen_a_ms belongs to
+    winner_id TEXT NOT NULL,
+    loser_id TEXT NOT NULL,
+    listen_a_ms INTEGER NOT NULL DEFAULT 0, -- Listen time submitted with the vote
+    listen_b_ms INTEGER NOT NULL DEFAULT 0,
+    listen_winner_ms INTEGER,               -- Cumulative session listen time used for the weight
+    listen_loser_ms INTEGER,
+    vote_weight REAL,
+    winner_elo_before INTEGER,
+    winner_elo_after INTEGER,
+    loser_elo_before INTEGER,
+    loser_elo_after INTEGER,
+    voted_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_game_votes_session ON game_votes(session_id, voted_at);
+CREATE INDEX IF NOT EXISTS idx_game_votes_voted_at ON game_votes(voted_at);
+
+-- anthem-listen-history
+CREATE TABLE IF NOT EXISTS game_listens (
+    session_id TEXT NOT NULL,
+    country_id TEXT NOT NULL,
+    total_listen_ms INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (session_id, country_id)
+);
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (13, 'Anthem game');
@@ -0,0 +1,24 @@
+-- Schema Version 14: Game analytics
+-- `worldanthem game import` copies the DynamoDB tables of sam/game into the
+-- game_* tables; this view joins their votes to countries, so results can be
+-- broken down by the voter's and the anthems' countries and regions.
+
+-- Hashed IP of the session (anthem-sessions ip_hash), for audits
+ALTER TABLE game_sessions ADD COLUMN ip_hash TEXT;
+CREATE INDEX IF NOT EXISTS idx_game_sessions_ip ON game_sessions(ip_hash, created_at);
+
+CREATE VIEW IF NOT EXISTS game_vote_details AS
+SELECT v.vote_id, v.session_id, v.winner_id, v.loser_id, v.vote_weight, v.voted_at,
+    v.listen_winner_ms, v.listen_loser_ms,
+    UPPER(s.user_country) AS voter_country,  -- ISO alpha-2 from CloudFront, if known
+    vc.region AS voter_region,
+    w.iso_alpha2 AS winner_iso2, w.region AS winner_region,
+    l.iso_alpha2 AS loser_iso2, l.region AS loser_region
+FROM game_votes v
+LEFT JOIN game_sessions s ON s.session_id = v.session_id
+LEFT JOIN countries vc ON vc.iso_alpha2 = UPPER(s.user_country)
+LEFT JOIN countries w ON w.iso_alpha3 = v.winner_id
+LEFT JOIN countries l ON l.iso_alpha3 = v.loser_id;
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (14, 'Game analytics');
@@ -0,0 +1,11 @@
+-- Schema Version 15: Audio mirror validators
+-- Written by `worldanthem data audio mirror` for sources that publish no
+-- SHA-1: the next run sends them in a conditional request (or compares the
+-- size) and skips the download when the file has not changed.
+
+ALTER TABLE audio_recordings ADD COLUMN source_etag TEXT;           -- ETag of the last download
+ALTER TABLE audio_recordings ADD COLUMN source_last_modified TEXT;  -- Last-Modified of the last download
+ALTER TABLE audio_recordings ADD COLUMN source_size INTEGER;        -- Size in bytes of the last download
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (15, 'Audio mirror validators');
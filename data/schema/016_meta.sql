@@ -0,0 +1,12 @@
+-- Schema Version 16: Database-wide state
+-- Key/value pairs that belong to no table, such as search_index_stale, set
+-- when a build without FTS5 drops the search triggers (see pkg/db/search.go)
+-- and cleared when the index is rebuilt.
+
+CREATE TABLE IF NOT EXISTS meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (16, 'Meta');
@@ -0,0 +1,35 @@
+-- Schema Version 17: Row sources
+-- Records which data source last wrote each country and anthem, shown by
+-- `worldanthem country show`. `worldanthem data download` stores the ID of
+-- the source it runs in meta under writing_source (see pkg/db/provenance.go),
+-- and these triggers copy it onto every row that source inserts or updates.
+
+ALTER TABLE countries ADD COLUMN updated_by TEXT;  -- Data source ID, e.g. 'rest-countries-api'
+ALTER TABLE anthems ADD COLUMN updated_by TEXT;    -- Data source ID, e.g. 'wikidata-sparql'
+
+CREATE TRIGGER IF NOT EXISTS countries_updated_by_insert AFTER INSERT ON countries
+WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source')
+BEGIN
+    UPDATE countries SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS countries_updated_by_update AFTER UPDATE ON countries
+WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source' AND value IS NOT NEW.updated_by)
+BEGIN
+    UPDATE countries SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS anthems_updated_by_insert AFTER INSERT ON anthems
+WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source')
+BEGIN
+    UPDATE anthems SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS anthems_updated_by_update AFTER UPDATE ON anthems
+WHEN EXISTS (SELECT 1 FROM meta WHERE key = 'writing_source' AND value IS NOT NEW.updated_by)
+BEGIN
+    UPDATE anthems SET updated_by = (SELECT value FROM meta WHERE key = 'writing_source') WHERE id = NEW.id;
+END;
+
+-- Record schema version
+INSERT INTO schema_version (version, description) VALUES (17, 'Row sources');
@@ -1,4 +1,5 @@
-// Countries DataTable — loads live data from /data/anthems.json
+// Countries DataTable — loads live data from /data/anthems.json, and searches
+// with the prebuilt index in /data/search.json when it was exported
 
 document.addEventListener('DOMContentLoaded', function() {
     if (document.getElementById('countries-table')) {
@@ -44,6 +45,7 @@ function renderTable(data) {
                 region,                    // col 6: region
                 audioFile ? audioFile.url : '', // col 7: audio url
                 audioFile ? (audioFile.format || 'ogg') : '', // col 8: audio format
+                isoKey,                    // col 9: key, as in search.json docs
             ]);
         }
     }
@@ -79,6 +81,7 @@ function renderTable(data) {
                 </audio>`;
             }},
             { title: "Audio Format", visible: false },  // 8 - used by Audio render
+            { title: "Key", visible: false, searchable: false }, // 9 - matched by search.json
         ],
         pageLength: 25,
         lengthMenu: [[10, 25, 50, 100, -1], [10, 25, 50, 100, "All"]],
@@ -101,6 +104,10 @@ function renderTable(data) {
         },
     });
 
+    if (rows.length > 0) {
+        useSearchIndex(table);
+    }
+
     if (!data || rows.length === 0) {
         $('#countries-table_wrapper').before(
             '<div class="alert alert-warning mb-3">' +
@@ -120,3 +127,65 @@ function renderTable(data) {
     }
 }
 
+
+// useSearchIndex replaces the table's substring search with search.json, so
+// composers, lyricists, history and symbols are searched too, accents are
+// ignored and the last word matches as a prefix. Without the file (exported
+// by "worldanthem data format --search-index") the default search stays.
+function useSearchIndex(table) {
+    fetch('/data/search.json')
+        .then(r => r.ok ? r.json() : Promise.reject(new Error('HTTP ' + r.status)))
+        .then(index => {
+            if (index.version !== 1) {
+                throw new Error('unsupported search.json version ' + index.version);
+            }
+            const stopWords = new Set(index.stop_words);
+            const termKeys  = Object.keys(index.terms);
+            let matches = null; // keys of the matching countries, null for all
+
+            $.fn.dataTable.ext.search.push((settings, row) =>
+                settings.nTable !== table.table().node() || matches === null || matches.has(row[9]));
+
+            $('#countries-table_filter input')
+                .off()
+                .on('input', function () {
+                    matches = searchKeys(index, termKeys, stopWords, this.value);
+                    table.draw();
+                });
+            table.search('').draw();
+        })
+        .catch(err => console.info('[countries-table] Search index not used:', err.message));
+}
+
+// searchKeys returns the keys of the countries containing every word of query
+function searchKeys(index, termKeys, stopWords, query) {
+    const words = tokenize(query).filter(w => !stopWords.has(w));
+    if (words.length === 0) {
+        return null;
+    }
+    let result = null;
+    words.forEach((word, i) => {
+        const terms = i === words.length - 1
+            ? termKeys.filter(t => t.startsWith(word))
+            : (index.terms[word] ? [word] : []);
+        const docs = new Set();
+        for (const term of terms) {
+            const postings = index.terms[term];
+            for (let j = 0; j < postings.length; j += 2) {
+                docs.add(postings[j]);
+            }
+        }
+        result = result === null ? docs : new Set([...result].filter(d => docs.has(d)));
+    });
+    return new Set([...result].map(d => index.docs[d][0]));
+}
+
+// tokenize splits text into terms the way search.json was built: lowercased,
+// accents removed, split on anything that is not a letter or digit
+function tokenize(text) {
+    return text.toLowerCase()
+        .normalize('NFD')
+        .replace(/\p{Mn}/gu, '')
+        .split(/[^\p{L}\p{N}]+/u)
+        .filter(Boolean);
+}
@@ -132,7 +132,7 @@ function buildPopupContent(countryName, isoCode, countryRecord) {
             : '';
 
         let audioPlayerHTML = '';
-        const instrumental = audio.find(a => a.type === 'instrumental') || audio[0];
+        const instrumental = audio.find(a => a.primary) || audio.find(a => a.type === 'instrumental') || audio[0];
         if (instrumental && instrumental.url) {
             audioPlayerHTML = `
                 <div class="mt-2">
@@ -13,8 +13,10 @@
  *
  * On success:
  *   - Updates ELO scores (scaled by vote_weight)
- *   - Stores vote record
- *   - Updates session vote count
+ *   - Stores vote record, with country_a and the cumulative listen times and
+ *     weight used, so the vote can be analysed without the listen history
+ *   - Updates session vote counts: vote_count, which the matchup Lambda uses
+ *     for wildcards, and vote_count_today for the daily limit
  *   - Updates listen history
  *   - Returns updated ELO scores + vote_weight
  *
@@ -122,8 +124,10 @@ exports.handler = async (event) => {
             // Store vote record
             db.send(new PutCommand({
                 TableName: VOTES_TABLE,
-                Item: { vote_id: voteId, session_id, matchup_id, winner_id, loser_id,
-                        listen_a_ms, listen_b_ms, voted_at: votedAt, ttl },
+                Item: { vote_id: voteId, session_id, matchup_id, country_a, winner_id, loser_id,
+                        listen_a_ms, listen_b_ms,
+                        listen_winner_ms: totalListenWinner, listen_loser_ms: totalListenLoser, vote_weight,
+                        voted_at: votedAt, ttl },
             })),
             // Update winner ELO
             db.send(new UpdateCommand({
@@ -139,12 +143,12 @@ exports.handler = async (event) => {
                 UpdateExpression: 'SET elo_score = :e, losses = if_not_exists(losses, :z) + :one, updated_at = :t',
                 ExpressionAttributeValues: { ':e': newLoserElo, ':z': 0, ':one': 1, ':t': votedAt },
             })),
-            // Update session vote count (daily) + clear active matchup
+            // Update session vote counts (total, for wildcards, and daily) + clear active matchup
             db.send(new UpdateCommand({
                 TableName: SESSIONS_TABLE,
                 Key: { session_id },
-                UpdateExpression: 'SET vote_count_today = :new_count, vote_date = :today REMOVE current_matchup',
-                ExpressionAttributeValues: { ':new_count': voteToday + 1, ':today': today },
+                UpdateExpression: 'SET vote_count = if_not_exists(vote_count, :z) + :one, vote_count_today = :new_count, vote_date = :today REMOVE current_matchup',
+                ExpressionAttributeValues: { ':z': 0, ':one': 1, ':new_count': voteToday + 1, ':today': today },
             })),
             // Update listen history for winner
             db.send(new UpdateCommand({
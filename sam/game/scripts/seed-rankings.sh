@@ -1,7 +1,10 @@
 #!/usr/bin/env bash
 # seed-rankings.sh
-# Seeds anthem-rankings DynamoDB table from anthems.json.
-# Requires: aws CLI, LocalStack running, anthems.json built.
+# Seeds anthem-rankings DynamoDB table.
+# With a worldanthem binary (./worldanthem or on PATH) and no argument, the
+# rankings come from the CLI database via `worldanthem game seed`, so only
+# countries with playable audio are seeded. Otherwise from anthems.json.
+# Requires: LocalStack running; aws CLI and anthems.json for the fallback.
 #
 # Usage: ./sam/game/scripts/seed-rankings.sh [anthems.json path]
 set -euo pipefail
@@ -14,6 +17,20 @@ ANTHEMS="${1:-hugo/site/static/data/anthems.json}"
 
 AWS="aws --endpoint-url=$ENDPOINT --region=$REGION"
 
+WORLDANTHEM=""
+if [[ -x ./worldanthem ]]; then
+  WORLDANTHEM=./worldanthem
+elif command -v worldanthem >/dev/null 2>&1; then
+  WORLDANTHEM=worldanthem
+fi
+if [[ $# -eq 0 && -n "$WORLDANTHEM" ]]; then
+  echo "==> Seeding $TABLE from the worldanthem database..."
+  "$WORLDANTHEM" game seed --target dynamodb --reset \
+    --endpoint "$ENDPOINT" --region "$REGION" --stage "$STAGE"
+  echo "==> Seeding complete."
+  exit 0
+fi
+
 if [[ ! -f "$ANTHEMS" ]]; then
   echo "Error: anthems.json not found at $ANTHEMS"
   echo "Run: worldanthem data format --output hugo/site/static/data"